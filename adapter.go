@@ -2,18 +2,36 @@ package pgxadapter
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/casbin/casbin/v2/model"
-	"github.com/casbin/casbin/v2/persist"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/zeebo/xxh3"
+	"go.opentelemetry.io/otel/metric"
 )
 
 const DefaultTableName = "casbin_rules"
 const DefaultDatabaseName = "casbin"
 
+// DefaultCloseTimeout is how long Close waits for in-flight operations to finish
+const DefaultCloseTimeout = 30 * time.Second
+
+// DefaultValueColumns is the number of value columns (v0..v5) used unless WithValueColumns is given
+const DefaultValueColumns = 6
+
+// MaxValueColumns is the highest number of value columns supported by WithValueColumns
+const MaxValueColumns = 12
+
 // CasbinRule represents a rule in Casbin.
 type CasbinRule struct {
 	ID    string
@@ -24,19 +42,127 @@ type CasbinRule struct {
 	V3    string
 	V4    string
 	V5    string
+	// Extra holds the values of v6 and above when the adapter is configured with WithValueColumns
+	Extra []string
+
+	// size is the length of the rule the line was created from, see WithNullValues
+	size int
 }
 
+// Filter selects the rules loaded by LoadFilteredPolicy, P and G are the values of the p and g rules to load,
+// an empty value is a wildcard. The rules of a nil section are not loaded.
+//
+// LoadFilteredPolicy also accepts the string filters of other casbin adapters, a line like "p, alice, , read"
+// or a []string of such lines, and the values by ptype of a map[string][]string or map[string][][]string,
+// see parseFilter. NewFilter builds the latter by field index.
 type Filter struct {
 	P []string
 	G []string
 }
 
+// PgxPool is the subset of *pgxpool.Pool used by Adapter.
+// It matches pgxmock's PgxPoolIface so the adapter can be tested without a database.
+type PgxPool interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Close()
+}
+
 // Adapter represents the adapter for policy storage.
 type Adapter struct {
-	db              *pgxpool.Pool
-	tableName       string
-	skipTableCreate bool
-	filtered        bool
+	db               PgxPool
+	tableName        string // guarded by mu, see SetTableName
+	valueColumns     int
+	validator        RuleValidator
+	normalization    *Normalization
+	encryption       *encryption
+	nullValues       bool
+	skipTableCreate  bool
+	skipSchemaVerify bool
+	verifyTable      bool
+	bootstrapLock    bool
+	createTableHook  CreateTableHook
+	distributed      bool
+	collateC         bool
+	colocateWith     string
+	tableGrants      map[string][]string
+	dualWriteTable   string
+	dualWriteMode    DualWriteMode
+
+	// settings of the pool created by NewAdapter
+	dbName             string
+	applicationName    string
+	applicationNameSet bool
+	beforeConnect      []func(ctx context.Context, cfg *pgx.ConnConfig) error
+	afterConnect       []func(ctx context.Context, conn *pgx.Conn) error
+	maxConns           int32
+	minConns           int32
+	connLifetime       time.Duration
+	connIdleTime       time.Duration
+	healthCheckPeriod  time.Duration
+	role               string
+	scanBatchSize      int
+	snapshotScan       bool
+	loadWorkers        int
+	loadSkew           bool
+	readOnlyReconnect  bool
+	hooks              []Hooks
+	rewriter           QueryRewriter
+	commenter          SQLCommenter
+	maxBatchSize       int
+	txPerChunk         bool
+	temporary          bool
+	readRelation       string
+	namespace          string
+	namespaced         bool
+	tenantFromContext  bool
+	tenantRequired     bool
+	tableResolver      TableResolver
+	routed             routedTables
+	meterProvider      metric.MeterProvider
+	metrics            *adapterMetrics
+	logger             *slog.Logger
+	slowThreshold      time.Duration
+	slowSQL            bool
+	autoRecreate       bool
+	history            bool
+	revisions          bool
+	swapSave           bool
+	truncateSave       bool
+	sharedLoad         *loadFlight
+	loadHandler        LoadHandler
+	dryRun             bool
+	maxLoadRules       int64
+	filterThreshold    FilterThreshold
+	rowQuota           int64
+	partialQuota       bool
+	progressFn         ProgressFunc
+	progressRows       int64
+	progressInterval   time.Duration
+	orphanDef          OrphanDefinition
+	subjectFields      map[string][]int
+	idScheme           IDScheme
+	fallbackTargets    []any
+	failbackInterval   time.Duration
+	opTimeout          time.Duration
+	readTimeout        time.Duration
+	writeTimeout       time.Duration
+	// ownsPool is set when the pool was created by NewAdapter
+	ownsPool bool
+
+	// mu guards the state changed after the adapter is created
+	mu       sync.RWMutex
+	filtered bool
+	closed   bool
+	// loadedRevisions are the revisions read by LoadPolicy by table, see WithRevisions
+	loadedRevisions map[string]int64
+	// inflight tracks the operations Shutdown waits for, active counts them
+	inflight sync.WaitGroup
+	active   atomic.Int64
+	// abandon cancels the contexts of the in-flight operations when Shutdown gives up waiting for them
+	abandoned context.Context
+	abandon   context.CancelFunc
 }
 
 type Option func(a *Adapter)
@@ -45,43 +171,251 @@ type Option func(a *Adapter)
 // param:arg should be a PostgreS URL string or of type *pgxpool.Config
 // param:dbname is the name of the database to use and can is optional.
 // If no dbname is provided, the default database name is "casbin" which will be created automatically.
-// If arg is *pgxpool.Config, the arg.ConnConfig.Database field is omitted and will be modified according to dbname
+// If arg is *pgxpool.Config, the arg.ConnConfig.Database field is omitted, the adapter connects to dbname
+// using a copy of the config, arg itself is not modified.
+// dbname is quoted when the database is created, so it is case sensitive and may contain characters such as hyphens,
+// an empty or too long name returns ErrInvalidDatabaseName
 func NewAdapter(arg any, dbname ...string) (*Adapter, error) {
-	dbn := DefaultDatabaseName
+	var opts []Option
 	if len(dbname) > 0 {
-		dbn = dbname[0]
+		opts = append(opts, WithDatabaseName(dbname[0]))
 	}
-	db, err := createCasbinDatabase(arg, dbn)
+	return NewAdapterWithOptions(arg, opts...)
+}
 
+// NewAdapterWithOptions is like NewAdapter but also accepts options,
+// the database name is given with WithDatabaseName and defaults to "casbin".
+// Options configuring the pool, such as WithApplicationName, only apply to the pool created by this constructor
+func NewAdapterWithOptions(arg any, opts ...Option) (*Adapter, error) {
+	a, err := newAdapter(opts)
 	if err != nil {
-		return nil, fmt.Errorf("pgadapter.NewAdapter: %v", err)
+		return nil, err
 	}
 
-	a := &Adapter{db: db, tableName: DefaultTableName}
-
-	if err := a.createTableifNotExists(); err != nil {
-		return nil, fmt.Errorf("pgadapter.NewAdapter: %v", err)
+	var db PgxPool
+	if len(a.fallbackTargets) > 0 {
+		db, err = a.newFailoverPool(arg)
+	} else {
+		db, err = a.createCasbinDatabase(arg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: %w", err)
+	}
+	a.db = db
+	a.ownsPool = true
+	if a.temporary {
+		if a.db, err = a.pinConnection(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	if a.dryRun {
+		a.db = &dryRunPool{PgxPool: a.db, a: a}
+	}
+	if a.dualWriteTable != "" {
+		a.db = &dualWritePool{PgxPool: a.db, a: a}
+	}
+	if a.slowThreshold > 0 {
+		a.db = &slowPool{PgxPool: a.db, a: a}
+	}
+	if a.commenter != nil {
+		a.db = &commentPool{PgxPool: a.db, comment: a.commenter}
+	}
+	if a.rewriter != nil {
+		a.db = &rewritePool{PgxPool: a.db, rewrite: a.rewriter}
 	}
 
+	if err := a.setup(); err != nil {
+		a.db.Close()
+		return nil, err
+	}
 	return a, nil
 }
 
 // NewAdapterByDB creates new Adapter by using existing DB connection
 // creates table from CasbinRule struct if it doesn't exist
 func NewAdapterByDB(db *pgxpool.Pool, opts ...Option) (*Adapter, error) {
-	a := &Adapter{db: db, tableName: DefaultTableName}
+	return NewAdapterByPgxPool(db, opts...)
+}
+
+// NewAdapterByPgxPool creates new Adapter by using any PgxPool implementation,
+// e.g. a pgxmock pool in unit tests
+func NewAdapterByPgxPool(db PgxPool, opts ...Option) (*Adapter, error) {
+	a, err := newAdapter(opts)
+	if err != nil {
+		return nil, err
+	}
+	a.db = db
+	if a.poolTuned() {
+		a.logger.LogAttrs(context.Background(), slog.LevelWarn, "pgadapter: pool settings ignored for a pool passed to the adapter",
+			slog.Int("max_conns", int(a.maxConns)), slog.Int("min_conns", int(a.minConns)),
+			slog.Duration("conn_lifetime", a.connLifetime), slog.Duration("conn_idle_time", a.connIdleTime),
+			slog.Duration("health_check_period", a.healthCheckPeriod))
+	}
+	if a.temporary {
+		if a.db, err = a.pinConnection(db); err != nil {
+			return nil, err
+		}
+	}
+	if a.role != "" {
+		a.db = &rolePool{PgxPool: a.db, role: a.role}
+	}
+	if a.dryRun {
+		a.db = &dryRunPool{PgxPool: a.db, a: a}
+	}
+	if a.dualWriteTable != "" {
+		a.db = &dualWritePool{PgxPool: a.db, a: a}
+	}
+	if a.slowThreshold > 0 {
+		a.db = &slowPool{PgxPool: a.db, a: a}
+	}
+	if a.commenter != nil {
+		a.db = &commentPool{PgxPool: a.db, comment: a.commenter}
+	}
+	if a.rewriter != nil {
+		a.db = &rewritePool{PgxPool: a.db, rewrite: a.rewriter}
+	}
+
+	if err := a.setup(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// newAdapter creates an adapter without database from the default settings and opts
+func newAdapter(opts []Option) (*Adapter, error) {
+	a := &Adapter{
+		tableName:        DefaultTableName,
+		valueColumns:     DefaultValueColumns,
+		dbName:           DefaultDatabaseName,
+		applicationName:  DefaultApplicationName,
+		scanBatchSize:    DefaultScanBatchSize,
+		orphanDef:        OrphanDefinition{RoleField: 1},
+		maxBatchSize:     DefaultMaxBatchSize,
+		failbackInterval: DefaultFailbackInterval,
+	}
+	a.abandoned, a.abandon = context.WithCancel(context.Background())
 	for _, opt := range opts {
 		opt(a)
 	}
 
-	if !a.skipTableCreate {
-		if err := a.createTableifNotExists(); err != nil {
-			return nil, fmt.Errorf("pgadapter.NewAdapter: %v", err)
+	if err := validateTableName(a.tableName); err != nil {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: %w", err)
+	}
+	if a.valueColumns < 1 || a.valueColumns > MaxValueColumns {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: value columns must be between 1 and %d, got %d", MaxValueColumns, a.valueColumns)
+	}
+	if a.maxBatchSize < 1 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: max batch size must be positive, got %d", a.maxBatchSize)
+	}
+	if a.scanBatchSize < 1 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: scan batch size must be positive, got %d", a.scanBatchSize)
+	}
+	if a.idScheme != IDSchemeXXH3 && a.idScheme != IDSchemeMD5 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: unknown id scheme %v", a.idScheme)
+	}
+	if a.temporary && a.loadWorkers > 1 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithParallelLoad can't be used with WithTemporaryTable")
+	}
+	if a.temporary && a.dryRun {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithDryRun can't be used with WithTemporaryTable")
+	}
+	if a.temporary && len(a.fallbackTargets) > 0 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithFallbackTargets can't be used with WithTemporaryTable")
+	}
+	if a.distributed && !a.namespaced {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithDistributedTable needs WithNamespace or WithTenantFromContext")
+	}
+	if a.distributed && a.temporary {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithDistributedTable can't be used with WithTemporaryTable")
+	}
+	if a.distributed && a.swapSave {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithDistributedTable can't be used with WithSwapSave")
+	}
+	if a.encryption != nil {
+		if err := a.encryption.validate(a.valueColumns); err != nil {
+			return nil, fmt.Errorf("pgadapter.NewAdapter: WithEncryption: %w", err)
+		}
+	}
+	if a.truncateSave && a.swapSave {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithTruncateSave can't be used with WithSwapSave")
+	}
+	if a.dualWriteTable != "" {
+		if err := validateTableName(a.dualWriteTable); err != nil {
+			return nil, fmt.Errorf("pgadapter.NewAdapter: WithDualWrite: %w", err)
+		}
+		if a.dualWriteTable == a.tableName {
+			return nil, fmt.Errorf("pgadapter.NewAdapter: WithDualWrite table %q is the rules table", a.dualWriteTable)
+		}
+		if a.dualWriteMode != DualWriteBestEffort && a.dualWriteMode != DualWriteStrict {
+			return nil, fmt.Errorf("pgadapter.NewAdapter: unknown dual write mode %v", a.dualWriteMode)
+		}
+		if a.temporary || a.swapSave {
+			return nil, fmt.Errorf("pgadapter.NewAdapter: WithDualWrite can't be used with WithTemporaryTable or WithSwapSave")
+		}
+	}
+	if err := validateGrants(a.tableGrants); err != nil {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithTableGrants: %w", err)
+	}
+	if len(a.tableGrants) > 0 && a.temporary {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithTableGrants can't be used with WithTemporaryTable")
+	}
+	if err := a.filterThreshold.validate(); err != nil {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: %w", err)
+	}
+	if a.rowQuota < 0 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: row quota can't be negative, got %d", a.rowQuota)
+	}
+	if a.maxLoadRules < 0 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: max load rules can't be negative, got %d", a.maxLoadRules)
+	}
+	if a.opTimeout < 0 || a.readTimeout < 0 || a.writeTimeout < 0 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: timeouts can't be negative")
+	}
+	if a.maxConns < 0 || a.minConns < 0 || a.connLifetime < 0 || a.connIdleTime < 0 || a.healthCheckPeriod < 0 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: pool settings can't be negative")
+	}
+	if a.maxConns > 0 && a.minConns > a.maxConns {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: min conns %d is greater than max conns %d", a.minConns, a.maxConns)
+	}
+	if a.failbackInterval <= 0 {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: failback interval must be positive, got %v", a.failbackInterval)
+	}
+	if a.logger == nil {
+		a.logger = slog.Default()
+	}
+	if a.meterProvider != nil {
+		var err error
+		if a.metrics, err = newMetrics(a.meterProvider); err != nil {
+			return nil, fmt.Errorf("pgadapter.NewAdapter: %w", err)
 		}
 	}
 	return a, nil
 }
 
+// setup prepares the rules table when the adapter starts
+func (a *Adapter) setup() error {
+	if !a.skipTableCreate {
+		if err := a.createTableifNotExists(withOp(context.Background(), "NewAdapter")); err != nil {
+			return fmt.Errorf("pgadapter.NewAdapter: %w", err)
+		}
+	}
+	if a.verifyTable {
+		if err := a.probeTable(withOp(context.Background(), "NewAdapter")); err != nil {
+			return fmt.Errorf("pgadapter.NewAdapter: table %q is not usable by the adapter: %w", a.tableName, err)
+		}
+	}
+	if !a.skipSchemaVerify {
+		if err := a.VerifySchema(context.Background()); err != nil {
+			return fmt.Errorf("pgadapter.NewAdapter: %w", err)
+		}
+	}
+	if err := a.registerPoolMetrics(); err != nil {
+		return fmt.Errorf("pgadapter.NewAdapter: %w", err)
+	}
+	return nil
+}
+
 // WithTableName can be used to pass custom table name for Casbin rules
 func WithTableName(tableName string) Option {
 	return func(a *Adapter) {
@@ -89,6 +423,43 @@ func WithTableName(tableName string) Option {
 	}
 }
 
+// WithReadRelation makes the adapter read rules from relation, e.g. a view merging several tables,
+// while AddPolicy, RemovePolicy and the other writes keep targeting the rules table.
+// LoadPolicy, LoadFilteredPolicy, LoadPolicyPage, ForEachRule and CountRules read from relation,
+// which must expose the id, ptype and value columns of the rules table.
+// VerifySchema and WithTableVerification check relation as well as the rules table.
+func WithReadRelation(relation string) Option {
+	return func(a *Adapter) {
+		a.readRelation = relation
+	}
+}
+
+// readTable returns the relation rules are read from
+func (a *Adapter) readTable(ctx context.Context) string {
+	if a.readRelation != "" && a.table(ctx) == a.TableName() {
+		return a.readRelation
+	}
+	return a.table(ctx)
+}
+
+// WithValueColumns sets the number of value columns (v0, v1, ...) used to store rules.
+// The default is 6 (v0..v5). When n is above 6, the missing columns are added to an existing table
+// unless SkipTableCreate is used.
+func WithValueColumns(n int) Option {
+	return func(a *Adapter) {
+		a.valueColumns = n
+	}
+}
+
+// WithNullValues stores the value columns not used by a rule as NULL instead of an empty string,
+// e.g. v3, v4 and v5 of a rule with three values. Values explicitly set to "" are still stored as the empty string.
+// NULL values are loaded as empty strings and policy ids are computed the same way as without this option.
+func WithNullValues() Option {
+	return func(a *Adapter) {
+		a.nullValues = true
+	}
+}
+
 // SkipTableCreate skips the table creation step when the adapter starts
 // If the Casbin rules table does not exist, it will lead to issues when using the adapter
 func SkipTableCreate() Option {
@@ -97,149 +468,554 @@ func SkipTableCreate() Option {
 	}
 }
 
-func createCasbinDatabase(arg any, dbname string) (*pgxpool.Pool, error) {
-	var err error
-	var pool *pgxpool.Pool
-	var cfg *pgxpool.Config
-	ctx := context.Background()
-	if connURL, ok := arg.(string); ok {
-		cfg, err = pgxpool.ParseConfig(connURL)
-	} else {
-		cfg, ok = arg.(*pgxpool.Config)
-		if !ok {
-			return nil, fmt.Errorf("must pass in a PostgreS URL string or an instance of *pgxpool.Config, received %T instead", arg)
-		}
+// maxIdentifierLength is the longest identifier accepted by postgres, longer names are truncated
+const maxIdentifierLength = 63
+
+// validateDatabaseName checks that name can be used as a database name.
+// The name is quoted when the database is created, so it may contain any character except NUL
+func validateDatabaseName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name is empty", ErrInvalidDatabaseName)
+	case len(name) > maxIdentifierLength:
+		return fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidDatabaseName, name, maxIdentifierLength)
+	case strings.ContainsRune(name, 0):
+		return fmt.Errorf("%w: %q contains a NUL character", ErrInvalidDatabaseName, name)
+	case !utf8.ValidString(name):
+		return fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidDatabaseName, name)
 	}
+	return nil
+}
+
+// createCasbinDatabase creates the adapter database if needed and returns a pool connected to it
+func (a *Adapter) createCasbinDatabase(arg any) (*pgxpool.Pool, error) {
+	ctx := context.Background()
+	cfg, err := parseTarget(arg)
 	if err != nil {
 		return nil, err
 	}
-	pool, err = pgxpool.NewWithConfig(ctx, cfg)
-	if err != nil {
+	if err := validateDatabaseName(a.dbName); err != nil {
 		return nil, err
 	}
-
-	defer pool.Close()
-
-	_, err = pool.Exec(ctx, fmt.Sprintf("CREATE DATABASE %s", dbname))
-	if err != nil && !strings.Contains(err.Error(), "42P04") {
+	if err := a.createDatabase(ctx, cfg); err != nil {
 		return nil, err
 	}
-	pool.Close()
+	return a.openPool(ctx, cfg)
+}
 
-	cfg.ConnConfig.Database = dbname
-	pool, err = pgxpool.NewWithConfig(ctx, cfg)
+// parseTarget returns the pool config of arg, a PostgreSQL URL string or a *pgxpool.Config
+func parseTarget(arg any) (*pgxpool.Config, error) {
+	if connURL, ok := arg.(string); ok {
+		return pgxpool.ParseConfig(connURL)
+	}
+	cfg, ok := arg.(*pgxpool.Config)
+	if !ok {
+		return nil, fmt.Errorf("must pass in a PostgreS URL string or an instance of *pgxpool.Config, received %T instead", arg)
+	}
+	return cfg, nil
+}
+
+// createDatabase creates the adapter database on the server of cfg if it doesn't exist
+func (a *Adapter) createDatabase(ctx context.Context, cfg *pgxpool.Config) error {
+	// the caller's config is never modified, both pools use their own copy
+	bootstrap, err := pgxpool.NewWithConfig(ctx, a.poolConfig(cfg))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer bootstrap.Close()
+	// another process may be creating the same database, an existing database is not an error
+	return retryBootstrap(ctx, func() error {
+		_, err := bootstrap.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{a.dbName}.Sanitize())
+		if isPgError(err, codeDuplicateDatabase) {
+			return nil
+		}
+		return err
+	})
+}
 
-	return pool, nil
+// openPool returns a pool connected to the adapter database on the server of cfg
+func (a *Adapter) openPool(ctx context.Context, cfg *pgxpool.Config) (*pgxpool.Pool, error) {
+	cfg = a.poolConfig(cfg)
+	cfg.ConnConfig.Database = a.dbName
+	a.tunePool(cfg)
+	if a.role != "" {
+		// the role only applies to the adapter database, creating it may need other privileges
+		cfg.AfterConnect = chainAfterConnect(cfg.AfterConnect, a.setSessionRole)
+	}
+	return pgxpool.NewWithConfig(ctx, cfg)
 }
 
 // Close close database connection
+// It waits up to DefaultCloseTimeout for in-flight operations, see Shutdown
 func (a *Adapter) Close() error {
-	if a != nil && a.db != nil {
-		a.db.Close()
-	}
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCloseTimeout)
+	defer cancel()
+	return a.Shutdown(ctx)
+}
+
+// Shutdown closes the database connection once the in-flight operations are finished.
+// When ctx is done first, the contexts of the operations still running are canceled, which interrupts
+// their statements, and the connection is closed without waiting for them. The error then gives the number
+// of abandoned operations and wraps the context error.
+// Methods called after Shutdown fail with ErrAdapterClosed.
+//
+// Shutdown is the variant of Close with a deadline, Close keeps its signature of io.Closer.
+func (a *Adapter) Shutdown(ctx context.Context) error {
+	if a == nil || a.db == nil {
+		return nil
+	}
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	a.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		a.inflight.Wait()
+		close(finished)
+	}()
+
+	var err error
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		n := a.active.Load()
+		a.abandon()
+		err = fmt.Errorf("pgadapter: %d in-flight operations abandoned: %w", n, ctx.Err())
+	}
+	if a.metrics != nil && a.metrics.pool != nil {
+		a.metrics.pool.Unregister()
+	}
+	a.db.Close()
+	a.abandon()
+	return err
+}
+
+// begin registers an in-flight operation, the returned function must be called when it's done.
+// The returned context is canceled when Shutdown abandons the operation.
+func (a *Adapter) begin(ctx context.Context) (context.Context, func(), error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return ctx, nil, ErrAdapterClosed
+	}
+	a.inflight.Add(1)
+	a.active.Add(1)
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(a.abandoned, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		a.active.Add(-1)
+		a.inflight.Done()
+	}, nil
+}
+
+// execer is implemented by PgxPool and pgx.Tx
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
 }
 
-func (a *Adapter) createTableifNotExists() error {
-	_, err := a.db.Exec(context.Background(), fmt.Sprintf(`
-		CREATE TABLE IF NOT EXISTS "%v" (
-			id TEXT PRIMARY KEY,
-			ptype TEXT NOT NULL,
-			v0 TEXT,
-			v1 TEXT,
-			v2 TEXT,
-			v3 TEXT,
-			v4 TEXT,
-			v5 TEXT
+// querier is implemented by PgxPool and pgx.Tx
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// execQuerier is implemented by PgxPool and pgx.Tx
+type execQuerier interface {
+	execer
+	querier
+}
+
+func (a *Adapter) createTableifNotExists(ctx context.Context) error {
+	return retryBootstrap(ctx, func() error {
+		if (a.bootstrapLock || a.createTableHook != nil) && !a.temporary {
+			return a.createTableLocked(ctx)
+		}
+		return a.createTable(ctx, a.db)
+	})
+}
+
+func (a *Adapter) createTable(ctx context.Context, db execQuerier) error {
+	var cols strings.Builder
+	for i := 0; i < a.valueColumns; i++ {
+		fmt.Fprintf(&cols, ",\n\t\t\tv%d TEXT%v", i, a.collation())
+	}
+	if a.namespaced {
+		cols.WriteString(",\n\t\t\tnamespace TEXT NOT NULL DEFAULT ''")
+	}
+	key := " PRIMARY KEY"
+	if a.distributed {
+		// Citus needs the distribution column in the primary key
+		key = " NOT NULL"
+		cols.WriteString(",\n\t\t\tPRIMARY KEY (namespace, id)")
+	}
+	create := "CREATE TABLE"
+	if a.temporary {
+		create = "CREATE TEMP TABLE"
+	}
+	ddl := fmt.Sprintf(`
+		%v IF NOT EXISTS "%v" (
+			id TEXT%v%v,
+			ptype TEXT NOT NULL%v
 		)
-	`, a.tableName))
-	if err != nil {
+	`, create, a.table(ctx), a.collation(), key, cols.String())
+	if a.createTableHook != nil {
+		if err := a.runCreateTableHook(ctx, db, ddl); err != nil {
+			return err
+		}
+	} else if _, err := db.Exec(ctx, ddl); err != nil && !isPgError(err, codeDuplicateTable) {
 		return err
 	}
-	return nil
+
+	// tables created with fewer value columns or without namespace are extended in place,
+	// the rules stored before belong to the empty namespace
+	var alters []string
+	for i := DefaultValueColumns; i < a.valueColumns; i++ {
+		alters = append(alters, fmt.Sprintf("ADD COLUMN IF NOT EXISTS v%d TEXT%v", i, a.collation()))
+	}
+	if a.namespaced {
+		alters = append(alters, "ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT ''")
+	}
+	if len(alters) > 0 {
+		_, err := db.Exec(ctx, fmt.Sprintf(`ALTER TABLE "%v" %v`, a.table(ctx), strings.Join(alters, ", ")))
+		if err != nil {
+			return err
+		}
+	}
+	if a.distributed {
+		if err := a.distributeTable(ctx, db); err != nil {
+			return err
+		}
+	}
+	if a.history {
+		if err := a.createHistory(ctx, db); err != nil {
+			return err
+		}
+	}
+	if a.revisions {
+		if err := a.createRevisions(ctx, db); err != nil {
+			return err
+		}
+	}
+	return a.grantTables(ctx, db)
+}
+
+// values returns the first n values of the rule, padded with empty strings.
+func (r *CasbinRule) values(n int) []string {
+	vals := append([]string{r.V0, r.V1, r.V2, r.V3, r.V4, r.V5}, r.Extra...)
+	for len(vals) < n {
+		vals = append(vals, "")
+	}
+	return vals[:n]
+}
+
+// rule returns the first n values of the rule without the trailing empty values,
+// like the rule given to AddPolicy the row was stored for
+func (r *CasbinRule) rule(n int) []string {
+	return trimRule(r.values(n))
+}
+
+// trimRule removes the trailing empty values of rule
+func trimRule(rule []string) []string {
+	for len(rule) > 0 && rule[len(rule)-1] == "" {
+		rule = rule[:len(rule)-1]
+	}
+	return rule
+}
+
+// setValue sets the value at index i, growing Extra when needed.
+func (r *CasbinRule) setValue(i int, v string) {
+	switch i {
+	case 0:
+		r.V0 = v
+	case 1:
+		r.V1 = v
+	case 2:
+		r.V2 = v
+	case 3:
+		r.V3 = v
+	case 4:
+		r.V4 = v
+	case 5:
+		r.V5 = v
+	default:
+		for len(r.Extra) <= i-DefaultValueColumns {
+			r.Extra = append(r.Extra, "")
+		}
+		r.Extra[i-DefaultValueColumns] = v
+	}
+}
+
+// tokens returns the ptype and the non empty values of the rule, the tokens of its policy line
+func (r *CasbinRule) tokens() []string {
+	vals := r.values(DefaultValueColumns + len(r.Extra))
+	tokens := make([]string, 1, len(vals)+1)
+	tokens[0] = r.Ptype
+	for _, v := range vals {
+		if v != "" {
+			tokens = append(tokens, v)
+		}
+	}
+	return tokens
 }
 
 func (r *CasbinRule) String() string {
 	const prefixLine = ", "
 	var sb strings.Builder
 
-	sb.Grow(
-		len(r.Ptype) +
-			len(r.V0) + len(r.V1) + len(r.V2) +
-			len(r.V3) + len(r.V4) + len(r.V5),
-	)
+	vals := r.values(DefaultValueColumns + len(r.Extra))
+	size := len(r.Ptype)
+	for _, v := range vals {
+		size += len(v)
+	}
+	sb.Grow(size)
 
 	sb.WriteString(r.Ptype)
-	if len(r.V0) > 0 {
-		sb.WriteString(prefixLine)
-		sb.WriteString(r.V0)
+	for _, v := range vals {
+		if len(v) > 0 {
+			sb.WriteString(prefixLine)
+			sb.WriteString(v)
+		}
 	}
-	if len(r.V1) > 0 {
-		sb.WriteString(prefixLine)
-		sb.WriteString(r.V1)
+
+	return sb.String()
+}
+
+// columns returns the comma separated list of the table columns
+func (a *Adapter) columns() string {
+	cols := make([]string, 0, a.valueColumns+2)
+	cols = append(cols, "id", "ptype")
+	for i := 0; i < a.valueColumns; i++ {
+		cols = append(cols, fmt.Sprintf("v%d", i))
+	}
+	return strings.Join(cols, ", ")
+}
+
+// insertSQL returns the INSERT statement for a single rule, see insertArgs
+func (a *Adapter) insertSQL(ctx context.Context) string {
+	return a.insertRowsSQL(ctx, 1)
+}
+
+func (a *Adapter) insertArgs(ctx context.Context, line *CasbinRule) []any {
+	args := make([]any, 0, a.rowParams())
+	args = append(args, line.ID, line.Ptype)
+	args = append(args, a.valueArgs(line)...)
+	if a.namespaced {
+		args = append(args, a.namespaceOf(ctx))
+	}
+	return args
+}
+
+// valueArgs returns the query arguments for the value columns of line
+func (a *Adapter) valueArgs(line *CasbinRule) []any {
+	args := make([]any, 0, a.valueColumns)
+	for i, v := range line.values(a.valueColumns) {
+		if a.nullValues && i >= line.size {
+			args = append(args, nil)
+		} else {
+			args = append(args, a.fieldArg(i, v))
+		}
 	}
-	if len(r.V2) > 0 {
-		sb.WriteString(prefixLine)
-		sb.WriteString(r.V2)
+	return args
+}
+
+// scanRule scans the current row of a query selecting a.columns()
+func (a *Adapter) scanRule(rows pgx.Rows) (*CasbinRule, error) {
+	return a.newRuleScanner().scan(rows)
+}
+
+// ruleScanner scans rows into a single CasbinRule reused for every row
+type ruleScanner struct {
+	enc  *encryption
+	line CasbinRule
+	vals []pgtype.Text
+	dest []any
+}
+
+func (a *Adapter) newRuleScanner() *ruleScanner {
+	return &ruleScanner{
+		enc:  a.encryption,
+		vals: make([]pgtype.Text, a.valueColumns),
+		dest: make([]any, a.valueColumns+2),
 	}
-	if len(r.V3) > 0 {
-		sb.WriteString(prefixLine)
-		sb.WriteString(r.V3)
+}
+
+// scan returns the rule of the current row, it is only valid until the next call
+func (s *ruleScanner) scan(rows pgx.Rows) (*CasbinRule, error) {
+	// destinations are set for every row, pgxmock replaces them with nil for NULL values
+	s.dest[0], s.dest[1] = &s.line.ID, &s.line.Ptype
+	for i := range s.vals {
+		s.vals[i] = pgtype.Text{}
+		s.dest[i+2] = &s.vals[i]
 	}
-	if len(r.V4) > 0 {
-		sb.WriteString(prefixLine)
-		sb.WriteString(r.V4)
+	if err := rows.Scan(s.dest...); err != nil {
+		return nil, err
 	}
-	if len(r.V5) > 0 {
-		sb.WriteString(prefixLine)
-		sb.WriteString(r.V5)
+	// NULL values are loaded as empty strings
+	for i, v := range s.vals {
+		value := v.String
+		if s.enc.encrypts(i) {
+			var err error
+			if value, err = s.enc.decrypt(value); err != nil {
+				return nil, fmt.Errorf("rule %v: %w", s.line.ID, err)
+			}
+		}
+		s.line.setValue(i, value)
 	}
+	return &s.line, nil
+}
 
-	return sb.String()
+// checkFieldValues returns ErrInvalidFilter if a filter value is out of the value columns
+func (a *Adapter) checkFieldValues(fieldIndex int, fieldValues []string) error {
+	if fieldIndex < 0 {
+		return fmt.Errorf("%w: field index must not be negative, got %d", ErrInvalidFilter, fieldIndex)
+	}
+	for i, v := range fieldValues {
+		if v != "" && fieldIndex+i >= a.valueColumns {
+			return fmt.Errorf("%w: filter has more values than expected, should not exceed %d values", ErrInvalidFilter, a.valueColumns)
+		}
+	}
+	return nil
+}
+
+// checkRule makes sure the rule fits into the configured value columns
+func (a *Adapter) checkRule(rule []string) error {
+	if len(rule) > a.valueColumns {
+		return fmt.Errorf("rule has %d values, should not exceed %d values", len(rule), a.valueColumns)
+	}
+	return nil
 }
 
 // LoadPolicy loads policy from database.
-func (a *Adapter) LoadPolicy(model model.Model) error {
-	var lines []*CasbinRule
-	ctx := context.Background()
-	rows, err := a.db.Query(ctx, fmt.Sprintf(`SELECT * FROM "%v"`, a.tableName))
+func (a *Adapter) LoadPolicy(model model.Model) (err error) {
+	ctx, finish, err := a.start(context.Background(), OpInfo{Op: "LoadPolicy"})
 	if err != nil {
 		return err
 	}
+	defer finish(&err)
 
-	for rows.Next() {
-		var id, ptype, v0, v1, v2, v3, v4, v5 string
-		if err := rows.Scan(&id, &ptype, &v0, &v1, &v2, &v3, &v4, &v5); err != nil {
+	return a.loadAll(ctx, model)
+}
+
+// loadAll adds all the rules to model within the operation running with ctx, see LoadPolicy
+func (a *Adapter) loadAll(ctx context.Context, model model.Model) (err error) {
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		if a.sharedLoad == nil {
+			return a.loadPolicy(ctx, func(line *CasbinRule) error {
+				return a.loadRule(line, model)
+			})
+		}
+		lines, err := a.fetchRules(ctx)
+		if err != nil {
 			return err
 		}
-		lines = append(lines, &CasbinRule{
-			ID:    id,
-			Ptype: ptype,
-			V0:    v0,
-			V1:    v1,
-			V2:    v2,
-			V3:    v3,
-			V4:    v4,
-			V5:    v5,
+		for i := range lines {
+			if err := a.loadRule(&lines[i], model); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// fetchRules returns the rules loaded by LoadPolicy, fetched once for the concurrent calls with WithSharedLoad.
+// The returned rules may be shared and must not be modified.
+func (a *Adapter) fetchRules(ctx context.Context) ([]CasbinRule, error) {
+	fetch := func() ([]CasbinRule, error) {
+		var lines []CasbinRule
+		err := a.loadPolicy(ctx, func(line *CasbinRule) error {
+			copied := *line
+			copied.Extra = append([]string(nil), line.Extra...)
+			lines = append(lines, copied)
+			return nil
 		})
+		return lines, err
 	}
-	rows.Close()
+	if a.sharedLoad == nil {
+		return fetch()
+	}
+	return a.sharedLoad.do(ctx, fetch)
+}
 
-	for _, line := range lines {
-		err := persist.LoadPolicyLine(line.String(), model)
-		if err != nil {
+// loadPolicy calls load with each rule loaded by LoadPolicy
+func (a *Adapter) loadPolicy(ctx context.Context, load func(line *CasbinRule) error) (err error) {
+	// the revision is read first, a change made during the load makes SavePolicyChecked fail rather than miss it
+	var revision int64
+	if a.revisions {
+		if revision, err = a.revision(ctx, a.db, false); err != nil {
+			return err
+		}
+	}
+	sql, args := a.loadQuery(ctx)
+	total := int64(-1)
+	if a.maxLoadRules > 0 {
+		if total, err = a.countRows(ctx, sql, args); err != nil {
+			return err
+		}
+		if err := a.checkLoadLimit(total); err != nil {
+			return err
+		}
+	}
+	p := a.newProgress("LoadPolicy", total)
+	add := func(line *CasbinRule) error {
+		if err := load(line); err != nil {
 			return err
 		}
+		p.add(1)
+		return nil
+	}
+	if a.loadWorkers > 1 {
+		err = a.loadParallel(ctx, add)
+	} else {
+		err = a.loadRows(ctx, a.db, sql, args, add)
+	}
+	if err != nil {
+		return err
 	}
 
-	a.filtered = false
+	a.setFiltered(false)
+	if a.revisions {
+		a.setLoadedRevision(ctx, revision)
+	}
+	p.end()
 
 	return nil
 }
 
+// loadQuery returns the query of LoadPolicy and its arguments
+func (a *Adapter) loadQuery(ctx context.Context) (string, []any) {
+	return a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v"`, a.columns(), a.readTable(ctx)), nil, true)
+}
+
+// loadRows runs the query with q and calls fn with each rule as it is scanned,
+// rules are not buffered so fn must not run queries
+func (a *Adapter) loadRows(ctx context.Context, q querier, sql string, args []any, fn func(line *CasbinRule) error) error {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var n int64
+	defer func() { a.addLoaded(ctx, n) }()
+	scanner := a.newRuleScanner()
+	for rows.Next() {
+		line, err := scanner.scan(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+		n++
+	}
+	return rows.Err()
+}
+
 func policyID(ptype string, rule []string) string {
 	data := strings.Join(append([]string{ptype}, rule...), ",")
 	sum := xxh3.HashString(data)
@@ -247,26 +1023,10 @@ func policyID(ptype string, rule []string) string {
 }
 
 func savePolicyLine(ptype string, rule []string) *CasbinRule {
-	line := &CasbinRule{Ptype: ptype}
+	line := &CasbinRule{Ptype: ptype, size: len(rule)}
 
-	l := len(rule)
-	if l > 0 {
-		line.V0 = rule[0]
-	}
-	if l > 1 {
-		line.V1 = rule[1]
-	}
-	if l > 2 {
-		line.V2 = rule[2]
-	}
-	if l > 3 {
-		line.V3 = rule[3]
-	}
-	if l > 4 {
-		line.V4 = rule[4]
-	}
-	if l > 5 {
-		line.V5 = rule[5]
+	for i, v := range rule {
+		line.setValue(i, v)
 	}
 
 	line.ID = policyID(ptype, rule)
@@ -276,309 +1036,362 @@ func savePolicyLine(ptype string, rule []string) *CasbinRule {
 
 // SavePolicy saves policy to database.
 func (a *Adapter) SavePolicy(model model.Model) error {
-	ctx := context.Background()
-	tx, err := a.db.Begin(ctx)
-	if err != nil {
-		return fmt.Errorf("start DB transaction: %v", err)
-	}
-	defer tx.Rollback(ctx)
+	return a.savePolicy(context.Background(), model)
+}
 
-	_, err = tx.Exec(ctx, fmt.Sprintf(`DELETE FROM "%v"`, a.tableName))
+func (a *Adapter) savePolicy(ctx context.Context, model model.Model) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "SavePolicy", Rules: modelRules(model)})
 	if err != nil {
 		return err
 	}
+	defer finish(&err)
 
-	var lines []*CasbinRule
-
-	for ptype, ast := range model["p"] {
-		for _, rule := range ast.Policy {
-			line := savePolicyLine(ptype, rule)
-			lines = append(lines, line)
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		lines, err := a.modelLines(ctx, model)
+		if err != nil {
+			return err
+		}
+		p := a.newProgress("SavePolicy", int64(len(lines)))
+		if a.swapSave {
+			return a.swapRules(ctx, lines, p)
 		}
-	}
 
-	for ptype, ast := range model["g"] {
-		for _, rule := range ast.Policy {
-			line := savePolicyLine(ptype, rule)
-			lines = append(lines, line)
+		tx, err := a.conn(ctx).Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("start DB transaction: %w", err)
 		}
-	}
+		defer tx.Rollback(ctx)
 
-	for _, line := range lines {
-		_, err = tx.Exec(ctx,
-			fmt.Sprintf(`INSERT INTO "%v" VALUES($1, $2, $3, $4, $5, $6, $7, $8)`, a.tableName),
-			line.ID, line.Ptype, line.V0, line.V1, line.V2, line.V3, line.V4, line.V5,
-		)
+		sql, args := a.inNamespace(ctx, fmt.Sprintf(`DELETE FROM "%v"`, a.table(ctx)), nil, true)
+		if a.truncateSave && !a.namespaced {
+			// a namespaced adapter deletes its rules only, the other namespaces are kept
+			sql = fmt.Sprintf(`TRUNCATE "%v"`, a.table(ctx))
+		}
+		_, err = tx.Exec(ctx, sql, args...)
 		if err != nil {
 			return err
 		}
-	}
 
-	return tx.Commit(ctx)
+		for _, line := range lines {
+			_, err = tx.Exec(ctx, a.insertSQL(ctx), a.insertArgs(ctx, line)...)
+			if err != nil {
+				return ruleError(line, err)
+			}
+			p.add(1)
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+		p.end()
+		return nil
+	})
+}
+
+// modelLines returns the rules of the p and g sections of model
+func (a *Adapter) modelLines(ctx context.Context, model model.Model) ([]*CasbinRule, error) {
+	var lines []*CasbinRule
+	for _, sec := range []string{"p", "g"} {
+		for ptype, ast := range model[sec] {
+			for _, rule := range ast.Policy {
+				if err := a.checkRule(rule); err != nil {
+					return nil, err
+				}
+				lines = append(lines, a.policyLine(ctx, ptype, rule))
+			}
+		}
+	}
+	return lines, nil
 }
 
 // AddPolicy adds a policy rule to the storage.
 func (a *Adapter) AddPolicy(sec string, ptype string, rule []string) error {
-	line := savePolicyLine(ptype, rule)
-	ctx := context.Background()
-	tx, err := a.db.Begin(ctx)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback(ctx)
+	return a.addPolicy(context.Background(), sec, ptype, rule)
+}
 
-	_, err = tx.Exec(ctx,
-		fmt.Sprintf(`INSERT INTO "%v" VALUES($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING`, a.tableName),
-		line.ID, line.Ptype, line.V0, line.V1, line.V2, line.V3, line.V4, line.V5,
-	)
+func (a *Adapter) addPolicy(ctx context.Context, sec string, ptype string, rule []string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "AddPolicy", Ptype: ptype, Rules: 1})
 	if err != nil {
 		return err
 	}
+	defer finish(&err)
 
-	return tx.Commit(ctx)
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		rule = a.normalization.normalize(0, rule)
+		if err := a.validateRule(sec, ptype, rule); err != nil {
+			return err
+		}
+		line := a.policyLine(ctx, ptype, rule)
+		tx, err := a.conn(ctx).Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		// a single rule is stored or not, partial admission doesn't apply
+		if _, quotaErr, err := a.admitLines(ctx, tx, []*CasbinRule{line}); err != nil {
+			return err
+		} else if quotaErr != nil {
+			return quotaErr
+		}
+		_, err = tx.Exec(ctx, a.insertSQL(ctx)+" ON CONFLICT DO NOTHING", a.insertArgs(ctx, line)...)
+		if err != nil {
+			return ruleError(line, err)
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
 // AddPolicies adds policy rules to the storage.
 func (a *Adapter) AddPolicies(sec string, ptype string, rules [][]string) error {
-	ctx := context.Background()
-	tx, err := a.db.Begin(ctx)
+	return a.addPolicies(context.Background(), sec, ptype, rules)
+}
+
+func (a *Adapter) addPolicies(ctx context.Context, sec string, ptype string, rules [][]string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "AddPolicies", Ptype: ptype, Rules: len(rules)})
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer finish(&err)
 
-	for _, rule := range rules {
-		line := savePolicyLine(ptype, rule)
-		_, err = tx.Exec(ctx,
-			fmt.Sprintf(`INSERT INTO "%v" VALUES($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING`, a.tableName),
-			line.ID, line.Ptype, line.V0, line.V1, line.V2, line.V3, line.V4, line.V5,
-		)
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		rules = a.normalization.normalizeRules(rules)
+		for _, rule := range rules {
+			if err := a.validateRule(sec, ptype, rule); err != nil {
+				return err
+			}
+		}
+		lines := make([]*CasbinRule, 0, len(rules))
+		for _, rule := range rules {
+			lines = append(lines, a.policyLine(ctx, ptype, rule))
+		}
+		var exceeded *QuotaExceededError
+		err := a.writeChunks(ctx, lines, func(tx pgx.Tx, chunk []*CasbinRule) error {
+			chunk, quotaErr, err := a.admitLines(ctx, tx, chunk)
+			if err != nil {
+				return err
+			}
+			if quotaErr != nil {
+				if exceeded == nil {
+					exceeded = quotaErr
+				} else {
+					exceeded.Requested += quotaErr.Requested
+					exceeded.Admitted += quotaErr.Admitted
+				}
+			}
+			if len(chunk) == 0 {
+				return nil
+			}
+			_, err = a.insertChunk(ctx, tx, chunk)
+			return err
+		})
 		if err != nil {
 			return err
 		}
-	}
-
-	return tx.Commit(ctx)
+		if exceeded != nil {
+			return exceeded
+		}
+		return nil
+	})
 }
 
 // RemovePolicy removes a policy rule from the storage.
 func (a *Adapter) RemovePolicy(sec string, ptype string, rule []string) error {
-	line := savePolicyLine(ptype, rule)
+	return a.removePolicy(context.Background(), sec, ptype, rule)
+}
 
-	ctx := context.Background()
-	tx, err := a.db.Begin(ctx)
+func (a *Adapter) removePolicy(ctx context.Context, sec string, ptype string, rule []string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RemovePolicy", Ptype: ptype, Rules: 1})
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer finish(&err)
 
-	_, err = tx.Exec(ctx,
-		fmt.Sprintf(`DELETE FROM "%v" WHERE id=$1`, a.tableName),
-		line.ID,
-	)
-	if err != nil {
-		return err
-	}
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		line := a.policyLine(ctx, ptype, a.normalization.normalize(0, rule))
 
-	return tx.Commit(ctx)
+		tx, err := a.conn(ctx).Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		sql, args := a.byID(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE id=$1`, a.table(ctx)), []any{line.ID})
+		_, err = tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return ruleError(line, err)
+		}
+
+		return tx.Commit(ctx)
+	})
 }
 
 // RemovePolicies removes policy rules from the storage.
 func (a *Adapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
-	ctx := context.Background()
-	tx, err := a.db.Begin(ctx)
+	return a.removePolicies(context.Background(), sec, ptype, rules)
+}
+
+func (a *Adapter) removePolicies(ctx context.Context, sec string, ptype string, rules [][]string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RemovePolicies", Ptype: ptype, Rules: len(rules)})
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
-	for _, rule := range rules {
-		line := savePolicyLine(ptype, rule)
-		_, err = tx.Exec(ctx,
-			fmt.Sprintf(`DELETE FROM "%v" WHERE id=$1`, a.tableName),
-			line.ID,
-		)
-		if err != nil {
-			return err
-		}
-	}
+	defer finish(&err)
 
-	return tx.Commit(ctx)
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		rules = a.normalization.normalizeRules(rules)
+		lines := make([]*CasbinRule, 0, len(rules))
+		for _, rule := range rules {
+			lines = append(lines, a.policyLine(ctx, ptype, rule))
+		}
+		return a.writeChunks(ctx, lines, func(tx pgx.Tx, chunk []*CasbinRule) error {
+			return a.deleteChunk(ctx, tx, chunk)
+		})
+	})
 }
 
 // RemoveFilteredPolicy removes policy rules that match the filter from the storage.
 func (a *Adapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
-	ctx := context.Background()
-	tx, err := a.db.Begin(ctx)
+	return a.removeFilteredPolicy(context.Background(), sec, ptype, fieldIndex, fieldValues...)
+}
+
+func (a *Adapter) removeFilteredPolicy(ctx context.Context, sec string, ptype string, fieldIndex int, fieldValues ...string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RemoveFilteredPolicy", Ptype: ptype})
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback(ctx)
+	defer finish(&err)
+
+	_, err = a.removeFiltered(ctx, []FilteredRemoval{{Ptype: ptype, FieldIndex: fieldIndex, FieldValues: fieldValues}}, false)
+	return err
+}
 
-	sql := fmt.Sprintf(`DELETE FROM "%v" WHERE ptype = $1`, a.tableName)
+// removeFilteredQuery returns the statement of RemoveFilteredPolicy and its arguments, see checkFieldValues
+func (a *Adapter) removeFilteredQuery(ctx context.Context, ptype string, fieldIndex int, fieldValues []string) (string, []any) {
+	fieldValues = a.normalization.normalize(fieldIndex, fieldValues)
+	sql := fmt.Sprintf(`DELETE FROM "%v" WHERE ptype = $1`, a.table(ctx))
 	args := []any{ptype}
 
 	idx := fieldIndex + len(fieldValues)
-	if fieldIndex <= 0 && idx > 0 && fieldValues[0-fieldIndex] != "" {
-		sql += fmt.Sprintf(" AND v0 = $%v", len(args)+1)
-		args = append(args, fieldValues[0-fieldIndex])
-	}
-	if fieldIndex <= 1 && idx > 1 && fieldValues[1-fieldIndex] != "" {
-		sql += fmt.Sprintf(" AND v1 = $%v", len(args)+1)
-		args = append(args, fieldValues[1-fieldIndex])
-	}
-	if fieldIndex <= 2 && idx > 2 && fieldValues[2-fieldIndex] != "" {
-		sql += fmt.Sprintf(" AND v2 = $%v", len(args)+1)
-		args = append(args, fieldValues[2-fieldIndex])
-	}
-	if fieldIndex <= 3 && idx > 3 && fieldValues[3-fieldIndex] != "" {
-		sql += fmt.Sprintf(" AND v3 = $%v", len(args)+1)
-		args = append(args, fieldValues[3-fieldIndex])
-	}
-	if fieldIndex <= 4 && idx > 4 && fieldValues[4-fieldIndex] != "" {
-		sql += fmt.Sprintf(" AND v4 = $%v", len(args)+1)
-		args = append(args, fieldValues[4-fieldIndex])
-	}
-	if fieldIndex <= 5 && idx > 5 && fieldValues[5-fieldIndex] != "" {
-		sql += fmt.Sprintf(" AND v5 = $%v", len(args)+1)
-		args = append(args, fieldValues[5-fieldIndex])
+	for i := 0; i < a.valueColumns; i++ {
+		if fieldIndex <= i && idx > i && fieldValues[i-fieldIndex] != "" {
+			sql += fmt.Sprintf(" AND v%d = $%v", i, len(args)+1)
+			args = append(args, a.fieldArg(i, fieldValues[i-fieldIndex]))
+		}
 	}
+	return a.inNamespace(ctx, sql, args, false)
+}
 
-	_, err = tx.Exec(ctx, sql, args...)
+func (a *Adapter) LoadFilteredPolicy(model model.Model, filter any) (err error) {
+	ctx, finish, err := a.start(context.Background(), OpInfo{Op: "LoadFilteredPolicy"})
 	if err != nil {
 		return err
 	}
+	defer finish(&err)
 
-	return tx.Commit(ctx)
-}
-
-func (a *Adapter) LoadFilteredPolicy(model model.Model, filter any) error {
 	if filter == nil {
-		return a.LoadPolicy(model)
+		return a.loadAll(ctx, model)
 	}
 
-	filterValue, ok := filter.(*Filter)
-	if !ok {
-		return fmt.Errorf("invalid filter type")
-	}
-	err := a.loadFilteredPolicy(model, filterValue, persist.LoadPolicyLine)
+	filters, err := parseFilter(filter)
 	if err != nil {
 		return err
 	}
-	a.filtered = true
-	return nil
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		err := a.loadFilteredPolicy(ctx, model, filters)
+		if err != nil {
+			return err
+		}
+		a.setFiltered(true)
+		return nil
+	})
+	if errors.Is(err, ErrBroadFilter) && !a.filterThreshold.Fail {
+		// loadAll clears the filtered flag
+		return a.loadAll(ctx, model)
+	}
+	return err
 }
 
-func buildQuery(query string, args []any, values []string) (string, []any, error) {
+// buildQuery appends a condition for every non empty value, values may not exceed columns entries.
+// Empty values are wildcards and are never compared to the empty string, so NULL values (see WithNullValues) match them.
+func buildQuery(query string, args []any, values []string, columns int) (string, []any, error) {
 	for ind, v := range values {
 		if v == "" {
 			continue
 		}
-		switch ind {
-		case 0:
-			query += fmt.Sprintf(" AND v0 = $%v", len(args)+1)
-			args = append(args, v)
-		case 1:
-			query += fmt.Sprintf(" AND v1 = $%v", len(args)+1)
-			args = append(args, v)
-		case 2:
-			query += fmt.Sprintf(" AND v2 = $%v", len(args)+1)
-			args = append(args, v)
-		case 3:
-			query += fmt.Sprintf(" AND v3 = $%v", len(args)+1)
-			args = append(args, v)
-		case 4:
-			query += fmt.Sprintf(" AND v4 = $%v", len(args)+1)
-			args = append(args, v)
-		case 5:
-			query += fmt.Sprintf(" AND v5 = $%v", len(args)+1)
-			args = append(args, v)
-		default:
-			return "", nil, fmt.Errorf("filter has more values than expected, should not exceed 6 values")
+		if ind >= columns {
+			return "", nil, fmt.Errorf("%w: filter has more values than expected, should not exceed %d values", ErrInvalidFilter, columns)
 		}
+		query += fmt.Sprintf(" AND v%d = $%v", ind, len(args)+1)
+		args = append(args, v)
 	}
 
 	return query, args, nil
 }
 
-func (a *Adapter) loadFilteredPolicy(model model.Model, filter *Filter, handler func(string, model.Model) error) error {
-	ctx := context.Background()
-	sql := fmt.Sprintf(`SELECT * FROM "%v" WHERE ptype=$1`, a.tableName)
-	if filter.P != nil {
-		lines := []*CasbinRule{}
-		args := []any{"p"}
-		sql, args, err := buildQuery(sql, args, filter.P)
+func (a *Adapter) loadFilteredPolicy(ctx context.Context, model model.Model, filters []ptypeFilter) error {
+	load := func(line *CasbinRule) error {
+		return a.loadRule(line, model)
+	}
+	// every filter is checked before the rules are loaded
+	queries, queryArgs, err := a.filterQueries(ctx, filters)
+	if err != nil {
+		return err
+	}
+	if a.maxLoadRules > 0 || a.filterThreshold.enabled() {
+		matched, err := a.countQueries(ctx, queries, queryArgs)
 		if err != nil {
 			return err
 		}
-		rows, err := a.db.Query(ctx, sql, args...)
-		if err != nil {
+		if err := a.checkLoadLimit(matched); err != nil {
 			return err
 		}
-
-		for rows.Next() {
-			var id, ptype, v0, v1, v2, v3, v4, v5 string
-			if err := rows.Scan(&id, &ptype, &v0, &v1, &v2, &v3, &v4, &v5); err != nil {
-				return err
-			}
-			lines = append(lines, &CasbinRule{
-				ID:    id,
-				Ptype: ptype,
-				V0:    v0,
-				V1:    v1,
-				V2:    v2,
-				V3:    v3,
-				V4:    v4,
-				V5:    v5,
-			})
-		}
-		rows.Close()
-
-		for _, line := range lines {
-			handler(line.String(), model)
-		}
-	}
-	if filter.G != nil {
-		lines := []*CasbinRule{}
-		args := []any{"g"}
-		sql, args, err := buildQuery(sql, args, filter.G)
-		if err != nil {
+		if err := a.checkFilterThreshold(ctx, matched); err != nil {
 			return err
 		}
-		rows, err := a.db.Query(ctx, sql, args...)
-		if err != nil {
+	}
+	for i, sql := range queries {
+		if err := a.loadRows(ctx, a.db, sql, queryArgs[i], load); err != nil {
 			return err
 		}
+	}
 
-		for rows.Next() {
-			var id, ptype, v0, v1, v2, v3, v4, v5 string
-			if err := rows.Scan(&id, &ptype, &v0, &v1, &v2, &v3, &v4, &v5); err != nil {
-				return err
-			}
-			lines = append(lines, &CasbinRule{
-				ID:    id,
-				Ptype: ptype,
-				V0:    v0,
-				V1:    v1,
-				V2:    v2,
-				V3:    v3,
-				V4:    v4,
-				V5:    v5,
-			})
-		}
-		rows.Close()
+	return nil
+}
 
-		for _, line := range lines {
-			handler(line.String(), model)
+// filterQueries returns the queries loading the rules of filters and their arguments
+func (a *Adapter) filterQueries(ctx context.Context, filters []ptypeFilter) ([]string, [][]any, error) {
+	sql := fmt.Sprintf(`SELECT %v FROM "%v" WHERE ptype=$1`, a.columns(), a.readTable(ctx))
+	queries := make([]string, len(filters))
+	queryArgs := make([][]any, len(filters))
+	for i, f := range filters {
+		args := []any{f.ptype}
+		values, err := a.encryptFields(0, a.normalization.normalize(0, f.values))
+		if err != nil {
+			return nil, nil, err
+		}
+		sql, args, err := buildQuery(sql, args, values, a.valueColumns)
+		if err != nil {
+			return nil, nil, err
 		}
+		queries[i], queryArgs[i] = a.inNamespace(ctx, sql, args, false)
 	}
-
-	return nil
+	return queries, queryArgs, nil
 }
 
 func (a *Adapter) IsFiltered() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	return a.filtered
 }
 
+func (a *Adapter) setFiltered(filtered bool) {
+	a.mu.Lock()
+	a.filtered = filtered
+	a.mu.Unlock()
+}
+
 // UpdatePolicy updates a policy rule from storage.
 // This is part of the Auto-Save feature.
 func (a *Adapter) UpdatePolicy(sec string, ptype string, oldRule, newPolicy []string) error {
@@ -586,76 +1399,106 @@ func (a *Adapter) UpdatePolicy(sec string, ptype string, oldRule, newPolicy []st
 }
 
 // UpdatePolicies updates some policy rules to storage, like db, redis.
+// oldRules[i] is replaced with newRules[i], an error is returned when the slices don't have the same length.
+// Nothing is written when they are empty.
 func (a *Adapter) UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
-	oldLines := make([]*CasbinRule, 0, len(oldRules))
-	newLines := make([]*CasbinRule, 0, len(newRules))
-	for _, rule := range oldRules {
-		oldLines = append(oldLines, savePolicyLine(ptype, rule))
+	return a.updatePolicies(context.Background(), sec, ptype, oldRules, newRules)
+}
+
+func (a *Adapter) updatePolicies(ctx context.Context, sec string, ptype string, oldRules, newRules [][]string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "UpdatePolicies", Ptype: ptype, Rules: len(oldRules)})
+	if err != nil {
+		return err
 	}
-	for _, rule := range newRules {
-		newLines = append(newLines, savePolicyLine(ptype, rule))
+	defer finish(&err)
+
+	if len(oldRules) != len(newRules) {
+		return fmt.Errorf("%d old rules and %d new rules given, every old rule must have a new rule", len(oldRules), len(newRules))
+	}
+	if len(oldRules) == 0 {
+		return nil
 	}
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		oldRules = a.normalization.normalizeRules(oldRules)
+		newRules = a.normalization.normalizeRules(newRules)
+		oldLines := make([]*CasbinRule, 0, len(oldRules))
+		newLines := make([]*CasbinRule, 0, len(newRules))
+		for _, rule := range oldRules {
+			oldLines = append(oldLines, a.policyLine(ctx, ptype, rule))
+		}
+		for _, rule := range newRules {
+			if err := a.validateRule(sec, ptype, rule); err != nil {
+				return err
+			}
+			newLines = append(newLines, a.policyLine(ctx, ptype, rule))
+		}
 
-	return a.updatePolicies(oldLines, newLines)
+		return a.updateLines(ctx, oldLines, newLines)
+	})
 }
 
 func (a *Adapter) UpdateFilteredPolicies(sec string, ptype string, newPolicies [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
-	line := &CasbinRule{}
+	return a.updateFilteredPolicies(context.Background(), sec, ptype, newPolicies, fieldIndex, fieldValues...)
+}
 
-	line.Ptype = ptype
-	if fieldIndex <= 0 && 0 < fieldIndex+len(fieldValues) {
-		line.V0 = fieldValues[0-fieldIndex]
-	}
-	if fieldIndex <= 1 && 1 < fieldIndex+len(fieldValues) {
-		line.V1 = fieldValues[1-fieldIndex]
-	}
-	if fieldIndex <= 2 && 2 < fieldIndex+len(fieldValues) {
-		line.V2 = fieldValues[2-fieldIndex]
+func (a *Adapter) updateFilteredPolicies(ctx context.Context, sec string, ptype string, newPolicies [][]string, fieldIndex int, fieldValues ...string) (_ [][]string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "UpdateFilteredPolicies", Ptype: ptype, Rules: len(newPolicies)})
+	if err != nil {
+		return nil, err
 	}
-	if fieldIndex <= 3 && 3 < fieldIndex+len(fieldValues) {
-		line.V3 = fieldValues[3-fieldIndex]
+	defer finish(&err)
+
+	if err := a.checkFieldValues(fieldIndex, fieldValues); err != nil {
+		return nil, err
 	}
-	if fieldIndex <= 4 && 4 < fieldIndex+len(fieldValues) {
-		line.V4 = fieldValues[4-fieldIndex]
+	newPolicies = a.normalization.normalizeRules(newPolicies)
+	fieldValues = a.normalization.normalize(fieldIndex, fieldValues)
+	if fieldValues, err = a.encryptFields(fieldIndex, fieldValues); err != nil {
+		return nil, err
 	}
-	if fieldIndex <= 5 && 5 < fieldIndex+len(fieldValues) {
-		line.V5 = fieldValues[5-fieldIndex]
+	line := &CasbinRule{}
+
+	line.Ptype = ptype
+	for i := 0; i < a.valueColumns; i++ {
+		if fieldIndex <= i && i < fieldIndex+len(fieldValues) {
+			line.setValue(i, fieldValues[i-fieldIndex])
+		}
 	}
 
 	newP := make([]CasbinRule, 0, len(newPolicies))
 	oldP := make([]CasbinRule, 0)
 	for _, newRule := range newPolicies {
-		newP = append(newP, *(savePolicyLine(ptype, newRule)))
-	}
-
-	ctx := context.Background()
-	tx, err := a.db.Begin(ctx)
-	if err != nil {
-		return nil, err
+		if err := a.validateRule(sec, ptype, newRule); err != nil {
+			return nil, err
+		}
+		newP = append(newP, *(a.policyLine(ctx, ptype, newRule)))
 	}
-	defer tx.Rollback(ctx)
-
-	for i := range newP {
-		str, args := line.queryString()
 
-		sql := fmt.Sprintf(`DELETE FROM "%v" WHERE %v`, a.tableName, str)
-		_, err = tx.Exec(ctx, sql, args...)
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		tx, err := a.conn(ctx).Begin(ctx)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		defer tx.Rollback(ctx)
 
-		row := newP[i]
-		_, err = tx.Exec(ctx, fmt.Sprintf(
-			`INSERT INTO "%v" VALUES($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT DO NOTHING`,
-			a.tableName,
-		), row.ID, row.Ptype, row.V0, row.V1, row.V2, row.V3, row.V4, row.V5)
+		for i := range newP {
+			str, args := line.queryString(a.valueColumns)
 
-		if err != nil {
-			return nil, err
+			sql, args := a.inNamespace(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE %v`, a.table(ctx), str), args, false)
+			_, err = tx.Exec(ctx, sql, args...)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(ctx, a.insertSQL(ctx)+" ON CONFLICT DO NOTHING", a.insertArgs(ctx, &newP[i])...)
+			if err != nil {
+				return ruleError(&newP[i], err)
+			}
 		}
-	}
 
-	if err := tx.Commit(ctx); err != nil {
+		return tx.Commit(ctx)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -668,33 +1511,16 @@ func (a *Adapter) UpdateFilteredPolicies(sec string, ptype string, newPolicies [
 	return oldPolicies, err
 }
 
-func (c *CasbinRule) queryString() (string, []any) {
+// queryString returns the WHERE clause matching the rule on its first n values
+func (c *CasbinRule) queryString(n int) (string, []any) {
 	queryArgs := []any{c.Ptype}
 
 	queryStr := "ptype = $1"
-	if c.V0 != "" {
-		queryStr += fmt.Sprintf(" AND v0 = $%v", len(queryArgs)+1)
-		queryArgs = append(queryArgs, c.V0)
-	}
-	if c.V1 != "" {
-		queryStr += fmt.Sprintf(" AND v1 = $%v", len(queryArgs)+1)
-		queryArgs = append(queryArgs, c.V1)
-	}
-	if c.V2 != "" {
-		queryStr += fmt.Sprintf(" AND v2 = $%v", len(queryArgs)+1)
-		queryArgs = append(queryArgs, c.V2)
-	}
-	if c.V3 != "" {
-		queryStr += fmt.Sprintf(" AND v3 = $%v", len(queryArgs)+1)
-		queryArgs = append(queryArgs, c.V3)
-	}
-	if c.V4 != "" {
-		queryStr += fmt.Sprintf(" AND v4 = $%v", len(queryArgs)+1)
-		queryArgs = append(queryArgs, c.V4)
-	}
-	if c.V5 != "" {
-		queryStr += fmt.Sprintf(" AND v5 = $%v", len(queryArgs)+1)
-		queryArgs = append(queryArgs, c.V5)
+	for i, v := range c.values(n) {
+		if v != "" {
+			queryStr += fmt.Sprintf(" AND v%d = $%v", i, len(queryArgs)+1)
+			queryArgs = append(queryArgs, v)
+		}
 	}
 
 	return queryStr, queryArgs
@@ -705,55 +1531,49 @@ func (c *CasbinRule) toStringPolicy() []string {
 	if c.Ptype != "" {
 		policy = append(policy, c.Ptype)
 	}
-	if c.V0 != "" {
-		policy = append(policy, c.V0)
-	}
-	if c.V1 != "" {
-		policy = append(policy, c.V1)
-	}
-	if c.V2 != "" {
-		policy = append(policy, c.V2)
-	}
-	if c.V3 != "" {
-		policy = append(policy, c.V3)
-	}
-	if c.V4 != "" {
-		policy = append(policy, c.V4)
-	}
-	if c.V5 != "" {
-		policy = append(policy, c.V5)
+	for _, v := range c.values(DefaultValueColumns + len(c.Extra)) {
+		if v != "" {
+			policy = append(policy, v)
+		}
 	}
 	return policy
 }
 
-func (a *Adapter) updatePolicies(oldLines, newLines []*CasbinRule) error {
-	ctx := context.Background()
-	tx, err := a.db.Begin(ctx)
+// updateLines replaces the stored oldLines with newLines
+func (a *Adapter) updateLines(ctx context.Context, oldLines, newLines []*CasbinRule) error {
+	tx, err := a.conn(ctx).Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx)
 
 	for i, line := range oldLines {
-		str, args := line.queryString()
+		stored, err := a.encryptedLine(line)
+		if err != nil {
+			return err
+		}
+		str, args := stored.queryString(a.valueColumns)
+		str, args = a.inNamespace(ctx, str, args, false)
 
+		sets := []string{fmt.Sprintf("ptype=$%v", len(args)+1)}
+		for j := 0; j < a.valueColumns; j++ {
+			sets = append(sets, fmt.Sprintf("v%d=$%v", j, len(args)+j+2))
+		}
 		sql := fmt.Sprintf(
-			`UPDATE "%v" SET ptype=$%v, v0=$%v, v1=$%v, v2=$%v, v3=$%v, v4=$%v, v5=$%v WHERE %v`,
-			a.tableName,
-			len(args)+1,
-			len(args)+2,
-			len(args)+3,
-			len(args)+4,
-			len(args)+5,
-			len(args)+6,
-			len(args)+7,
+			`UPDATE "%v" SET %v WHERE %v`,
+			a.table(ctx),
+			strings.Join(sets, ", "),
 			str,
 		)
 		row := newLines[i]
-		args = append(args, row.Ptype, row.V0, row.V1, row.V2, row.V3, row.V4, row.V5)
-		_, err = tx.Exec(ctx, sql, args...)
+		args = append(args, row.Ptype)
+		args = append(args, a.valueArgs(row)...)
+		tag, err := tx.Exec(ctx, sql, args...)
 		if err != nil {
-			return err
+			return ruleError(line, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("%w: %v", ErrPolicyNotFound, line.String())
 		}
 	}
 
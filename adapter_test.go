@@ -2,12 +2,21 @@ package pgxadapter
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
 	"github.com/casbin/casbin/v2/util"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -161,6 +170,58 @@ func (s *AdapterTestSuite) TestConstructorDB() {
 	)
 }
 
+func (s *AdapterTestSuite) TestReadRelation() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+
+	for _, sql := range []string{
+		`DROP VIEW IF EXISTS rules_merged`,
+		`DROP TABLE IF EXISTS rules_local, rules_domain`,
+		`CREATE TABLE rules_domain (id TEXT PRIMARY KEY, ptype TEXT NOT NULL, v0 TEXT, v1 TEXT, v2 TEXT, v3 TEXT, v4 TEXT, v5 TEXT)`,
+		`INSERT INTO rules_domain VALUES ('d1', 'p', 'bob', 'data2', 'write', '', '', ''), ('d2', 'g', 'carol', 'admin', '', '', '', '')`,
+	} {
+		_, err := pool.Exec(ctx, sql)
+		s.Require().NoError(err)
+	}
+
+	a, err := NewAdapterByDB(pool, WithTableName("rules_local"), WithReadRelation("rules_merged"), SkipSchemaVerification())
+	s.Require().NoError(err)
+	defer a.Close()
+	_, err = pool.Exec(ctx, `CREATE VIEW rules_merged AS SELECT * FROM rules_local UNION ALL SELECT * FROM rules_domain`)
+	s.Require().NoError(err)
+	s.Require().NoError(a.VerifySchema(ctx))
+
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	s.e, err = casbin.NewEnforcer("examples/rbac_model.conf", a)
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}, s.e.GetPolicy())
+	s.assertPolicy([][]string{{"carol", "admin"}}, s.e.GetGroupingPolicy())
+
+	count, err := a.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Assert().EqualValues(3, count)
+
+	for _, sql := range []string{`DROP VIEW rules_merged`, `DROP TABLE rules_local, rules_domain`} {
+		_, err := pool.Exec(ctx, sql)
+		s.Require().NoError(err)
+	}
+}
+
+func (s *AdapterTestSuite) TestConstructorConfigUnchanged() {
+	cfg, err := pgxpool.ParseConfig(os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	database, connString := cfg.ConnConfig.Database, cfg.ConnString()
+
+	a, err := NewAdapter(cfg)
+	s.Require().NoError(err)
+	defer a.Close()
+
+	s.Assert().Equal(database, cfg.ConnConfig.Database)
+	s.Assert().Equal(connString, cfg.ConnString())
+}
+
 func (s *AdapterTestSuite) TestConstructorOptions() {
 	a, err := NewAdapter(os.Getenv("PG_CONN"))
 	s.Require().NoError(err)
@@ -175,6 +236,71 @@ func (s *AdapterTestSuite) TestConstructorOptions() {
 	)
 }
 
+func (s *AdapterTestSuite) TestValueColumns() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+
+	// existing 6-column table gets extended
+	a, err := NewAdapterByDB(pool, WithTableName("rules_abac"))
+	s.Require().NoError(err)
+	a, err = NewAdapterByDB(pool, WithTableName("rules_abac"), WithValueColumns(7))
+	s.Require().NoError(err)
+	defer a.Close()
+	defer pool.Exec(ctx, `DROP TABLE "rules_abac"`)
+
+	rule := []string{"alice", "data1", "read", "dom1", "127.0.0.1", "9-17", "deny"}
+	s.Require().NoError(a.AddPolicy("p", "p", rule))
+
+	m := model.NewModel()
+	m.AddDef("p", "p", "sub, obj, act, dom, ip, time, eft")
+	s.Require().NoError(a.LoadPolicy(m))
+	s.assertPolicy([][]string{rule}, m.GetPolicy("p", "p"))
+
+	m = model.NewModel()
+	m.AddDef("p", "p", "sub, obj, act, dom, ip, time, eft")
+	s.Require().NoError(a.LoadFilteredPolicy(m, &Filter{P: []string{"", "", "", "", "", "", "deny"}}))
+	s.assertPolicy([][]string{rule}, m.GetPolicy("p", "p"))
+
+	s.Require().NoError(a.RemoveFilteredPolicy("p", "p", 6, "deny"))
+	m = model.NewModel()
+	m.AddDef("p", "p", "sub, obj, act, dom, ip, time, eft")
+	s.Require().NoError(a.LoadPolicy(m))
+	s.assertPolicy([][]string{}, m.GetPolicy("p", "p"))
+}
+
+func (s *AdapterTestSuite) TestNullValues() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	a, err := NewAdapterByDB(pool, WithTableName("rules_null"), WithNullValues())
+	s.Require().NoError(err)
+	defer a.Close()
+	defer pool.Exec(ctx, `DROP TABLE "rules_null"`)
+
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", "examples/rbac_policy.csv")
+	s.Require().NoError(err)
+	s.Require().NoError(a.SavePolicy(e.GetModel()))
+
+	var nulls int
+	err = pool.QueryRow(ctx, `SELECT count(*) FROM "rules_null" WHERE v3 IS NULL AND v4 IS NULL AND v5 IS NULL`).Scan(&nulls)
+	s.Require().NoError(err)
+	s.Assert().Equal(5, nulls)
+
+	e, err = casbin.NewEnforcer("examples/rbac_model.conf", a)
+	s.Require().NoError(err)
+	s.assertPolicy(
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+		e.GetPolicy(),
+	)
+
+	_, err = e.RemoveFilteredPolicy(0, "", "data2")
+	s.Require().NoError(err)
+	s.Require().NoError(e.LoadPolicy())
+	s.assertPolicy([][]string{{"alice", "data1", "read"}}, e.GetPolicy())
+	s.assertPolicy([][]string{{"alice", "data2_admin"}}, e.GetGroupingPolicy())
+}
+
 func (s *AdapterTestSuite) TestRemovePolicy() {
 	_, err := s.e.RemovePolicy("alice", "data1", "read")
 	s.Require().NoError(err)
@@ -369,3 +495,380 @@ func (s *AdapterTestSuite) TestUpdateFilteredPolicies() {
 func TestAdapterTestSuite(t *testing.T) {
 	suite.Run(t, new(AdapterTestSuite))
 }
+
+func newMockAdapter(t *testing.T, opts ...Option) (*Adapter, pgxmock.PgxPoolIface) {
+	t.Helper()
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	a, err := NewAdapterByPgxPool(mock, append([]Option{SkipTableCreate(), SkipSchemaVerification()}, opts...)...)
+	require.NoError(t, err)
+	return a, mock
+}
+
+func TestMockCreateTable(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery("information_schema.columns").
+		WithArgs("rules").
+		WillReturnRows(schemaRows("id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"))
+
+	_, err = NewAdapterByPgxPool(mock, WithTableName("rules"))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMockRemoveFilteredPolicy(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v1 = $2 AND v2 = $3`)).
+		WithArgs("p", "data2", "read").
+		WillReturnResult(pgxmock.NewResult("DELETE", 2))
+	mock.ExpectCommit()
+
+	err := a.RemoveFilteredPolicy("p", "p", 0, "", "data2", "read")
+	require.NoError(t, err)
+}
+
+func TestMockLoadFilteredPolicy(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v2 = $2`)).
+		WithArgs("p", "read").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", "alice", "data1", "read", "", "", "").
+			AddRow("2", "p", "data2_admin", "data2", "read", "", "", ""))
+
+	err = a.LoadFilteredPolicy(m, &Filter{P: []string{"", "", "read"}})
+	require.NoError(t, err)
+	require.True(t, a.IsFiltered())
+	require.Equal(t, [][]string{{"alice", "data1", "read"}, {"data2_admin", "data2", "read"}}, m.GetPolicy("p", "p"))
+}
+
+func TestMockReadRelation(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	mock.ExpectQuery("information_schema.columns").WithArgs("casbin_rules").
+		WillReturnRows(schemaRows("id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"))
+	mock.ExpectQuery("information_schema.columns").WithArgs("rules_view").
+		WillReturnRows(schemaRows("id", "ptype", "v0", "v1"))
+	_, err = NewAdapterByPgxPool(mock, SkipTableCreate(), WithReadRelation("rules_view"))
+	var schemaErr *SchemaError
+	require.ErrorAs(t, err, &schemaErr)
+	require.Equal(t, "rules_view", schemaErr.Table)
+
+	a, err := NewAdapterByPgxPool(mock, SkipTableCreate(), SkipSchemaVerification(), WithReadRelation("rules_view"))
+	require.NoError(t, err)
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "rules_view"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", "alice", "data1", "read", "", "", "").
+			AddRow("2", "g", "alice", "admin", "", "", "", ""))
+	require.NoError(t, a.LoadPolicy(m))
+	require.Equal(t, [][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "rules_view" WHERE ptype=$1 AND v0 = $2`)).
+		WithArgs("p", "alice").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	require.NoError(t, a.LoadFilteredPolicy(m, &Filter{P: []string{"alice"}}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", []string{"bob", "data2", "write"}))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBuildQuery(t *testing.T) {
+	sql, args, err := buildQuery("SELECT * FROM t WHERE ptype=$1", []any{"p"}, []string{"alice", "", "read"}, DefaultValueColumns)
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM t WHERE ptype=$1 AND v0 = $2 AND v2 = $3", sql)
+	require.Equal(t, []any{"p", "alice", "read"}, args)
+
+	_, _, err = buildQuery("", nil, make([]string, 7), DefaultValueColumns)
+	require.NoError(t, err)
+	_, _, err = buildQuery("", nil, []string{"", "", "", "", "", "", "x"}, DefaultValueColumns)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	require.ErrorContains(t, err, "should not exceed 6 values")
+
+	sql, _, err = buildQuery("", nil, []string{"", "", "", "", "", "", "x"}, 7)
+	require.NoError(t, err)
+	require.Equal(t, " AND v6 = $1", sql)
+	_, _, err = buildQuery("", nil, []string{"", "", "", "", "", "", "", "y"}, 7)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	require.ErrorContains(t, err, "should not exceed 7 values")
+}
+
+func TestMockNullValues(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNullValues())
+	rule := []string{"alice", "data1", ""}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(policyID("p", rule), "p", "alice", "data1", "", nil, nil, nil).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", rule))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", "alice", "data1", "read", nil, nil, nil).
+			AddRow("2", "g", "alice", "admin", nil, nil, nil, nil))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicy(m))
+	require.Equal(t, [][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "admin"}}, m.GetPolicy("g", "g"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v1 = $2`)).
+		WithArgs("p", "data1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemoveFilteredPolicy("p", "p", 0, "", "data1", ""))
+}
+
+func TestMockFilteredConcurrency(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	mock.MatchExpectationsInOrder(false)
+
+	const loads = 20
+	for i := 0; i < loads; i++ {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+				AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < loads; i++ {
+		wg.Add(2)
+		var filter any
+		if i%2 == 0 {
+			filter = &Filter{P: []string{"alice"}}
+		}
+		go func() {
+			defer wg.Done()
+			m, err := model.NewModelFromFile("examples/rbac_model.conf")
+			require.NoError(t, err)
+			require.NoError(t, a.LoadFilteredPolicy(m, filter))
+		}()
+		go func() {
+			defer wg.Done()
+			a.IsFiltered()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMockValueColumns(t *testing.T) {
+	_, err := NewAdapterByPgxPool(nil, WithValueColumns(MaxValueColumns+1))
+	require.Error(t, err)
+
+	a, mock := newMockAdapter(t, WithValueColumns(7))
+	rule := []string{"alice", "data1", "read", "dom1", "ip", "time", "deny"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules" (id, ptype, v0, v1, v2, v3, v4, v5, v6) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT DO NOTHING`)).
+		WithArgs(policyID("p", rule), "p", "alice", "data1", "read", "dom1", "ip", "time", "deny").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", rule))
+
+	require.Error(t, a.AddPolicy("p", "p", append(rule, "extra")))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v6 = $2`)).
+		WithArgs("p", "deny").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemoveFilteredPolicy("p", "p", 6, "deny"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5, v6 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "v6"}).
+			AddRow("1", "p", "alice", "data1", "read", "dom1", "ip", "time", "deny"))
+	m := model.NewModel()
+	m.AddDef("p", "p", "sub, obj, act, dom, ip, time, eft")
+	require.NoError(t, a.LoadPolicy(m))
+	require.Equal(t, [][]string{rule}, m.GetPolicy("p", "p"))
+}
+
+func TestMockShutdown(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	loaded := make(chan error)
+	go func() {
+		loaded <- a.LoadPolicy(m)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, a.Shutdown(context.Background()))
+	select {
+	case err := <-loaded:
+		require.NoError(t, err)
+	default:
+		t.Fatal("Shutdown returned before the in-flight load finished")
+	}
+	require.Equal(t, [][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+
+	require.ErrorIs(t, a.LoadPolicy(m), ErrAdapterClosed)
+	require.ErrorIs(t, a.AddPolicy("p", "p", []string{"bob", "data2", "write"}), ErrAdapterClosed)
+	require.NoError(t, a.Close())
+}
+
+func (s *AdapterTestSuite) TestShutdownAbandon() {
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithQueryRewriter(func(op, sql string, args []any) (string, []any) {
+		if op == "CountRules" {
+			return "SELECT count(*) FROM pg_sleep(60)", nil
+		}
+		return sql, args
+	}))
+	s.Require().NoError(err)
+
+	counted := make(chan error)
+	go func() {
+		_, err := a.CountRules(context.Background(), "")
+		counted <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	begun := time.Now()
+	s.Require().ErrorIs(a.Shutdown(ctx), context.DeadlineExceeded)
+	s.Require().Less(time.Since(begun), 5*time.Second)
+	s.Require().Error(<-counted)
+}
+
+func TestMockShutdownTimeout(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	// the query would hang without the cancellation of its context
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillDelayFor(time.Hour).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	loaded := make(chan error)
+	go func() {
+		loaded <- a.LoadPolicy(m)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	begun := time.Now()
+	err = a.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualError(t, err, "pgadapter: 1 in-flight operations abandoned: context deadline exceeded")
+	require.Less(t, time.Since(begun), time.Second)
+
+	select {
+	case err := <-loaded:
+		require.ErrorIs(t, err, pgxmock.ErrCancelled)
+	case <-time.After(time.Second):
+		t.Fatal("the abandoned load was not canceled")
+	}
+}
+
+func TestNewAdapterConfigUnchanged(t *testing.T) {
+	cfg, err := pgxpool.ParseConfig("postgres://user@127.0.0.1:1/app?connect_timeout=1")
+	require.NoError(t, err)
+
+	_, err = NewAdapter(cfg, "casbin")
+	require.Error(t, err)
+	require.Equal(t, "app", cfg.ConnConfig.Database)
+}
+
+func TestValidateDatabaseName(t *testing.T) {
+	for _, name := range []string{"casbin", "casbin-rules", "Casbin Rules", `say "hi"`, `x"; DROP DATABASE postgres; --`, "политики"} {
+		require.NoError(t, validateDatabaseName(name), name)
+	}
+	for _, name := range []string{"", strings.Repeat("a", 64), "a\x00b", "\xff"} {
+		require.ErrorIs(t, validateDatabaseName(name), ErrInvalidDatabaseName, name)
+	}
+
+	require.Equal(t, `"casbin-rules"`, pgx.Identifier{"casbin-rules"}.Sanitize())
+	require.Equal(t, `"x""; DROP DATABASE postgres; --"`, pgx.Identifier{`x"; DROP DATABASE postgres; --`}.Sanitize())
+}
+
+func TestNewAdapterInvalidDatabaseName(t *testing.T) {
+	_, err := NewAdapter("postgres://user@127.0.0.1:1/app?connect_timeout=1", "")
+	require.ErrorIs(t, err, ErrInvalidDatabaseName)
+}
+
+func TestMockLoadPolicyReusesScanBuffer(t *testing.T) {
+	a, mock := newMockAdapter(t, WithValueColumns(8))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5, v6, v7 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "v6", "v7"}).
+			AddRow("1", "p", "alice", "data1", "read", "a", "b", "c", "d", "e").
+			AddRow("2", "g", "alice", "admin", nil, nil, nil, nil, nil, nil).
+			AddRow("3", "p", "bob", "data2", "write", "f", "g", "h", "i", "j"))
+	m := model.NewModel()
+	m.AddDef("r", "r", "sub, obj, act, a, b, c, d, e")
+	m.AddDef("p", "p", "sub, obj, act, a, b, c, d, e")
+	m.AddDef("g", "g", "_, _")
+	require.NoError(t, a.LoadPolicy(m))
+	require.Equal(t, [][]string{{"alice", "data1", "read", "a", "b", "c", "d", "e"}, {"bob", "data2", "write", "f", "g", "h", "i", "j"}}, m.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "admin"}}, m.GetPolicy("g", "g"))
+}
+
+func BenchmarkLoadPolicy(b *testing.B) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(b, err)
+	a, err := NewAdapterByPgxPool(mock, SkipTableCreate(), SkipSchemaVerification())
+	require.NoError(b, err)
+
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+	values := make([][]any, 10000)
+	for i := range values {
+		values[i] = []any{fmt.Sprint(i), "p", fmt.Sprintf("user%d", i), fmt.Sprintf("data%d", i%100), "read", nil, nil, nil}
+	}
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		m.ClearPolicy()
+		mock.ExpectQuery("SELECT").WillReturnRows(pgxmock.NewRows(cols).AddRows(values...))
+		b.StartTimer()
+		require.NoError(b, a.LoadPolicy(m))
+	}
+}
+
+func TestMockUpdatePoliciesLengths(t *testing.T) {
+	// no statement is expected, a transaction would fail the test
+	a, _ := newMockAdapter(t)
+	rules := [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}
+
+	err := a.UpdatePolicies("p", "p", rules, rules[:1])
+	require.ErrorContains(t, err, "2 old rules and 1 new rules given")
+	err = a.UpdatePolicies("p", "p", rules[:1], rules)
+	require.ErrorContains(t, err, "1 old rules and 2 new rules given")
+	require.NoError(t, a.UpdatePolicies("p", "p", nil, [][]string{}))
+}
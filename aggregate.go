@@ -0,0 +1,119 @@
+package pgxadapter
+
+import (
+	"context"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// AggregateAdapter loads the union of the rules of several adapters, e.g. of an old and a new rules table during
+// a migration, while its writes go to the primary adapter only. A rule stored by several adapters is loaded once,
+// from the first adapter storing a row with its id: the primary, then the secondaries in order,
+// so a row of the primary wins over a conflicting row of a secondary having the same id.
+//
+// LoadPolicy and LoadFilteredPolicy read every adapter, the other methods are the ones of the primary,
+// e.g. CountRules and ForEachRule only read the primary. The secondaries are only read, with their own
+// table and options, the limits of WithMaxLoadRules and WithFilterThreshold are not applied to the union.
+type AggregateAdapter struct {
+	*Adapter
+	secondaries []*Adapter
+}
+
+// NewAggregateAdapter returns an adapter loading the rules of primary and secondaries and writing to primary.
+// Closing the AggregateAdapter closes primary, the secondaries must be closed by the caller.
+func NewAggregateAdapter(primary *Adapter, secondaries ...*Adapter) *AggregateAdapter {
+	return &AggregateAdapter{Adapter: primary, secondaries: secondaries}
+}
+
+// LoadPolicy loads the rules of every adapter
+func (g *AggregateAdapter) LoadPolicy(model model.Model) error {
+	seen := map[string]bool{}
+	for _, a := range g.sources() {
+		err := a.loadSource("LoadPolicy", seen, model, func(ctx context.Context) ([]string, [][]any, error) {
+			sql, args := a.loadQuery(ctx)
+			return []string{sql}, [][]any{args}, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	g.setFiltered(false)
+	return nil
+}
+
+// LoadFilteredPolicy loads the rules of every adapter matching filter, which takes the types accepted by Adapter.LoadFilteredPolicy
+func (g *AggregateAdapter) LoadFilteredPolicy(model model.Model, filter any) error {
+	if filter == nil {
+		return g.LoadPolicy(model)
+	}
+	filters, err := parseFilter(filter)
+	if err != nil {
+		return &OpError{Op: "LoadFilteredPolicy", Table: g.TableName(), Err: err}
+	}
+
+	seen := map[string]bool{}
+	for _, a := range g.sources() {
+		err := a.loadSource("LoadFilteredPolicy", seen, model, func(ctx context.Context) ([]string, [][]any, error) {
+			return a.filterQueries(ctx, filters)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	g.setFiltered(true)
+	return nil
+}
+
+// sources returns the primary and the secondaries, in the order their rules win
+func (g *AggregateAdapter) sources() []*Adapter {
+	return append([]*Adapter{g.Adapter}, g.secondaries...)
+}
+
+// loadSource runs the operation op of an adapter of an AggregateAdapter,
+// loading the rules of the queries returned by queries which are not in seen
+func (a *Adapter) loadSource(op string, seen map[string]bool, model model.Model, queries func(ctx context.Context) ([]string, [][]any, error)) (err error) {
+	ctx, finish, err := a.start(context.Background(), OpInfo{Op: op})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	sqls, args, err := queries(ctx)
+	if err != nil {
+		return err
+	}
+	for i, sql := range sqls {
+		if err := a.loadUnseen(ctx, sql, args[i], seen, model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadUnseen loads into model the rules returned by the query whose id is not in seen, and adds their ids to seen
+func (a *Adapter) loadUnseen(ctx context.Context, sql string, args []any, seen map[string]bool, model model.Model) error {
+	rows, err := a.db.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var n int64
+	defer func() { a.addLoaded(ctx, n) }()
+	scanner := a.newRuleScanner()
+	for rows.Next() {
+		line, err := scanner.scan(rows)
+		if err != nil {
+			return err
+		}
+		if seen[line.ID] {
+			continue
+		}
+		seen[line.ID] = true
+		if err := a.loadRule(line, model); err != nil {
+			return err
+		}
+		n++
+	}
+	return rows.Err()
+}
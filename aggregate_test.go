@@ -0,0 +1,99 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestAggregateAdapter() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+	pool.Exec(ctx, `DROP TABLE IF EXISTS rules_aggregate_new, rules_aggregate_old`)
+	defer pool.Exec(ctx, `DROP TABLE IF EXISTS rules_aggregate_new, rules_aggregate_old`)
+
+	primary, err := NewAdapterByDB(pool, WithTableName("rules_aggregate_new"))
+	s.Require().NoError(err)
+	secondary, err := NewAdapterByDB(pool, WithTableName("rules_aggregate_old"))
+	s.Require().NoError(err)
+	s.Require().NoError(primary.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+	s.Require().NoError(secondary.AddPolicy("p", "p", []string{"bob", "data2", "write"}))
+	s.Require().NoError(secondary.AddPolicy("g", "g", []string{"bob", "admin"}))
+	// a row of the secondary with the id of a rule of the primary but other values
+	_, err = pool.Exec(ctx, `INSERT INTO rules_aggregate_old (id, ptype, v0, v1, v2) VALUES ($1, 'p', 'mallory', 'data1', 'read')`,
+		ComputePolicyID("p", []string{"alice", "data1", "read"}))
+	s.Require().NoError(err)
+
+	g := NewAggregateAdapter(primary, secondary)
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", g)
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}, e.GetPolicy())
+	s.assertPolicy([][]string{{"bob", "admin"}}, e.GetGroupingPolicy())
+
+	s.Require().NoError(e.LoadFilteredPolicy(&Filter{P: []string{"", "data2"}}))
+	s.Assert().True(e.IsFiltered())
+	s.assertPolicy([][]string{{"bob", "data2", "write"}}, e.GetPolicy())
+
+	// the writes only change the primary
+	s.Require().NoError(e.LoadPolicy())
+	_, err = e.AddPolicy("carol", "data3", "read")
+	s.Require().NoError(err)
+	_, err = e.RemovePolicy("bob", "data2", "write")
+	s.Require().NoError(err)
+	_, err = e.RemoveFilteredPolicy(0, "alice")
+	s.Require().NoError(err)
+	count, err := secondary.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Assert().EqualValues(3, count)
+	count, err = primary.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Assert().EqualValues(1, count)
+	s.Require().NoError(e.LoadPolicy())
+	s.assertPolicy([][]string{{"mallory", "data1", "read"}, {"bob", "data2", "write"}, {"carol", "data3", "read"}}, e.GetPolicy())
+}
+
+func TestMockAggregateAdapter(t *testing.T) {
+	primary, mock := newMockAdapter(t)
+	secondary, secondaryMock := newMockAdapter(t, WithTableName("rules_old"))
+	g := NewAggregateAdapter(primary, secondary)
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+	secondaryMock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "rules_old"`)).
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("1", "p", "mallory", "data1", "read", "", "", "").
+			AddRow("2", "p", "bob", "data2", "write", "", "", "").
+			AddRow("2", "p", "bob", "data2", "write", "", "", ""))
+	require.NoError(t, g.LoadPolicy(m))
+	require.Equal(t, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}, m["p"]["p"].Policy)
+	require.False(t, g.IsFiltered())
+
+	m.ClearPolicy()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v1 = $2`)).
+		WithArgs("p", "data2").
+		WillReturnRows(pgxmock.NewRows(cols))
+	secondaryMock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "rules_old" WHERE ptype=$1 AND v1 = $2`)).
+		WithArgs("p", "data2").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("2", "p", "bob", "data2", "write", "", "", ""))
+	require.NoError(t, g.LoadFilteredPolicy(m, &Filter{P: []string{"", "data2"}}))
+	require.Equal(t, [][]string{{"bob", "data2", "write"}}, m["p"]["p"].Policy)
+	require.True(t, g.IsFiltered())
+
+	// the writes go to the primary only, the secondary mock expects nothing
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, g.AddPolicy("p", "p", []string{"carol", "data3", "read"}))
+}
@@ -0,0 +1,128 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultMaxBatchSize is the number of rules written per statement by AddPolicies and RemovePolicies
+// unless WithMaxBatchSize is given
+const DefaultMaxBatchSize = 1000
+
+// maxParams is the number of parameters postgres accepts in a statement
+const maxParams = 65535
+
+// WithMaxBatchSize sets the number of rules AddPolicies and RemovePolicies write per statement,
+// larger batches are split in chunks of at most n rules. Chunks inserting rules are also limited
+// by the number of parameters postgres accepts in a statement.
+// The chunks are written in a single transaction unless WithTransactionPerChunk is used.
+func WithMaxBatchSize(n int) Option {
+	return func(a *Adapter) {
+		a.maxBatchSize = n
+	}
+}
+
+// WithTransactionPerChunk makes AddPolicies and RemovePolicies commit each chunk of WithMaxBatchSize
+// in its own transaction, so large batches don't produce a single large transaction.
+// A batch failing on a chunk keeps the changes of the chunks already committed, the error tells how many rules they hold.
+func WithTransactionPerChunk() Option {
+	return func(a *Adapter) {
+		a.txPerChunk = true
+	}
+}
+
+// batchSize returns the number of rules per chunk of the batch methods
+func (a *Adapter) batchSize() int {
+	n := a.maxBatchSize
+	if limit := maxParams / a.rowParams(); n > limit {
+		n = limit
+	}
+	return n
+}
+
+// insertRowsSQL returns the statement inserting n rules
+func (a *Adapter) insertRowsSQL(ctx context.Context, n int) string {
+	cols := a.rowParams()
+	rows := make([]string, 0, n)
+	params := make([]string, 0, cols)
+	for r := 0; r < n; r++ {
+		params = params[:0]
+		for i := 1; i <= cols; i++ {
+			params = append(params, fmt.Sprintf("$%d", r*cols+i))
+		}
+		rows = append(rows, "("+strings.Join(params, ", ")+")")
+	}
+	return fmt.Sprintf(`INSERT INTO "%v" (%v) VALUES%v`, a.table(ctx), a.insertColumns(), strings.Join(rows, ", "))
+}
+
+// chunkError adds the rules of the chunk a statement failed for to err
+func chunkError(chunk []*CasbinRule, err error) error {
+	if len(chunk) == 1 {
+		return ruleError(chunk[0], err)
+	}
+	return fmt.Errorf("rules %q to %q: %w", chunk[0].String(), chunk[len(chunk)-1].String(), err)
+}
+
+// writeChunks calls write with the lines split in chunks of batchSize lines,
+// in a single transaction or in a transaction per chunk with WithTransactionPerChunk
+func (a *Adapter) writeChunks(ctx context.Context, lines []*CasbinRule, write func(tx pgx.Tx, chunk []*CasbinRule) error) error {
+	size := a.batchSize()
+	if !a.txPerChunk {
+		tx, err := a.conn(ctx).Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		for start := 0; start < len(lines); start += size {
+			chunk := lines[start:min(start+size, len(lines))]
+			if err := write(tx, chunk); err != nil {
+				return chunkError(chunk, err)
+			}
+		}
+		return tx.Commit(ctx)
+	}
+
+	for start := 0; start < len(lines); start += size {
+		chunk := lines[start:min(start+size, len(lines))]
+		err := func() error {
+			tx, err := a.conn(ctx).Begin(ctx)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback(ctx)
+			if err := write(tx, chunk); err != nil {
+				return chunkError(chunk, err)
+			}
+			return tx.Commit(ctx)
+		}()
+		if err != nil {
+			return fmt.Errorf("%d of %d rules committed: %w", start, len(lines), err)
+		}
+	}
+	return nil
+}
+
+// insertChunk inserts the lines with a single statement, ignoring the rules already stored,
+// and returns the number of rules inserted
+func (a *Adapter) insertChunk(ctx context.Context, tx pgx.Tx, chunk []*CasbinRule) (int64, error) {
+	args := make([]any, 0, len(chunk)*a.rowParams())
+	for _, line := range chunk {
+		args = append(args, a.insertArgs(ctx, line)...)
+	}
+	tag, err := tx.Exec(ctx, a.insertRowsSQL(ctx, len(chunk))+" ON CONFLICT DO NOTHING", args...)
+	return tag.RowsAffected(), err
+}
+
+// deleteChunk deletes the lines with a single statement
+func (a *Adapter) deleteChunk(ctx context.Context, tx pgx.Tx, chunk []*CasbinRule) error {
+	ids := make([]string, 0, len(chunk))
+	for _, line := range chunk {
+		ids = append(ids, line.ID)
+	}
+	sql, args := a.byID(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE id = ANY($1)`, a.table(ctx)), []any{ids})
+	_, err := tx.Exec(ctx, sql, args...)
+	return err
+}
@@ -0,0 +1,116 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestMaxBatchSize() {
+	rules := make([][]string, 25)
+	for i := range rules {
+		rules[i] = []string{fmt.Sprintf("user%d", i), "data", "read"}
+	}
+	for _, opts := range [][]Option{{WithMaxBatchSize(10)}, {WithMaxBatchSize(10), WithTransactionPerChunk()}} {
+		a, err := NewAdapterByDB(s.a.db.(*pgxpool.Pool), append(opts, SkipTableCreate())...)
+		s.Require().NoError(err)
+
+		s.Require().NoError(a.AddPolicies("p", "p", rules))
+		count, err := a.CountRules(context.Background(), "p")
+		s.Require().NoError(err)
+		s.Require().EqualValues(4+len(rules), count)
+
+		s.Require().NoError(a.RemovePolicies("p", "p", rules))
+		count, err = a.CountRules(context.Background(), "p")
+		s.Require().NoError(err)
+		s.Require().EqualValues(4, count)
+	}
+}
+
+func testRules(n int) [][]string {
+	rules := make([][]string, n)
+	for i := range rules {
+		rules[i] = []string{fmt.Sprintf("user%d", i), "data", "read"}
+	}
+	return rules
+}
+
+func insertArgs(rules ...[]string) []any {
+	var args []any
+	for _, rule := range rules {
+		args = append(args, policyID("p", rule), "p", rule[0], rule[1], rule[2], "", "", "")
+	}
+	return args
+}
+
+func TestMockMaxBatchSize(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			a, mock := newMockAdapter(t, WithMaxBatchSize(2))
+			rules := testRules(n)
+
+			mock.ExpectBegin()
+			for start := 0; start < n; start += 2 {
+				chunk := rules[start:min(start+2, n)]
+				mock.ExpectExec(regexp.QuoteMeta(a.insertRowsSQL(context.Background(), len(chunk)) + " ON CONFLICT DO NOTHING")).
+					WithArgs(insertArgs(chunk...)...).
+					WillReturnResult(pgxmock.NewResult("INSERT", int64(len(chunk))))
+			}
+			mock.ExpectCommit()
+			require.NoError(t, a.AddPolicies("p", "p", rules))
+
+			mock.ExpectBegin()
+			for start := 0; start < n; start += 2 {
+				var ids []string
+				for _, rule := range rules[start:min(start+2, n)] {
+					ids = append(ids, policyID("p", rule))
+				}
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id = ANY($1)`)).
+					WithArgs(ids).
+					WillReturnResult(pgxmock.NewResult("DELETE", int64(len(ids))))
+			}
+			mock.ExpectCommit()
+			require.NoError(t, a.RemovePolicies("p", "p", rules))
+		})
+	}
+}
+
+func TestMockTransactionPerChunk(t *testing.T) {
+	a, mock := newMockAdapter(t, WithMaxBatchSize(2), WithTransactionPerChunk())
+	rules := testRules(5)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(insertArgs(rules[0:2]...)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(insertArgs(rules[2:4]...)...).
+		WillReturnError(errors.New("disk full"))
+	mock.ExpectRollback()
+
+	err := a.AddPolicies("p", "p", rules)
+	require.EqualError(t, err, `pgadapter.AddPolicies: table "casbin_rules": 2 of 5 rules committed: rules "p, user2, data, read" to "p, user3, data, read": disk full`)
+}
+
+func TestBatchSize(t *testing.T) {
+	a, err := newAdapter([]Option{WithMaxBatchSize(100000), WithValueColumns(MaxValueColumns)})
+	require.NoError(t, err)
+	require.Equal(t, maxParams/(MaxValueColumns+2), a.batchSize())
+	require.LessOrEqual(t, a.batchSize()*(MaxValueColumns+2), maxParams)
+
+	a, err = newAdapter([]Option{WithValueColumns(1)})
+	require.NoError(t, err)
+	require.Equal(t, DefaultMaxBatchSize, a.batchSize())
+	require.Equal(t, `INSERT INTO "casbin_rules" (id, ptype, v0) VALUES($1, $2, $3), ($4, $5, $6)`, a.insertRowsSQL(context.Background(), 2))
+
+	_, err = newAdapter([]Option{WithMaxBatchSize(0)})
+	require.Error(t, err)
+}
@@ -0,0 +1,105 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// bestEffortSavepoint is the savepoint each rule of AddPoliciesBestEffort is inserted in
+const bestEffortSavepoint = "pgadapter_rule"
+
+// FailedRule is a rule not stored by AddPoliciesBestEffort
+type FailedRule struct {
+	Rule []string
+	Err  error
+}
+
+// AddPoliciesBestEffort adds the rules of ptype like AddPolicies, but a rule failing to be stored, e.g. one rejected
+// by the validator of the adapter or violating a constraint of the rules table, doesn't abort the others: each rule
+// is inserted in its own savepoint, the savepoint of a failing rule is rolled back and the next rules are inserted.
+// The rules not stored are returned with their errors, the others are committed in a single transaction.
+// err is only set when the transaction itself fails, then no rule is stored.
+//
+// With WithRowQuota, the rules beyond the quota are returned with a *QuotaExceededError.
+// AddPoliciesBestEffort runs a statement per rule, AddPolicies is faster for batches expected to succeed.
+func (a *Adapter) AddPoliciesBestEffort(ctx context.Context, ptype string, rules [][]string) (failed []FailedRule, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "AddPoliciesBestEffort", Ptype: ptype, Rules: len(rules)})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	if ptype == "" {
+		return nil, fmt.Errorf("%w: empty ptype", ErrInvalidFilter)
+	}
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		failed = nil
+		sec := ptype[:1]
+		normalized := a.normalization.normalizeRules(rules)
+		lines := make([]*CasbinRule, 0, len(normalized))
+		// given maps the lines to their rules as given, before the normalization
+		given := make(map[*CasbinRule][]string, len(normalized))
+		for i, rule := range normalized {
+			if err := a.validateRule(sec, ptype, rule); err != nil {
+				failed = append(failed, FailedRule{Rule: rules[i], Err: err})
+				continue
+			}
+			line := a.policyLine(ctx, ptype, rule)
+			lines = append(lines, line)
+			given[line] = rules[i]
+		}
+
+		tx, err := a.conn(ctx).Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		admitted, quotaErr, err := a.admitLines(ctx, tx, lines)
+		if err != nil {
+			return err
+		}
+		if quotaErr != nil {
+			in := make(map[*CasbinRule]bool, len(admitted))
+			for _, line := range admitted {
+				in[line] = true
+			}
+			for _, line := range lines {
+				if !in[line] {
+					failed = append(failed, FailedRule{Rule: given[line], Err: quotaErr})
+				}
+			}
+		}
+		for _, line := range admitted {
+			ruleErr, err := a.insertInSavepoint(ctx, tx, line)
+			if err != nil {
+				return err
+			}
+			if ruleErr != nil {
+				failed = append(failed, FailedRule{Rule: given[line], Err: ruleErr})
+			}
+		}
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return failed, nil
+}
+
+// insertInSavepoint inserts line in a savepoint of tx, rolled back if the insert fails.
+// ruleErr is the error of the insert, err the error of the savepoint statements which leaves tx unusable.
+func (a *Adapter) insertInSavepoint(ctx context.Context, tx pgx.Tx, line *CasbinRule) (ruleErr, err error) {
+	if _, err := tx.Exec(ctx, "SAVEPOINT "+bestEffortSavepoint); err != nil {
+		return nil, err
+	}
+	if _, ruleErr = tx.Exec(ctx, a.insertSQL(ctx)+" ON CONFLICT DO NOTHING", a.insertArgs(ctx, line)...); ruleErr != nil {
+		if _, err := tx.Exec(ctx, "ROLLBACK TO SAVEPOINT "+bestEffortSavepoint); err != nil {
+			return nil, err
+		}
+	}
+	_, err = tx.Exec(ctx, "RELEASE SAVEPOINT "+bestEffortSavepoint)
+	return ruleErr, err
+}
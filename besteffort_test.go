@@ -0,0 +1,76 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestAddPoliciesBestEffort() {
+	ctx := context.Background()
+	pool := s.a.db.(*pgxpool.Pool)
+	a, err := NewAdapterByDB(pool, WithTableName("rules_best_effort_test"), WithRuleValidator(func(sec, ptype string, rule []string) error {
+		if rule[0] == "" {
+			return errors.New("empty subject")
+		}
+		return nil
+	}))
+	s.Require().NoError(err)
+	defer pool.Exec(ctx, `DROP TABLE IF EXISTS "rules_best_effort_test"`)
+	_, err = pool.Exec(ctx, `ALTER TABLE "rules_best_effort_test" ADD CONSTRAINT no_bad_object CHECK (v1 <> 'bad')`)
+	s.Require().NoError(err)
+
+	failed, err := a.AddPoliciesBestEffort(ctx, "p", [][]string{
+		{"alice", "data1", "read"},
+		{"bob", "bad", "read"},
+		{"", "data1", "read"},
+		{"carol", "data2", "write"},
+	})
+	s.Require().NoError(err)
+	s.Require().Len(failed, 2)
+	s.Assert().Equal([]string{"", "data1", "read"}, failed[0].Rule)
+	s.Assert().ErrorContains(failed[0].Err, "empty subject")
+	s.Assert().Equal([]string{"bob", "bad", "read"}, failed[1].Rule)
+	s.Assert().True(isPgError(failed[1].Err, "23514"), failed[1].Err)
+
+	count, err := a.CountRules(ctx, "p")
+	s.Require().NoError(err)
+	s.Assert().EqualValues(2, count)
+}
+
+func TestMockAddPoliciesBestEffort(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	rules := testRules(3)
+	failure := errors.New("check violation")
+
+	mock.ExpectBegin()
+	for i, rule := range rules {
+		mock.ExpectExec("SAVEPOINT pgadapter_rule").WillReturnResult(pgxmock.NewResult("SAVEPOINT", 0))
+		insert := mock.ExpectExec(regexp.QuoteMeta(a.insertSQL(context.Background()) + " ON CONFLICT DO NOTHING")).
+			WithArgs(insertArgs(rule)...)
+		if i == 1 {
+			insert.WillReturnError(failure)
+			mock.ExpectExec("ROLLBACK TO SAVEPOINT pgadapter_rule").WillReturnResult(pgxmock.NewResult("ROLLBACK", 0))
+		} else {
+			insert.WillReturnResult(pgxmock.NewResult("INSERT", 1))
+		}
+		mock.ExpectExec("RELEASE SAVEPOINT pgadapter_rule").WillReturnResult(pgxmock.NewResult("RELEASE", 0))
+	}
+	mock.ExpectCommit()
+	failed, err := a.AddPoliciesBestEffort(context.Background(), "p", rules)
+	require.NoError(t, err)
+	require.Equal(t, []FailedRule{{Rule: rules[1], Err: failure}}, failed)
+
+	// a failing savepoint aborts the transaction
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT pgadapter_rule").WillReturnError(failure)
+	mock.ExpectRollback()
+	failed, err = a.AddPoliciesBestEffort(context.Background(), "p", rules)
+	require.ErrorIs(t, err, failure)
+	require.Empty(t, failed)
+}
@@ -0,0 +1,118 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Postgres error codes returned when several processes create the database or the rules table at the same time
+const (
+	codeDuplicateDatabase = "42P04"
+	codeDuplicateTable    = "42P07"
+	codeDuplicateColumn   = "42701"
+	codeUniqueViolation   = "23505"
+	codeInternalError     = "XX000"
+)
+
+// bootstrapRetries is how many times a statement creating the database or the table is attempted
+const bootstrapRetries = 3
+
+// bootstrapRetryDelay is the delay before the first retry, it doubles on every attempt
+var bootstrapRetryDelay = 50 * time.Millisecond
+
+// WithBootstrapLock serializes the table creation done when the adapter starts
+// with an advisory lock, so replicas starting together against a fresh database don't race on the DDL.
+// Without it concurrent creations are retried when they fail because of each other.
+func WithBootstrapLock() Option {
+	return func(a *Adapter) {
+		a.bootstrapLock = true
+	}
+}
+
+// isPgError reports whether err is a postgres error with one of the given codes
+func isPgError(err error, codes ...string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	for _, code := range codes {
+		if pgErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// isBootstrapRace reports whether err may be caused by another process running the same DDL concurrently,
+// e.g. a unique violation on the system catalogs or "tuple concurrently updated"
+func isBootstrapRace(err error) bool {
+	return isPgError(err, codeUniqueViolation, codeInternalError, codeDuplicateColumn)
+}
+
+// retryBootstrap runs fn until it succeeds, fails with an error not caused by a concurrent bootstrap
+// or bootstrapRetries attempts were made
+func retryBootstrap(ctx context.Context, fn func() error) error {
+	delay := bootstrapRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == bootstrapRetries || !isBootstrapRace(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// EnsureTable creates the rules table, and the columns, tables and triggers of the options given to the adapter,
+// if they don't exist, like the adapter does when it starts without SkipTableCreate, e.g. once the migrations
+// of an application created the schema of the table. It can be called several times and concurrently,
+// by this process or others: the creation holds the advisory lock used by Migrate and WithBootstrapLock.
+// With WithCreateTableHook, the table is then verified like VerifySchema, unless SkipSchemaVerification is used.
+func (a *Adapter) EnsureTable(ctx context.Context) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "EnsureTable"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	err = retryBootstrap(ctx, func() error {
+		if a.temporary {
+			return a.createTable(ctx, a.db)
+		}
+		return a.createTableLocked(ctx)
+	})
+	if err != nil || a.createTableHook == nil || a.skipSchemaVerify {
+		return err
+	}
+	// the table created by the hook must still have the columns of the adapter
+	for _, name := range a.relations(ctx) {
+		if err := a.verifyRelation(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTableLocked creates the rules table in a transaction holding the advisory lock used by Migrate
+func (a *Adapter) createTableLocked(ctx context.Context) error {
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, advisoryLockKey(SchemaVersionTable)); err != nil {
+		return err
+	}
+	if err := a.createTable(ctx, tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
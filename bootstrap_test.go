@@ -0,0 +1,192 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestConcurrentBootstrap() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+	pool.Exec(ctx, "DROP DATABASE IF EXISTS casbin_bootstrap")
+	defer pool.Exec(ctx, "DROP DATABASE IF EXISTS casbin_bootstrap")
+
+	const n = 10
+	adapters := make([]*Adapter, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			adapters[i], errs[i] = NewAdapter(os.Getenv("PG_CONN"), "casbin_bootstrap")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		s.Require().NoError(errs[i])
+		s.Require().NoError(adapters[i].Close())
+	}
+}
+
+func withFastBootstrapRetry(t *testing.T) {
+	delay := bootstrapRetryDelay
+	bootstrapRetryDelay = time.Millisecond
+	t.Cleanup(func() { bootstrapRetryDelay = delay })
+}
+
+func TestMockCreateTableRetry(t *testing.T) {
+	withFastBootstrapRetry(t)
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Code: codeUniqueViolation})
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Code: codeInternalError, Message: "tuple concurrently updated"})
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+
+	_, err = NewAdapterByPgxPool(mock, SkipSchemaVerification())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMockCreateTableRetryExhausted(t *testing.T) {
+	withFastBootstrapRetry(t)
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+
+	for i := 0; i < bootstrapRetries; i++ {
+		mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+			WillReturnError(&pgconn.PgError{Code: codeUniqueViolation})
+	}
+
+	_, err = NewAdapterByPgxPool(mock, SkipSchemaVerification())
+	require.Error(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMockCreateTableDuplicate(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Code: codeDuplicateTable})
+
+	_, err = NewAdapterByPgxPool(mock, SkipSchemaVerification())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMockCreateTableNoRetry(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Code: "42501", Message: "permission denied for schema public"})
+
+	_, err = NewAdapterByPgxPool(mock, SkipSchemaVerification())
+	require.ErrorContains(t, err, "permission denied")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMockBootstrapLock(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).
+		WithArgs(advisoryLockKey(SchemaVersionTable)).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectCommit()
+
+	_, err = NewAdapterByPgxPool(mock, WithBootstrapLock(), SkipSchemaVerification())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func (s *AdapterTestSuite) TestEnsureTable() {
+	ctx := context.Background()
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("casbin_rules_ensure"), SkipTableCreate(),
+		SkipSchemaVerification(), WithNamespace("tenant1"), WithRevisions())
+	s.Require().NoError(err)
+	defer a.Close()
+	defer a.db.Exec(ctx, `DROP TABLE IF EXISTS "casbin_rules_ensure", "casbin_rules_ensure_revision"`)
+	s.Require().ErrorIs(a.AddPolicy("p", "p", []string{"alice", "data1", "read"}), ErrTableNotExist)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = a.EnsureTable(ctx)
+		}(i)
+	}
+	wg.Wait()
+	s.Require().NoError(errs[0])
+	s.Require().NoError(errs[1])
+	s.Require().NoError(a.EnsureTable(ctx))
+
+	s.Require().NoError(a.VerifySchema(ctx))
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+	revision, err := a.Revision(ctx)
+	s.Require().NoError(err)
+	s.Require().Positive(revision)
+}
+
+func TestMockEnsureTable(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).
+			WithArgs(advisoryLockKey(SchemaVersionTable)).
+			WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+			WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+		mock.ExpectCommit()
+	}
+	require.NoError(t, a.EnsureTable(ctx))
+	require.NoError(t, a.EnsureTable(ctx))
+
+	// a concurrent creation by another process is retried
+	withFastBootstrapRetry(t)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Code: codeUniqueViolation})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectCommit()
+	require.NoError(t, a.EnsureTable(ctx))
+}
+
+func TestIsPgError(t *testing.T) {
+	err := &pgconn.PgError{Code: codeDuplicateDatabase}
+	require.True(t, isPgError(err, codeDuplicateDatabase))
+	require.True(t, isPgError(fmt.Errorf("wrapped: %w", err), codeDuplicateTable, codeDuplicateDatabase))
+	require.False(t, isPgError(err, codeDuplicateTable))
+	require.False(t, isPgError(errors.New("ERROR: database exists (SQLSTATE 42P04)"), codeDuplicateDatabase))
+}
@@ -0,0 +1,287 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// selectRules returns copies of the rules of ptype stored in the rules table, locked until tx ends
+func (a *Adapter) selectRules(ctx context.Context, tx pgx.Tx, ptype string) ([]*CasbinRule, error) {
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v" WHERE ptype = $1`, a.columns(), a.table(ctx)), []any{ptype}, false)
+	return a.queryRules(ctx, tx, sql+" FOR UPDATE", args...)
+}
+
+// queryRules returns copies of the rules selected by the query
+func (a *Adapter) queryRules(ctx context.Context, tx pgx.Tx, sql string, args ...any) ([]*CasbinRule, error) {
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []*CasbinRule
+	scanner := a.newRuleScanner()
+	for rows.Next() {
+		line, err := scanner.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		copied := *line
+		copied.Extra = append([]string(nil), line.Extra...)
+		lines = append(lines, &copied)
+	}
+	return lines, rows.Err()
+}
+
+// CopyPtype stores a copy of every rule of ptype from as a rule of ptype to, e.g. after renaming a policy type in the model,
+// and deletes the rules of from if deleteSource is true. The ids of the copies are computed for their new ptype.
+// CopyPtype returns the number of rules copied, it runs in a single transaction.
+//
+// Unless force is true, CopyPtype fails with ErrPolicyAlreadyExists when rules of ptype to are stored,
+// with force the copies of rules already stored are skipped.
+func (a *Adapter) CopyPtype(ctx context.Context, from, to string, deleteSource, force bool) (_ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "CopyPtype", Ptype: from})
+	if err != nil {
+		return 0, err
+	}
+	defer finish(&err)
+
+	if from == to {
+		return 0, fmt.Errorf("cannot copy ptype %q to itself", from)
+	}
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	if !force {
+		var exists bool
+		sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT 1 FROM "%v" WHERE ptype = $1`, a.table(ctx)), []any{to}, false)
+		err := tx.QueryRow(ctx, `SELECT EXISTS (`+sql+`)`, args...).Scan(&exists)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			return 0, fmt.Errorf("%w: ptype %q already has rules", ErrPolicyAlreadyExists, to)
+		}
+	}
+
+	sources, err := a.selectRules(ctx, tx, from)
+	if err != nil {
+		return 0, err
+	}
+	copies := make([]*CasbinRule, 0, len(sources))
+	for _, line := range sources {
+		copies = append(copies, a.policyLine(ctx, to, line.rule(a.valueColumns)))
+	}
+
+	var copied int64
+	size := a.batchSize()
+	for start := 0; start < len(copies); start += size {
+		chunk := copies[start:min(start+size, len(copies))]
+		n, err := a.insertChunk(ctx, tx, chunk)
+		if err != nil {
+			return 0, chunkError(chunk, err)
+		}
+		copied += n
+	}
+	if deleteSource {
+		for start := 0; start < len(sources); start += size {
+			chunk := sources[start:min(start+size, len(sources))]
+			if err := a.deleteChunk(ctx, tx, chunk); err != nil {
+				return 0, chunkError(chunk, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return copied, nil
+}
+
+// RenameConflict tells RenameFieldValue what to do with a rule whose renamed copy is already stored
+type RenameConflict int
+
+const (
+	// RenameSkip leaves the rule unchanged
+	RenameSkip RenameConflict = iota
+	// RenameMerge deletes the rule, the stored copy stands for both
+	RenameMerge
+)
+
+// RenameFieldValue replaces oldValue with newValue in the value columns of the stored rules, e.g. after renaming a user,
+// and recomputes the ids of the renamed rules. fields maps the ptypes to rename to the indexes of their values to check:
+//
+//	// alice is the subject of the p rules and the user or role of the g rules
+//	a.RenameFieldValue(ctx, "alice", "alice.smith", map[string][]int{"p": {0}, "g": {0, 1}}, RenameSkip)
+//
+// A nil or empty fields renames every value of every rule. Rules whose renamed copy is already stored,
+// or would be stored twice, are handled as set by onConflict.
+// RenameFieldValue returns the number of rules renamed or merged, it runs in a single transaction.
+func (a *Adapter) RenameFieldValue(ctx context.Context, oldValue, newValue string, fields map[string][]int, onConflict RenameConflict) (_ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RenameFieldValue"})
+	if err != nil {
+		return 0, err
+	}
+	defer finish(&err)
+
+	if oldValue == "" || oldValue == newValue {
+		return 0, fmt.Errorf("%w: cannot rename %q to %q", ErrInvalidFilter, oldValue, newValue)
+	}
+	all := make([]int, a.valueColumns)
+	for i := range all {
+		all[i] = i
+	}
+	for ptype, indexes := range fields {
+		for _, i := range indexes {
+			if i < 0 || i >= a.valueColumns {
+				return 0, fmt.Errorf("%w: field index %d of ptype %q is out of the %d value columns", ErrInvalidFilter, i, ptype, a.valueColumns)
+			}
+		}
+	}
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// the rules to rename with the indexes of their values to check
+	type match struct {
+		line    *CasbinRule
+		indexes []int
+	}
+	var matches []match
+	if len(fields) == 0 {
+		lines, err := a.selectValue(ctx, tx, "", oldValue, all)
+		if err != nil {
+			return 0, err
+		}
+		for _, line := range lines {
+			matches = append(matches, match{line, all})
+		}
+	}
+	ptypes := make([]string, 0, len(fields))
+	for ptype := range fields {
+		ptypes = append(ptypes, ptype)
+	}
+	sort.Strings(ptypes)
+	for _, ptype := range ptypes {
+		indexes := fields[ptype]
+		if len(indexes) == 0 {
+			continue
+		}
+		lines, err := a.selectValue(ctx, tx, ptype, oldValue, indexes)
+		if err != nil {
+			return 0, err
+		}
+		for _, line := range lines {
+			matches = append(matches, match{line, indexes})
+		}
+	}
+	if len(matches) == 0 {
+		return 0, tx.Commit(ctx)
+	}
+
+	renamed := make([]*CasbinRule, 0, len(matches))
+	ids := make([]string, 0, len(matches))
+	for _, m := range matches {
+		rule := m.line.values(a.valueColumns)
+		for _, i := range m.indexes {
+			if rule[i] == oldValue {
+				rule[i] = newValue
+			}
+		}
+		line := a.policyLine(ctx, m.line.Ptype, trimRule(rule))
+		renamed = append(renamed, line)
+		ids = append(ids, line.ID)
+	}
+	stored, err := a.storedIDs(ctx, tx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	var deletes, inserts []*CasbinRule
+	for i, m := range matches {
+		line := renamed[i]
+		if stored[line.ID] {
+			if onConflict == RenameSkip {
+				continue
+			}
+		} else {
+			stored[line.ID] = true
+			inserts = append(inserts, line)
+		}
+		deletes = append(deletes, m.line)
+	}
+
+	size := a.batchSize()
+	for start := 0; start < len(deletes); start += size {
+		chunk := deletes[start:min(start+size, len(deletes))]
+		if err := a.deleteChunk(ctx, tx, chunk); err != nil {
+			return 0, chunkError(chunk, err)
+		}
+	}
+	for start := 0; start < len(inserts); start += size {
+		chunk := inserts[start:min(start+size, len(inserts))]
+		if _, err := a.insertChunk(ctx, tx, chunk); err != nil {
+			return 0, chunkError(chunk, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return int64(len(deletes)), nil
+}
+
+// selectValue returns copies of the rules of ptype, or of every ptype if ptype is empty,
+// having value at one of the indexes, locked until tx ends
+func (a *Adapter) selectValue(ctx context.Context, tx pgx.Tx, ptype, value string, indexes []int) ([]*CasbinRule, error) {
+	conds := make([]string, 0, len(indexes))
+	var args []any
+	// value is given once in plain text and once encrypted when some of the indexes are encrypted, see WithEncryption
+	params := map[bool]int{}
+	for _, i := range indexes {
+		encrypted := a.encryption.encrypts(i)
+		if _, ok := params[encrypted]; !ok {
+			args = append(args, a.fieldArg(i, value))
+			params[encrypted] = len(args)
+		}
+		conds = append(conds, fmt.Sprintf("v%d = $%d", i, params[encrypted]))
+	}
+	sql := fmt.Sprintf(`SELECT %v FROM "%v" WHERE (%v)`, a.columns(), a.table(ctx), strings.Join(conds, " OR "))
+	if ptype != "" {
+		args = append(args, ptype)
+		sql += fmt.Sprintf(" AND ptype = $%d", len(args))
+	}
+	sql, args = a.inNamespace(ctx, sql, args, false)
+	return a.queryRules(ctx, tx, sql+" FOR UPDATE", args...)
+}
+
+// storedIDs returns the ids of ids stored in the rules table
+func (a *Adapter) storedIDs(ctx context.Context, tx pgx.Tx, ids []string) (map[string]bool, error) {
+	sql, args := a.byID(ctx, fmt.Sprintf(`SELECT id FROM "%v" WHERE id = ANY($1)`, a.table(ctx)), []any{ids})
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		stored[id] = true
+	}
+	return stored, rows.Err()
+}
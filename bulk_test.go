@@ -0,0 +1,211 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestCopyPtype() {
+	ctx := context.Background()
+	before, _, err := s.a.LoadPolicyPage(ctx, "p", 100, "")
+	s.Require().NoError(err)
+
+	n, err := s.a.CopyPtype(ctx, "p", "p2", false, false)
+	s.Require().NoError(err)
+	s.Require().EqualValues(len(before), n)
+
+	copies, _, err := s.a.LoadPolicyPage(ctx, "p2", 100, "")
+	s.Require().NoError(err)
+	s.Require().Len(copies, len(before))
+	ids := map[string]bool{}
+	for _, rule := range before {
+		ids[rule.ID] = true
+	}
+	for _, rule := range copies {
+		s.Assert().False(ids[rule.ID], rule.ID)
+		s.Assert().Equal(policyID("p2", rule.rule(DefaultValueColumns)), rule.ID)
+	}
+
+	_, err = s.a.CopyPtype(ctx, "p", "p2", false, false)
+	s.Require().ErrorIs(err, ErrPolicyAlreadyExists)
+	n, err = s.a.CopyPtype(ctx, "p", "p2", true, true)
+	s.Require().NoError(err)
+	s.Require().Zero(n)
+
+	count, err := s.a.CountRules(ctx, "p")
+	s.Require().NoError(err)
+	s.Require().Zero(count)
+	count, err = s.a.CountRules(ctx, "p2")
+	s.Require().NoError(err)
+	s.Require().EqualValues(len(before), count)
+}
+
+func TestMockCopyPtype(t *testing.T) {
+	a, mock := newMockAdapter(t, WithMaxBatchSize(1))
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM "casbin_rules" WHERE ptype = $1)`)).WithArgs("p2").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype = $1 FOR UPDATE`)).WithArgs("p").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("a", "p", "alice", "data1", "read", "", "", "").
+			AddRow("b", "p", "bob", "data2", "write", "", "", ""))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(insertArgsFor("p2", []string{"alice", "data1", "read"})...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(insertArgsFor("p2", []string{"bob", "data2", "write"})...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id = ANY($1)`)).WithArgs([]string{"a"}).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id = ANY($1)`)).WithArgs([]string{"b"}).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	n, err := a.CopyPtype(ctx, "p", "p2", true, false)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, n)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS`)).WithArgs("p2").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+	_, err = a.CopyPtype(ctx, "p", "p2", false, false)
+	require.ErrorIs(t, err, ErrPolicyAlreadyExists)
+	require.EqualError(t, err, `pgadapter.CopyPtype: table "casbin_rules": pgadapter: policy already exists: ptype "p2" already has rules`)
+
+	_, err = a.CopyPtype(ctx, "p", "p", false, true)
+	require.EqualError(t, err, `pgadapter.CopyPtype: table "casbin_rules": cannot copy ptype "p" to itself`)
+}
+
+func TestCasbinRuleRule(t *testing.T) {
+	line := savePolicyLine("p", []string{"alice", "", "read"})
+	require.Equal(t, []string{"alice", "", "read"}, line.rule(DefaultValueColumns))
+	require.Equal(t, []string{"alice"}, line.rule(2))
+	require.Empty(t, (&CasbinRule{}).rule(DefaultValueColumns))
+}
+
+// insertArgsFor returns the arguments inserting the rule with the default value columns
+func insertArgsFor(ptype string, rule []string) []any {
+	args := []any{policyID(ptype, rule), ptype}
+	for i := 0; i < DefaultValueColumns; i++ {
+		if i < len(rule) {
+			args = append(args, rule[i])
+		} else {
+			args = append(args, "")
+		}
+	}
+	return args
+}
+
+func (s *AdapterTestSuite) TestRenameFieldValue() {
+	ctx := context.Background()
+	s.Require().NoError(s.a.AddPolicies("g", "g", [][]string{{"bob", "data2_admin"}, {"carol", "alice"}}))
+	s.Require().NoError(s.a.AddPolicy("p", "p", []string{"bob", "data1", "read"}))
+
+	// alice's rules are renamed, her read rule on data1 collides with bob's one and is merged
+	n, err := s.a.RenameFieldValue(ctx, "alice", "bob", map[string][]int{"p": {0}, "g": {0, 1}}, RenameMerge)
+	s.Require().NoError(err)
+	s.Require().EqualValues(2, n)
+
+	s.e, err = casbin.NewEnforcer("examples/rbac_model.conf", s.a)
+	s.Require().NoError(err)
+	s.assertPolicy(
+		[][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}, {"bob", "data1", "read"}},
+		s.e.GetPolicy(),
+	)
+	s.assertPolicy([][]string{{"bob", "data2_admin"}, {"carol", "bob"}}, s.e.GetGroupingPolicy())
+
+	// the ids match the renamed rules, so the rules can be removed
+	removed, err := s.e.RemovePolicy("bob", "data1", "read")
+	s.Require().NoError(err)
+	s.Require().True(removed)
+	removed, err = s.e.RemoveGroupingPolicy("carol", "bob")
+	s.Require().NoError(err)
+	s.Require().True(removed)
+}
+
+var renameRows = []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+func TestMockRenameFieldValue(t *testing.T) {
+	for _, onConflict := range []RenameConflict{RenameSkip, RenameMerge} {
+		a, mock := newMockAdapter(t)
+		ctx := context.Background()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE (v0 = $1 OR v1 = $1) AND ptype = $2 FOR UPDATE`)).
+			WithArgs("alice", "g").
+			WillReturnRows(pgxmock.NewRows(renameRows).AddRow("g1", "g", "alice", "admin", "", "", "", ""))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE (v0 = $1) AND ptype = $2 FOR UPDATE`)).
+			WithArgs("alice", "p").
+			WillReturnRows(pgxmock.NewRows(renameRows).
+				AddRow("p1", "p", "alice", "data1", "read", "", "", "").
+				AddRow("p2", "p", "alice", "data2", "write", "", "", ""))
+		renamed := []string{
+			policyID("g", []string{"bob", "admin"}),
+			policyID("p", []string{"bob", "data1", "read"}),
+			policyID("p", []string{"bob", "data2", "write"}),
+		}
+		// bob can already read data1
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM "casbin_rules" WHERE id = ANY($1)`)).WithArgs(renamed).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(renamed[1]))
+
+		deleted := []string{"g1", "p2"}
+		if onConflict == RenameMerge {
+			deleted = []string{"g1", "p1", "p2"}
+		}
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id = ANY($1)`)).WithArgs(deleted).
+			WillReturnResult(pgxmock.NewResult("DELETE", int64(len(deleted))))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+			WithArgs(append(insertArgsFor("g", []string{"bob", "admin"}), insertArgsFor("p", []string{"bob", "data2", "write"})...)...).
+			WillReturnResult(pgxmock.NewResult("INSERT", 2))
+		mock.ExpectCommit()
+
+		n, err := a.RenameFieldValue(ctx, "alice", "bob", map[string][]int{"p": {0}, "g": {0, 1}}, onConflict)
+		require.NoError(t, err)
+		require.EqualValues(t, len(deleted), n)
+	}
+}
+
+func TestMockRenameFieldValueAllFields(t *testing.T) {
+	a, mock := newMockAdapter(t, WithValueColumns(2))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1 FROM "casbin_rules" WHERE (v0 = $1 OR v1 = $1) FOR UPDATE`)).
+		WithArgs("alice").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1"}).
+			AddRow("g1", "g", "alice", "alice").
+			AddRow("g2", "g", "bob", "alice"))
+	renamed := []string{policyID("g", []string{"bob", "bob"}), policyID("g", []string{"bob", "bob"})}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM "casbin_rules" WHERE id = ANY($1)`)).WithArgs(renamed).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+	// both rules become g, bob, bob which is inserted once
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id = ANY($1)`)).WithArgs([]string{"g1"}).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules" (id, ptype, v0, v1) VALUES($1, $2, $3, $4) ON CONFLICT DO NOTHING`)).
+		WithArgs(renamed[0], "g", "bob", "bob").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	n, err := a.RenameFieldValue(context.Background(), "alice", "bob", nil, RenameSkip)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+}
+
+func TestMockRenameFieldValueInvalid(t *testing.T) {
+	a, _ := newMockAdapter(t)
+	ctx := context.Background()
+
+	_, err := a.RenameFieldValue(ctx, "", "bob", nil, RenameSkip)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	_, err = a.RenameFieldValue(ctx, "bob", "bob", nil, RenameSkip)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	_, err = a.RenameFieldValue(ctx, "alice", "bob", map[string][]int{"p": {6}}, RenameSkip)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	require.ErrorContains(t, err, `field index 6 of ptype "p" is out of the 6 value columns`)
+}
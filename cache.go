@@ -0,0 +1,222 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+// CacheOption configures a CachedAdapter
+type CacheOption func(c *CachedAdapter)
+
+// WithMaxAge makes the CachedAdapter fetch the rules again when they were fetched more than maxAge ago,
+// e.g. as a fallback when the notifications of WithInvalidationWatcher may be missed.
+// The default maxAge is 0, the cached rules don't expire.
+func WithMaxAge(maxAge time.Duration) CacheOption {
+	return func(c *CachedAdapter) {
+		c.maxAge = maxAge
+	}
+}
+
+// WithInvalidationWatcher makes the CachedAdapter drop the cached rules when w is notified, e.g. by the watchers of
+// the other instances, or when w connects again after losing its connection. NewCachedAdapter sets the update
+// callback of w, so w must be dedicated to the cache rather than the watcher of an enforcer: create it with NewWatcher
+// on the channel of the enforcers' watchers. The CachedAdapter doesn't close w.
+func WithInvalidationWatcher(w *Watcher) CacheOption {
+	return func(c *CachedAdapter) {
+		c.watcher = w
+	}
+}
+
+// WithRevisionCheck makes the CachedAdapter read the revision of the rules table before serving the cached rules,
+// a single row query, and fetch the rules again when it changed since they were fetched, including when
+// the rules were changed by hand. The inner adapter must be created with WithRevisions.
+func WithRevisionCheck() CacheOption {
+	return func(c *CachedAdapter) {
+		c.revisionCheck = true
+	}
+}
+
+// CachedAdapter is an adapter keeping the rules loaded by LoadPolicy in memory, so the enforcers created or reloaded
+// while the rules don't change, e.g. in read-heavy services, don't scan the rules table again.
+// The writes, and LoadFilteredPolicy, pass through to the inner adapter, the writes drop the cached rules.
+// The cached rules are also dropped by Invalidate, by the notifications of WithInvalidationWatcher, when they are
+// older than WithMaxAge and when the revision checked by WithRevisionCheck changed.
+//
+// The changes made by other instances are not seen until the cached rules are dropped.
+// A CachedAdapter is safe for concurrent use, the concurrent loads missing the cache each fetch the rules
+// unless the inner adapter is created with WithSharedLoad.
+type CachedAdapter struct {
+	inner         *Adapter
+	maxAge        time.Duration
+	watcher       *Watcher
+	revisionCheck bool
+
+	mu       sync.Mutex
+	rules    []CasbinRule
+	cached   bool
+	loaded   time.Time
+	revision int64
+	// generation is increased by Invalidate, a fetch begun before is not cached
+	generation uint64
+}
+
+var (
+	_ persist.BatchAdapter     = (*CachedAdapter)(nil)
+	_ persist.FilteredAdapter  = (*CachedAdapter)(nil)
+	_ persist.UpdatableAdapter = (*CachedAdapter)(nil)
+)
+
+// NewCachedAdapter returns an adapter caching the rules loaded by inner
+func NewCachedAdapter(inner *Adapter, opts ...CacheOption) (*CachedAdapter, error) {
+	c := &CachedAdapter{inner: inner}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.revisionCheck && !inner.revisions {
+		return nil, fmt.Errorf("pgadapter.NewCachedAdapter: %w", errRevisionsDisabled)
+	}
+	if c.watcher != nil {
+		if err := c.watcher.SetUpdateCallback(func(string) { c.Invalidate() }); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Inner returns the adapter the CachedAdapter caches the rules of
+func (c *CachedAdapter) Inner() *Adapter {
+	return c.inner
+}
+
+// Invalidate drops the cached rules, the next LoadPolicy fetches them again
+func (c *CachedAdapter) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rules = nil
+	c.cached = false
+	c.generation++
+}
+
+// LoadPolicy loads the cached rules into model, fetching them with the inner adapter if they are not cached
+func (c *CachedAdapter) LoadPolicy(model model.Model) error {
+	rules, err := c.cachedRules()
+	if err != nil {
+		return err
+	}
+	for i := range rules {
+		if err := c.inner.loadRule(&rules[i], model); err != nil {
+			return err
+		}
+	}
+	c.inner.setFiltered(false)
+	return nil
+}
+
+// cachedRules returns the cached rules, fetched again if they are not fresh
+func (c *CachedAdapter) cachedRules() ([]CasbinRule, error) {
+	ctx := context.Background()
+	var revision int64
+	if c.revisionCheck {
+		var err error
+		if revision, err = c.inner.Revision(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.Lock()
+	if c.cached && (c.maxAge == 0 || time.Since(c.loaded) <= c.maxAge) && (!c.revisionCheck || c.revision == revision) {
+		rules := c.rules
+		c.mu.Unlock()
+		return rules, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	rules, err := c.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.rules = rules
+		c.cached = true
+		c.loaded = time.Now()
+		c.revision = revision
+	}
+	return rules, nil
+}
+
+// fetch returns the rules loaded by the LoadPolicy of the inner adapter
+func (c *CachedAdapter) fetch(ctx context.Context) (_ []CasbinRule, err error) {
+	a := c.inner
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "LoadPolicy"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	var rules []CasbinRule
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		rules, err = a.fetchRules(ctx)
+		return err
+	})
+	return rules, err
+}
+
+// written drops the cached rules after a write, even a failed one which may have changed some rules
+func (c *CachedAdapter) written(err error) error {
+	c.Invalidate()
+	return err
+}
+
+// LoadFilteredPolicy loads the rules matching filter with the inner adapter, they are not cached
+func (c *CachedAdapter) LoadFilteredPolicy(model model.Model, filter any) error {
+	return c.inner.LoadFilteredPolicy(model, filter)
+}
+
+func (c *CachedAdapter) IsFiltered() bool {
+	return c.inner.IsFiltered()
+}
+
+func (c *CachedAdapter) SavePolicy(model model.Model) error {
+	return c.written(c.inner.SavePolicy(model))
+}
+
+func (c *CachedAdapter) AddPolicy(sec string, ptype string, rule []string) error {
+	return c.written(c.inner.AddPolicy(sec, ptype, rule))
+}
+
+func (c *CachedAdapter) AddPolicies(sec string, ptype string, rules [][]string) error {
+	return c.written(c.inner.AddPolicies(sec, ptype, rules))
+}
+
+func (c *CachedAdapter) RemovePolicy(sec string, ptype string, rule []string) error {
+	return c.written(c.inner.RemovePolicy(sec, ptype, rule))
+}
+
+func (c *CachedAdapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	return c.written(c.inner.RemovePolicies(sec, ptype, rules))
+}
+
+func (c *CachedAdapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	return c.written(c.inner.RemoveFilteredPolicy(sec, ptype, fieldIndex, fieldValues...))
+}
+
+func (c *CachedAdapter) UpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	return c.written(c.inner.UpdatePolicy(sec, ptype, oldRule, newRule))
+}
+
+func (c *CachedAdapter) UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	return c.written(c.inner.UpdatePolicies(sec, ptype, oldRules, newRules))
+}
+
+func (c *CachedAdapter) UpdateFilteredPolicies(sec string, ptype string, newRules [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
+	removed, err := c.inner.UpdateFilteredPolicies(sec, ptype, newRules, fieldIndex, fieldValues...)
+	return removed, c.written(err)
+}
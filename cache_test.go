@@ -0,0 +1,134 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestCachedAdapter() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+	w, err := NewWatcher(ctx, pool, WithChannel("casbin_cache_test"))
+	s.Require().NoError(err)
+	defer w.Close()
+	other, err := NewWatcher(ctx, pool, WithChannel("casbin_cache_test"))
+	s.Require().NoError(err)
+	defer other.Close()
+
+	c, err := NewCachedAdapter(s.a, WithInvalidationWatcher(w))
+	s.Require().NoError(err)
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", c)
+	s.Require().NoError(err)
+	s.Require().Len(e.GetPolicy(), 4)
+
+	// a change made by another instance is seen once it is notified
+	_, err = pool.Exec(ctx, `DELETE FROM casbin_rules WHERE v0 = 'bob'`)
+	s.Require().NoError(err)
+	s.Require().NoError(e.LoadPolicy())
+	s.Require().Len(e.GetPolicy(), 4)
+	s.Require().NoError(other.Update())
+	s.Require().Eventually(func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return !c.cached
+	}, 5*time.Second, 10*time.Millisecond)
+	s.Require().NoError(e.LoadPolicy())
+	s.Require().Len(e.GetPolicy(), 3)
+
+	_, err = e.AddPolicy("carol", "data3", "read")
+	s.Require().NoError(err)
+	e2, err := casbin.NewEnforcer("examples/rbac_model.conf", c)
+	s.Require().NoError(err)
+	s.Require().Len(e2.GetPolicy(), 4)
+}
+
+// expectLoad expects the query of LoadPolicy returning a single p rule of subject
+func expectLoad(mock pgxmock.PgxPoolIface, subject string) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", subject, "data1", "read", "", "", ""))
+}
+
+func TestMockCachedAdapter(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	c, err := NewCachedAdapter(a)
+	require.NoError(t, err)
+	load := func(subject string) {
+		t.Helper()
+		m, err := model.NewModelFromFile("examples/rbac_model.conf")
+		require.NoError(t, err)
+		require.NoError(t, c.LoadPolicy(m))
+		require.Equal(t, [][]string{{subject, "data1", "read"}}, m.GetPolicy("p", "p"))
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+
+	// the second load runs no query
+	expectLoad(mock, "alice")
+	load("alice")
+	load("alice")
+
+	// a write drops the cached rules
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id=$1`)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, c.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+	expectLoad(mock, "bob")
+	load("bob")
+	load("bob")
+
+	c.Invalidate()
+	expectLoad(mock, "carol")
+	load("carol")
+}
+
+func TestMockCachedAdapterExpiry(t *testing.T) {
+	a, mock := newMockAdapter(t, WithRevisions())
+	c, err := NewCachedAdapter(a, WithMaxAge(50*time.Millisecond), WithRevisionCheck())
+	require.NoError(t, err)
+	load := func() {
+		t.Helper()
+		m, err := model.NewModelFromFile("examples/rbac_model.conf")
+		require.NoError(t, err)
+		require.NoError(t, c.LoadPolicy(m))
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+	revision := func(n int64) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT revision FROM "casbin_rules_revision"`)).
+			WillReturnRows(pgxmock.NewRows([]string{"revision"}).AddRow(n))
+	}
+
+	revision(1)
+	revision(1)
+	expectLoad(mock, "alice")
+	load()
+	revision(1)
+	load()
+
+	// the rules are fetched again when the revision changed
+	revision(2)
+	revision(2)
+	expectLoad(mock, "alice")
+	load()
+
+	// and when they expired
+	time.Sleep(60 * time.Millisecond)
+	revision(2)
+	revision(2)
+	expectLoad(mock, "alice")
+	load()
+
+	b, _ := newMockAdapter(t)
+	_, err = NewCachedAdapter(b, WithRevisionCheck())
+	require.ErrorIs(t, err, errRevisionsDisabled)
+}
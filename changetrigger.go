@@ -0,0 +1,95 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ChangeTriggerPayload is the payload of the notifications of InstallChangeTrigger whose payload would be too long
+const ChangeTriggerPayload = "changed"
+
+// maxNotifyPayload is the longest payload the trigger sends, postgres rejects payloads of 8000 bytes or more
+const maxNotifyPayload = 7999
+
+// InstallChangeTrigger creates a trigger notifying channel of every row inserted, updated or deleted in the rules table,
+// so the watchers listening to it, see NewWatcher and WithChannel, also see the rules changed with SQL, e.g. by hand with psql.
+// The payload is a JSON object giving the operation, the id and the ptype of the row:
+//
+//	{"op": "DELETE", "id": "6af26041539f44835d695c6a1b3dd062", "ptype": "p"}
+//
+// and is ChangeTriggerPayload when the object would be too long for a notification.
+// The trigger fires for the changes made by the adapter too, one notification per row, and the watcher of
+// the instance making them doesn't ignore them, WithDebounce coalesces them into a single reload.
+// The trigger is replaced when InstallChangeTrigger is called again, e.g. with another channel,
+// UninstallChangeTrigger drops it.
+func (a *Adapter) InstallChangeTrigger(ctx context.Context, channel string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "InstallChangeTrigger"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	if channel == "" {
+		return errors.New("pgadapter: channel is empty")
+	}
+	// the channel is written in the body of the function, which is dollar quoted
+	if strings.ContainsAny(channel, "$\x00") {
+		return fmt.Errorf("pgadapter: channel %q contains a dollar sign or a NUL character", channel)
+	}
+	table := a.table(ctx)
+	_, err = a.db.Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION "%[1]v_notify_change"() RETURNS trigger LANGUAGE plpgsql AS $fn$
+		DECLARE
+			r record;
+			payload text;
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				r := OLD;
+			ELSE
+				r := NEW;
+			END IF;
+			payload := json_build_object('op', TG_OP, 'id', r.id, 'ptype', r.ptype)::text;
+			IF octet_length(payload) > %[3]d THEN
+				payload := '%[4]v';
+			END IF;
+			PERFORM pg_notify('%[2]v', payload);
+			RETURN NULL;
+		END
+		$fn$
+	`, table, strings.ReplaceAll(channel, "'", "''"), maxNotifyPayload, ChangeTriggerPayload))
+	if err != nil {
+		return err
+	}
+
+	_, err = a.db.Exec(ctx, fmt.Sprintf(`
+		DO $do$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'notify_change' AND tgrelid = '"%[1]v"'::regclass) THEN
+				CREATE TRIGGER notify_change AFTER INSERT OR UPDATE OR DELETE ON "%[1]v" FOR EACH ROW EXECUTE FUNCTION "%[1]v_notify_change"();
+			END IF;
+		END
+		$do$
+	`, table))
+	if err != nil && !isPgError(err, codeDuplicateObject) {
+		return err
+	}
+	return nil
+}
+
+// UninstallChangeTrigger drops the trigger created by InstallChangeTrigger and its function, if any
+func (a *Adapter) UninstallChangeTrigger(ctx context.Context) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "UninstallChangeTrigger"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	table := a.table(ctx)
+	if _, err := a.db.Exec(ctx, fmt.Sprintf(`DROP TRIGGER IF EXISTS notify_change ON "%v"`, table)); err != nil {
+		return err
+	}
+	_, err = a.db.Exec(ctx, fmt.Sprintf(`DROP FUNCTION IF EXISTS "%v_notify_change"()`, table))
+	return err
+}
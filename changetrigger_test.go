@@ -0,0 +1,91 @@
+package pgxadapter
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestChangeTrigger() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+
+	a, err := NewAdapterByDB(pool, WithTableName("rules_change_trigger"))
+	s.Require().NoError(err)
+	defer pool.Exec(ctx, `DROP TABLE IF EXISTS rules_change_trigger`)
+	s.Require().NoError(a.InstallChangeTrigger(ctx, "casbin_change_trigger"))
+	s.Require().NoError(a.InstallChangeTrigger(ctx, "casbin_change_trigger"))
+
+	w, err := NewWatcher(ctx, pool, WithChannel("casbin_change_trigger"))
+	s.Require().NoError(err)
+	defer w.Close()
+	payloads := make(chan string, 10)
+	s.Require().NoError(w.SetUpdateCallback(func(payload string) { payloads <- payload }))
+	next := func() map[string]string {
+		select {
+		case payload := <-payloads:
+			var got map[string]string
+			s.Require().NoError(json.Unmarshal([]byte(payload), &got))
+			return got
+		case <-time.After(5 * time.Second):
+			s.FailNow("the update callback wasn't called after the table was changed")
+		}
+		return nil
+	}
+
+	_, err = pool.Exec(ctx, `INSERT INTO rules_change_trigger (id, ptype, v0, v1) VALUES ('manual', 'g', 'alice', 'admin')`)
+	s.Require().NoError(err)
+	s.Assert().Equal(map[string]string{"op": "INSERT", "id": "manual", "ptype": "g"}, next())
+	_, err = pool.Exec(ctx, `UPDATE rules_change_trigger SET v1 = 'root' WHERE id = 'manual'`)
+	s.Require().NoError(err)
+	s.Assert().Equal(map[string]string{"op": "UPDATE", "id": "manual", "ptype": "g"}, next())
+	_, err = pool.Exec(ctx, `DELETE FROM rules_change_trigger WHERE id = 'manual'`)
+	s.Require().NoError(err)
+	s.Assert().Equal(map[string]string{"op": "DELETE", "id": "manual", "ptype": "g"}, next())
+
+	// a payload too long for a notification is replaced
+	_, err = pool.Exec(ctx, `INSERT INTO rules_change_trigger (id, ptype) VALUES ('long', $1)`, strings.Repeat("x", 8000))
+	s.Require().NoError(err)
+	s.Assert().Equal(ChangeTriggerPayload, <-payloads)
+
+	s.Require().NoError(a.UninstallChangeTrigger(ctx))
+	s.Require().NoError(a.UninstallChangeTrigger(ctx))
+	_, err = pool.Exec(ctx, `DELETE FROM rules_change_trigger`)
+	s.Require().NoError(err)
+	select {
+	case payload := <-payloads:
+		s.Failf("notified after the trigger was dropped", "payload %q", payload)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestMockChangeTrigger(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE OR REPLACE FUNCTION "casbin_rules_notify_change"()`) + `.*` +
+		regexp.QuoteMeta(`PERFORM pg_notify('it''s', payload);`)).
+		WillReturnResult(pgxmock.NewResult("CREATE FUNCTION", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TRIGGER notify_change AFTER INSERT OR UPDATE OR DELETE ON "casbin_rules" FOR EACH ROW EXECUTE FUNCTION "casbin_rules_notify_change"()`)).
+		WillReturnResult(pgxmock.NewResult("DO", 0))
+	require.NoError(t, a.InstallChangeTrigger(ctx, "it's"))
+
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TRIGGER IF EXISTS notify_change ON "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("DROP TRIGGER", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DROP FUNCTION IF EXISTS "casbin_rules_notify_change"()`)).
+		WillReturnResult(pgxmock.NewResult("DROP FUNCTION", 0))
+	require.NoError(t, a.UninstallChangeTrigger(ctx))
+
+	require.ErrorContains(t, a.InstallChangeTrigger(ctx, ""), "channel is empty")
+	require.ErrorContains(t, a.InstallChangeTrigger(ctx, "a$fn$b"), "contains a dollar sign")
+}
@@ -0,0 +1,66 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// WithDistributedTable distributes the rules table with Citus by its namespace column, so the rules of each tenant
+// of WithTenantFromContext, or each namespace of WithNamespace, live on a single shard. colocateWith, if not empty,
+// is the distributed table whose shards hold the rules of the same tenants, e.g. a table of the application
+// distributed by tenant, see the colocate_with argument of create_distributed_table.
+//
+// The table created by the adapter then has the primary key (namespace, id), which Citus needs, and is distributed
+// right after its creation. Every statement of the adapter on the rules of a namespace filters on its namespace,
+// so it runs on a single shard, the operations on the whole table, such as VerifySchema, run on every shard.
+// The adapter fails to start with ErrCitusNotInstalled when the citus extension is not installed in the database.
+// A table created beforehand must have a primary key including the namespace column, it is distributed when
+// it is not yet. The option needs WithNamespace or WithTenantFromContext and can't be used with WithTemporaryTable
+// or WithSwapSave. With WithDryRun, the adapter fails to start rather than distribute a table which is not yet.
+// The tables of WithHistory and WithRevisions stay local tables, their triggers need citus.enable_unsafe_triggers.
+func WithDistributedTable(colocateWith string) Option {
+	return func(a *Adapter) {
+		a.distributed = true
+		a.colocateWith = colocateWith
+	}
+}
+
+// distributeTable distributes the rules table of ctx by namespace with Citus if it is not already distributed
+func (a *Adapter) distributeTable(ctx context.Context, db execQuerier) error {
+	var installed bool
+	if err := queryRowWith(ctx, db, `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'citus')`, nil, &installed); err != nil {
+		return err
+	}
+	if !installed {
+		return fmt.Errorf("%w: run CREATE EXTENSION citus in the database", ErrCitusNotInstalled)
+	}
+
+	table := pgx.Identifier{a.table(ctx)}.Sanitize()
+	var distributed bool
+	err := queryRowWith(ctx, db, `SELECT EXISTS (SELECT 1 FROM pg_dist_partition WHERE logicalrelid = $1::regclass)`, []any{table}, &distributed)
+	if err != nil || distributed {
+		return err
+	}
+	if a.dryRun {
+		return errors.New("pgadapter: the rules table can't be distributed with WithDryRun")
+	}
+	colocate := "default"
+	if a.colocateWith != "" {
+		colocate = pgx.Identifier{a.colocateWith}.Sanitize()
+	}
+	_, err = db.Exec(ctx, `SELECT create_distributed_table($1, 'namespace', colocate_with => $2)`, table, colocate)
+	return err
+}
+
+// byID appends the namespace condition to sql, selecting rules by id, when the table is distributed,
+// so the statement runs on the shard of the namespace. The ids depend on the namespace, so the condition
+// is not needed otherwise.
+func (a *Adapter) byID(ctx context.Context, sql string, args []any) (string, []any) {
+	if !a.distributed {
+		return sql, args
+	}
+	return a.inNamespace(ctx, sql, args, false)
+}
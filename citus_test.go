@@ -0,0 +1,150 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockDistributedTableCreate(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	mock.ExpectExec(`id TEXT NOT NULL,(?s:.*)namespace TEXT NOT NULL DEFAULT '',\s+PRIMARY KEY \(namespace, id\)`).
+		WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "casbin_rules" ADD COLUMN IF NOT EXISTS namespace`)).
+		WillReturnResult(pgxmock.NewResult("ALTER", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'citus')`)).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM pg_dist_partition WHERE logicalrelid = $1::regclass)`)).
+		WithArgs(`"casbin_rules"`).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT create_distributed_table($1, 'namespace', colocate_with => $2)`)).
+		WithArgs(`"casbin_rules"`, `"tenants"`).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	_, err = NewAdapterByPgxPool(mock, WithTenantFromContext(false), WithDistributedTable("tenants"), SkipSchemaVerification())
+	require.NoError(t, err)
+
+	// an already distributed table is kept
+	mock.ExpectExec(`CREATE TABLE`).WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	mock.ExpectExec(`ALTER TABLE`).WillReturnResult(pgxmock.NewResult("ALTER", 0))
+	mock.ExpectQuery(`pg_extension`).WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`pg_dist_partition`).WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	_, err = NewAdapterByPgxPool(mock, WithNamespace("tenant1"), WithDistributedTable(""), SkipSchemaVerification())
+	require.NoError(t, err)
+
+	mock.ExpectExec(`CREATE TABLE`).WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	mock.ExpectExec(`ALTER TABLE`).WillReturnResult(pgxmock.NewResult("ALTER", 0))
+	mock.ExpectQuery(`pg_extension`).WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	_, err = NewAdapterByPgxPool(mock, WithNamespace("tenant1"), WithDistributedTable(""), SkipSchemaVerification())
+	require.ErrorIs(t, err, ErrCitusNotInstalled)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	for _, opts := range [][]Option{
+		{WithDistributedTable("")},
+		{WithNamespace("tenant1"), WithDistributedTable(""), WithTemporaryTable()},
+		{WithNamespace("tenant1"), WithDistributedTable(""), WithSwapSave()},
+	} {
+		_, err = NewAdapterByPgxPool(mock, append(opts, SkipTableCreate(), SkipSchemaVerification())...)
+		require.ErrorContains(t, err, "WithDistributedTable")
+	}
+}
+
+func TestMockDistributedTableDryRun(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	// the creation of the table is rolled back, create_distributed_table would not be
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE`).WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE`).WillReturnResult(pgxmock.NewResult("ALTER", 0))
+	mock.ExpectRollback()
+	mock.ExpectQuery(`pg_extension`).WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`pg_dist_partition`).WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	_, err = NewAdapterByPgxPool(mock, WithNamespace("tenant1"), WithDistributedTable(""), WithDryRun(), SkipSchemaVerification())
+	require.ErrorContains(t, err, "can't be distributed with WithDryRun")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMockDistributedTableQueries(t *testing.T) {
+	// every statement on the rules table filters on the distribution column, so it runs on a single shard
+	var statements []string
+	matcher := pgxmock.QueryMatcherFunc(func(expectedSQL, actualSQL string) error {
+		statements = append(statements, actualSQL)
+		return pgxmock.QueryMatcherRegexp.Match(expectedSQL, actualSQL)
+	})
+	mock, err := pgxmock.NewPool(pgxmock.QueryMatcherOption(matcher))
+	require.NoError(t, err)
+	defer mock.Close()
+	a, err := NewAdapterByPgxPool(mock, WithNamespace("tenant1"), WithDistributedTable(""),
+		SkipTableCreate(), SkipSchemaVerification())
+	require.NoError(t, err)
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+	rule := []string{"alice", "data1", "read"}
+
+	mock.ExpectQuery(`SELECT`).WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", a)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT`).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", rule))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE`).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemovePolicy("p", "p", rule))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE`).WillReturnResult(pgxmock.NewResult("DELETE", 2))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemovePolicies("p", "p", [][]string{rule, {"bob", "data2", "write"}}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE`).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemoveFilteredPolicy("p", "p", 0, "alice"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE`).WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.UpdatePolicy("p", "p", rule, []string{"alice", "data1", "write"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE`).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(`INSERT`).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, e.SavePolicy())
+
+	mock.ExpectQuery(`SELECT`).WillReturnRows(pgxmock.NewRows(cols))
+	require.NoError(t, a.LoadFilteredPolicy(e.GetModel(), &Filter{P: []string{"alice"}}))
+
+	mock.ExpectQuery(`SELECT count`).WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	_, err = a.CountRules(context.Background(), "")
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	var checked int
+	for _, sql := range statements {
+		if !strings.Contains(sql, `"casbin_rules"`) {
+			continue
+		}
+		checked++
+		if strings.HasPrefix(sql, "INSERT") {
+			require.Contains(t, sql, ", namespace)", sql)
+		} else {
+			require.Contains(t, sql, "namespace = $", sql)
+		}
+	}
+	require.Equal(t, 10, checked)
+}
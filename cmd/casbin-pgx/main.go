@@ -0,0 +1,460 @@
+// Command casbin-pgx exports, imports, counts and migrates the casbin rules stored by the pgx adapter.
+//
+// Usage:
+//
+//	casbin-pgx [-dsn url] [-table name] <command> [flags] [args]
+//
+// The commands are:
+//
+//	export [-format csv|json]                  writes the rules to stdout, csv is the format of casbin's file adapter
+//	import -model path [-replace] policy.csv   stores the rules of a policy file, see Adapter.ImportFromFileAdapter
+//	count [-ptype p]                           prints the number of rules
+//	verify-ids                                 prints the rows whose id is not the one the adapter gives to their rule
+//	migrate-from-gorm -source-table name       copies the rules of a table of casbin's gorm adapter
+//
+// The DSN defaults to the DATABASE_URL environment variable, or to the PGHOST, PGUSER... variables read by pgx
+// when it is unset, and the table to CASBIN_TABLE, or to the default table of the adapter.
+//
+// The exit status is 0 on success, 1 when the rules are invalid or the command fails,
+// 2 when the command line is invalid and 3 when the database can't be reached.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	pgxadapter "github.com/thnt/casbin-pgx-adapter"
+)
+
+// the exit statuses of the command
+const (
+	exitOK         = 0
+	exitData       = 1
+	exitUsage      = 2
+	exitConnection = 3
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// command is a subcommand, parse checks its flags and arguments before the database is reached
+// and returns the action to run with the adapter and the pool it uses
+type command struct {
+	name  string
+	usage string
+	parse func(args []string) (action, error)
+	// readOnly is set for the commands only reading the rules, they don't create a missing table
+	readOnly bool
+}
+
+// action runs a parsed command
+type action func(ctx context.Context, env *env) error
+
+var commands = []command{
+	{"export", "export [-format csv|json]", parseExport, true},
+	{"import", "import -model path [-replace] policy.csv", parseImport, false},
+	{"count", "count [-ptype p]", parseCount, true},
+	{"verify-ids", "verify-ids", parseVerifyIDs, true},
+	{"migrate-from-gorm", "migrate-from-gorm -source-table name", parseMigrateFromGorm, false},
+}
+
+// env is what the commands run with
+type env struct {
+	a      *pgxadapter.Adapter
+	pool   *pgxpool.Pool
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// usageError is returned for an invalid command line
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+// dataError is returned when the command ran but found invalid rules
+type dataError struct {
+	msg string
+}
+
+func (e *dataError) Error() string {
+	return e.msg
+}
+
+// run runs the command line args and returns the exit status
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("casbin-pgx", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dsn := fs.String("dsn", os.Getenv("DATABASE_URL"), "PostgreSQL URL of the database")
+	table := fs.String("table", envOr("CASBIN_TABLE", pgxadapter.DefaultTableName), "name of the rules table")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: casbin-pgx [-dsn url] [-table name] <command> [flags] [args]")
+		fmt.Fprintln(stderr, "\ncommands:")
+		for _, c := range commands {
+			fmt.Fprintln(stderr, "  "+c.usage)
+		}
+		fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == fs.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "casbin-pgx: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage
+	}
+	act, err := cmd.parse(fs.Args()[1:])
+	if err != nil {
+		return cmd.fail(stderr, err)
+	}
+
+	cfg, err := pgxpool.ParseConfig(*dsn)
+	if err != nil {
+		fmt.Fprintf(stderr, "casbin-pgx: invalid DSN: %v\n", err)
+		return exitUsage
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "casbin-pgx: %v\n", err)
+		return exitConnection
+	}
+	defer pool.Close()
+	if err := pool.Ping(ctx); err != nil {
+		fmt.Fprintf(stderr, "casbin-pgx: %v\n", err)
+		return exitConnection
+	}
+	opts := []pgxadapter.Option{pgxadapter.WithTableName(*table)}
+	if cmd.readOnly {
+		// a mistyped table fails the command instead of being created empty
+		opts = append(opts, pgxadapter.SkipTableCreate())
+	}
+	a, err := pgxadapter.NewAdapterByDB(pool, opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "casbin-pgx: %v\n", err)
+		return exitCode(err)
+	}
+	defer a.Close()
+
+	err = act(ctx, &env{a: a, pool: pool, stdout: stdout, stderr: stderr})
+	if err != nil {
+		return cmd.fail(stderr, err)
+	}
+	return exitOK
+}
+
+// fail reports the error of the command and returns the exit status
+func (c *command) fail(stderr io.Writer, err error) int {
+	fmt.Fprintf(stderr, "casbin-pgx %v: %v\n", c.name, err)
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintln(stderr, "usage: casbin-pgx "+c.usage)
+	}
+	return exitCode(err)
+}
+
+// exitCode returns the exit status of the error of a command
+func exitCode(err error) int {
+	var usageErr *usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case isConnectionError(err):
+		return exitConnection
+	}
+	return exitData
+}
+
+// isConnectionError tells whether err is caused by the connection to the server rather than by the rules,
+// i.e. a network error, or a connection or authentication failure reported by the server
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, "08") || strings.HasPrefix(pgErr.Code, "28")
+	}
+	return false
+}
+
+// envOr returns the value of the environment variable name, or def when it is unset or empty
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// parseFlags parses the flags of a command, which takes nargs arguments
+func parseFlags(fs *flag.FlagSet, args []string, nargs int) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return &usageError{err.Error()}
+	}
+	if fs.NArg() != nargs {
+		return &usageError{fmt.Sprintf("expected %d arguments, got %d", nargs, fs.NArg())}
+	}
+	return nil
+}
+
+// ruleValues returns the values of a stored rule without the trailing empty values
+func ruleValues(r pgxadapter.CasbinRule) []string {
+	vals := append([]string{r.V0, r.V1, r.V2, r.V3, r.V4, r.V5}, r.Extra...)
+	for len(vals) > 0 && vals[len(vals)-1] == "" {
+		vals = vals[:len(vals)-1]
+	}
+	return vals
+}
+
+// exportedRule is a rule written by export -format json
+type exportedRule struct {
+	Ptype string   `json:"ptype"`
+	Rule  []string `json:"rule"`
+}
+
+// parseExport parses export, which writes the rules to stdout, one per line,
+// reading the table in batches with ForEachRule
+func parseExport(args []string) (action, error) {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "output format, csv or json")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return nil, err
+	}
+
+	switch *format {
+	case "csv":
+		return func(ctx context.Context, env *env) error {
+			w := csv.NewWriter(env.stdout)
+			err := env.a.ForEachRule(ctx, func(r pgxadapter.CasbinRule) error {
+				return w.Write(append([]string{r.Ptype}, ruleValues(r)...))
+			})
+			if err != nil {
+				return err
+			}
+			w.Flush()
+			return w.Error()
+		}, nil
+	case "json":
+		return func(ctx context.Context, env *env) error {
+			enc := json.NewEncoder(env.stdout)
+			return env.a.ForEachRule(ctx, func(r pgxadapter.CasbinRule) error {
+				return enc.Encode(exportedRule{Ptype: r.Ptype, Rule: ruleValues(r)})
+			})
+		}, nil
+	}
+	return nil, &usageError{fmt.Sprintf("unknown format %q", *format)}
+}
+
+// parseImport parses import, which stores the rules of a policy file with ImportFromFileAdapter,
+// the rejected lines are a data error
+func parseImport(args []string) (action, error) {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	modelPath := fs.String("model", "", "path of the casbin model the rules are validated against")
+	replace := fs.Bool("replace", false, "delete the stored rules first")
+	if err := parseFlags(fs, args, 1); err != nil {
+		return nil, err
+	}
+	if *modelPath == "" {
+		return nil, &usageError{"-model is required"}
+	}
+
+	return func(ctx context.Context, env *env) error {
+		report, err := env.a.ImportFromFileAdapter(ctx, *modelPath, fs.Arg(0), *replace)
+		if err != nil {
+			return err
+		}
+		for _, r := range report.Rejected {
+			fmt.Fprintf(env.stderr, "line %d: %v: %v\n", r.Line, r.Err, r.Text)
+		}
+		fmt.Fprintf(env.stdout, "%d rules read, %d imported\n", report.Rules, report.Imported)
+		if len(report.Rejected) > 0 {
+			return &dataError{fmt.Sprintf("%d lines rejected", len(report.Rejected))}
+		}
+		return nil
+	}, nil
+}
+
+// parseCount parses count, which prints the number of rules
+func parseCount(args []string) (action, error) {
+	fs := flag.NewFlagSet("count", flag.ContinueOnError)
+	ptype := fs.String("ptype", "", "count the rules of this ptype only")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, env *env) error {
+		n, err := env.a.CountRules(ctx, *ptype)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(env.stdout, n)
+		return nil
+	}, nil
+}
+
+// parseVerifyIDs parses verify-ids, which prints the rows returned by VerifyIDs, which are a data error
+func parseVerifyIDs(args []string) (action, error) {
+	fs := flag.NewFlagSet("verify-ids", flag.ContinueOnError)
+	if err := parseFlags(fs, args, 0); err != nil {
+		return nil, err
+	}
+	return runVerifyIDs, nil
+}
+
+// runVerifyIDs prints the rows returned by VerifyIDs, which are a data error
+func runVerifyIDs(ctx context.Context, env *env) error {
+	mismatches, err := env.a.VerifyIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, m := range mismatches {
+		fmt.Fprintf(env.stdout, "%v\t%v\t%v, %v\n", m.ID, m.ExpectedID, m.Ptype, strings.Join(m.Rule, ", "))
+	}
+	if len(mismatches) > 0 {
+		return &dataError{fmt.Sprintf("%d rows have a wrong id, RepairIDs fixes them", len(mismatches))}
+	}
+	return nil
+}
+
+// gormValueColumns is the number of value columns of the table of the gorm adapter
+const gormValueColumns = 6
+
+// parseMigrateFromGorm parses migrate-from-gorm, see runMigrateFromGorm
+func parseMigrateFromGorm(args []string) (action, error) {
+	fs := flag.NewFlagSet("migrate-from-gorm", flag.ContinueOnError)
+	source := fs.String("source-table", "", "table of the gorm adapter, optionally qualified by its schema")
+	if err := parseFlags(fs, args, 0); err != nil {
+		return nil, err
+	}
+	if *source == "" {
+		return nil, &usageError{"-source-table is required"}
+	}
+	return func(ctx context.Context, env *env) error {
+		return runMigrateFromGorm(ctx, env, *source)
+	}, nil
+}
+
+// runMigrateFromGorm copies the rules of the table source of casbin's gorm adapter, which has the columns id, ptype
+// and v0 to v5, in a single transaction. The source table is read in batches ordered by id, and each batch is stored
+// with AddPolicies, which ignores the rules already stored so the migration can be run again.
+func runMigrateFromGorm(ctx context.Context, env *env, source string) error {
+	sql := fmt.Sprintf(`SELECT id, ptype, %v FROM %v WHERE id > $1 ORDER BY id LIMIT $2`,
+		gormColumns(), pgx.Identifier(strings.Split(source, ".")).Sanitize())
+	var read int
+	err := env.a.WithTx(ctx, func(tx pgxadapter.TxAdapter) error {
+		var after int64
+		for {
+			batch, last, err := readGormBatch(ctx, env.pool, sql, after)
+			if err != nil {
+				return err
+			}
+			for _, b := range batch {
+				if b.ptype == "" {
+					return &dataError{fmt.Sprintf("rule %v has no ptype", strings.Join(b.rules[0], ", "))}
+				}
+				if err := tx.AddPolicies(b.ptype[:1], b.ptype, b.rules); err != nil {
+					return err
+				}
+				read += len(b.rules)
+			}
+			if len(batch) == 0 {
+				return nil
+			}
+			after = last
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stdout, "%d rules read\n", read)
+	return nil
+}
+
+// gormColumns returns the value columns of the gorm table, the values are nullable there
+func gormColumns() string {
+	cols := make([]string, gormValueColumns)
+	for i := range cols {
+		cols[i] = fmt.Sprintf("coalesce(v%d, '')", i)
+	}
+	return strings.Join(cols, ", ")
+}
+
+// gormRules are the rules of a ptype read from the gorm table
+type gormRules struct {
+	ptype string
+	rules [][]string
+}
+
+// readGormBatch returns the rules of the next batch of the gorm table grouped by ptype, and the id of its last row
+func readGormBatch(ctx context.Context, pool *pgxpool.Pool, sql string, after int64) ([]gormRules, int64, error) {
+	rows, err := pool.Query(ctx, sql, after, pgxadapter.DefaultScanBatchSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var batch []gormRules
+	index := map[string]int{}
+	// a single statement can't insert the same rule twice
+	seen := map[string]bool{}
+	for rows.Next() {
+		var ptype string
+		vals := make([]string, gormValueColumns)
+		dest := []any{&after, &ptype}
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, err
+		}
+		for len(vals) > 0 && vals[len(vals)-1] == "" {
+			vals = vals[:len(vals)-1]
+		}
+		key := strings.Join(append([]string{ptype}, vals...), "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		i, ok := index[ptype]
+		if !ok {
+			i = len(batch)
+			index[ptype] = i
+			batch = append(batch, gormRules{ptype: ptype})
+		}
+		batch[i].rules = append(batch[i].rules, vals)
+	}
+	return batch, after, rows.Err()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+const testTable = "casbin_pgx_cli_rules"
+
+// runCLI runs the command line with the test database and table, and returns the exit status and the outputs
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-dsn", os.Getenv("PG_CONN"), "-table", testTable}, args...)
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// testPool connects to the test database and drops the tables of the tests when the test ends
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	require.NoError(t, err)
+	drop := func() {
+		pool.Exec(ctx, `DROP TABLE IF EXISTS `+testTable)
+		pool.Exec(ctx, `DROP TABLE IF EXISTS casbin_pgx_cli_gorm`)
+	}
+	drop()
+	t.Cleanup(func() {
+		drop()
+		pool.Close()
+	})
+	return pool
+}
+
+func TestImportExportCount(t *testing.T) {
+	testPool(t)
+
+	code, out, stderr := runCLI(t, "import", "-model", "../../examples/rbac_model.conf", "../../examples/rbac_policy.csv")
+	require.Equal(t, exitOK, code, stderr)
+	require.Equal(t, "5 rules read, 5 imported\n", out)
+
+	code, out, _ = runCLI(t, "count")
+	require.Equal(t, exitOK, code)
+	require.Equal(t, "5\n", out)
+	code, out, _ = runCLI(t, "count", "-ptype", "g")
+	require.Equal(t, exitOK, code)
+	require.Equal(t, "1\n", out)
+
+	code, csvOut, _ := runCLI(t, "export")
+	require.Equal(t, exitOK, code)
+	require.Len(t, strings.Split(strings.TrimSpace(csvOut), "\n"), 5)
+	require.Contains(t, csvOut, "p,alice,data1,read\n")
+	require.Contains(t, csvOut, "g,alice,data2_admin\n")
+
+	code, out, _ = runCLI(t, "export", "-format", "json")
+	require.Equal(t, exitOK, code)
+	require.Contains(t, out, `{"ptype":"p","rule":["alice","data1","read"]}`+"\n")
+
+	// the csv export imports back into the same rules
+	path := filepath.Join(t.TempDir(), "policy.csv")
+	require.NoError(t, os.WriteFile(path, []byte(csvOut), 0o600))
+	code, out, stderr = runCLI(t, "import", "-model", "../../examples/rbac_model.conf", "-replace", path)
+	require.Equal(t, exitOK, code, stderr)
+	require.Equal(t, "5 rules read, 5 imported\n", out)
+
+	code, _, stderr = runCLI(t, "verify-ids")
+	require.Equal(t, exitOK, code, stderr)
+}
+
+func TestImportRejectedLines(t *testing.T) {
+	testPool(t)
+
+	path := filepath.Join(t.TempDir(), "policy.csv")
+	require.NoError(t, os.WriteFile(path, []byte("p, alice, data1, read\np, bob\n"), 0o600))
+	code, out, stderr := runCLI(t, "import", "-model", "../../examples/rbac_model.conf", path)
+	require.Equal(t, exitData, code)
+	require.Equal(t, "1 rules read, 1 imported\n", out)
+	require.Contains(t, stderr, "line 2:")
+}
+
+func TestVerifyIDsMismatch(t *testing.T) {
+	pool := testPool(t)
+
+	code, _, stderr := runCLI(t, "import", "-model", "../../examples/rbac_model.conf", "../../examples/rbac_policy.csv")
+	require.Equal(t, exitOK, code, stderr)
+	_, err := pool.Exec(context.Background(), `UPDATE `+testTable+` SET id = 'wrong' WHERE v0 = 'bob'`)
+	require.NoError(t, err)
+
+	code, out, _ := runCLI(t, "verify-ids")
+	require.Equal(t, exitData, code)
+	require.True(t, strings.HasPrefix(out, "wrong\t"), out)
+}
+
+func TestMigrateFromGorm(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	_, err := pool.Exec(ctx, `CREATE TABLE casbin_pgx_cli_gorm (
+		id BIGSERIAL PRIMARY KEY, ptype VARCHAR(100),
+		v0 VARCHAR(100), v1 VARCHAR(100), v2 VARCHAR(100), v3 VARCHAR(100), v4 VARCHAR(100), v5 VARCHAR(100)
+	)`)
+	require.NoError(t, err)
+	_, err = pool.Exec(ctx, `INSERT INTO casbin_pgx_cli_gorm (ptype, v0, v1, v2) VALUES
+		('p', 'alice', 'data1', 'read'), ('p', 'alice', 'data1', 'read'), ('g', 'alice', 'admin', NULL)`)
+	require.NoError(t, err)
+
+	code, out, stderr := runCLI(t, "migrate-from-gorm", "-source-table", "casbin_pgx_cli_gorm")
+	require.Equal(t, exitOK, code, stderr)
+	require.Equal(t, "2 rules read\n", out)
+
+	// running the migration again keeps the stored rules
+	code, _, stderr = runCLI(t, "migrate-from-gorm", "-source-table", "casbin_pgx_cli_gorm")
+	require.Equal(t, exitOK, code, stderr)
+	code, out, _ = runCLI(t, "export")
+	require.Equal(t, exitOK, code)
+	require.ElementsMatch(t, []string{"p,alice,data1,read", "g,alice,admin"}, strings.Split(strings.TrimSpace(out), "\n"))
+
+	code, _, _ = runCLI(t, "migrate-from-gorm", "-source-table", "casbin_pgx_cli_missing")
+	require.Equal(t, exitData, code)
+}
+
+func TestExitCodes(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, exitUsage, run(context.Background(), nil, &stdout, &stderr))
+	require.Equal(t, exitUsage, run(context.Background(), []string{"frobnicate"}, &stdout, &stderr))
+
+	// nothing listens on port 1
+	args := []string{"-dsn", "postgres://postgres@127.0.0.1:1/postgres?connect_timeout=1", "count"}
+	require.Equal(t, exitConnection, run(context.Background(), args, &stdout, &stderr))
+
+	// the command line is checked before the database is reached
+	for _, cmd := range [][]string{
+		{"export", "-format", "xml"},
+		{"import", "../../examples/rbac_policy.csv"},
+		{"migrate-from-gorm"},
+		{"count", "extra"},
+	} {
+		require.Equal(t, exitUsage, run(context.Background(), append(args[:2:2], cmd...), &stdout, &stderr), cmd)
+	}
+}
+
+func TestReadCommandsMissingTable(t *testing.T) {
+	pool := testPool(t)
+
+	// the read commands don't create a missing table
+	for _, args := range [][]string{{"export"}, {"count"}, {"verify-ids"}} {
+		code, out, _ := runCLI(t, args...)
+		require.Equal(t, exitData, code, args)
+		require.Empty(t, out, args)
+	}
+	var exists bool
+	require.NoError(t, pool.QueryRow(context.Background(), `SELECT to_regclass($1) IS NOT NULL`, testTable).Scan(&exists))
+	require.False(t, exists)
+}
@@ -0,0 +1,40 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// WithCollationC creates the id and value columns of the rules table with the "C" collation, comparing the
+// bytes of the values rather than following the rules of a locale, so their comparisons and index scans are
+// cheaper. The rule values are identifiers compared for equality, only the order of LoadPolicyPage and of the
+// sorted results may change. The lowercasing of WithNormalization happens before the values are stored,
+// it doesn't depend on the collation.
+// The existing tables keep their collation until Migrate runs its collation step, which alters the columns
+// and so rebuilds the indexes using them, holding an exclusive lock on the table meanwhile.
+func WithCollationC() Option {
+	return func(a *Adapter) {
+		a.collateC = true
+	}
+}
+
+// collation returns the COLLATE clause of the id and value columns, see WithCollationC
+func (a *Adapter) collation() string {
+	if a.collateC {
+		return ` COLLATE "C"`
+	}
+	return ""
+}
+
+// collateColumns alters the id and value columns of the rules table of ctx to the "C" collation,
+// postgres rebuilds the indexes using them
+func (a *Adapter) collateColumns(ctx context.Context, db execer) error {
+	alters := make([]string, 0, a.valueColumns+1)
+	alters = append(alters, `ALTER COLUMN id TYPE TEXT COLLATE "C"`)
+	for i := 0; i < a.valueColumns; i++ {
+		alters = append(alters, fmt.Sprintf(`ALTER COLUMN v%d TYPE TEXT COLLATE "C"`, i))
+	}
+	_, err := db.Exec(ctx, fmt.Sprintf(`ALTER TABLE "%v" %v`, a.table(ctx), strings.Join(alters, ", ")))
+	return err
+}
@@ -0,0 +1,96 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestCollationC() {
+	ctx := context.Background()
+	collations := func(a *Adapter, table string) map[string]string {
+		rows, err := a.db.Query(ctx, `SELECT a.attname::text, coalesce(c.collname::text, '')
+			FROM pg_attribute a LEFT JOIN pg_collation c ON c.oid = a.attcollation
+			WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped`, `"`+table+`"`)
+		s.Require().NoError(err)
+		got := map[string]string{}
+		for rows.Next() {
+			var name, coll string
+			s.Require().NoError(rows.Scan(&name, &coll))
+			got[name] = coll
+		}
+		s.Require().NoError(rows.Err())
+		return got
+	}
+
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("rules_collation"), WithCollationC(), WithValueColumns(8))
+	s.Require().NoError(err)
+	defer a.Close()
+	defer a.db.Exec(ctx, `DROP TABLE IF EXISTS "rules_collation", "rules_collation_old"`)
+	got := collations(a, "rules_collation")
+	for _, col := range []string{"id", "v0", "v5", "v7"} {
+		s.Assert().Equal("C", got[col], col)
+	}
+	s.Assert().Equal("default", got["ptype"])
+
+	// an existing table is altered by Migrate, its indexes are rebuilt
+	b, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("rules_collation_old"))
+	s.Require().NoError(err)
+	defer b.Close()
+	s.Require().NoError(b.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+	s.Require().NoError(b.EnsureDomainIndexes(ctx, DomainLayout{"p": 1}))
+	s.Assert().Equal("default", collations(b, "rules_collation_old")["v0"])
+
+	c, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("rules_collation_old"), WithCollationC())
+	s.Require().NoError(err)
+	defer c.Close()
+	ran, err := c.Migrate(ctx)
+	s.Require().NoError(err)
+	s.Assert().Contains(ran, MigrationStep{3, "use the C collation for the id and value columns"})
+	got = collations(c, "rules_collation_old")
+	s.Assert().Equal("C", got["id"])
+	s.Assert().Equal("C", got["v1"])
+	var valid bool
+	s.Require().NoError(c.queryRow(ctx, `SELECT bool_and(i.indisvalid) FROM pg_index i WHERE i.indrelid = '"rules_collation_old"'::regclass`, nil, &valid))
+	s.Assert().True(valid)
+	ok, err := c.ExistsMatchingPolicy(ctx, "p", map[int]string{0: "alice", 1: "data1"})
+	s.Require().NoError(err)
+	s.Assert().True(ok)
+}
+
+func TestMockCollationC(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	mock.ExpectExec(`id TEXT COLLATE "C" PRIMARY KEY,\s+ptype TEXT NOT NULL,\s+v0 TEXT COLLATE "C",(?s:.*)v6 TEXT COLLATE "C"\s+\)`).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "casbin_rules" ADD COLUMN IF NOT EXISTS v6 TEXT COLLATE "C"`)).
+		WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	a, err := NewAdapterByPgxPool(mock, WithCollationC(), WithValueColumns(7), SkipSchemaVerification())
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_schema_version"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version FROM "casbin_schema_version" WHERE table_name = $1`)).
+		WithArgs("casbin_rules").
+		WillReturnRows(pgxmock.NewRows([]string{"version"}).AddRow(1).AddRow(2))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "casbin_rules" ALTER COLUMN id TYPE TEXT COLLATE "C", ALTER COLUMN v0 TYPE TEXT COLLATE "C", `) +
+		`(?s:.*)` + regexp.QuoteMeta(`ALTER COLUMN v6 TYPE TEXT COLLATE "C"`)).
+		WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_schema_version"`)).
+		WithArgs("casbin_rules", 3, "use the C collation for the id and value columns").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	ran, err := a.Migrate(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []MigrationStep{{3, "use the C collation for the id and value columns"}}, ran)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,91 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// RuleConflict is a pair of p rules identical except for their effect, returned by FindConflictingRules
+type RuleConflict struct {
+	Ptype string
+	// Allow is the rule whose effect is allow
+	Allow []string
+	// Deny is the rule whose effect is deny
+	Deny []string
+}
+
+// FindConflictingRules returns the pairs of rules of the p section which are identical except for the value
+// at effectFieldIndex, the effect column, which is "allow" in one and "deny" in the other, e.g. a deny rule
+// shadowing an allow rule with a deny-override model. Only the rules of ptypes are compared,
+// or the rules of every ptype of the p section if none is given.
+// The pairs are found by a single self-join of the table, ordered by ptype and values.
+// Values differing only by empty values and NULL (see WithNullValues) are identical.
+func (a *Adapter) FindConflictingRules(ctx context.Context, effectFieldIndex int, ptypes ...string) (_ []RuleConflict, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "FindConflictingRules"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	if effectFieldIndex < 0 || effectFieldIndex >= a.valueColumns {
+		return nil, fmt.Errorf("%w: effect field %d is out of the %d value columns", ErrInvalidFilter, effectFieldIndex, a.valueColumns)
+	}
+	values := make([]string, a.valueColumns)
+	joins := []string{"d.ptype = r.ptype"}
+	for i := range values {
+		values[i] = fmt.Sprintf("coalesce(r.v%d, '')", i)
+		if i != effectFieldIndex {
+			joins = append(joins, fmt.Sprintf("coalesce(d.v%[1]d, '') = coalesce(r.v%[1]d, '')", i))
+		}
+	}
+	if a.namespaced {
+		joins = append(joins, "d.namespace = r.namespace")
+	}
+	var args []any
+	filter := "r.ptype LIKE 'p%'"
+	if len(ptypes) > 0 {
+		args = append(args, ptypes)
+		filter = "r.ptype = ANY($1)"
+	}
+	sql := fmt.Sprintf(`SELECT DISTINCT r.ptype, %[1]v FROM "%[2]v" r JOIN "%[2]v" d ON %[3]v AND d.v%[4]d = 'deny'
+		WHERE %[5]v AND r.v%[4]d = 'allow'`,
+		strings.Join(values, ", "), a.readTable(ctx), strings.Join(joins, " AND "), effectFieldIndex, filter)
+	if a.namespaced {
+		args = append(args, a.namespaceOf(ctx))
+		sql += fmt.Sprintf(" AND r.namespace = $%d", len(args))
+	}
+	sql += " ORDER BY 1, " + strings.Join(values, ", ")
+
+	var conflicts []RuleConflict
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		conflicts = nil
+		rows, err := a.db.Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var ptype string
+		vals := make([]string, a.valueColumns)
+		dest := []any{&ptype}
+		for i := range vals {
+			dest = append(dest, &vals[i])
+		}
+		for rows.Next() {
+			if err := rows.Scan(dest...); err != nil {
+				return err
+			}
+			if err := a.decryptFields(vals); err != nil {
+				return err
+			}
+			allow := trimRule(slices.Clone(vals))
+			deny := slices.Clone(vals)
+			deny[effectFieldIndex] = "deny"
+			conflicts = append(conflicts, RuleConflict{Ptype: ptype, Allow: allow, Deny: trimRule(deny)})
+		}
+		return rows.Err()
+	})
+	return conflicts, err
+}
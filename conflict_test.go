@@ -0,0 +1,59 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestFindConflictingRules() {
+	ctx := context.Background()
+	s.Require().NoError(s.a.AddPolicies("p", "p", [][]string{
+		{"alice", "data1", "read", "allow"}, {"alice", "data1", "read", "deny"},
+		{"bob", "data2", "write", "deny"}, {"carol", "data2", "write", "allow"},
+	}))
+
+	conflicts, err := s.a.FindConflictingRules(ctx, 3)
+	s.Require().NoError(err)
+	s.Require().Equal([]RuleConflict{{Ptype: "p", Allow: []string{"alice", "data1", "read", "allow"}, Deny: []string{"alice", "data1", "read", "deny"}}}, conflicts)
+
+	conflicts, err = s.a.FindConflictingRules(ctx, 3, "p2")
+	s.Require().NoError(err)
+	s.Require().Empty(conflicts)
+}
+
+func TestMockFindConflictingRules(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT r.ptype, coalesce(r.v0, ''), coalesce(r.v1, ''), coalesce(r.v2, ''), coalesce(r.v3, ''), coalesce(r.v4, ''), coalesce(r.v5, '') `+
+		`FROM "casbin_rules" r JOIN "casbin_rules" d ON d.ptype = r.ptype AND coalesce(d.v0, '') = coalesce(r.v0, '') AND coalesce(d.v1, '') = coalesce(r.v1, '') `+
+		`AND coalesce(d.v3, '') = coalesce(r.v3, '') AND coalesce(d.v4, '') = coalesce(r.v4, '') AND coalesce(d.v5, '') = coalesce(r.v5, '') `+
+		`AND d.namespace = r.namespace AND d.v2 = 'deny'`)+`\s+`+
+		regexp.QuoteMeta(`WHERE r.ptype = ANY($1) AND r.v2 = 'allow' AND r.namespace = $2 ORDER BY 1, coalesce(r.v0, '')`)).
+		WithArgs([]string{"p"}, "tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("p", "alice", "data1", "allow", "", "", "").
+			AddRow("p", "bob", "data2", "allow", "", "", ""))
+	conflicts, err := a.FindConflictingRules(ctx, 2, "p")
+	require.NoError(t, err)
+	require.Equal(t, []RuleConflict{
+		{Ptype: "p", Allow: []string{"alice", "data1", "allow"}, Deny: []string{"alice", "data1", "deny"}},
+		{Ptype: "p", Allow: []string{"bob", "data2", "allow"}, Deny: []string{"bob", "data2", "deny"}},
+	}, conflicts)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE r.ptype LIKE 'p%' AND r.v3 = 'allow'`)).
+		WithArgs("tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	conflicts, err = a.FindConflictingRules(ctx, 3)
+	require.NoError(t, err)
+	require.Empty(t, conflicts)
+
+	_, err = a.FindConflictingRules(ctx, 6)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	_, err = a.FindConflictingRules(ctx, -1)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+}
@@ -0,0 +1,235 @@
+package pgxadapter
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// codeCheckViolation is the postgres error of a row rejected by a CHECK constraint
+const codeCheckViolation = "23514"
+
+// modelConstraintPrefix returns the prefix of the names of the constraints of InstallModelConstraints on table
+func modelConstraintPrefix(table string) string {
+	return table + "_model_"
+}
+
+// ModelConstraintError is wrapped by the errors of the writes rejected by a constraint of InstallModelConstraints
+type ModelConstraintError struct {
+	// Constraint is the name of the violated constraint
+	Constraint string
+	// Ptype is the ptype whose values the constraint checks, empty for the constraint checking the ptypes
+	Ptype string
+	// Err is the postgres error
+	Err error
+}
+
+func (e *ModelConstraintError) Error() string {
+	if e.Ptype == "" {
+		return fmt.Sprintf("ptype is not defined in the model, rejected by constraint %q: %v", e.Constraint, e.Err)
+	}
+	return fmt.Sprintf("rule has more values than ptype %q of the model, rejected by constraint %q: %v", e.Ptype, e.Constraint, e.Err)
+}
+
+func (e *ModelConstraintError) Unwrap() error {
+	return e.Err
+}
+
+// modelConstraintError returns the *ModelConstraintError of err if it is the violation of a constraint of
+// InstallModelConstraints on table, nil otherwise
+func modelConstraintError(table string, err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != codeCheckViolation {
+		return nil
+	}
+	suffix, ok := strings.CutPrefix(pgErr.ConstraintName, modelConstraintPrefix(table))
+	if !ok {
+		return nil
+	}
+	e := &ModelConstraintError{Constraint: pgErr.ConstraintName, Err: err}
+	if ptype, ok := strings.CutPrefix(suffix, "ptype_"); ok {
+		e.Ptype = ptype
+	}
+	return e
+}
+
+// modelConstraints returns the expressions of the constraints of InstallModelConstraints by name
+func (a *Adapter) modelConstraints(table string, m model.Model) (map[string]string, error) {
+	prefix := modelConstraintPrefix(table)
+	constraints := map[string]string{}
+	var ptypes []string
+	for _, sec := range []string{"p", "g"} {
+		for ptype, ast := range m[sec] {
+			if ptype == "" {
+				continue
+			}
+			n := len(ast.Tokens)
+			if n > a.valueColumns {
+				return nil, fmt.Errorf("ptype %q of the model has %d values, the table has %d value columns", ptype, n, a.valueColumns)
+			}
+			ptypes = append(ptypes, ptype)
+			if n == a.valueColumns {
+				continue
+			}
+			// the values of NULL columns, see WithNullValues, are empty
+			empty := make([]string, 0, a.valueColumns-n)
+			for i := n; i < a.valueColumns; i++ {
+				empty = append(empty, fmt.Sprintf("coalesce(v%d, '') = ''", i))
+			}
+			constraints[prefix+"ptype_"+ptype] = fmt.Sprintf("ptype <> %v OR (%v)", quoteLiteral(ptype), strings.Join(empty, " AND "))
+		}
+	}
+	if len(ptypes) == 0 {
+		return nil, errors.New("model defines no ptype")
+	}
+	sort.Strings(ptypes)
+	quoted := make([]string, len(ptypes))
+	for i, ptype := range ptypes {
+		quoted[i] = quoteLiteral(ptype)
+	}
+	constraints[prefix+"ptypes"] = fmt.Sprintf("ptype IN (%v)", strings.Join(quoted, ", "))
+	for name := range constraints {
+		if len(name) > maxIdentifierLength {
+			return nil, fmt.Errorf("constraint name %q is longer than %d bytes", name, maxIdentifierLength)
+		}
+	}
+	return constraints, nil
+}
+
+// sortedNames returns the names of the constraints in order
+func sortedNames(constraints map[string]string) []string {
+	names := make([]string, 0, len(constraints))
+	for name := range constraints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// constraintsFingerprint returns the hash of the constraints, the comment of the ptypes constraint
+// telling InstallModelConstraints whether the installed constraints changed
+func constraintsFingerprint(constraints map[string]string) string {
+	sum := sha256.New()
+	for _, name := range sortedNames(constraints) {
+		fmt.Fprintf(sum, "%v\x00%v\x00", name, constraints[name])
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// InstallModelConstraints adds CHECK constraints to the rules table so postgres rejects the rows not fitting the
+// definitions of m, including the rows written with SQL, e.g. by hand with psql: a constraint per ptype of m
+// requires the values beyond the tokens of the ptype to be empty, and another one requires the ptype to be defined in m.
+// The writes rejected by the constraints fail with an error wrapping a *ModelConstraintError.
+//
+// The constraints are replaced when InstallModelConstraints is called with a model whose definitions changed,
+// nothing is done when they are unchanged. Adding the constraints checks every stored rule, holding an exclusive lock
+// on the table meanwhile, and fails if a stored rule doesn't fit m. The constraints apply to every namespace of the table.
+// UninstallModelConstraints drops them.
+func (a *Adapter) InstallModelConstraints(ctx context.Context, m model.Model) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "InstallModelConstraints"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	table := a.table(ctx)
+	constraints, err := a.modelConstraints(table, m)
+	if err != nil {
+		return err
+	}
+	names := sortedNames(constraints)
+	fingerprint := constraintsFingerprint(constraints)
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	installed, comment, err := a.installedModelConstraints(ctx, tx, table)
+	if err != nil {
+		return err
+	}
+	if comment == fingerprint && len(installed) == len(names) {
+		return tx.Commit(ctx)
+	}
+	alters := make([]string, 0, len(installed)+len(names))
+	for _, name := range installed {
+		alters = append(alters, fmt.Sprintf(`DROP CONSTRAINT "%v"`, name))
+	}
+	for _, name := range names {
+		alters = append(alters, fmt.Sprintf(`ADD CONSTRAINT "%v" CHECK (%v)`, name, constraints[name]))
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`ALTER TABLE "%v" %v`, table, strings.Join(alters, ", "))); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, fmt.Sprintf(`COMMENT ON CONSTRAINT "%v" ON "%v" IS %v`, modelConstraintPrefix(table)+"ptypes", table, quoteLiteral(fingerprint)))
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// UninstallModelConstraints drops the constraints of InstallModelConstraints, nothing is done when they are not installed
+func (a *Adapter) UninstallModelConstraints(ctx context.Context) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "UninstallModelConstraints"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	table := a.table(ctx)
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	installed, _, err := a.installedModelConstraints(ctx, tx, table)
+	if err != nil {
+		return err
+	}
+	if len(installed) > 0 {
+		drops := make([]string, len(installed))
+		for i, name := range installed {
+			drops[i] = fmt.Sprintf(`DROP CONSTRAINT "%v"`, name)
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`ALTER TABLE "%v" %v`, table, strings.Join(drops, ", "))); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// installedModelConstraints returns the names of the constraints of InstallModelConstraints on table,
+// and the comment of its ptypes constraint
+func (a *Adapter) installedModelConstraints(ctx context.Context, q querier, table string) ([]string, string, error) {
+	rows, err := q.Query(ctx, `SELECT conname, coalesce(obj_description(oid, 'pg_constraint'), '') FROM pg_constraint
+		WHERE conrelid = $1::regclass AND contype = 'c' AND starts_with(conname, $2) ORDER BY conname`,
+		`"`+table+`"`, modelConstraintPrefix(table))
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var names []string
+	var fingerprint string
+	for rows.Next() {
+		var name, comment string
+		if err := rows.Scan(&name, &comment); err != nil {
+			return nil, "", err
+		}
+		names = append(names, name)
+		if name == modelConstraintPrefix(table)+"ptypes" {
+			fingerprint = comment
+		}
+	}
+	return names, fingerprint, rows.Err()
+}
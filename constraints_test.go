@@ -0,0 +1,121 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const domainModelText = `
+[request_definition]
+r = sub, dom, obj, act
+
+[policy_definition]
+p = sub, dom, obj, act
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && r.obj == p.obj && r.act == p.act
+`
+
+func (s *AdapterTestSuite) TestModelConstraints() {
+	ctx := context.Background()
+	pool := s.a.db.(*pgxpool.Pool)
+	a, err := NewAdapterByDB(pool, WithTableName("rules_constraints_test"))
+	s.Require().NoError(err)
+	defer pool.Exec(ctx, `DROP TABLE IF EXISTS "rules_constraints_test"`)
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a.InstallModelConstraints(ctx, m))
+	s.Require().NoError(a.InstallModelConstraints(ctx, m))
+
+	_, err = pool.Exec(ctx, `INSERT INTO "rules_constraints_test" (id, ptype, v0, v1, v2, v3) VALUES ('1', 'p', 'bob', 'domain1', 'data1', 'read')`)
+	s.Require().True(isPgError(err, codeCheckViolation), err)
+	_, err = pool.Exec(ctx, `INSERT INTO "rules_constraints_test" (id, ptype, v0, v1) VALUES ('2', 'p2', 'bob', 'data1')`)
+	s.Require().True(isPgError(err, codeCheckViolation), err)
+	err = a.AddPolicy("p", "p", []string{"bob", "domain1", "data1", "read"})
+	var constraintErr *ModelConstraintError
+	s.Require().ErrorAs(err, &constraintErr)
+	s.Assert().Equal("rules_constraints_test_model_ptype_p", constraintErr.Constraint)
+	s.Assert().Equal("p", constraintErr.Ptype)
+
+	// the rules of the new model are accepted once the constraints are replaced
+	s.Require().NoError(a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+	domains, err := model.NewModelFromString(domainModelText)
+	s.Require().NoError(err)
+	s.Require().NoError(a.InstallModelConstraints(ctx, domains))
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"bob", "domain1", "data1", "read"}))
+	err = a.AddPolicy("p", "p", []string{"bob", "domain1", "data1", "read", "allow"})
+	s.Require().ErrorAs(err, &constraintErr)
+	var def string
+	s.Require().NoError(a.queryRow(ctx, `SELECT pg_get_constraintdef(oid) FROM pg_constraint WHERE conname = 'rules_constraints_test_model_ptype_p'`, nil, &def))
+	s.Assert().NotContains(def, "v3")
+	s.Assert().Contains(def, "v4")
+
+	s.Require().NoError(a.UninstallModelConstraints(ctx))
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"bob", "domain1", "data1", "read", "allow"}))
+	s.Require().NoError(a.UninstallModelConstraints(ctx))
+}
+
+func TestMockModelConstraints(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	constraints, err := a.modelConstraints("casbin_rules", m)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"casbin_rules_model_ptype_p":  "ptype <> 'p' OR (coalesce(v3, '') = '' AND coalesce(v4, '') = '' AND coalesce(v5, '') = '')",
+		"casbin_rules_model_ptype_g":  "ptype <> 'g' OR (coalesce(v2, '') = '' AND coalesce(v3, '') = '' AND coalesce(v4, '') = '' AND coalesce(v5, '') = '')",
+		"casbin_rules_model_ptype_g2": "ptype <> 'g2' OR (coalesce(v2, '') = '' AND coalesce(v3, '') = '' AND coalesce(v4, '') = '' AND coalesce(v5, '') = '')",
+		"casbin_rules_model_ptypes":   "ptype IN ('g', 'g2', 'p')",
+	}, constraints)
+
+	installedSQL := regexp.QuoteMeta(`SELECT conname, coalesce(obj_description(oid, 'pg_constraint'), '') FROM pg_constraint`)
+	mock.ExpectBegin()
+	mock.ExpectQuery(installedSQL).WithArgs(`"casbin_rules"`, "casbin_rules_model_").
+		WillReturnRows(pgxmock.NewRows([]string{"conname", "comment"}).AddRow("casbin_rules_model_ptypes", "outdated"))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "casbin_rules" DROP CONSTRAINT "casbin_rules_model_ptypes", ` +
+		`ADD CONSTRAINT "casbin_rules_model_ptype_g" CHECK (ptype <> 'g' OR (coalesce(v2, '') = ''`)).
+		WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`COMMENT ON CONSTRAINT "casbin_rules_model_ptypes" ON "casbin_rules" IS '` + constraintsFingerprint(constraints) + `'`)).
+		WillReturnResult(pgxmock.NewResult("COMMENT", 0))
+	mock.ExpectCommit()
+	require.NoError(t, a.InstallModelConstraints(context.Background(), m))
+
+	// nothing is done when the constraints are unchanged
+	mock.ExpectBegin()
+	mock.ExpectQuery(installedSQL).
+		WillReturnRows(pgxmock.NewRows([]string{"conname", "comment"}).
+			AddRow("casbin_rules_model_ptype_g", "").
+			AddRow("casbin_rules_model_ptype_g2", "").
+			AddRow("casbin_rules_model_ptype_p", "").
+			AddRow("casbin_rules_model_ptypes", constraintsFingerprint(constraints)))
+	mock.ExpectCommit()
+	require.NoError(t, a.InstallModelConstraints(context.Background(), m))
+
+	_, err = a.modelConstraints("casbin_rules", model.Model{})
+	require.ErrorContains(t, err, "model defines no ptype")
+}
+
+func TestModelConstraintError(t *testing.T) {
+	err := modelConstraintError("casbin_rules", &pgconn.PgError{Code: codeCheckViolation, ConstraintName: "casbin_rules_model_ptype_p2"})
+	require.Equal(t, "p2", err.(*ModelConstraintError).Ptype)
+	err = modelConstraintError("casbin_rules", &pgconn.PgError{Code: codeCheckViolation, ConstraintName: "casbin_rules_model_ptypes"})
+	require.Empty(t, err.(*ModelConstraintError).Ptype)
+	require.ErrorContains(t, err, "ptype is not defined in the model")
+	require.Nil(t, modelConstraintError("casbin_rules", &pgconn.PgError{Code: codeCheckViolation, ConstraintName: "other"}))
+	require.Nil(t, modelConstraintError("casbin_rules", &pgconn.PgError{Code: codeUniqueViolation}))
+}
@@ -0,0 +1,32 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CredentialProvider returns the user and password of a new connection, e.g. a rotated password read from a
+// secret store or a short-lived IAM auth token. An empty user keeps the user of the config.
+type CredentialProvider func(ctx context.Context) (user, password string, err error)
+
+// WithCredentialProvider calls provider before each connection of the pool created by NewAdapter is opened,
+// including the connection creating the database, and connects with the credentials it returns,
+// so the pool keeps working when the password rotates. Established connections are not affected
+// and keep working until the pool recycles them, see pgxpool.Config.MaxConnLifetime.
+// An error of provider fails the connection. The provider runs after the hooks of WithBeforeConnect given before it.
+// The option has no effect on pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return WithBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+		user, password, err := provider(ctx)
+		if err != nil {
+			return fmt.Errorf("pgadapter: credential provider for host %v: %w", cc.Host, err)
+		}
+		if user != "" {
+			cc.User = user
+		}
+		cc.Password = password
+		return nil
+	})
+}
@@ -0,0 +1,42 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateTableHook returns the statements creating the rules table named table,
+// given defaultDDL, the CREATE TABLE statement the adapter would run
+type CreateTableHook func(defaultDDL string, table string) []string
+
+// WithCreateTableHook replaces the statement creating the rules table with the statements returned by hook,
+// e.g. defaultDDL with storage parameters or a tablespace, followed by a GRANT. The statements run in order,
+// only when the table doesn't exist yet, in the transaction creating the table when the adapter starts or in
+// EnsureTable, holding the advisory lock of WithBootstrapLock, so they never run twice. With WithTemporaryTable
+// they run without a transaction. The columns the adapter needs for its options are then added when missing
+// and the table is verified like any other, unless SkipSchemaVerification is used.
+func WithCreateTableHook(hook CreateTableHook) Option {
+	return func(a *Adapter) {
+		a.createTableHook = hook
+	}
+}
+
+// runCreateTableHook runs the statements of the hook of WithCreateTableHook when the rules table of ctx doesn't exist
+func (a *Adapter) runCreateTableHook(ctx context.Context, db execQuerier, defaultDDL string) error {
+	table := a.table(ctx)
+	var exists bool
+	if err := queryRowWith(ctx, db, `SELECT to_regclass($1) IS NOT NULL`, []any{pgx.Identifier{table}.Sanitize()}, &exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	for i, sql := range a.createTableHook(defaultDDL, table) {
+		if _, err := db.Exec(ctx, sql); err != nil {
+			return fmt.Errorf("create table hook statement %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestCreateTableHook() {
+	ctx := context.Background()
+	hook := func(defaultDDL, table string) []string {
+		return []string{
+			strings.Replace(defaultDDL, "ptype TEXT NOT NULL", "ptype TEXT NOT NULL,\n\t\t\tnote TEXT", 1),
+			fmt.Sprintf(`GRANT SELECT ON "%v" TO PUBLIC`, table),
+		}
+	}
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("casbin_rules_hook"), WithCreateTableHook(hook))
+	s.Require().NoError(err)
+	defer a.Close()
+	defer a.db.Exec(ctx, `DROP TABLE IF EXISTS "casbin_rules_hook"`)
+
+	var column, grant bool
+	s.Require().NoError(a.queryRow(ctx, `SELECT EXISTS (SELECT 1 FROM information_schema.columns
+		WHERE table_name = 'casbin_rules_hook' AND column_name = 'note')`, nil, &column))
+	s.Require().True(column)
+	s.Require().NoError(a.queryRow(ctx, `SELECT EXISTS (SELECT 1 FROM information_schema.table_privileges
+		WHERE table_name = 'casbin_rules_hook' AND grantee = 'PUBLIC' AND privilege_type = 'SELECT')`, nil, &grant))
+	s.Require().True(grant)
+
+	// the table exists, the hook doesn't run again
+	s.Require().NoError(a.EnsureTable(ctx))
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	// a table missing a column of the adapter is rejected
+	b, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("casbin_rules_hook_bad"), SkipTableCreate(), SkipSchemaVerification(),
+		WithCreateTableHook(func(defaultDDL, table string) []string {
+			return []string{fmt.Sprintf(`CREATE TABLE "%v" (id TEXT PRIMARY KEY, ptype TEXT NOT NULL)`, table)}
+		}))
+	s.Require().NoError(err)
+	defer b.Close()
+	defer b.db.Exec(ctx, `DROP TABLE IF EXISTS "casbin_rules_hook_bad"`)
+	s.Require().NoError(b.EnsureTable(ctx))
+	b.skipSchemaVerify = false
+	var schemaErr *SchemaError
+	s.Require().ErrorAs(b.EnsureTable(ctx), &schemaErr)
+}
+
+func TestMockCreateTableHook(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	var defaultDDL string
+	hook := func(ddl, table string) []string {
+		defaultDDL = ddl
+		return []string{ddl + " TABLESPACE fast", `GRANT SELECT ON "` + table + `" TO reader`}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).
+		WithArgs(advisoryLockKey(SchemaVersionTable)).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+		WithArgs(`"casbin_rules"`).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "casbin_rules" (?s:.*) TABLESPACE fast`).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`GRANT SELECT ON "casbin_rules" TO reader`)).
+		WillReturnResult(pgxmock.NewResult("GRANT", 0))
+	mock.ExpectCommit()
+	a, err := NewAdapterByPgxPool(mock, WithCreateTableHook(hook), SkipSchemaVerification())
+	require.NoError(t, err)
+	require.Contains(t, defaultDDL, `CREATE TABLE IF NOT EXISTS "casbin_rules"`)
+
+	// the table exists
+	defaultDDL = ""
+	ctx := context.Background()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectCommit()
+	require.NoError(t, a.EnsureTable(ctx))
+	require.Empty(t, defaultDDL)
+
+	// a failing statement rolls the creation back
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`CREATE TABLE`).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(`GRANT`).
+		WillReturnError(&pgconn.PgError{Code: "42704", Message: `role "reader" does not exist`})
+	mock.ExpectRollback()
+	require.ErrorContains(t, a.EnsureTable(ctx), "create table hook statement 2")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,147 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// duplicate is a group of rows storing the same rule
+type duplicate struct {
+	line *CasbinRule
+	ids  []string
+}
+
+// keep returns the id of the row kept among the duplicates, the one the adapter gives to the rule if stored,
+// and the ids of the rows to remove
+func (d duplicate) keep() (string, []string) {
+	keep := d.ids[0]
+	if slices.Contains(d.ids, d.line.ID) {
+		keep = d.line.ID
+	}
+	remove := make([]string, 0, len(d.ids)-1)
+	for _, id := range d.ids {
+		if id != keep {
+			remove = append(remove, id)
+		}
+	}
+	return keep, remove
+}
+
+// DeduplicateRules removes the rows storing the same rule as another row under a different id,
+// e.g. rows imported with another id scheme, which LoadPolicy would load twice.
+// Of every group of duplicates the row with the id the adapter gives to the rule is kept, or the lowest id if none has it.
+// Rows whose values differ only by empty values and NULL (see WithNullValues) are duplicates.
+// It returns the number of rows removed, or that would be removed when dryRun is set, in which case nothing is changed.
+// Every group is logged at the info level with the logger of WithLogger.
+// The groups are handled in batches of the size of WithScanBatchSize, each batch in its own transaction.
+func (a *Adapter) DeduplicateRules(ctx context.Context, dryRun bool) (removed int, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "DeduplicateRules"})
+	if err != nil {
+		return 0, err
+	}
+	defer finish(&err)
+
+	if dryRun {
+		groups, err := a.selectDuplicates(ctx, a.db, 0)
+		if err != nil {
+			return 0, err
+		}
+		for _, d := range groups {
+			removed += a.logDuplicate(ctx, d, true)
+		}
+		return removed, nil
+	}
+
+	for {
+		n, err := a.deduplicateBatch(ctx)
+		removed += n
+		if err != nil || n == 0 {
+			return removed, err
+		}
+	}
+}
+
+// deduplicateBatch removes the duplicates of a batch of groups in a transaction and returns the number of rows removed
+func (a *Adapter) deduplicateBatch(ctx context.Context) (int, error) {
+	tx, err := a.conn(ctx).Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	groups, err := a.selectDuplicates(ctx, tx, a.scanBatchSize)
+	if err != nil || len(groups) == 0 {
+		return 0, err
+	}
+	var ids []string
+	for _, d := range groups {
+		_, remove := d.keep()
+		ids = append(ids, remove...)
+	}
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE id = ANY($1)`, a.table(ctx)), []any{ids}, false)
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	var removed int
+	for _, d := range groups {
+		removed += a.logDuplicate(ctx, d, false)
+	}
+	return removed, nil
+}
+
+// selectDuplicates returns up to limit groups of duplicates, or all of them if limit is 0
+func (a *Adapter) selectDuplicates(ctx context.Context, q querier, limit int) ([]duplicate, error) {
+	values := make([]string, a.valueColumns)
+	for i := range values {
+		values[i] = fmt.Sprintf("coalesce(v%d, '')", i)
+	}
+	cols := "ptype, " + strings.Join(values, ", ")
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v, array_agg(id ORDER BY id) FROM "%v"`, cols, a.table(ctx)), nil, true)
+	sql += fmt.Sprintf(" GROUP BY %v HAVING count(*) > 1 ORDER BY %v", cols, cols)
+	if limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []duplicate
+	vals := make([]string, a.valueColumns)
+	dest := make([]any, 0, a.valueColumns+2)
+	var ptype string
+	var ids []string
+	dest = append(dest, &ptype)
+	for i := range vals {
+		dest = append(dest, &vals[i])
+	}
+	dest = append(dest, &ids)
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if err := a.decryptFields(vals); err != nil {
+			return nil, err
+		}
+		groups = append(groups, duplicate{line: a.policyLine(ctx, ptype, trimRule(slices.Clone(vals))), ids: ids})
+		ids = nil
+	}
+	return groups, rows.Err()
+}
+
+// logDuplicate logs the rows removed of a group of duplicates and returns their number
+func (a *Adapter) logDuplicate(ctx context.Context, d duplicate, dryRun bool) int {
+	keep, remove := d.keep()
+	a.logger.LogAttrs(ctx, slog.LevelInfo, "pgadapter: duplicate rule",
+		slog.String("table", a.table(ctx)), slog.String("rule", d.line.String()),
+		slog.String("kept", keep), slog.Any("removed", remove), slog.Bool("dry_run", dryRun))
+	return len(remove)
+}
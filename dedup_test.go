@@ -0,0 +1,81 @@
+package pgxadapter
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestDeduplicateRules() {
+	ctx := context.Background()
+	_, err := s.a.db.Exec(ctx, `INSERT INTO casbin_rules (id, ptype, v0, v1, v2, v3, v4, v5) VALUES
+		('legacy-1', 'p', 'alice', 'data1', 'read', '', '', ''),
+		('legacy-2', 'p', 'alice', 'data1', 'read', '', '', ''),
+		('legacy-3', 'p', 'carol', 'data3', 'read', '', '', ''),
+		('legacy-4', 'p', 'carol', 'data3', 'read', '', '', '')`)
+	s.Require().NoError(err)
+
+	removed, err := s.a.DeduplicateRules(ctx, true)
+	s.Require().NoError(err)
+	s.Require().Equal(3, removed)
+	count, err := s.a.CountRules(ctx, "p")
+	s.Require().NoError(err)
+	s.Require().EqualValues(8, count)
+
+	removed, err = s.a.DeduplicateRules(ctx, false)
+	s.Require().NoError(err)
+	s.Require().Equal(3, removed)
+	s.Require().NoError(s.e.LoadPolicy())
+	s.assertPolicy(
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}, {"carol", "data3", "read"}},
+		s.e.GetPolicy(),
+	)
+	// the id given by the adapter is kept
+	s.Require().NoError(s.a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+	count, err = s.a.CountRules(ctx, "p")
+	s.Require().NoError(err)
+	s.Require().EqualValues(4, count)
+}
+
+func TestMockDeduplicateRules(t *testing.T) {
+	var buf bytes.Buffer
+	a, mock := newMockAdapter(t, WithScanBatchSize(2), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	ctx := context.Background()
+	cols := []string{"ptype", "v0", "v1", "v2", "v3", "v4", "v5", "ids"}
+	alice := policyID("p", []string{"alice", "data1", "read"})
+	groups := regexp.QuoteMeta(`SELECT ptype, coalesce(v0, ''), coalesce(v1, ''), coalesce(v2, ''), coalesce(v3, ''), coalesce(v4, ''), coalesce(v5, ''), array_agg(id ORDER BY id) FROM "casbin_rules" GROUP BY ptype, coalesce(v0, '')`)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(groups + ".* HAVING count\\(\\*\\) > 1 .* LIMIT 2").
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("p", "alice", "data1", "read", "", "", "", []string{"a1", alice, "z9"}).
+			AddRow("p", "bob", "data2", "write", "", "", "", []string{"b1", "b2"}))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id = ANY($1)`)).WithArgs([]string{"a1", "z9", "b2"}).
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(groups).WillReturnRows(pgxmock.NewRows(cols))
+	mock.ExpectRollback()
+	removed, err := a.DeduplicateRules(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, 3, removed)
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 2)
+	require.Equal(t, "pgadapter: duplicate rule", entries[0]["msg"])
+	require.Equal(t, alice, entries[0]["kept"])
+	require.Equal(t, []any{"a1", "z9"}, entries[0]["removed"])
+	require.Equal(t, "b1", entries[1]["kept"])
+
+	// a dry run changes nothing
+	mock.ExpectQuery(groups).
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("p", "bob", "data2", "write", "", "", "", []string{"b1", "b2"}))
+	removed, err = a.DeduplicateRules(ctx, true)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+}
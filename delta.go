@@ -0,0 +1,90 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+// errHistoryDisabled is returned by the methods reading the history of the adapters created without WithHistory
+var errHistoryDisabled = errors.New("history is not recorded, see WithHistory")
+
+// LoadPolicyDelta applies to model the changes of the rules made since the watermark since, and returns
+// the watermark to pass to the next call, so a replica can keep its model up to date without reloading it.
+// The changes are read from the history table of WithHistory, which the trigger of the rules table writes in the
+// transaction of every change, so the rules removed by any write, including SavePolicy and the statements run by
+// hand, are removed from the model. An updated rule is removed then added with its new values.
+// The first call is made with the watermark of a full load, or the zero time to load every stored rule into an
+// empty model. The enforcer must rebuild its role links after a delta changing g rules, see BuildRoleLinks.
+//
+// The watermark is a time of the database server, so the clocks of the replicas don't matter. It is set before
+// the start of the transactions still writing when the delta is read, whose changes are then read again by the
+// next call, applying a change twice has no effect. This needs the role of the adapter to see the transactions of
+// the other roles writing the rules in pg_stat_activity, e.g. with pg_read_all_stats, otherwise a transaction
+// committing after the delta was read, with changes stamped before the watermark, is missed until a full load.
+// The changes whose history was deleted by PurgeHistory are missed as well, the watermark must be more recent
+// than the purge.
+func (a *Adapter) LoadPolicyDelta(ctx context.Context, model model.Model, since time.Time) (_ time.Time, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "LoadPolicyDelta"})
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer finish(&err)
+
+	if !a.history {
+		return time.Time{}, errHistoryDisabled
+	}
+	// the watermark is read before the snapshot, so a transaction committing in between is seen by both
+	var watermark time.Time
+	err = a.queryRow(ctx, `SELECT least(now(), min(xact_start)) FROM pg_stat_activity
+		WHERE datname = current_database() AND backend_xid IS NOT NULL`, nil, &watermark)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`); err != nil {
+		return time.Time{}, err
+	}
+
+	// the removals are applied first, a rule removed then added again since the watermark ends up in the model
+	if !since.IsZero() {
+		sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v" WHERE valid_to > $1`,
+			a.columns(), a.historyTable(ctx)), []any{since}, false)
+		sql += " ORDER BY history_id"
+		if err := a.loadRows(ctx, tx, sql, args, func(line *CasbinRule) error {
+			return removePolicyArray(line.tokens(), model)
+		}); err != nil {
+			return time.Time{}, err
+		}
+	}
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v" WHERE valid_from > $1 AND valid_to IS NULL`,
+		a.columns(), a.historyTable(ctx)), []any{since}, false)
+	sql += " ORDER BY history_id"
+	if err := a.loadRows(ctx, tx, sql, args, func(line *CasbinRule) error {
+		return persist.LoadPolicyArray(line.tokens(), model)
+	}); err != nil {
+		return time.Time{}, err
+	}
+	return watermark, nil
+}
+
+// removePolicyArray removes the rule of a policy array from model, it is the counterpart of persist.LoadPolicyArray.
+// The rules of a ptype the model doesn't define are not in it and are ignored.
+func removePolicyArray(rule []string, m model.Model) error {
+	ptype := rule[0]
+	sec := ptype[:1]
+	if _, ok := m[sec][ptype]; !ok {
+		return nil
+	}
+	m.RemovePolicy(sec, ptype, rule[1:])
+	return nil
+}
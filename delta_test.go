@@ -0,0 +1,123 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestLoadPolicyDelta() {
+	ctx := context.Background()
+	writer, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("rules_delta_test"), WithHistory())
+	s.Require().NoError(err)
+	defer writer.Close()
+	defer writer.db.Exec(ctx, `DROP FUNCTION IF EXISTS "rules_delta_test_history_record"() CASCADE`)
+	defer writer.db.Exec(ctx, `DROP TABLE IF EXISTS "rules_delta_test", "rules_delta_test_history"`)
+	replica, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("rules_delta_test"), WithHistory())
+	s.Require().NoError(err)
+	defer replica.Close()
+
+	s.Require().NoError(writer.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	since, err := replica.LoadPolicyDelta(ctx, m, time.Time{})
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}, m.GetPolicy("p", "p"))
+
+	// an add, an update and a removal
+	s.Require().NoError(writer.AddPolicy("g", "g", []string{"alice", "data2_admin"}))
+	s.Require().NoError(writer.UpdatePolicy("p", "p", []string{"bob", "data2", "write"}, []string{"bob", "data2", "read"}))
+	s.Require().NoError(writer.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+	next, err := replica.LoadPolicyDelta(ctx, m, since)
+	s.Require().NoError(err)
+	s.Require().True(next.After(since))
+	s.assertPolicy([][]string{{"bob", "data2", "read"}}, m.GetPolicy("p", "p"))
+	s.assertPolicy([][]string{{"alice", "data2_admin"}}, m.GetPolicy("g", "g"))
+
+	// applying the same delta again changes nothing
+	_, err = replica.LoadPolicyDelta(ctx, m, since)
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"bob", "data2", "read"}}, m.GetPolicy("p", "p"))
+
+	// a rule removed then added back stays
+	s.Require().NoError(writer.RemovePolicy("p", "p", []string{"bob", "data2", "read"}))
+	s.Require().NoError(writer.AddPolicy("p", "p", []string{"bob", "data2", "read"}))
+	_, err = replica.LoadPolicyDelta(ctx, m, next)
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"bob", "data2", "read"}}, m.GetPolicy("p", "p"))
+
+	_, err = s.a.LoadPolicyDelta(ctx, m, next)
+	s.Require().ErrorIs(err, errHistoryDisabled)
+}
+
+func TestMockLoadPolicyDelta(t *testing.T) {
+	a, mock := newMockAdapter(t, WithHistory(), WithNamespace("tenant1"))
+	ctx := context.Background()
+	since := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	watermark := since.Add(time.Minute)
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	m.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	m.AddPolicy("p", "p", []string{"bob", "data2", "write"})
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT least(now(), min(xact_start)) FROM pg_stat_activity`)).
+		WillReturnRows(pgxmock.NewRows([]string{"least"}).AddRow(watermark))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`)).
+		WillReturnResult(pgxmock.NewResult("SET", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules_history" WHERE valid_to > $1 AND namespace = $2 ORDER BY history_id`)).
+		WithArgs(since, "tenant1").
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("1", "p", "alice", "data1", "read", "", "", "").
+			AddRow("2", "p", "bob", "data2", "write", "", "", "").
+			AddRow("3", "p2", "carol", "data3", "read", "", "", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules_history" WHERE valid_from > $1 AND valid_to IS NULL AND namespace = $2 ORDER BY history_id`)).
+		WithArgs(since, "tenant1").
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("2", "p", "bob", "data2", "write", "", "", "").
+			AddRow("4", "g", "alice", "data2_admin", "", "", "", ""))
+	mock.ExpectRollback()
+
+	next, err := a.LoadPolicyDelta(ctx, m, since)
+	require.NoError(t, err)
+	require.Equal(t, watermark, next)
+	require.Equal(t, [][]string{{"bob", "data2", "write"}}, m.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "data2_admin"}}, m.GetPolicy("g", "g"))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	b, _ := newMockAdapter(t)
+	_, err = b.LoadPolicyDelta(ctx, m, since)
+	require.ErrorIs(t, err, errHistoryDisabled)
+}
+
+func TestMockLoadPolicyDeltaQuotedValues(t *testing.T) {
+	a, mock := newMockAdapter(t, WithHistory())
+	since := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	m.AddPolicy("p", "p", []string{"carol", `reports, "2026"`, "read"})
+
+	// the values are applied as stored, a comma or a quote doesn't split them
+	mock.ExpectQuery(`pg_stat_activity`).WillReturnRows(pgxmock.NewRows([]string{"least"}).AddRow(since))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET TRANSACTION`).WillReturnResult(pgxmock.NewResult("SET", 0))
+	mock.ExpectQuery(`WHERE valid_to > \$1`).WithArgs(since).
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "p", "carol", `reports, "2026"`, "read", "", "", ""))
+	mock.ExpectQuery(`WHERE valid_from > \$1`).WithArgs(since).
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("2", "p", "dave", `say "hi", bye`, "write", "", "", ""))
+	mock.ExpectRollback()
+
+	_, err = a.LoadPolicyDelta(context.Background(), m, since)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"dave", `say "hi", bye`, "write"}}, m.GetPolicy("p", "p"))
+}
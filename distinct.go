@@ -0,0 +1,86 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetDistinctValues returns the distinct values stored at fieldIndex by the rules of ptype, or of every ptype
+// if ptype is empty, in order, e.g. the subjects of the p rules with fieldIndex 0, or the domains of a model
+// with domains. The empty values, and the NULL values of WithNullValues, are never returned.
+// prefix, if not empty, keeps the values starting with it, and limit, if positive, caps the number of values,
+// e.g. for the suggestions of a type-ahead. The values are read from the namespace of the operation,
+// see WithNamespace and WithTenantFromContext.
+func (a *Adapter) GetDistinctValues(ctx context.Context, ptype string, fieldIndex int, prefix string, limit int) (_ []string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "GetDistinctValues", Ptype: ptype})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	if fieldIndex < 0 || fieldIndex >= a.valueColumns {
+		return nil, fmt.Errorf("%w: field index %d is out of the %d value columns", ErrInvalidFilter, fieldIndex, a.valueColumns)
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("pgadapter: limit can't be negative, got %d", limit)
+	}
+	encrypted := a.encryption.encrypts(fieldIndex)
+	if encrypted && prefix != "" {
+		return nil, a.checkUnencrypted(fieldIndex, "a prefix")
+	}
+	column := fmt.Sprintf("v%d", fieldIndex)
+	sql := fmt.Sprintf(`SELECT DISTINCT %[1]v FROM "%[2]v" WHERE %[1]v <> ''`, column, a.readTable(ctx))
+	var args []any
+	if ptype != "" {
+		args = append(args, ptype)
+		sql += fmt.Sprintf(" AND ptype = $%d", len(args))
+	}
+	if prefix != "" {
+		args = append(args, likeEscaper.Replace(a.normalization.normalize(fieldIndex, []string{prefix})[0])+"%")
+		sql += fmt.Sprintf(" AND %v LIKE $%d", column, len(args))
+	}
+	sql, args = a.inNamespace(ctx, sql, args, false)
+	sql += " ORDER BY 1"
+	// the encrypted values are sorted and limited once decrypted
+	if limit > 0 && !encrypted {
+		sql += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	var values []string
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		values = nil
+		rows, err := a.db.Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				return err
+			}
+			values = append(values, v)
+		}
+		return rows.Err()
+	})
+	if err != nil || !encrypted {
+		return values, err
+	}
+	for i, v := range values {
+		if values[i], err = a.encryption.decrypt(v); err != nil {
+			return nil, err
+		}
+	}
+	// a value encrypted with several keys is read several times
+	slices.Sort(values)
+	values = slices.Compact(values)
+	if limit > 0 && len(values) > limit {
+		values = values[:limit]
+	}
+	return values, nil
+}
@@ -0,0 +1,66 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestGetDistinctValues() {
+	ctx := context.Background()
+	s.Require().NoError(s.a.AddPolicies("p", "p", [][]string{{"alice", "data2", "read"}, {"al_1", "data1", "read"}}))
+	s.Require().NoError(s.a.AddPolicy("g", "g", []string{"carol", "alice"}))
+
+	values, err := s.a.GetDistinctValues(ctx, "p", 0, "", 0)
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"al_1", "alice", "bob", "data2_admin"}, values)
+	// the values are scoped to the ptype, or read from every ptype
+	values, err = s.a.GetDistinctValues(ctx, "g", 0, "", 0)
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"alice", "carol"}, values)
+	values, err = s.a.GetDistinctValues(ctx, "", 1, "", 0)
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"alice", "data1", "data2", "data2_admin"}, values)
+
+	// the wildcards of LIKE are matched literally
+	values, err = s.a.GetDistinctValues(ctx, "p", 0, "al_", 0)
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"al_1"}, values)
+	values, err = s.a.GetDistinctValues(ctx, "p", 0, "", 2)
+	s.Require().NoError(err)
+	s.Assert().Equal([]string{"al_1", "alice"}, values)
+
+	// the empty values are not returned
+	values, err = s.a.GetDistinctValues(ctx, "g", 2, "", 0)
+	s.Require().NoError(err)
+	s.Assert().Empty(values)
+}
+
+func TestMockGetDistinctValues(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT v0 FROM "casbin_rules" WHERE v0 <> '' AND ptype = $1 AND v0 LIKE $2 AND namespace = $3 ORDER BY 1 LIMIT 10`)).
+		WithArgs("p", `a\%b\_c\\%`, "tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"v0"}).AddRow(`a%b_c\d`))
+	values, err := a.GetDistinctValues(ctx, "p", 0, `a%b_c\`, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{`a%b_c\d`}, values)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT v2 FROM "casbin_rules" WHERE v2 <> '' AND namespace = $1 ORDER BY 1`)).
+		WithArgs("tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"v2"}))
+	values, err = a.GetDistinctValues(ctx, "", 2, "", 0)
+	require.NoError(t, err)
+	require.Empty(t, values)
+
+	_, err = a.GetDistinctValues(ctx, "p", 6, "", 0)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	_, err = a.GetDistinctValues(ctx, "p", -1, "", 0)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	_, err = a.GetDistinctValues(ctx, "p", 0, "", -1)
+	require.ErrorContains(t, err, "limit can't be negative")
+}
@@ -0,0 +1,101 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// DomainLayout maps the ptypes of a model with domains to the index of the value holding the domain in their rules,
+// e.g. DomainLayout{"p": 1, "g": 2} for the usual RBAC with domains model whose rules are
+// "p, sub, dom, obj, act" and "g, user, role, dom". The ptypes not in the layout are not loaded by LoadDomainPolicy.
+type DomainLayout map[string]int
+
+// ptypes returns the ptypes of the layout in order, after checking their indexes are in the value columns
+func (l DomainLayout) ptypes(columns int) ([]string, error) {
+	if len(l) == 0 {
+		return nil, fmt.Errorf("%w: domain layout is empty", ErrInvalidFilter)
+	}
+	ptypes := make([]string, 0, len(l))
+	for ptype, i := range l {
+		if i < 0 || i >= columns {
+			return nil, fmt.Errorf("%w: domain index %d of ptype %q is out of the %d value columns", ErrInvalidFilter, i, ptype, columns)
+		}
+		ptypes = append(ptypes, ptype)
+	}
+	slices.Sort(ptypes)
+	return ptypes, nil
+}
+
+// LoadDomainPolicy loads the rules of the ptypes of layout whose domain is domain into model, in a single query.
+// The adapter is marked as filtered afterwards, so the enforcer can't overwrite the other domains with SavePolicy.
+// EnsureDomainIndexes creates the indexes making the query efficient on large tables.
+func (a *Adapter) LoadDomainPolicy(ctx context.Context, model model.Model, domain string, layout DomainLayout) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "LoadDomainPolicy"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	ptypes, err := layout.ptypes(a.valueColumns)
+	if err != nil {
+		return err
+	}
+	if domain == "" {
+		return fmt.Errorf("%w: domain is empty", ErrInvalidFilter)
+	}
+
+	conds := make([]string, 0, len(ptypes))
+	args := make([]any, 0, 2*len(ptypes))
+	for _, ptype := range ptypes {
+		i := layout[ptype]
+		args = append(args, ptype, a.fieldArg(i, a.normalization.normalize(i, []string{domain})[0]))
+		conds = append(conds, fmt.Sprintf("(ptype = $%d AND v%d = $%d)", len(args)-1, i, len(args)))
+	}
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v" WHERE (%v)`, a.columns(), a.readTable(ctx), strings.Join(conds, " OR ")),
+		args, false)
+
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		err := a.loadRows(ctx, a.db, sql, args, func(line *CasbinRule) error {
+			return a.loadRule(line, model)
+		})
+		if err != nil {
+			return err
+		}
+		a.setFiltered(true)
+		return nil
+	})
+}
+
+// EnsureDomainIndexes creates, if they don't exist, the indexes on ptype and the domain columns of layout
+// used by LoadDomainPolicy. Building an index locks the table against writes, on large tables it is better
+// run when the rules are not being changed, or replaced by indexes created concurrently by hand.
+func (a *Adapter) EnsureDomainIndexes(ctx context.Context, layout DomainLayout) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "EnsureDomainIndexes"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	if _, err := layout.ptypes(a.valueColumns); err != nil {
+		return err
+	}
+	indexes := make([]int, 0, len(layout))
+	for _, i := range layout {
+		if !slices.Contains(indexes, i) {
+			indexes = append(indexes, i)
+		}
+	}
+	slices.Sort(indexes)
+
+	for _, i := range indexes {
+		_, err := a.db.Exec(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%[1]v_ptype_v%[2]d_idx" ON "%[1]v" (ptype, v%[2]d)`, a.table(ctx), i))
+		if err != nil && !isPgError(err, codeDuplicateTable, codeUniqueViolation) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestLoadDomainPolicy() {
+	ctx := context.Background()
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTemporaryTable(), WithTableName("rules_domains"))
+	s.Require().NoError(err)
+	defer a.Close()
+
+	s.Require().NoError(a.AddPolicies("p", "p", [][]string{
+		{"admin", "domain1", "data1", "read"},
+		{"admin", "domain2", "data2", "read"},
+	}))
+	s.Require().NoError(a.AddPolicies("g", "g", [][]string{
+		{"alice", "admin", "domain1"},
+		{"bob", "admin", "domain2"},
+	}))
+	layout := DomainLayout{"p": 1, "g": 2}
+	s.Require().NoError(a.EnsureDomainIndexes(ctx, layout))
+
+	m, err := model.NewModelFromFile("examples/rbac_with_domains_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a.LoadDomainPolicy(ctx, m, "domain1", layout))
+	s.Require().True(a.IsFiltered())
+	e, err := casbin.NewEnforcer(m)
+	s.Require().NoError(err)
+	s.Require().Equal([][]string{{"admin", "domain1", "data1", "read"}}, e.GetPolicy())
+	s.Require().Equal([][]string{{"alice", "admin", "domain1"}}, e.GetGroupingPolicy())
+	ok, err := e.Enforce("alice", "domain1", "data1", "read")
+	s.Require().NoError(err)
+	s.Require().True(ok)
+}
+
+func TestMockLoadDomainPolicy(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+	ctx := context.Background()
+	layout := DomainLayout{"p": 1, "g": 2}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ((ptype = $1 AND v2 = $2) OR (ptype = $3 AND v1 = $4)) AND namespace = $5`)).
+		WithArgs("g", "domain1", "p", "domain1", "tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", "admin", "domain1", "data1", "read", "", "").
+			AddRow("2", "g", "alice", "admin", "domain1", "", "", ""))
+	m, err := model.NewModelFromFile("examples/rbac_with_domains_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadDomainPolicy(ctx, m, "domain1", layout))
+	require.Equal(t, [][]string{{"admin", "domain1", "data1", "read"}}, m.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "admin", "domain1"}}, m.GetPolicy("g", "g"))
+	require.True(t, a.IsFiltered())
+
+	require.ErrorIs(t, a.LoadDomainPolicy(ctx, m, "domain1", DomainLayout{"p": 6}), ErrInvalidFilter)
+	require.ErrorIs(t, a.LoadDomainPolicy(ctx, m, "domain1", nil), ErrInvalidFilter)
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX IF NOT EXISTS "casbin_rules_ptype_v1_idx" ON "casbin_rules" (ptype, v1)`)).
+		WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX IF NOT EXISTS "casbin_rules_ptype_v2_idx" ON "casbin_rules" (ptype, v2)`)).
+		WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	require.NoError(t, a.EnsureDomainIndexes(ctx, layout))
+}
@@ -0,0 +1,195 @@
+package pgxadapter
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// WithDryRun makes the adapter run its writes in transactions which are always rolled back,
+// so the statements an operation would run can be reviewed before running it for real, e.g. a policy cleanup.
+// Every statement other than a read is logged at the info level with the logger of WithLogger,
+// with its operation, its SQL, its arguments and the number of rows it changed before the rollback.
+// The operations return as if they had succeeded, the commits of their transactions, including the one
+// of WithTx, are rollbacks. The reads run normally and don't see the rolled back writes.
+//
+// The rules table must already exist, the statements creating it when the adapter starts are rolled back too.
+// WithDryRun can't be used with WithTemporaryTable. PrepareTx, CreateReplicationSlot and DropReplicationSlot
+// return an error with it, their statements would not be rolled back.
+func WithDryRun() Option {
+	return func(a *Adapter) {
+		a.dryRun = true
+	}
+}
+
+// readStatements are the first keywords of the statements a dry run doesn't report nor roll back,
+// they don't change the rules or take part in the transactions the dry run already rolls back
+var readStatements = map[string]bool{
+	"SELECT":    true,
+	"SHOW":      true,
+	"SET":       true,
+	"LOCK":      true,
+	"SAVEPOINT": true,
+	"RELEASE":   true,
+	"ROLLBACK":  true,
+}
+
+// isWriteStatement reports whether sql may change the database
+func isWriteStatement(sql string) bool {
+	return !readStatements[statementKeyword(sql)]
+}
+
+// statementKeyword returns the first keyword of sql in upper case, skipping the leading comments
+func statementKeyword(sql string) string {
+	for {
+		sql = strings.TrimSpace(sql)
+		switch {
+		case strings.HasPrefix(sql, "--"):
+			_, sql, _ = strings.Cut(sql, "\n")
+		case strings.HasPrefix(sql, "/*"):
+			_, sql, _ = strings.Cut(sql, "*/")
+		default:
+			keyword, _, _ := strings.Cut(sql, " ")
+			keyword, _, _ = strings.Cut(keyword, "\n")
+			return strings.ToUpper(strings.TrimSpace(keyword))
+		}
+	}
+}
+
+// dryRunPool runs the writes sent to the pool in transactions which are rolled back, and rolls back
+// the transactions it begins instead of committing them, see WithDryRun
+type dryRunPool struct {
+	PgxPool
+	a *Adapter
+}
+
+func (p *dryRunPool) unwrap() PgxPool {
+	return p.PgxPool
+}
+
+func (p *dryRunPool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	if !isWriteStatement(sql) {
+		return p.PgxPool.Exec(ctx, sql, arguments...)
+	}
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer tx.Rollback(ctx)
+	return tx.Exec(ctx, sql, arguments...)
+}
+
+func (p *dryRunPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if !isWriteStatement(sql) {
+		return p.PgxPool.Query(ctx, sql, args...)
+	}
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	return &dryRunRows{Rows: rows, close: func() { tx.Rollback(ctx) }}, nil
+}
+
+func (p *dryRunPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.PgxPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dryRunTx{Tx: tx, a: p.a, top: true}, nil
+}
+
+// dryRunTx reports the writes of a transaction of a dryRunPool, its commit is a rollback
+type dryRunTx struct {
+	pgx.Tx
+	a *Adapter
+	// top is set for the transaction begun by the pool, the commits of its savepoints are kept
+	top bool
+}
+
+func (tx *dryRunTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	sp, err := tx.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dryRunTx{Tx: sp, a: tx.a}, nil
+}
+
+func (tx *dryRunTx) Commit(ctx context.Context) error {
+	if !tx.top {
+		return tx.Tx.Commit(ctx)
+	}
+	return tx.Tx.Rollback(ctx)
+}
+
+func (tx *dryRunTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	tag, err := tx.Tx.Exec(ctx, sql, arguments...)
+	if err == nil && isWriteStatement(sql) {
+		tx.a.logDryRun(ctx, sql, arguments, tag.RowsAffected())
+	}
+	return tag, err
+}
+
+func (tx *dryRunTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := tx.Tx.Query(ctx, sql, args...)
+	if err != nil || !isWriteStatement(sql) {
+		return rows, err
+	}
+	return &dryRunRows{Rows: rows, close: func() {
+		if rows.Err() == nil {
+			tx.a.logDryRun(ctx, sql, args, rows.CommandTag().RowsAffected())
+		}
+	}}, nil
+}
+
+func (tx *dryRunTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := tx.Query(ctx, sql, args...)
+	return &slowRow{rows: rows, err: err}
+}
+
+func (tx *dryRunTx) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	n, err := tx.Tx.CopyFrom(ctx, table, columns, src)
+	if err == nil {
+		tx.a.logDryRun(ctx, "COPY "+table.Sanitize()+" ("+strings.Join(columns, ", ")+") FROM STDIN", nil, n)
+	}
+	return n, err
+}
+
+// dryRunRows calls close once the rows of a write are closed
+type dryRunRows struct {
+	pgx.Rows
+	close func()
+	done  bool
+}
+
+func (r *dryRunRows) Close() {
+	r.Rows.Close()
+	if !r.done {
+		r.done = true
+		r.close()
+	}
+}
+
+func (r *dryRunRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+// logDryRun logs a write statement of a dry run, see WithDryRun
+func (a *Adapter) logDryRun(ctx context.Context, sql string, args []any, rows int64) {
+	a.logger.LogAttrs(ctx, slog.LevelInfo, "pgadapter: dry run statement",
+		slog.String("op", opFrom(ctx)),
+		slog.String("sql", strings.Join(strings.Fields(sql), " ")),
+		slog.Any("args", args),
+		slog.Int64("rows", rows))
+}
@@ -0,0 +1,111 @@
+package pgxadapter
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestDryRun() {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithDryRun(), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	s.Require().NoError(err)
+	defer a.Close()
+	buf.Reset()
+
+	s.Require().NoError(a.RemoveFilteredPolicy("p", "p", 1, "data2"))
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"carol", "data3", "read"}))
+	s.Require().NoError(a.WithTx(ctx, func(tx TxAdapter) error {
+		return tx.RemovePolicy("p", "p", []string{"alice", "data1", "read"})
+	}))
+
+	// nothing changed
+	count, err := s.a.CountRules(ctx, "p")
+	s.Require().NoError(err)
+	s.Require().EqualValues(4, count)
+
+	var rows []float64
+	for _, entry := range logEntries(s.T(), &buf) {
+		s.Require().Equal("pgadapter: dry run statement", entry["msg"])
+		rows = append(rows, entry["rows"].(float64))
+	}
+	s.Require().Equal([]float64{3, 1, 1}, rows)
+}
+
+func TestIsWriteStatement(t *testing.T) {
+	require.False(t, isWriteStatement(`SELECT id FROM "casbin_rules"`))
+	require.False(t, isWriteStatement("\n\t\tselect 1"))
+	require.False(t, isWriteStatement("/* tenant1 */ SELECT 1"))
+	require.False(t, isWriteStatement("-- routed\nSELECT 1"))
+	require.False(t, isWriteStatement("SAVEPOINT pgadapter_op"))
+	require.True(t, isWriteStatement(`DELETE FROM "casbin_rules" WHERE ptype = $1`))
+	require.True(t, isWriteStatement("/* tenant1 */ UPDATE t SET v0 = $1"))
+	require.True(t, isWriteStatement("\n\t\tCREATE TABLE IF NOT EXISTS t ()"))
+	require.True(t, isWriteStatement("WITH d AS (DELETE FROM t RETURNING *) SELECT count(*) FROM d"))
+}
+
+func TestMockDryRun(t *testing.T) {
+	var buf bytes.Buffer
+	a, mock := newMockAdapter(t, WithDryRun(), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	ctx := context.Background()
+
+	// the commit of the operation is a rollback
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v1 = $2`)).
+		WithArgs("p", "data2").
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	mock.ExpectRollback()
+	require.NoError(t, a.RemoveFilteredPolicy("p", "p", 1, "data2"))
+
+	// a write sent to the pool runs in a transaction rolled back once its rows are read
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM "casbin_rules" g WHERE g.ptype = 'g'`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "g", "bob", "ghost", "", "", "", ""))
+	mock.ExpectRollback()
+	orphans, err := a.RemoveOrphanGroupingRules(ctx, false)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"bob", "ghost"}}, orphans)
+
+	// reads run normally
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(4)))
+	count, err := a.CountRules(ctx, "")
+	require.NoError(t, err)
+	require.EqualValues(t, 4, count)
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 2)
+	require.Equal(t, "pgadapter: dry run statement", entries[0]["msg"])
+	require.Equal(t, "RemoveFilteredPolicy", entries[0]["op"])
+	require.Equal(t, `DELETE FROM "casbin_rules" WHERE ptype = $1 AND v1 = $2`, entries[0]["sql"])
+	require.Equal(t, []any{"p", "data2"}, entries[0]["args"])
+	require.EqualValues(t, 3, entries[0]["rows"])
+	require.Equal(t, "RemoveOrphanGroupingRules", entries[1]["op"])
+
+	_, err = newAdapter([]Option{WithDryRun(), WithTemporaryTable()})
+	require.EqualError(t, err, "pgadapter.NewAdapter: WithDryRun can't be used with WithTemporaryTable")
+}
+
+func TestMockCreateReplicationSlotDryRun(t *testing.T) {
+	a, _ := newMockAdapter(t, WithDryRun())
+
+	// the slot is not created, pg_create_logical_replication_slot is not rolled back
+	err := a.CreateReplicationSlot(context.Background(), "casbin")
+	require.ErrorContains(t, err, "CreateReplicationSlot can't be used with WithDryRun")
+}
+
+func TestMockDropReplicationSlotDryRun(t *testing.T) {
+	a, _ := newMockAdapter(t, WithDryRun())
+
+	// the slot is not dropped, pg_drop_replication_slot is not rolled back
+	err := a.DropReplicationSlot(context.Background(), "casbin")
+	require.ErrorContains(t, err, "DropReplicationSlot can't be used with WithDryRun")
+}
@@ -0,0 +1,284 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DualWriteMode tells how the writes to the secondary table of WithDualWrite fail
+type DualWriteMode int
+
+const (
+	// DualWriteBestEffort logs the failed writes to the secondary table, the operations succeed when the rules table is written
+	DualWriteBestEffort DualWriteMode = iota
+	// DualWriteStrict fails the operations whose writes to the secondary table fail, nothing is written to either table
+	DualWriteStrict
+)
+
+func (m DualWriteMode) String() string {
+	switch m {
+	case DualWriteBestEffort:
+		return "best effort"
+	case DualWriteStrict:
+		return "strict"
+	}
+	return fmt.Sprintf("DualWriteMode(%d)", int(m))
+}
+
+// dualWriteStatements are the first keywords of the statements WithDualWrite runs on the secondary table too
+var dualWriteStatements = map[string]bool{
+	"INSERT":   true,
+	"UPDATE":   true,
+	"DELETE":   true,
+	"TRUNCATE": true,
+}
+
+// WithDualWrite makes the adapter apply every change of the rules to table too, e.g. the new table of a migration,
+// so the reads can be switched to it once both tables store the same rules, see VerifyParity.
+// table is in the database of the adapter and must have the columns of the rules table, the adapter doesn't create it.
+//
+// The statements inserting, updating and deleting the rows of the rules table are run again with table in place of
+// the rules table, in the transaction of the operation, or in a transaction begun for both when the statement
+// runs alone. With DualWriteStrict, an operation whose statement fails on table fails and is rolled back.
+// With DualWriteBestEffort, the statement runs in a savepoint and its failure is logged as a warning with
+// the logger of WithLogger, the operation goes on. The tables of WithHistory and WithRevisions, and the tables
+// returned by a TableResolver, are not written twice.
+// WithDualWrite can't be used with WithTemporaryTable or WithSwapSave: the final step of a swap save copies the rules
+// between the tables with SELECT *, run again on table it would depend on its column order and, with WithNamespace,
+// copy the rules of the other namespaces into the staging table twice.
+func WithDualWrite(table string, mode DualWriteMode) Option {
+	return func(a *Adapter) {
+		a.dualWriteTable = table
+		a.dualWriteMode = mode
+	}
+}
+
+// mirror returns the statement writing sql to the secondary table of WithDualWrite, it returns false
+// when sql doesn't write the rules table
+func (a *Adapter) mirror(sql string) (string, bool) {
+	primary := `"` + a.TableName() + `"`
+	if !dualWriteStatements[statementKeyword(sql)] || !strings.Contains(sql, primary) {
+		return "", false
+	}
+	return strings.ReplaceAll(sql, primary, `"`+a.dualWriteTable+`"`), true
+}
+
+// mirrorFailed logs the failure of a statement on the secondary table in best effort mode
+func (a *Adapter) mirrorFailed(ctx context.Context, sql string, err error) {
+	a.logger.LogAttrs(ctx, slog.LevelWarn, "pgadapter: dual write to the secondary table failed",
+		slog.String("op", opFrom(ctx)),
+		slog.String("table", a.dualWriteTable),
+		slog.String("sql", strings.Join(strings.Fields(sql), " ")),
+		slog.String("error", err.Error()))
+}
+
+// dualWritePool runs the writes of the rules table on the secondary table of WithDualWrite too
+type dualWritePool struct {
+	PgxPool
+	a *Adapter
+}
+
+func (p *dualWritePool) unwrap() PgxPool {
+	return p.PgxPool
+}
+
+func (p *dualWritePool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	mirrored, ok := p.a.mirror(sql)
+	if !ok {
+		return p.PgxPool.Exec(ctx, sql, arguments...)
+	}
+	if p.a.dualWriteMode != DualWriteStrict {
+		tag, err := p.PgxPool.Exec(ctx, sql, arguments...)
+		if err != nil {
+			return tag, err
+		}
+		if _, err := p.PgxPool.Exec(ctx, mirrored, arguments...); err != nil {
+			p.a.mirrorFailed(ctx, mirrored, err)
+		}
+		return tag, nil
+	}
+
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(ctx, sql, arguments...)
+	if err != nil {
+		return tag, err
+	}
+	return tag, tx.Commit(ctx)
+}
+
+func (p *dualWritePool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	mirrored, ok := p.a.mirror(sql)
+	if !ok {
+		return p.PgxPool.Query(ctx, sql, args...)
+	}
+	if p.a.dualWriteMode != DualWriteStrict {
+		if _, err := p.PgxPool.Exec(ctx, mirrored, args...); err != nil {
+			p.a.mirrorFailed(ctx, mirrored, err)
+		}
+		return p.PgxPool.Query(ctx, sql, args...)
+	}
+
+	// the transaction writing both tables is committed once the rows of the rules table are read
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	return &dryRunRows{Rows: rows, close: func() {
+		if rows.Err() != nil {
+			tx.Rollback(ctx)
+			return
+		}
+		if err := tx.Commit(ctx); err != nil {
+			p.a.logger.LogAttrs(ctx, slog.LevelError, "pgadapter: dual write commit failed",
+				slog.String("op", opFrom(ctx)), slog.String("error", err.Error()))
+		}
+	}}, nil
+}
+
+func (p *dualWritePool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.PgxPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dualWriteTx{Tx: tx, a: p.a}, nil
+}
+
+// dualWriteTx runs the writes of the rules table on the secondary table too, in the transaction
+type dualWriteTx struct {
+	pgx.Tx
+	a *Adapter
+}
+
+func (tx *dualWriteTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	sp, err := tx.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &dualWriteTx{Tx: sp, a: tx.a}, nil
+}
+
+// execMirror runs the statement on the secondary table, in a savepoint in best effort mode
+func (tx *dualWriteTx) execMirror(ctx context.Context, sql string, arguments []any) error {
+	if tx.a.dualWriteMode == DualWriteStrict {
+		_, err := tx.Tx.Exec(ctx, sql, arguments...)
+		return err
+	}
+	sp, err := tx.Tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := sp.Exec(ctx, sql, arguments...); err != nil {
+		tx.a.mirrorFailed(ctx, sql, err)
+		return sp.Rollback(ctx)
+	}
+	return sp.Commit(ctx)
+}
+
+func (tx *dualWriteTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	tag, err := tx.Tx.Exec(ctx, sql, arguments...)
+	if err != nil {
+		return tag, err
+	}
+	if mirrored, ok := tx.a.mirror(sql); ok {
+		if err := tx.execMirror(ctx, mirrored, arguments); err != nil {
+			return tag, err
+		}
+	}
+	return tag, nil
+}
+
+func (tx *dualWriteTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	// the secondary table is written first, the rows of the rules table are read by the caller
+	if mirrored, ok := tx.a.mirror(sql); ok {
+		if err := tx.execMirror(ctx, mirrored, args); err != nil {
+			return nil, err
+		}
+	}
+	return tx.Tx.Query(ctx, sql, args...)
+}
+
+func (tx *dualWriteTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := tx.Query(ctx, sql, args...)
+	return &slowRow{rows: rows, err: err}
+}
+
+// ParityReport is the difference between the rules table and the secondary table of WithDualWrite, see VerifyParity
+type ParityReport struct {
+	// Missing are the ids of the rows of the rules table which are not in the secondary table
+	Missing []string
+	// Extra are the ids of the rows of the secondary table which are not in the rules table
+	Extra []string
+	// Different are the ids of the rows of both tables whose ptype or values differ
+	Different []string
+}
+
+// Equal tells whether both tables store the same rows
+func (r ParityReport) Equal() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Different) == 0
+}
+
+// VerifyParity compares the rows of the rules table and of the secondary table of WithDualWrite by id,
+// in the namespace of the operation when the table is namespaced. The ids are returned in order.
+func (a *Adapter) VerifyParity(ctx context.Context) (_ ParityReport, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "VerifyParity"})
+	if err != nil {
+		return ParityReport{}, err
+	}
+	defer finish(&err)
+
+	if a.dualWriteTable == "" {
+		return ParityReport{}, fmt.Errorf("pgadapter: no secondary table, see WithDualWrite")
+	}
+	values := []string{"ptype"}
+	for i := 0; i < a.valueColumns; i++ {
+		values = append(values, fmt.Sprintf("v%d", i))
+	}
+	cols := "id, " + strings.Join(values, ", ")
+	primary, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v"`, cols, a.table(ctx)), nil, true)
+	secondary, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v"`, cols, a.dualWriteTable), args, true)
+	p, s := make([]string, len(values)), make([]string, len(values))
+	for i, v := range values {
+		p[i], s[i] = "p."+v, "s."+v
+	}
+	sql := fmt.Sprintf(`SELECT coalesce(p.id, s.id), p.id IS NULL, s.id IS NULL FROM (%v) p FULL JOIN (%v) s ON p.id = s.id
+		WHERE p.id IS NULL OR s.id IS NULL OR (%v) IS DISTINCT FROM (%v) ORDER BY 1`,
+		primary, secondary, strings.Join(p, ", "), strings.Join(s, ", "))
+
+	rows, err := a.db.Query(ctx, sql, args...)
+	if err != nil {
+		return ParityReport{}, err
+	}
+	defer rows.Close()
+
+	var report ParityReport
+	for rows.Next() {
+		var id string
+		var extra, missing bool
+		if err := rows.Scan(&id, &extra, &missing); err != nil {
+			return ParityReport{}, err
+		}
+		switch {
+		case missing:
+			report.Missing = append(report.Missing, id)
+		case extra:
+			report.Extra = append(report.Extra, id)
+		default:
+			report.Different = append(report.Different, id)
+		}
+	}
+	return report, rows.Err()
+}
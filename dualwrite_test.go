@@ -0,0 +1,149 @@
+package pgxadapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestDualWrite() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+	pool.Exec(ctx, `DROP TABLE IF EXISTS rules_dual_old, rules_dual_new`)
+	defer pool.Exec(ctx, `DROP TABLE IF EXISTS rules_dual_old, rules_dual_new`)
+	_, err = NewAdapterByDB(pool, WithTableName("rules_dual_new"))
+	s.Require().NoError(err)
+
+	a, err := NewAdapterByDB(pool, WithTableName("rules_dual_old"), WithDualWrite("rules_dual_new", DualWriteStrict))
+	s.Require().NoError(err)
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", "examples/rbac_policy.csv")
+	s.Require().NoError(err)
+	s.Require().NoError(a.SavePolicy(e.GetModel()))
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"carol", "data3", "read"}))
+	s.Require().NoError(a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+	s.Require().NoError(a.UpdatePolicy("p", "p", []string{"bob", "data2", "write"}, []string{"bob", "data2", "read"}))
+	s.Require().NoError(a.RemoveFilteredPolicy("p", "p", 0, "data2_admin"))
+	report, err := a.VerifyParity(ctx)
+	s.Require().NoError(err)
+	s.Assert().True(report.Equal(), "%+v", report)
+
+	b, err := NewAdapterByDB(pool, WithTableName("rules_dual_new"))
+	s.Require().NoError(err)
+	count, err := b.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Assert().EqualValues(3, count)
+
+	// a rule only in the secondary table, and one changed by hand
+	_, err = pool.Exec(ctx, `INSERT INTO rules_dual_new (id, ptype, v0) VALUES ('extra', 'p', 'dave')`)
+	s.Require().NoError(err)
+	_, err = pool.Exec(ctx, `UPDATE rules_dual_new SET v2 = 'write' WHERE v0 = 'bob'`)
+	s.Require().NoError(err)
+	_, err = pool.Exec(ctx, `DELETE FROM rules_dual_new WHERE v0 = 'carol'`)
+	s.Require().NoError(err)
+	report, err = a.VerifyParity(ctx)
+	s.Require().NoError(err)
+	s.Assert().Equal(ParityReport{
+		Missing:   []string{ComputePolicyID("p", []string{"carol", "data3", "read"})},
+		Extra:     []string{"extra"},
+		Different: []string{ComputePolicyID("p", []string{"bob", "data2", "read"})},
+	}, report)
+
+	// the secondary table rejects the writes once it is gone
+	_, err = pool.Exec(ctx, `DROP TABLE rules_dual_new`)
+	s.Require().NoError(err)
+	s.Require().ErrorIs(a.AddPolicy("p", "p", []string{"erin", "data4", "read"}), ErrTableNotExist)
+	ok, err := a.ExistsMatchingPolicy(ctx, "p", map[int]string{0: "erin"})
+	s.Require().NoError(err)
+	s.Assert().False(ok)
+
+	var buf bytes.Buffer
+	c, err := NewAdapterByDB(pool, WithTableName("rules_dual_old"), WithDualWrite("rules_dual_new", DualWriteBestEffort),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	s.Require().NoError(err)
+	s.Require().NoError(c.AddPolicy("p", "p", []string{"erin", "data4", "read"}))
+	ok, err = c.ExistsMatchingPolicy(ctx, "p", map[int]string{0: "erin"})
+	s.Require().NoError(err)
+	s.Assert().True(ok)
+	s.Assert().Contains(buf.String(), "dual write to the secondary table failed")
+}
+
+func TestMockDualWriteStrict(t *testing.T) {
+	a, mock := newMockAdapter(t, WithDualWrite("rules_new", DualWriteStrict))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "rules_new"`)).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	// the failure of the secondary table rolls back the operation
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id=$1`)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "rules_new" WHERE id=$1`)).WillReturnError(errors.New("secondary is down"))
+	mock.ExpectRollback()
+	require.ErrorContains(t, a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}), "secondary is down")
+
+	// the reads only use the rules table
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	_, err := a.CountRules(context.Background(), "")
+	require.NoError(t, err)
+}
+
+func TestMockDualWriteBestEffort(t *testing.T) {
+	var buf bytes.Buffer
+	a, mock := newMockAdapter(t, WithDualWrite("rules_new", DualWriteBestEffort), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id=$1`)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "rules_new" WHERE id=$1`)).WillReturnError(errors.New("secondary is down"))
+	mock.ExpectRollback()
+	mock.ExpectCommit()
+	require.NoError(t, a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+	require.Contains(t, buf.String(), `"msg":"pgadapter: dual write to the secondary table failed"`)
+	require.Contains(t, buf.String(), `"table":"rules_new"`)
+	require.Contains(t, buf.String(), `"error":"secondary is down"`)
+}
+
+func TestMockVerifyParity(t *testing.T) {
+	a, mock := newMockAdapter(t, WithDualWrite("rules_new", DualWriteStrict), WithNamespace("tenant1"), WithValueColumns(2))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT coalesce(p.id, s.id), p.id IS NULL, s.id IS NULL FROM (SELECT id, ptype, v0, v1 FROM "casbin_rules" WHERE namespace = $1) p `+
+		`FULL JOIN (SELECT id, ptype, v0, v1 FROM "rules_new" WHERE namespace = $2) s ON p.id = s.id`)+`\s+`+
+		regexp.QuoteMeta(`WHERE p.id IS NULL OR s.id IS NULL OR (p.ptype, p.v0, p.v1) IS DISTINCT FROM (s.ptype, s.v0, s.v1) ORDER BY 1`)).
+		WithArgs("tenant1", "tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "extra", "missing"}).
+			AddRow("a", false, true).
+			AddRow("b", true, false).
+			AddRow("c", false, false))
+	report, err := a.VerifyParity(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, ParityReport{Missing: []string{"a"}, Extra: []string{"b"}, Different: []string{"c"}}, report)
+	require.False(t, report.Equal())
+
+	b, _ := newMockAdapter(t)
+	_, err = b.VerifyParity(context.Background())
+	require.ErrorContains(t, err, "WithDualWrite")
+
+	for _, opts := range [][]Option{
+		{WithDualWrite("casbin_rules", DualWriteStrict)},
+		{WithDualWrite(`bad"name`, DualWriteStrict)},
+		{WithDualWrite("rules_new", DualWriteMode(5))},
+		{WithDualWrite("rules_new", DualWriteStrict), WithSwapSave()},
+	} {
+		_, err = NewAdapterByPgxPool(nil, append(opts, SkipTableCreate(), SkipSchemaVerification())...)
+		require.Error(t, err)
+	}
+}
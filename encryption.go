@@ -0,0 +1,380 @@
+package pgxadapter
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// KeyProvider gives the AES keys of WithEncryption, identified by a version stored with every encrypted value
+type KeyProvider interface {
+	// CurrentKey returns the key encrypting the values and its version
+	CurrentKey() (version uint32, key []byte, err error)
+	// Key returns the key of version, to decrypt the values encrypted with it
+	Key(version uint32) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider holding the keys in memory, by version
+type StaticKeys struct {
+	// Current is the version of the key encrypting the values
+	Current uint32
+	// Keys are the keys by version, of 16, 24 or 32 bytes for AES-128, AES-192 or AES-256
+	Keys map[uint32][]byte
+}
+
+func (k StaticKeys) CurrentKey() (uint32, []byte, error) {
+	key, err := k.Key(k.Current)
+	return k.Current, key, err
+}
+
+func (k StaticKeys) Key(version uint32) ([]byte, error) {
+	key, ok := k.Keys[version]
+	if !ok {
+		return nil, fmt.Errorf("pgadapter: no encryption key of version %d", version)
+	}
+	return key, nil
+}
+
+// encryptedPrefix starts the encrypted values, the values without it are stored in plain text
+const encryptedPrefix = "enc:"
+
+// encryptionHeader is the length of the header of the encrypted values, the version of their key
+const encryptionHeader = 4
+
+// WithEncryption encrypts the values of the rules at the given field indexes, 0 for v0, with AES-GCM and the keys
+// of keys, e.g. the fields holding personal identifiers. The values are encrypted before they are written
+// and decrypted when they are read, the ids of the rules are computed from the plain values so they don't change.
+// An encrypted value is stored as "enc:" followed by the base64 of the version of its key, the nonce and the ciphertext.
+// The empty values are stored as they are.
+//
+// The encryption is deterministic: the nonce is derived from the value and the key, so a value is always encrypted
+// the same way with a key. The filters of LoadFilteredPolicy, RemoveFilteredPolicy and the other operations
+// comparing values can then match the encrypted fields, the trade-off being that the rows storing the same value
+// can be told apart from the others without the key. The comparisons use the current key: after a key rotation,
+// the values encrypted with the previous keys are still decrypted but not matched until ReencryptRules encrypts
+// them with the current key. The operations matching a part of a value, such as the prefix of GetDistinctValues
+// and the FieldContains of SearchRules, and sorting by an encrypted field, fail with ErrEncryptedField.
+//
+// The values stored before WithEncryption is used are read as they are, ReencryptRules encrypts them.
+// The statements run on the database, such as the triggers of InstallIDTrigger and the queries of
+// FindOrphanGroupingRules comparing an encrypted field with another one, see the encrypted values,
+// and so do the tables of WithHistory and the staged changes, which store them encrypted or not as they are given.
+func WithEncryption(keys KeyProvider, fields ...int) Option {
+	return func(a *Adapter) {
+		a.encryption = &encryption{keys: keys, fields: fields, ciphers: map[uint32]*valueCipher{}}
+	}
+}
+
+// encryption encrypts the values of the fields of WithEncryption
+type encryption struct {
+	keys   KeyProvider
+	fields []int
+
+	// ciphers caches the ciphers of the keys by version
+	mu      sync.Mutex
+	ciphers map[uint32]*valueCipher
+}
+
+// valueCipher encrypts the values with a key
+type valueCipher struct {
+	aead cipher.AEAD
+	// nonceKey derives the nonces from the values
+	nonceKey []byte
+}
+
+// validate checks the fields and the current key
+func (e *encryption) validate(columns int) error {
+	if e.keys == nil {
+		return errors.New("no key provider")
+	}
+	if len(e.fields) == 0 {
+		return errors.New("no field to encrypt")
+	}
+	for _, i := range e.fields {
+		if i < 0 || i >= columns {
+			return fmt.Errorf("field index %d is out of the %d value columns", i, columns)
+		}
+	}
+	_, _, err := e.current()
+	return err
+}
+
+// encrypts tells whether the values of field i are encrypted
+func (e *encryption) encrypts(i int) bool {
+	return e != nil && slices.Contains(e.fields, i)
+}
+
+// cipher returns the cipher of the key of version, key is the key of version when it is known already
+func (e *encryption) cipher(version uint32, key []byte) (*valueCipher, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.ciphers[version]; ok {
+		return c, nil
+	}
+	if key == nil {
+		var err error
+		if key, err = e.keys.Key(version); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("pgadapter: encryption key of version %d: %w", version, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("pgadapter nonce"))
+	c := &valueCipher{aead: aead, nonceKey: mac.Sum(nil)}
+	e.ciphers[version] = c
+	return c, nil
+}
+
+// current returns the cipher of the current key and its version
+func (e *encryption) current() (*valueCipher, uint32, error) {
+	version, key, err := e.keys.CurrentKey()
+	if err != nil {
+		return nil, 0, err
+	}
+	c, err := e.cipher(version, key)
+	return c, version, err
+}
+
+// encrypt returns the encrypted value of v with the current key, the empty values are not encrypted
+func (e *encryption) encrypt(v string) (string, error) {
+	if v == "" {
+		return v, nil
+	}
+	c, version, err := e.current()
+	if err != nil {
+		return "", err
+	}
+	header := binary.BigEndian.AppendUint32(nil, version)
+	mac := hmac.New(sha256.New, c.nonceKey)
+	mac.Write([]byte(v))
+	nonce := mac.Sum(nil)[:c.aead.NonceSize()]
+	data := c.aead.Seal(append(slices.Clone(header), nonce...), nonce, []byte(v), header)
+	return encryptedPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decrypt returns the plain value of v, the values without the prefix of the encrypted values are returned as they are
+func (e *encryption) decrypt(v string) (string, error) {
+	encoded, ok := strings.CutPrefix(v, encryptedPrefix)
+	if !ok {
+		return v, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(data) < encryptionHeader {
+		return "", fmt.Errorf("pgadapter: malformed encrypted value %q", v)
+	}
+	version := binary.BigEndian.Uint32(data)
+	c, err := e.cipher(version, nil)
+	if err != nil {
+		return "", err
+	}
+	header, rest := data[:encryptionHeader], data[encryptionHeader:]
+	if len(rest) < c.aead.NonceSize() {
+		return "", fmt.Errorf("pgadapter: malformed encrypted value %q", v)
+	}
+	plain, err := c.aead.Open(nil, rest[:c.aead.NonceSize()], rest[c.aead.NonceSize():], header)
+	if err != nil {
+		return "", fmt.Errorf("pgadapter: decrypting a value with the key of version %d: %w", version, err)
+	}
+	return string(plain), nil
+}
+
+// stale tells whether v is stored in plain text or encrypted with another key than the current one of version
+func (e *encryption) stale(v string, version uint32) bool {
+	encoded, ok := strings.CutPrefix(v, encryptedPrefix)
+	if !ok {
+		return v != ""
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	return err != nil || len(data) < encryptionHeader || binary.BigEndian.Uint32(data) != version
+}
+
+// encryptedArg is a value of an encrypted field given to a statement, it is encrypted when the statement runs
+type encryptedArg struct {
+	e *encryption
+	v string
+}
+
+func (arg encryptedArg) Value() (driver.Value, error) {
+	return arg.e.encrypt(arg.v)
+}
+
+// fieldArg returns the argument storing v in field i, see WithEncryption
+func (a *Adapter) fieldArg(i int, v string) any {
+	if v == "" || !a.encryption.encrypts(i) {
+		return v
+	}
+	return encryptedArg{e: a.encryption, v: v}
+}
+
+// encryptFields returns values, the values of the fields from fieldIndex, with the values of the encrypted fields
+// encrypted, like normalize it returns values when no field is encrypted
+func (a *Adapter) encryptFields(fieldIndex int, values []string) ([]string, error) {
+	if a.encryption == nil {
+		return values, nil
+	}
+	encrypted := slices.Clone(values)
+	for j, v := range values {
+		if a.encryption.encrypts(fieldIndex + j) {
+			var err error
+			if encrypted[j], err = a.encryption.encrypt(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return encrypted, nil
+}
+
+// encryptedLine returns a copy of line with the values of the encrypted fields encrypted, to compare it with the rows
+func (a *Adapter) encryptedLine(line *CasbinRule) (*CasbinRule, error) {
+	if a.encryption == nil {
+		return line, nil
+	}
+	values, err := a.encryptFields(0, line.values(a.valueColumns))
+	if err != nil {
+		return nil, err
+	}
+	encrypted := &CasbinRule{ID: line.ID, Ptype: line.Ptype, size: line.size}
+	for i, v := range values {
+		encrypted.setValue(i, v)
+	}
+	return encrypted, nil
+}
+
+// decryptFields decrypts in place the values of the encrypted fields of vals, the values of v0 and above
+func (a *Adapter) decryptFields(vals []string) error {
+	if a.encryption == nil {
+		return nil
+	}
+	for _, i := range a.encryption.fields {
+		if i >= len(vals) {
+			continue
+		}
+		var err error
+		if vals[i], err = a.encryption.decrypt(vals[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkUnencrypted returns ErrEncryptedField when field i is encrypted, for the operations matching a part of the values
+func (a *Adapter) checkUnencrypted(i int, use string) error {
+	if a.encryption.encrypts(i) {
+		return fmt.Errorf("%w: v%d can't be used for %v", ErrEncryptedField, i, use)
+	}
+	return nil
+}
+
+// ReencryptRules encrypts the values of the encrypted fields with the current key of WithEncryption, e.g. after a key
+// rotation, so the filters match them, and encrypts the values stored before WithEncryption was used.
+// The values encrypted with the current key are left alone, the previous keys must still be given by the KeyProvider.
+// The rules of the namespace of the operation are encrypted in a single transaction, ReencryptRules returns
+// the number of rows changed.
+func (a *Adapter) ReencryptRules(ctx context.Context) (_ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "ReencryptRules"})
+	if err != nil {
+		return 0, err
+	}
+	defer finish(&err)
+
+	if a.encryption == nil {
+		return 0, errors.New("pgadapter: no encrypted field, see WithEncryption")
+	}
+	_, version, err := a.encryption.current()
+	if err != nil {
+		return 0, err
+	}
+	fields := slices.Sorted(slices.Values(a.encryption.fields))
+	fields = slices.Compact(fields)
+	cols := make([]string, len(fields))
+	for j, i := range fields {
+		cols[j] = fmt.Sprintf("v%d", i)
+	}
+
+	tx, err := a.conn(ctx).Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT id, %v FROM "%v"`, strings.Join(cols, ", "), a.table(ctx)), nil, true)
+	rows, err := tx.Query(ctx, sql+" FOR UPDATE", args...)
+	if err != nil {
+		return 0, err
+	}
+	type row struct {
+		id   string
+		vals []pgtype.Text
+	}
+	var stale []row
+	for rows.Next() {
+		r := row{vals: make([]pgtype.Text, len(fields))}
+		dest := []any{&r.id}
+		for j := range r.vals {
+			dest = append(dest, &r.vals[j])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if slices.ContainsFunc(r.vals, func(v pgtype.Text) bool { return a.encryption.stale(v.String, version) }) {
+			stale = append(stale, r)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	sets := make([]string, len(fields))
+	for j, col := range cols {
+		sets[j] = fmt.Sprintf("%v = $%d", col, j+2)
+	}
+	update := fmt.Sprintf(`UPDATE "%v" SET %v WHERE id = $1`, a.table(ctx), strings.Join(sets, ", "))
+	for _, r := range stale {
+		args := []any{r.id}
+		for _, v := range r.vals {
+			if !v.Valid {
+				// the NULL values of WithNullValues are kept
+				args = append(args, nil)
+				continue
+			}
+			plain, err := a.encryption.decrypt(v.String)
+			if err != nil {
+				return 0, fmt.Errorf("rule %v: %w", r.id, err)
+			}
+			encrypted, err := a.encryption.encrypt(plain)
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, encrypted)
+		}
+		sql, args := a.inNamespace(ctx, update, args, false)
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return int64(len(stale)), nil
+}
@@ -0,0 +1,219 @@
+package pgxadapter
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+var testKeys = StaticKeys{Current: 1, Keys: map[uint32][]byte{
+	1: bytes.Repeat([]byte{1}, 32),
+	2: bytes.Repeat([]byte{2}, 16),
+}}
+
+func (s *AdapterTestSuite) TestEncryption() {
+	ctx := context.Background()
+	pool := s.a.db.(*pgxpool.Pool)
+	a, err := NewAdapterByDB(pool, WithEncryption(testKeys, 0), SkipTableCreate())
+	s.Require().NoError(err)
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"carol", "data3", "read"}))
+
+	var stored string
+	s.Require().NoError(pool.QueryRow(ctx, `SELECT v0 FROM casbin_rules WHERE id = $1`, ComputePolicyID("p", []string{"carol", "data3", "read"})).
+		Scan(&stored))
+	s.Assert().True(strings.HasPrefix(stored, encryptedPrefix), stored)
+	s.Assert().NotContains(stored, "carol")
+
+	// the rules stored before the encryption are read as they are
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", a)
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"},
+		{"data2_admin", "data2", "write"}, {"carol", "data3", "read"}}, e.GetPolicy())
+	s.Require().NoError(e.LoadFilteredPolicy(&Filter{P: []string{"carol"}}))
+	s.assertPolicy([][]string{{"carol", "data3", "read"}}, e.GetPolicy())
+	ok, err := a.ExistsMatchingPolicy(ctx, "p", map[int]string{0: "carol", 1: "data3"})
+	s.Require().NoError(err)
+	s.Assert().True(ok)
+
+	// after a rotation, the values encrypted with the previous key are read but not matched until they are encrypted again
+	rotated := testKeys
+	rotated.Current = 2
+	b, err := NewAdapterByDB(pool, WithEncryption(rotated, 0), SkipTableCreate())
+	s.Require().NoError(err)
+	ok, err = b.ExistsMatchingPolicy(ctx, "p", map[int]string{0: "carol"})
+	s.Require().NoError(err)
+	s.Assert().False(ok)
+	n, err := b.ReencryptRules(ctx)
+	s.Require().NoError(err)
+	s.Assert().EqualValues(6, n)
+	ok, err = b.ExistsMatchingPolicy(ctx, "p", map[int]string{0: "carol"})
+	s.Require().NoError(err)
+	s.Assert().True(ok)
+	n, err = b.ReencryptRules(ctx)
+	s.Require().NoError(err)
+	s.Assert().Zero(n)
+
+	s.Require().NoError(b.RemoveFilteredPolicy("p", "p", 0, "data2_admin"))
+	s.Require().NoError(b.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data1", "write"}))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(b.LoadPolicy(m))
+	s.assertPolicy([][]string{{"alice", "data1", "write"}, {"bob", "data2", "write"}, {"carol", "data3", "read"}}, m.GetPolicy("p", "p"))
+	s.Assert().Equal([][]string{{"alice", "data2_admin"}}, m.GetPolicy("g", "g"))
+	mismatches, err := b.VerifyIDs(ctx)
+	s.Require().NoError(err)
+	s.Assert().Empty(mismatches)
+}
+
+// encryptedValue matches the arguments encrypting plain, see WithEncryption
+type encryptedValue struct {
+	e     *encryption
+	plain string
+}
+
+func (m encryptedValue) Match(v any) bool {
+	valuer, ok := v.(driver.Valuer)
+	if !ok {
+		return false
+	}
+	value, err := valuer.Value()
+	if err != nil {
+		return false
+	}
+	plain, err := m.e.decrypt(value.(string))
+	return err == nil && plain == m.plain && value != m.plain
+}
+
+func TestMockEncryption(t *testing.T) {
+	a, mock := newMockAdapter(t, WithEncryption(testKeys, 0, 2))
+	alice := encryptedValue{e: a.encryption, plain: "alice"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(policyID("p", []string{"alice", "data1", "read"}), "p", alice, "data1", encryptedValue{e: a.encryption, plain: "read"}, "", "", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	encrypted, err := a.encryption.encrypt("alice")
+	require.NoError(t, err)
+	read, err := a.encryption.encrypt("read")
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v0 = $2`)).
+		WithArgs("p", encrypted).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", encrypted, "data1", read, "", "", ""))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadFilteredPolicy(m, &Filter{P: []string{"alice"}}))
+	require.Equal(t, [][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v0 = $2 AND v1 = $3`)).
+		WithArgs("p", alice, "data1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemoveFilteredPolicy("p", "p", 0, "alice", "data1"))
+
+	// the values can't be matched partially nor sorted
+	_, err = a.GetDistinctValues(context.Background(), "p", 0, "al", 0)
+	require.ErrorIs(t, err, ErrEncryptedField)
+	_, _, err = a.SearchRules(context.Background(), SearchQuery{FieldContains: map[int]string{2: "rea"}})
+	require.ErrorIs(t, err, ErrEncryptedField)
+	_, _, err = a.SearchRules(context.Background(), SearchQuery{OrderBy: "v0"})
+	require.ErrorIs(t, err, ErrEncryptedField)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT v0 FROM "casbin_rules" WHERE v0 <> '' ORDER BY 1`)).
+		WillReturnRows(pgxmock.NewRows([]string{"v0"}).AddRow(encrypted).AddRow("bob").AddRow("aaron"))
+	values, err := a.GetDistinctValues(context.Background(), "", 0, "", 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"aaron", "alice"}, values)
+}
+
+func TestMockReencryptRules(t *testing.T) {
+	a, mock := newMockAdapter(t, WithEncryption(testKeys, 0))
+	current, err := a.encryption.encrypt("alice")
+	require.NoError(t, err)
+	b, _ := newMockAdapter(t, WithEncryption(StaticKeys{Current: 2, Keys: testKeys.Keys}, 0))
+	previous, err := b.encryption.encrypt("bob")
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, v0 FROM "casbin_rules" FOR UPDATE`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "v0"}).
+			AddRow("1", current).
+			AddRow("2", previous).
+			AddRow("3", "carol").
+			AddRow("4", ""))
+	bob, err := a.encryption.encrypt("bob")
+	require.NoError(t, err)
+	carol, err := a.encryption.encrypt("carol")
+	require.NoError(t, err)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "casbin_rules" SET v0 = $2 WHERE id = $1`)).WithArgs("2", bob).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "casbin_rules" SET v0 = $2 WHERE id = $1`)).WithArgs("3", carol).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectCommit()
+	n, err := a.ReencryptRules(context.Background())
+	require.NoError(t, err)
+	require.EqualValues(t, 2, n)
+}
+
+func TestEncryptionValues(t *testing.T) {
+	e := &encryption{keys: testKeys, fields: []int{0}, ciphers: map[uint32]*valueCipher{}}
+	encrypted, err := e.encrypt("alice")
+	require.NoError(t, err)
+	again, err := e.encrypt("alice")
+	require.NoError(t, err)
+	require.Equal(t, encrypted, again)
+	other, err := e.encrypt("alicf")
+	require.NoError(t, err)
+	require.NotEqual(t, encrypted[:20], other[:20])
+	plain, err := e.decrypt(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "alice", plain)
+	empty, err := e.encrypt("")
+	require.NoError(t, err)
+	require.Empty(t, empty)
+	plain, err = e.decrypt("bob")
+	require.NoError(t, err)
+	require.Equal(t, "bob", plain)
+
+	// the values encrypted with a previous key are decrypted with it
+	rotated := &encryption{keys: StaticKeys{Current: 2, Keys: testKeys.Keys}, fields: []int{0}, ciphers: map[uint32]*valueCipher{}}
+	plain, err = rotated.decrypt(encrypted)
+	require.NoError(t, err)
+	require.Equal(t, "alice", plain)
+	require.True(t, rotated.stale(encrypted, 2))
+	require.False(t, e.stale(encrypted, 1))
+
+	tampered := []byte(encrypted)
+	tampered[len(tampered)-2] ^= 1
+	_, err = e.decrypt(string(tampered))
+	require.Error(t, err)
+	_, err = e.decrypt(encryptedPrefix + "!")
+	require.ErrorContains(t, err, "malformed encrypted value")
+	unknown := &encryption{keys: StaticKeys{Current: 2, Keys: map[uint32][]byte{2: testKeys.Keys[2]}}, ciphers: map[uint32]*valueCipher{}}
+	_, err = unknown.decrypt(encrypted)
+	require.ErrorContains(t, err, "no encryption key of version 1")
+
+	for _, opts := range [][]Option{
+		{WithEncryption(testKeys)},
+		{WithEncryption(testKeys, 6)},
+		{WithEncryption(nil, 0)},
+		{WithEncryption(StaticKeys{Current: 3, Keys: testKeys.Keys}, 0)},
+		{WithEncryption(StaticKeys{Current: 1, Keys: map[uint32][]byte{1: []byte("short")}}, 0)},
+	} {
+		_, err = NewAdapterByPgxPool(nil, append(opts, SkipTableCreate(), SkipSchemaVerification())...)
+		require.Error(t, err)
+	}
+}
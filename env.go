@@ -0,0 +1,114 @@
+package pgxadapter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// the variables read by NewAdapterFromEnv, after the prefix and an underscore
+const (
+	envDatabaseURL = "DATABASE_URL"
+	envTable       = "CASBIN_TABLE"
+	envSchema      = "CASBIN_SCHEMA"
+	envSkipCreate  = "CASBIN_SKIP_CREATE"
+)
+
+// NewAdapterFromEnv is like NewAdapterWithOptions but reads its settings from the environment variables
+// named after prefix, e.g. with the prefix "AUTHZ":
+//
+//   - AUTHZ_DATABASE_URL is the PostgreSQL URL of the server, when it is unset or empty the connection is configured
+//     by the PGHOST, PGUSER, PGPASSWORD... variables read by pgx
+//   - AUTHZ_CASBIN_TABLE is the name of the rules table, see WithTableName
+//   - AUTHZ_CASBIN_SCHEMA is the schema of the rules table, it is set as the search_path of the connections
+//     and must exist
+//   - AUTHZ_CASBIN_SKIP_CREATE skips the table creation when it is true, see SkipTableCreate,
+//     and creates it when it is false, it accepts the values of strconv.ParseBool
+//
+// The variables other than the URL are optional, and when set they override opts. An empty prefix reads the
+// variables without prefix, e.g. DATABASE_URL. An invalid value returns an error naming its variable.
+// The database is the one of the URL, or of PGDATABASE, unless opts has WithDatabaseName,
+// and DefaultDatabaseName when neither sets one.
+func NewAdapterFromEnv(prefix string, opts ...Option) (*Adapter, error) {
+	cfg, opts, err := configFromEnv(prefix, opts)
+	if err != nil {
+		return nil, fmt.Errorf("pgadapter.NewAdapterFromEnv: %w", err)
+	}
+	return NewAdapterWithOptions(cfg, opts...)
+}
+
+// envName returns the name of the variable name with prefix
+func envName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// configFromEnv returns the pool config of the variables with prefix and the options of the adapter:
+// the database of the config, then opts, then the options of the variables
+func configFromEnv(prefix string, opts []Option) (*pgxpool.Config, []Option, error) {
+	name := envName(prefix, envDatabaseURL)
+	connURL := os.Getenv(name)
+	cfg, err := pgxpool.ParseConfig(connURL)
+	if err != nil {
+		if connURL == "" {
+			return nil, nil, fmt.Errorf("%v is not set and the PG environment variables are invalid: %w", name, err)
+		}
+		// the error of net/url quotes the URL with its password
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, nil, fmt.Errorf("invalid %v: %w", name, urlErr.Err)
+		}
+		return nil, nil, fmt.Errorf("invalid %v: %w", name, err)
+	}
+
+	// the database of the URL, or of PGDATABASE, comes first so WithDatabaseName in opts overrides it
+	if cfg.ConnConfig.Database != "" {
+		opts = append([]Option{WithDatabaseName(cfg.ConnConfig.Database)}, opts...)
+	}
+	if table, ok := os.LookupEnv(envName(prefix, envTable)); ok {
+		if err := validateTableName(table); err != nil {
+			return nil, nil, fmt.Errorf("invalid %v: %w", envName(prefix, envTable), err)
+		}
+		opts = append(opts, WithTableName(table))
+	}
+	if schema, ok := os.LookupEnv(envName(prefix, envSchema)); ok {
+		if err := validateSchemaName(schema); err != nil {
+			return nil, nil, fmt.Errorf("invalid %v: %w", envName(prefix, envSchema), err)
+		}
+		if cfg.ConnConfig.RuntimeParams == nil {
+			cfg.ConnConfig.RuntimeParams = map[string]string{}
+		}
+		cfg.ConnConfig.RuntimeParams["search_path"] = pgx.Identifier{schema}.Sanitize()
+	}
+	if skip, ok := os.LookupEnv(envName(prefix, envSkipCreate)); ok {
+		v, err := strconv.ParseBool(skip)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %v: %q is not a boolean", envName(prefix, envSkipCreate), skip)
+		}
+		opts = append(opts, func(a *Adapter) {
+			a.skipTableCreate = v
+		})
+	}
+	return cfg, opts, nil
+}
+
+// validateSchemaName checks that name can be used as a schema name
+func validateSchemaName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("schema name is empty")
+	case len(name) > maxIdentifierLength:
+		return fmt.Errorf("schema name %q is longer than %d bytes", name, maxIdentifierLength)
+	case strings.ContainsRune(name, 0):
+		return fmt.Errorf("schema name %q contains a NUL character", name)
+	}
+	return nil
+}
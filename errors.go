@@ -0,0 +1,207 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// The errors returned by the adapter wrap one of the following errors when they apply,
+// they can be matched with errors.Is:
+//
+//   - ErrAdapterClosed by every method called after Close or Shutdown
+//   - ErrInvalidTableName by the constructors and SetTableName
+//   - ErrTableNotExist by every method reading or writing the rules table, and by VerifySchema
+//   - ErrPolicyAlreadyExists by the write methods when a rule would duplicate a stored one,
+//     AddPolicy and AddPolicies ignore rules already stored instead,
+//     and by CopyPtype when the target ptype already has rules
+//   - ErrPolicyNotFound by UpdatePolicy and UpdatePolicies when a rule to update is not stored,
+//     RemovePolicy and RemovePolicies ignore rules not stored instead
+//   - ErrInvalidFilter by LoadFilteredPolicy, RemoveFilteredPolicy, RemoveFilteredPolicies, UpdateFilteredPolicies and RenameFieldValue
+//   - ErrReadOnly by the write methods when the database is read only
+//   - ErrModelNotFound by LoadModelText and NewEnforcerFromDB
+//   - ErrWalLevelNotLogical by CreateReplicationSlot and NewReplicationWatcher
+//   - ErrStagedConflict and ErrStagedChangeNotFound by ApplyStaged
+//   - ErrTenantRequired by the operations without tenant of the adapters created with WithTenantFromContext(true)
+//   - ErrStaleModel by SavePolicyChecked, see StaleModelError
+//   - ErrTimeout by the operations canceled by their timeout, see TimeoutError
+//   - ErrTooManyRules by LoadPolicy and LoadFilteredPolicy, see TooManyRulesError
+//   - ErrBroadFilter by LoadFilteredPolicy, see WithFilterThreshold
+//   - ErrQuotaExceeded by AddPolicy and AddPolicies, see QuotaExceededError
+//   - ErrCitusNotInstalled by the constructors and EnsureTable, see WithDistributedTable
+//
+// Errors caused by postgres also wrap the *pgconn.PgError, which can be retrieved with errors.As.
+// The errors of the adapter methods are *OpError values giving the operation and the table.
+var (
+	// ErrAdapterClosed is returned by the adapter methods called after Close or Shutdown
+	ErrAdapterClosed = errors.New("pgadapter: adapter is closed")
+
+	// ErrInvalidDatabaseName is returned by NewAdapter when the database name can't be used by postgres
+	ErrInvalidDatabaseName = errors.New("pgadapter: invalid database name")
+
+	// ErrModelNotFound is returned by LoadModelText when no model was saved under the name
+	ErrModelNotFound = errors.New("pgadapter: model not found")
+
+	// ErrWatcherClosed is returned by Watcher.Update called after Close
+	ErrWatcherClosed = errors.New("pgadapter: watcher is closed")
+
+	// ErrInvalidTableName is returned by the constructors and SetTableName when the table name can't be used
+	ErrInvalidTableName = errors.New("pgadapter: invalid table name")
+
+	// ErrReadOnlyDatabase is wrapped by the errors of writes refused because the database is read only,
+	// e.g. a hot standby reached after a failover
+	ErrReadOnlyDatabase = errors.New("pgadapter: database is read only")
+
+	// ErrReadOnly is ErrReadOnlyDatabase
+	ErrReadOnly = ErrReadOnlyDatabase
+
+	// ErrTableNotExist is wrapped when the rules table doesn't exist, see TableNotExistError
+	ErrTableNotExist = errors.New("pgadapter: table does not exist")
+
+	// ErrPolicyAlreadyExists is wrapped when a write would store a rule twice
+	ErrPolicyAlreadyExists = errors.New("pgadapter: policy already exists")
+
+	// ErrPolicyNotFound is wrapped when a rule to update is not stored
+	ErrPolicyNotFound = errors.New("pgadapter: policy not found")
+
+	// ErrInvalidFilter is wrapped when a filter has the wrong type or more values than the value columns
+	ErrInvalidFilter = errors.New("pgadapter: invalid filter")
+
+	// ErrWalLevelNotLogical is wrapped by CreateReplicationSlot and NewReplicationWatcher
+	// when the server doesn't run with wal_level set to logical
+	ErrWalLevelNotLogical = errors.New("pgadapter: wal_level is not logical")
+
+	// ErrStagedConflict is wrapped by ApplyStaged when a staged change no longer applies to the stored rules
+	ErrStagedConflict = errors.New("pgadapter: staged change conflicts with the stored rules")
+
+	// ErrStagedChangeNotFound is wrapped by ApplyStaged when a staged change doesn't exist
+	ErrStagedChangeNotFound = errors.New("pgadapter: staged change not found")
+
+	// ErrTenantRequired is wrapped when the context of an operation has no tenant, see WithTenantFromContext
+	ErrTenantRequired = errors.New("pgadapter: tenant required in context")
+
+	// ErrStaleModel is wrapped by SavePolicyChecked when the rules changed since they were loaded
+	ErrStaleModel = errors.New("pgadapter: policy changed since it was loaded")
+
+	// ErrTimeout is wrapped when an operation is canceled by WithOperationTimeout, WithReadTimeout or WithWriteTimeout
+	ErrTimeout = errors.New("pgadapter: operation timeout exceeded")
+
+	// ErrTooManyRules is wrapped when a load would load more rules than the limit of WithMaxLoadRules
+	ErrTooManyRules = errors.New("pgadapter: too many rules to load")
+
+	// ErrBroadFilter is wrapped when a filter matches more rules than the threshold of WithFilterThreshold
+	ErrBroadFilter = errors.New("pgadapter: filter matches too many rules")
+
+	// ErrQuotaExceeded is wrapped when a write would store more rules than the quota of WithRowQuota
+	ErrQuotaExceeded = errors.New("pgadapter: rule quota exceeded")
+
+	// ErrCitusNotInstalled is wrapped when the table should be distributed but the citus extension is not installed
+	ErrCitusNotInstalled = errors.New("pgadapter: citus extension is not installed")
+
+	// ErrEncryptedField is wrapped when an operation matches a part of the values of a field encrypted by WithEncryption,
+	// or sorts the rules by such a field
+	ErrEncryptedField = errors.New("pgadapter: field is encrypted")
+
+	// ErrPreparedTxNotFound is wrapped by CommitPrepared and RollbackPrepared when no transaction is prepared with the gid
+	ErrPreparedTxNotFound = errors.New("pgadapter: prepared transaction not found")
+)
+
+// Postgres error codes mapped to the adapter errors
+const (
+	codeUndefinedTable      = "42P01"
+	codeReadOnlyTransaction = "25006"
+)
+
+// OpError is the error returned by the adapter methods, it records the operation and the table that failed
+type OpError struct {
+	Op    string
+	Table string
+	Err   error
+}
+
+func (e *OpError) Error() string {
+	return fmt.Sprintf("pgadapter.%v: table %q: %v", e.Op, e.Table, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// TableNotExistError is wrapped by the errors of the operations failing because the rules table,
+// or the relation given to WithReadRelation, doesn't exist. It matches ErrTableNotExist with errors.Is.
+type TableNotExistError struct {
+	// Table is the rules table of the adapter
+	Table string
+	// Schema is the schema of the table, empty when it is looked up in the schemas of the search_path
+	Schema string
+	// SkipTableCreate is set when the adapter was created with SkipTableCreate
+	SkipTableCreate bool
+	// Err is the postgres error naming the missing relation
+	Err error
+}
+
+func (e *TableNotExistError) Error() string {
+	where := "in the schemas of the search_path"
+	if e.Schema != "" {
+		where = fmt.Sprintf("in schema %q", e.Schema)
+	}
+	hint := "it may have been dropped after the adapter started"
+	if e.SkipTableCreate {
+		hint = "the adapter doesn't create it since SkipTableCreate is used"
+	}
+	return fmt.Sprintf("pgadapter: table %q does not exist %v, %v: %v", e.Table, where, hint, e.Err)
+}
+
+func (e *TableNotExistError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TableNotExistError) Is(target error) bool {
+	return target == ErrTableNotExist
+}
+
+// tableNotExist returns the *TableNotExistError of the postgres error err for table
+func (a *Adapter) tableNotExist(table string, err error) error {
+	e := &TableNotExistError{Table: table, SkipTableCreate: a.skipTableCreate, Err: err}
+	if a.temporary {
+		e.Schema = "pg_temp"
+	}
+	return e
+}
+
+// ruleError adds the rule a statement failed for to err
+func ruleError(line *CasbinRule, err error) error {
+	return fmt.Errorf("rule %q: %w", line.String(), err)
+}
+
+// checkError wraps *errp with the adapter error matching its postgres error code, if any,
+// and with an *OpError for the operation op on table unless it is already one
+func (a *Adapter) checkError(ctx context.Context, op, table string, errp *error) {
+	err := *errp
+	if err == nil {
+		return
+	}
+	var opErr *OpError
+	if errors.As(err, &opErr) {
+		return
+	}
+	switch {
+	case isPgError(err, codeReadOnlyTransaction):
+		err = fmt.Errorf("%w: %w", ErrReadOnlyDatabase, err)
+		if pool, ok := a.pool(); ok && a.ownsPool && a.readOnlyReconnect {
+			pool.Reset()
+		}
+		if p, ok := a.failover(); ok {
+			p.readOnly(ctx, err)
+		}
+	case isPgError(err, codeUndefinedTable):
+		err = a.tableNotExist(table, err)
+	case isPgError(err, codeUniqueViolation):
+		err = fmt.Errorf("%w: %w", ErrPolicyAlreadyExists, err)
+	case isPgError(err, codeCheckViolation):
+		if constraintErr := modelConstraintError(table, err); constraintErr != nil {
+			err = constraintErr
+		}
+	}
+	*errp = &OpError{Op: op, Table: table, Err: err}
+}
@@ -0,0 +1,129 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestSentinelErrors() {
+	err := s.a.UpdatePolicy("p", "p", []string{"nobody", "data1", "read"}, []string{"nobody", "data1", "write"})
+	s.Require().ErrorIs(err, ErrPolicyNotFound)
+
+	err = s.a.LoadFilteredPolicy(s.e.GetModel(), Filter{})
+	s.Require().ErrorIs(err, ErrInvalidFilter)
+
+	a, err := NewAdapterByDB(s.a.db.(*pgxpool.Pool), WithTableName("casbin_missing_rules"), SkipTableCreate(), SkipSchemaVerification())
+	s.Require().NoError(err)
+	err = a.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	s.Require().ErrorIs(err, ErrTableNotExist)
+	err = a.VerifySchema(context.Background())
+	s.Require().ErrorIs(err, ErrTableNotExist)
+}
+
+func TestMockSentinelErrors(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype`)).
+		WillReturnError(&pgconn.PgError{Code: codeUndefinedTable, Message: `relation "casbin_rules" does not exist`})
+	err = a.LoadPolicy(m)
+	require.ErrorIs(t, err, ErrTableNotExist)
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectRollback()
+	err = a.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data1", "write"})
+	require.ErrorIs(t, err, ErrPolicyNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Code: codeUniqueViolation})
+	mock.ExpectRollback()
+	err = a.UpdatePolicy("p", "p", []string{"alice", "data1", "read"}, []string{"alice", "data1", "write"})
+	require.ErrorIs(t, err, ErrPolicyAlreadyExists)
+
+	err = a.LoadFilteredPolicy(m, Filter{})
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	err = a.RemoveFilteredPolicy("p", "p", 0, "a", "b", "c", "d", "e", "f", "g")
+	require.ErrorIs(t, err, ErrInvalidFilter)
+
+	require.ErrorIs(t, fmt.Errorf("wrapped: %w", ErrReadOnlyDatabase), ErrReadOnly)
+	require.False(t, errors.Is(ErrPolicyNotFound, ErrPolicyAlreadyExists))
+}
+
+func TestMockOpError(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Severity: "ERROR", Code: codeUndefinedTable, Message: `relation "casbin_rules" does not exist`})
+	_, err := a.CountRules(context.Background(), "")
+	require.EqualError(t, err, `pgadapter.CountRules: table "casbin_rules": pgadapter: table "casbin_rules" does not exist in the schemas of the search_path, `+
+		`the adapter doesn't create it since SkipTableCreate is used: ERROR: relation "casbin_rules" does not exist (SQLSTATE 42P01)`)
+	var opErr *OpError
+	require.ErrorAs(t, err, &opErr)
+	require.Equal(t, "CountRules", opErr.Op)
+	require.Equal(t, "casbin_rules", opErr.Table)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Severity: "ERROR", Code: "23514", Message: `new row violates check constraint "casbin_rules_v0_check"`})
+	mock.ExpectRollback()
+	err = a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}})
+	require.EqualError(t, err, `pgadapter.AddPolicies: table "casbin_rules": rule "p, alice, data1, read": ERROR: new row violates check constraint "casbin_rules_v0_check" (SQLSTATE 23514)`)
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	require.Equal(t, "23514", pgErr.Code)
+}
+
+func (s *AdapterTestSuite) TestTableNotExistError() {
+	a, err := NewAdapterByDB(s.a.db.(*pgxpool.Pool), WithTableName("casbin_missing_rules"), SkipTableCreate(), SkipSchemaVerification())
+	s.Require().NoError(err)
+
+	_, err = casbin.NewEnforcer("examples/rbac_model.conf", a)
+	s.Require().ErrorIs(err, ErrTableNotExist)
+	var tableErr *TableNotExistError
+	s.Require().ErrorAs(err, &tableErr)
+	s.Require().Equal("casbin_missing_rules", tableErr.Table)
+	s.Require().True(tableErr.SkipTableCreate)
+	s.Require().ErrorContains(err, `table "casbin_missing_rules" does not exist in the schemas of the search_path`)
+}
+
+func TestMockTableNotExistError(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+	a, err := NewAdapterByPgxPool(mock, WithTableName("casbin_missing_rules"), SkipTableCreate(), SkipSchemaVerification())
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_missing_rules"`)).
+		WillReturnError(&pgconn.PgError{Severity: "ERROR", Code: codeUndefinedTable, Message: `relation "casbin_missing_rules" does not exist`})
+	_, err = casbin.NewEnforcer("examples/rbac_model.conf", a)
+	require.ErrorIs(t, err, ErrTableNotExist)
+	var tableErr *TableNotExistError
+	require.ErrorAs(t, err, &tableErr)
+	require.Equal(t, &TableNotExistError{
+		Table:           "casbin_missing_rules",
+		SkipTableCreate: true,
+		Err:             &pgconn.PgError{Severity: "ERROR", Code: codeUndefinedTable, Message: `relation "casbin_missing_rules" does not exist`},
+	}, tableErr)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// without SkipTableCreate the table was dropped after the adapter started
+	err = &TableNotExistError{Table: "casbin_rules", Schema: "pg_temp", Err: errors.New("relation does not exist")}
+	require.EqualError(t, err, `pgadapter: table "casbin_rules" does not exist in schema "pg_temp", it may have been dropped after the adapter started: relation does not exist`)
+}
@@ -0,0 +1,61 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Wildcard is the stored value ExistsMatchingPolicy matches with any requested value
+const Wildcard = "*"
+
+// ExistsMatchingPolicy reports whether a rule of ptype has, at every index of fields, the value given for it or Wildcard,
+// e.g. whether a p rule grants alice to read data1, directly or with a wildcard object:
+//
+//	ok, err := a.ExistsMatchingPolicy(ctx, "p", map[int]string{0: "alice", 1: "data1", 2: "read"})
+//
+// The indexes not in fields, and the ones given an empty value, match any stored value.
+// It is a storage level predicate answered with a single EXISTS query, not an enforcement:
+// the matchers of the model, the roles and the effects are ignored, use an enforcer to decide on a request.
+func (a *Adapter) ExistsMatchingPolicy(ctx context.Context, ptype string, fields map[int]string) (_ bool, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "ExistsMatchingPolicy", Ptype: ptype})
+	if err != nil {
+		return false, err
+	}
+	defer finish(&err)
+
+	indexes := make([]int, 0, len(fields))
+	for i := range fields {
+		if i < 0 || i >= a.valueColumns {
+			return false, fmt.Errorf("%w: field index %d is out of the %d value columns", ErrInvalidFilter, i, a.valueColumns)
+		}
+		indexes = append(indexes, i)
+	}
+	slices.Sort(indexes)
+
+	var sql strings.Builder
+	fmt.Fprintf(&sql, `SELECT EXISTS (SELECT 1 FROM "%v" WHERE ptype = $1`, a.readTable(ctx))
+	args := []any{ptype}
+	for _, i := range indexes {
+		value := a.normalization.normalize(i, []string{fields[i]})[0]
+		if value == "" {
+			continue
+		}
+		args = append(args, a.fieldArg(i, value))
+		if a.encryption.encrypts(i) {
+			// the wildcard is stored encrypted too
+			args = append(args, a.fieldArg(i, Wildcard))
+			fmt.Fprintf(&sql, " AND (v%[1]d = $%[2]d OR v%[1]d = $%[3]d)", i, len(args)-1, len(args))
+			continue
+		}
+		fmt.Fprintf(&sql, " AND (v%[1]d = $%[2]d OR v%[1]d = '%[3]v')", i, len(args), Wildcard)
+	}
+	query, args := a.inNamespace(ctx, sql.String(), args, false)
+
+	var exists bool
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		return a.queryRow(ctx, query+")", args, &exists)
+	})
+	return exists, err
+}
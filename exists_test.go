@@ -0,0 +1,52 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestExistsMatchingPolicy() {
+	ctx := context.Background()
+	s.Require().NoError(s.a.AddPolicy("p", "p", []string{"carol", "*", "read"}))
+
+	for _, tc := range []struct {
+		fields map[int]string
+		exists bool
+	}{
+		{map[int]string{0: "alice", 1: "data1", 2: "read"}, true},
+		{map[int]string{0: "alice", 1: "data1", 2: "write"}, false},
+		{map[int]string{0: "bob", 1: "data2"}, true},
+		{map[int]string{1: "data2", 2: "read"}, true},
+		{map[int]string{0: "carol", 1: "data9", 2: "read"}, true},
+		{map[int]string{0: "carol", 1: "data9", 2: "write"}, false},
+		{map[int]string{0: "dave"}, false},
+		{nil, true},
+	} {
+		exists, err := s.a.ExistsMatchingPolicy(ctx, "p", tc.fields)
+		s.Require().NoError(err)
+		s.Require().Equal(tc.exists, exists, "%v", tc.fields)
+	}
+
+	exists, err := s.a.ExistsMatchingPolicy(ctx, "g", map[int]string{0: "alice", 1: "data2_admin"})
+	s.Require().NoError(err)
+	s.Require().True(exists)
+}
+
+func TestMockExistsMatchingPolicy(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM "casbin_rules" WHERE ptype = $1 AND (v0 = $2 OR v0 = '*') AND (v2 = $3 OR v2 = '*') AND namespace = $4)`)).
+		WithArgs("p", "alice", "read", "tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	exists, err := a.ExistsMatchingPolicy(ctx, "p", map[int]string{2: "read", 1: "", 0: "alice"})
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	_, err = a.ExistsMatchingPolicy(ctx, "p", map[int]string{6: "x"})
+	require.ErrorIs(t, err, ErrInvalidFilter)
+}
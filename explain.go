@@ -0,0 +1,88 @@
+package pgxadapter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ExplainLoad returns the plans of the queries LoadFilteredPolicy runs for filter, one of the filters it accepts,
+// or of the query of LoadPolicy when filter is nil, as EXPLAIN (ANALYZE, BUFFERS, FORMAT TEXT) reports them.
+// The queries run with the arguments the load would use, their rules are not loaded. Every plan is preceded
+// by a comment line holding its query.
+func (a *Adapter) ExplainLoad(ctx context.Context, filter any) (_ string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "ExplainLoad"})
+	if err != nil {
+		return "", err
+	}
+	defer finish(&err)
+
+	var queries []string
+	var queryArgs [][]any
+	if filter == nil {
+		sql, args := a.loadQuery(ctx)
+		queries, queryArgs = []string{sql}, [][]any{args}
+	} else {
+		filters, err := parseFilter(filter)
+		if err != nil {
+			return "", err
+		}
+		if queries, queryArgs, err = a.filterQueries(ctx, filters); err != nil {
+			return "", err
+		}
+	}
+
+	var plans strings.Builder
+	for i, sql := range queries {
+		if err := a.explain(ctx, a.db, &plans, sql, queryArgs[i]); err != nil {
+			return "", err
+		}
+	}
+	return plans.String(), nil
+}
+
+// ExplainRemoveFiltered returns the plan of the statement RemoveFilteredPolicy runs for the same arguments,
+// as EXPLAIN (ANALYZE, BUFFERS, FORMAT TEXT) reports it, preceded by a comment line holding the statement.
+// The statement runs in a transaction which is rolled back, so no rule is removed, but it locks the rules
+// it matches until the rollback like RemoveFilteredPolicy does.
+func (a *Adapter) ExplainRemoveFiltered(ctx context.Context, ptype string, fieldIndex int, fieldValues ...string) (_ string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "ExplainRemoveFiltered", Ptype: ptype})
+	if err != nil {
+		return "", err
+	}
+	defer finish(&err)
+
+	if err := a.checkFieldValues(fieldIndex, fieldValues); err != nil {
+		return "", err
+	}
+	tx, err := a.conn(ctx).Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx)
+
+	var plan strings.Builder
+	sql, args := a.removeFilteredQuery(ctx, ptype, fieldIndex, fieldValues)
+	if err := a.explain(ctx, tx, &plan, sql, args); err != nil {
+		return "", err
+	}
+	return plan.String(), nil
+}
+
+// explain runs EXPLAIN ANALYZE on sql with q and writes the statement and its plan to w
+func (a *Adapter) explain(ctx context.Context, q querier, w *strings.Builder, sql string, args []any) error {
+	rows, err := q.Query(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT TEXT) "+sql, args...)
+	if err != nil {
+		return err
+	}
+	lines, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+	w.WriteString("-- " + sql + "\n")
+	for _, line := range lines {
+		w.WriteString(line + "\n")
+	}
+	return nil
+}
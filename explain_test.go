@@ -0,0 +1,85 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestExplain() {
+	ctx := context.Background()
+	plan, err := s.a.ExplainLoad(ctx, nil)
+	s.Require().NoError(err)
+	s.Assert().Contains(plan, `-- SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)
+	s.Assert().Contains(plan, "Execution Time")
+
+	plan, err = s.a.ExplainLoad(ctx, &Filter{P: []string{"alice"}, G: []string{"alice"}})
+	s.Require().NoError(err)
+	s.Assert().Contains(plan, `WHERE ptype=$1 AND v0 = $2`)
+	s.Assert().Regexp(`(?s)Execution Time.*Execution Time`, plan)
+
+	plan, err = s.a.ExplainRemoveFiltered(ctx, "p", 0, "alice")
+	s.Require().NoError(err)
+	s.Assert().Contains(plan, "Delete on casbin_rules")
+
+	// the rules are still stored
+	count, err := s.a.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Assert().EqualValues(5, count)
+}
+
+func TestMockExplain(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+	ctx := context.Background()
+	plan := func(lines ...string) *pgxmock.Rows {
+		rows := pgxmock.NewRows([]string{"QUERY PLAN"})
+		for _, line := range lines {
+			rows.AddRow(line)
+		}
+		return rows
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (ANALYZE, BUFFERS, FORMAT TEXT) SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE namespace = $1`)).
+		WithArgs("tenant1").
+		WillReturnRows(plan("Seq Scan on casbin_rules", "Execution Time: 0.1 ms"))
+	got, err := a.ExplainLoad(ctx, nil)
+	require.NoError(t, err)
+	require.Equal(t, `-- SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE namespace = $1
+Seq Scan on casbin_rules
+Execution Time: 0.1 ms
+`, got)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (ANALYZE, BUFFERS, FORMAT TEXT) SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v0 = $2 AND namespace = $3`)).
+		WithArgs("p", "alice", "tenant1").
+		WillReturnRows(plan("Index Scan using casbin_rules_ptype_idx on casbin_rules"))
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (ANALYZE, BUFFERS, FORMAT TEXT) SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND namespace = $2`)).
+		WithArgs("g", "tenant1").
+		WillReturnRows(plan("Seq Scan on casbin_rules"))
+	got, err = a.ExplainLoad(ctx, "")
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	require.Empty(t, got)
+	got, err = a.ExplainLoad(ctx, &Filter{P: []string{"alice"}, G: []string{}})
+	require.NoError(t, err)
+	require.Equal(t, `-- SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v0 = $2 AND namespace = $3
+Index Scan using casbin_rules_ptype_idx on casbin_rules
+-- SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND namespace = $2
+Seq Scan on casbin_rules
+`, got)
+
+	// the delete is rolled back
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN (ANALYZE, BUFFERS, FORMAT TEXT) DELETE FROM "casbin_rules" WHERE ptype = $1 AND v1 = $2 AND namespace = $3`)).
+		WithArgs("p", "data1", "tenant1").
+		WillReturnRows(plan("Delete on casbin_rules"))
+	mock.ExpectRollback()
+	got, err = a.ExplainRemoveFiltered(ctx, "p", 1, "data1")
+	require.NoError(t, err)
+	require.Contains(t, got, "Delete on casbin_rules")
+
+	_, err = a.ExplainRemoveFiltered(ctx, "p", 0, "", "", "", "", "", "", "read")
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
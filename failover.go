@@ -0,0 +1,289 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultFailbackInterval is how often the adapter checks whether it can go back to a preferred target, see WithFallbackTargets
+const DefaultFailbackInterval = 30 * time.Second
+
+// failbackCheckSQL tells whether a target only accepts reads
+const failbackCheckSQL = `SELECT pg_is_in_recovery() OR current_setting('default_transaction_read_only')::bool`
+
+// WithFallbackTargets gives the servers NewAdapter and NewAdapterWithOptions use when the server of arg can't be used,
+// e.g. the standby of a primary. Each target is a PostgreSQL URL string or a *pgxpool.Config, like arg,
+// and arg is the preferred target, followed by targets in their order.
+//
+// The adapter starts with the first target accepting writes, or the first reachable one if none does, and moves to the
+// next target when a connection can't be established, in which case the operation is retried on it, or when a write
+// fails with ErrReadOnlyDatabase, in which case the failed operation is not retried.
+// Every WithFailbackInterval the preferred targets are checked, and the adapter goes back to the first of them
+// reachable and accepting writes. Switches are logged with the logger of WithLogger and counted by the
+// pgxadapter.failover.switches metric of WithMeterProvider.
+//
+// The database is created on every reachable target accepting writes. The option can't be used with WithTemporaryTable
+// and has no effect on pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithFallbackTargets(targets ...any) Option {
+	return func(a *Adapter) {
+		a.fallbackTargets = append(a.fallbackTargets, targets...)
+	}
+}
+
+// WithFailbackInterval sets how often the adapter checks the targets preferred to the current one, see WithFallbackTargets,
+// it defaults to DefaultFailbackInterval
+func WithFailbackInterval(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.failbackInterval = d
+	}
+}
+
+// isConnectError tells whether err is a failure to establish a connection, nothing was sent to the server
+// so the statement can be sent to another one
+func isConnectError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// targetName returns the host and port of the target of cfg, used in the logs and metrics
+func targetName(cfg *pgxpool.Config) string {
+	return net.JoinHostPort(cfg.ConnConfig.Host, strconv.Itoa(int(cfg.ConnConfig.Port)))
+}
+
+// failoverTarget is a pool connected to one of the targets of WithFallbackTargets
+type failoverTarget struct {
+	name string
+	pool PgxPool
+}
+
+// failoverPool sends the statements to the current of its targets
+type failoverPool struct {
+	a       *Adapter
+	targets []failoverTarget
+
+	mu      sync.Mutex
+	current int
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// newFailoverPool connects to the target of arg and the targets of WithFallbackTargets
+func (a *Adapter) newFailoverPool(arg any) (*failoverPool, error) {
+	if err := validateDatabaseName(a.dbName); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	p := &failoverPool{a: a}
+	writable, readOnly := -1, -1
+	var unreachable error
+	for i, target := range append([]any{arg}, a.fallbackTargets...) {
+		cfg, err := parseTarget(target)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("target %d: %w", i, err)
+		}
+		name := targetName(cfg)
+		err = a.createDatabase(ctx, cfg)
+		switch {
+		case err == nil:
+			if writable < 0 {
+				writable = i
+			}
+		case isPgError(err, codeReadOnlyTransaction):
+			// a standby can't create the database, it gets it from its primary
+			if readOnly < 0 {
+				readOnly = i
+			}
+		case isConnectError(err):
+			a.logger.LogAttrs(ctx, slog.LevelWarn, "pgadapter: database target unreachable",
+				slog.String("target", name), slog.Any("error", err))
+			if unreachable == nil {
+				unreachable = err
+			}
+		default:
+			p.Close()
+			return nil, fmt.Errorf("target %v: %w", name, err)
+		}
+		// the pool connects lazily, so unreachable targets can be used once they are back
+		pool, err := a.openPool(ctx, cfg)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("target %v: %w", name, err)
+		}
+		p.targets = append(p.targets, failoverTarget{name: name, pool: pool})
+	}
+
+	switch {
+	case writable >= 0:
+		p.current = writable
+	case readOnly >= 0:
+		p.current = readOnly
+	default:
+		p.Close()
+		return nil, fmt.Errorf("no database target is reachable: %w", unreachable)
+	}
+	p.run(a.failbackInterval)
+	return p, nil
+}
+
+// failover returns the failoverPool of the adapter under the pools wrapping it, if any
+func (a *Adapter) failover() (*failoverPool, bool) {
+	db := a.db
+	for {
+		if p, ok := db.(*failoverPool); ok {
+			return p, true
+		}
+		w, ok := db.(interface{ unwrap() PgxPool })
+		if !ok {
+			return nil, false
+		}
+		db = w.unwrap()
+	}
+}
+
+// active returns the current target and its pool
+func (p *failoverPool) active() (int, PgxPool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.current, p.targets[p.current].pool
+}
+
+func (p *failoverPool) unwrap() PgxPool {
+	_, pool := p.active()
+	return pool
+}
+
+// switchTarget makes to the current target if from still is, err is the error which made from unusable
+// and is nil on failback. It returns the current target and its pool.
+func (p *failoverPool) switchTarget(ctx context.Context, from, to int, reason string, err error) (int, PgxPool) {
+	p.mu.Lock()
+	if p.current != from || from == to {
+		// another operation already switched
+		defer p.mu.Unlock()
+		return p.current, p.targets[p.current].pool
+	}
+	p.current = to
+	p.mu.Unlock()
+
+	level := slog.LevelWarn
+	if err == nil {
+		level = slog.LevelInfo
+	}
+	attrs := []slog.Attr{slog.String("from", p.targets[from].name), slog.String("to", p.targets[to].name), slog.String("reason", reason)}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	p.a.logger.LogAttrs(ctx, level, "pgadapter: switched database target", attrs...)
+	p.a.addFailover(ctx, p.targets[from].name, p.targets[to].name, reason)
+	return to, p.targets[to].pool
+}
+
+// next moves to the target following from because of err
+func (p *failoverPool) next(ctx context.Context, from int, reason string, err error) (int, PgxPool) {
+	return p.switchTarget(ctx, from, (from+1)%len(p.targets), reason, err)
+}
+
+// readOnly moves to the next target after a write failed with a read-only error
+func (p *failoverPool) readOnly(ctx context.Context, err error) {
+	current, _ := p.active()
+	p.next(ctx, current, "read-only", err)
+}
+
+// do runs fn with the pool of the current target, and with the next targets while connecting fails
+func (p *failoverPool) do(ctx context.Context, fn func(PgxPool) error) error {
+	current, pool := p.active()
+	for attempt := 1; ; attempt++ {
+		err := fn(pool)
+		if err == nil || !isConnectError(err) || attempt == len(p.targets) || ctx.Err() != nil {
+			return err
+		}
+		current, pool = p.next(ctx, current, "unreachable", err)
+	}
+}
+
+func (p *failoverPool) Exec(ctx context.Context, sql string, arguments ...any) (tag pgconn.CommandTag, err error) {
+	err = p.do(ctx, func(pool PgxPool) error {
+		tag, err = pool.Exec(ctx, sql, arguments...)
+		return err
+	})
+	return tag, err
+}
+
+func (p *failoverPool) Query(ctx context.Context, sql string, args ...any) (rows pgx.Rows, err error) {
+	err = p.do(ctx, func(pool PgxPool) error {
+		rows, err = pool.Query(ctx, sql, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (p *failoverPool) Begin(ctx context.Context) (tx pgx.Tx, err error) {
+	err = p.do(ctx, func(pool PgxPool) error {
+		tx, err = pool.Begin(ctx)
+		return err
+	})
+	return tx, err
+}
+
+// run checks the preferred targets every interval until the pool is closed
+func (p *failoverPool) run(interval time.Duration) {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				p.failback(ctx)
+				cancel()
+			}
+		}
+	}()
+}
+
+// failback moves to the first target preferred to the current one which is reachable and accepts writes
+func (p *failoverPool) failback(ctx context.Context) {
+	current, _ := p.active()
+	for i := 0; i < current; i++ {
+		var readOnly bool
+		if err := queryRowWith(ctx, p.targets[i].pool, failbackCheckSQL, nil, &readOnly); err != nil || readOnly {
+			continue
+		}
+		p.switchTarget(ctx, current, i, "failback", nil)
+		return
+	}
+}
+
+// Close stops the failback checks and closes the pools of all targets
+func (p *failoverPool) Close() {
+	p.once.Do(func() {
+		if p.stop != nil {
+			close(p.stop)
+			<-p.done
+		}
+		for _, t := range p.targets {
+			t.pool.Close()
+		}
+	})
+}
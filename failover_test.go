@@ -0,0 +1,146 @@
+package pgxadapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// deadPort returns a local port nothing listens on
+func deadPort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+	return port
+}
+
+// deadTarget returns a copy of cfg pointing at a local port nothing listens on
+func deadTarget(t *testing.T, cfg *pgxpool.Config) *pgxpool.Config {
+	t.Helper()
+	dead := cfg.Copy()
+	dead.ConnConfig.Host = "127.0.0.1"
+	dead.ConnConfig.Port = uint16(deadPort(t))
+	dead.ConnConfig.Fallbacks = nil
+	dead.ConnConfig.ConnectTimeout = time.Second
+	return dead
+}
+
+func (s *AdapterTestSuite) TestFallbackTargets() {
+	cfg, err := pgxpool.ParseConfig(os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	dead := deadTarget(s.T(), cfg)
+
+	a, err := NewAdapterWithOptions(dead, WithFallbackTargets(os.Getenv("PG_CONN")), WithFailbackInterval(time.Hour))
+	s.Require().NoError(err)
+	defer a.Close()
+	p, ok := a.failover()
+	s.Require().True(ok)
+	current, _ := p.active()
+	s.Require().Equal(1, current)
+
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data3", "read"}))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a.LoadPolicy(m))
+	s.Require().Contains(m.GetPolicy("p", "p"), []string{"alice", "data3", "read"})
+
+	// the dead target is still down, the adapter stays on the live one
+	p.failback(context.Background())
+	current, _ = p.active()
+	s.Require().Equal(1, current)
+}
+
+func TestMockFailover(t *testing.T) {
+	primary, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	standby, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	reader := sdkmetric.NewManualReader()
+	p := &failoverPool{targets: []failoverTarget{{name: "primary:5432", pool: primary}, {name: "standby:5432", pool: standby}}}
+	a, err := NewAdapterByPgxPool(p, SkipTableCreate(), SkipSchemaVerification(),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	require.NoError(t, err)
+	p.a = a
+
+	// the primary can't be reached, the statement is sent to the standby
+	primary.ExpectBegin().WillReturnError(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")})
+	standby.ExpectBegin()
+	standby.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	standby.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+	current, _ := p.active()
+	require.Equal(t, 1, current)
+
+	// the primary is back and accepts writes
+	primary.ExpectQuery(regexp.QuoteMeta(failbackCheckSQL)).WillReturnRows(pgxmock.NewRows([]string{"read_only"}).AddRow(false))
+	p.failback(context.Background())
+	current, _ = p.active()
+	require.Equal(t, 0, current)
+
+	// the primary became a standby, the failed write is not retried
+	primary.ExpectBegin()
+	primary.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Code: codeReadOnlyTransaction, Message: "cannot execute INSERT in a read-only transaction"})
+	primary.ExpectRollback()
+	require.ErrorIs(t, a.AddPolicy("p", "p", []string{"bob", "data2", "write"}), ErrReadOnlyDatabase)
+	current, _ = p.active()
+	require.Equal(t, 1, current)
+
+	// a read-only preferred target is not failed back to
+	primary.ExpectQuery(regexp.QuoteMeta(failbackCheckSQL)).WillReturnRows(pgxmock.NewRows([]string{"read_only"}).AddRow(true))
+	p.failback(context.Background())
+	current, _ = p.active()
+	require.Equal(t, 1, current)
+
+	require.NoError(t, primary.ExpectationsWereMet())
+	require.NoError(t, standby.ExpectationsWereMet())
+
+	var switches []string
+	for _, entry := range logEntries(t, &buf) {
+		require.Equal(t, "pgadapter: switched database target", entry["msg"])
+		switches = append(switches, entry["from"].(string)+">"+entry["to"].(string)+":"+entry["reason"].(string))
+	}
+	require.Equal(t, []string{"primary:5432>standby:5432:unreachable", "standby:5432>primary:5432:failback", "primary:5432>standby:5432:read-only"}, switches)
+
+	metrics := collectMetrics(t, reader)
+	require.EqualValues(t, 1, sumValue(t, metrics["pgxadapter.failover.switches"],
+		attribute.String("from", "primary:5432"), attribute.String("to", "standby:5432"), attribute.String("reason", "read-only")))
+	require.EqualValues(t, 1, sumValue(t, metrics["pgxadapter.failover.switches"],
+		attribute.String("from", "standby:5432"), attribute.String("to", "primary:5432"), attribute.String("reason", "failback")))
+
+	require.NoError(t, a.Close())
+}
+
+func TestMockFailoverUnreachable(t *testing.T) {
+	var buf bytes.Buffer
+	first := "postgres://postgres@127.0.0.1:" + strconv.Itoa(deadPort(t)) + "/postgres?connect_timeout=1"
+	second := "postgres://postgres@127.0.0.1:" + strconv.Itoa(deadPort(t)) + "/postgres?connect_timeout=1"
+	_, err := NewAdapterWithOptions(first, WithFallbackTargets(second), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	require.ErrorContains(t, err, "pgadapter.NewAdapter: no database target is reachable")
+	require.Len(t, logEntries(t, &buf), 2)
+
+	_, err = NewAdapterWithOptions(first, WithFallbackTargets(42))
+	require.EqualError(t, err, "pgadapter.NewAdapter: target 1: must pass in a PostgreS URL string or an instance of *pgxpool.Config, received int instead")
+
+	_, err = NewAdapterWithOptions(first, WithFallbackTargets(second), WithTemporaryTable())
+	require.EqualError(t, err, "pgadapter.NewAdapter: WithFallbackTargets can't be used with WithTemporaryTable")
+}
@@ -0,0 +1,106 @@
+package pgxadapter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ptypeFilter selects the rules of ptype matching values, an empty value is a wildcard
+type ptypeFilter struct {
+	ptype  string
+	values []string
+}
+
+// parseFilter returns the rules selected by a filter of LoadFilteredPolicy:
+//
+//   - a *Filter selects the p rules matching P and the g rules matching G
+//   - a string is a line in the format of casbin's file adapter, e.g. "p, alice, , read",
+//     its first token is the ptype and the others are the values, an empty value is a wildcard
+//   - a []string selects the rules matching any of its lines
+//   - a map[string][]string selects the rules of each ptype key matching its values,
+//     e.g. {"p": {"", "domain1"}, "g": {"", "", "domain1"}}, a nil value selects every rule of the ptype
+//   - a map[string][][]string selects the rules of each ptype key matching any of its sets of values
+//
+// The rules of the maps are loaded by ptype in lexical order, and like the nil sections of a *Filter,
+// the ptypes absent from a map are not loaded.
+func parseFilter(filter any) ([]ptypeFilter, error) {
+	switch filter := filter.(type) {
+	case *Filter:
+		var filters []ptypeFilter
+		if filter.P != nil {
+			filters = append(filters, ptypeFilter{ptype: "p", values: filter.P})
+		}
+		if filter.G != nil {
+			filters = append(filters, ptypeFilter{ptype: "g", values: filter.G})
+		}
+		return filters, nil
+	case string:
+		f, err := parseFilterLine(filter)
+		if err != nil {
+			return nil, err
+		}
+		return []ptypeFilter{f}, nil
+	case []string:
+		if len(filter) == 0 {
+			return nil, fmt.Errorf("%w: filter has no lines", ErrInvalidFilter)
+		}
+		filters := make([]ptypeFilter, 0, len(filter))
+		for i, line := range filter {
+			f, err := parseFilterLine(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			filters = append(filters, f)
+		}
+		return filters, nil
+	case map[string][]string:
+		sets := make(map[string][][]string, len(filter))
+		for ptype, values := range filter {
+			sets[ptype] = [][]string{values}
+		}
+		return mapFilters(sets)
+	case map[string][][]string:
+		return mapFilters(filter)
+	}
+	return nil, fmt.Errorf("%w: expected *Filter, string, []string, map[string][]string or map[string][][]string, got %T", ErrInvalidFilter, filter)
+}
+
+// mapFilters returns the filters of the sets of values by ptype, in the order of the ptypes
+func mapFilters(sets map[string][][]string) ([]ptypeFilter, error) {
+	var filters []ptypeFilter
+	for _, ptype := range slices.Sorted(maps.Keys(sets)) {
+		if ptype == "" {
+			return nil, fmt.Errorf("%w: filter has an empty ptype", ErrInvalidFilter)
+		}
+		for _, values := range sets[ptype] {
+			filters = append(filters, ptypeFilter{ptype: ptype, values: values})
+		}
+	}
+	return filters, nil
+}
+
+// parseFilterLine parses a filter line like "p, alice, , read"
+func parseFilterLine(line string) (ptypeFilter, error) {
+	if strings.TrimSpace(line) == "" {
+		return ptypeFilter{}, fmt.Errorf("%w: filter line is empty", ErrInvalidFilter)
+	}
+	r := csv.NewReader(strings.NewReader(line))
+	r.TrimLeadingSpace = true
+	tokens, err := r.Read()
+	if err != nil {
+		return ptypeFilter{}, fmt.Errorf("%w: filter line %q: %w", ErrInvalidFilter, line, err)
+	}
+	if _, err := r.Read(); err == nil {
+		return ptypeFilter{}, fmt.Errorf("%w: filter line %q has several lines", ErrInvalidFilter, line)
+	}
+	for i := range tokens {
+		tokens[i] = strings.TrimSpace(tokens[i])
+	}
+	if tokens[0] == "" {
+		return ptypeFilter{}, fmt.Errorf("%w: filter line %q has no ptype", ErrInvalidFilter, line)
+	}
+	return ptypeFilter{ptype: tokens[0], values: tokens[1:]}, nil
+}
@@ -0,0 +1,139 @@
+package pgxadapter
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestLoadFilteredPolicyString() {
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", s.a)
+	s.Require().NoError(err)
+
+	s.Require().NoError(e.LoadFilteredPolicy("p, , , read"))
+	s.Assert().True(e.IsFiltered())
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"data2_admin", "data2", "read"}}, e.GetPolicy())
+
+	s.Require().NoError(e.LoadFilteredPolicy([]string{"p, bob", "g, alice"}))
+	s.assertPolicy([][]string{{"bob", "data2", "write"}}, e.GetPolicy())
+	s.assertPolicy([][]string{{"alice", "data2_admin"}}, e.GetGroupingPolicy())
+}
+
+func TestParseFilter(t *testing.T) {
+	filters, err := parseFilter("p, alice, , read")
+	require.NoError(t, err)
+	require.Equal(t, []ptypeFilter{{ptype: "p", values: []string{"alice", "", "read"}}}, filters)
+
+	filters, err = parseFilter([]string{`p2, "bob, jr", data1`, "g", "g2 ,  , domain1 "})
+	require.NoError(t, err)
+	require.Equal(t, []ptypeFilter{
+		{ptype: "p2", values: []string{"bob, jr", "data1"}},
+		{ptype: "g", values: []string{}},
+		{ptype: "g2", values: []string{"", "domain1"}},
+	}, filters)
+
+	filters, err = parseFilter(&Filter{G: []string{"alice"}})
+	require.NoError(t, err)
+	require.Equal(t, []ptypeFilter{{ptype: "g", values: []string{"alice"}}}, filters)
+
+	for _, tc := range []struct {
+		filter any
+		msg    string
+	}{
+		{"", "filter line is empty"},
+		{" , alice", `filter line " , alice" has no ptype`},
+		{`p, "alice`, `filter line "p, \"alice"`},
+		{"p, alice\ng, bob", "has several lines"},
+		{Filter{}, "got pgxadapter.Filter"},
+		{42, "got int"},
+	} {
+		_, err := parseFilter(tc.filter)
+		require.ErrorIs(t, err, ErrInvalidFilter, tc.filter)
+		require.ErrorContains(t, err, tc.msg)
+	}
+	filters, err = parseFilter(map[string][]string{"p": {"", "domain1"}, "g": {"", "", "domain1"}, "p2": nil})
+	require.NoError(t, err)
+	require.Equal(t, []ptypeFilter{
+		{ptype: "g", values: []string{"", "", "domain1"}},
+		{ptype: "p", values: []string{"", "domain1"}},
+		{ptype: "p2"},
+	}, filters)
+
+	filters, err = parseFilter(map[string][][]string{"p": {{"alice"}, {"bob", "data2"}}, "g": {}})
+	require.NoError(t, err)
+	require.Equal(t, []ptypeFilter{{ptype: "p", values: []string{"alice"}}, {ptype: "p", values: []string{"bob", "data2"}}}, filters)
+
+	filters, err = parseFilter(map[string][]string{})
+	require.NoError(t, err)
+	require.Empty(t, filters)
+	_, err = parseFilter(map[string][]string{"": {"alice"}})
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	_, err = parseFilter([]string{"p, alice", ""})
+	require.EqualError(t, err, "line 2: pgadapter: invalid filter: filter line is empty")
+	_, err = parseFilter([]string{})
+	require.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func (s *AdapterTestSuite) TestLoadFilteredPolicyMap() {
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", s.a)
+	s.Require().NoError(err)
+
+	s.Require().NoError(e.LoadFilteredPolicy(map[string][]string{"p": {"", "data2"}, "g": {"alice"}}))
+	s.Assert().True(e.IsFiltered())
+	s.assertPolicy([][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}}, e.GetPolicy())
+	s.assertPolicy([][]string{{"alice", "data2_admin"}}, e.GetGroupingPolicy())
+
+	s.Require().NoError(e.LoadFilteredPolicy(map[string][][]string{"p": {{"alice"}, {"", "", "write"}}}))
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "write"}}, e.GetPolicy())
+	s.assertPolicy([][]string{}, e.GetGroupingPolicy())
+}
+
+func TestMockLoadFilteredPolicyMap(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v2 = $2`)).
+		WithArgs("g", "domain1").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "g", "alice", "admin", "domain1", "", "", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v0 = $2`)).
+		WithArgs("p", "admin").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("2", "p", "admin", "data1", "read", "", "", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v1 = $2`)).
+		WithArgs("p", "data2").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("3", "p", "bob", "data2", "write", "", "", ""))
+	require.NoError(t, a.LoadFilteredPolicy(m, map[string][][]string{"p": {{"admin"}, {"", "data2"}}, "g": {{"", "", "domain1"}}}))
+	require.True(t, a.IsFiltered())
+	require.Equal(t, [][]string{{"admin", "data1", "read"}, {"bob", "data2", "write"}}, m.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "admin", "domain1"}}, m.GetPolicy("g", "g"))
+}
+
+func TestMockLoadFilteredPolicyString(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v0 = $2 AND v2 = $3`)).
+		WithArgs("p", "alice", "read").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v0 = $2`)).
+		WithArgs("g", "alice").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("2", "g", "alice", "data2_admin", "", "", "", ""))
+	require.NoError(t, a.LoadFilteredPolicy(m, []string{"p, alice, , read", "g, alice"}))
+	require.True(t, a.IsFiltered())
+	require.Equal(t, [][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "data2_admin"}}, m.GetPolicy("g", "g"))
+
+	require.ErrorIs(t, a.LoadFilteredPolicy(m, "p, a, b, c, d, e, f, g"), ErrInvalidFilter)
+	require.ErrorIs(t, a.LoadFilteredPolicy(m, map[string][][]string{"p": {{"alice"}, {"a", "b", "c", "d", "e", "f", "g"}}}), ErrInvalidFilter)
+	require.ErrorIs(t, a.LoadFilteredPolicy(m, "p, \"alice"), ErrInvalidFilter)
+}
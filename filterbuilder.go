@@ -0,0 +1,105 @@
+package pgxadapter
+
+import "fmt"
+
+// FilterBuilder builds the filters of LoadFilteredPolicy by field index rather than by position in a slice:
+//
+//	filter, err := pgxadapter.NewFilter().
+//		P().Field(1, "domain1").
+//		G().Field(2, "domain1").
+//		Build()
+//
+// selects the p rules whose v1 is domain1 and the g rules whose v2 is domain1. Each call of P, G or Ptype starts
+// a set of values for its ptype, the following calls of Field set the values of this set, and the fields not set
+// match any value. The rules matching any of the sets of their ptype are loaded, a set without fields loads every
+// rule of its ptype, and the ptypes without a set are not loaded.
+//
+// The misuses, e.g. a field set before a ptype is selected, are reported by Build, which returns the first one.
+// A FilterBuilder must not be used after Build.
+type FilterBuilder struct {
+	columns int
+	sets    map[string][][]string
+	// ptype is the ptype of the set of values the fields are set in, the last set of sets[ptype]
+	ptype string
+	err   error
+}
+
+// NewFilter returns an empty FilterBuilder for an adapter with the default value columns, see ValueColumns
+func NewFilter() *FilterBuilder {
+	return &FilterBuilder{columns: DefaultValueColumns, sets: map[string][][]string{}}
+}
+
+// ValueColumns sets the number of value columns of the adapter the filter is for, see WithValueColumns,
+// the fields beyond them are rejected by Build
+func (b *FilterBuilder) ValueColumns(n int) *FilterBuilder {
+	if n < 1 && b.err == nil {
+		b.err = fmt.Errorf("%w: value columns must be positive, got %d", ErrInvalidFilter, n)
+	}
+	b.columns = n
+	return b
+}
+
+// P starts a set of values of the p rules
+func (b *FilterBuilder) P() *FilterBuilder {
+	return b.Ptype("p")
+}
+
+// G starts a set of values of the g rules
+func (b *FilterBuilder) G() *FilterBuilder {
+	return b.Ptype("g")
+}
+
+// Ptype starts a set of values of the rules of ptype, e.g. "p2" or "g2"
+func (b *FilterBuilder) Ptype(ptype string) *FilterBuilder {
+	if ptype == "" && b.err == nil {
+		b.err = fmt.Errorf("%w: filter has an empty ptype", ErrInvalidFilter)
+	}
+	b.ptype = ptype
+	b.sets[ptype] = append(b.sets[ptype], []string{})
+	return b
+}
+
+// Field sets the value at index, 0 for v0, of the current set of values, an empty value matches any value
+func (b *FilterBuilder) Field(index int, value string) *FilterBuilder {
+	if b.err != nil {
+		return b
+	}
+	sets := b.sets[b.ptype]
+	switch {
+	case len(sets) == 0:
+		b.err = fmt.Errorf("%w: field %d set before a ptype is selected, call P, G or Ptype first", ErrInvalidFilter, index)
+		return b
+	case index < 0:
+		b.err = fmt.Errorf("%w: field index must not be negative, got %d", ErrInvalidFilter, index)
+		return b
+	}
+	values := sets[len(sets)-1]
+	for len(values) <= index {
+		values = append(values, "")
+	}
+	if values[index] != "" && values[index] != value {
+		b.err = fmt.Errorf("%w: field %d of ptype %q set to %q and %q", ErrInvalidFilter, index, b.ptype, values[index], value)
+		return b
+	}
+	values[index] = value
+	sets[len(sets)-1] = values
+	return b
+}
+
+// Build returns the filter to give to LoadFilteredPolicy, or the first misuse of the builder
+func (b *FilterBuilder) Build() (map[string][][]string, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.sets) == 0 {
+		return nil, fmt.Errorf("%w: filter selects no ptype, call P, G or Ptype", ErrInvalidFilter)
+	}
+	for ptype, sets := range b.sets {
+		for _, values := range sets {
+			if len(values) > b.columns {
+				return nil, fmt.Errorf("%w: field %d of ptype %q is out of the %d value columns", ErrInvalidFilter, len(values)-1, ptype, b.columns)
+			}
+		}
+	}
+	return b.sets, nil
+}
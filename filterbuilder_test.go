@@ -0,0 +1,65 @@
+package pgxadapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestFilterBuilder() {
+	filter, err := NewFilter().P().Field(1, "data2").Field(2, "write").G().Build()
+	s.Require().NoError(err)
+	s.Require().NoError(s.e.LoadFilteredPolicy(filter))
+	s.assertPolicy([][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "write"}}, s.e.GetPolicy())
+	s.Require().Equal([][]string{{"alice", "data2_admin"}}, s.e.GetGroupingPolicy())
+
+	filter, err = NewFilter().P().Field(0, "alice").P().Field(0, "bob").Build()
+	s.Require().NoError(err)
+	s.Require().NoError(s.e.LoadFilteredPolicy(filter))
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}, s.e.GetPolicy())
+	s.Require().Empty(s.e.GetGroupingPolicy())
+}
+
+func TestFilterBuilder(t *testing.T) {
+	filter, err := NewFilter().
+		P().Field(1, "domain1").
+		G().Field(2, "domain1").Field(0, "alice").
+		Ptype("p2").
+		P().Field(0, "bob").Field(0, "bob").
+		Build()
+	require.NoError(t, err)
+	require.Equal(t, map[string][][]string{
+		"p":  {{"", "domain1"}, {"bob"}},
+		"g":  {{"alice", "", "domain1"}},
+		"p2": {{}},
+	}, filter)
+	filters, err := parseFilter(filter)
+	require.NoError(t, err)
+	require.Equal(t, []ptypeFilter{
+		{ptype: "g", values: []string{"alice", "", "domain1"}},
+		{ptype: "p", values: []string{"", "domain1"}},
+		{ptype: "p", values: []string{"bob"}},
+		{ptype: "p2", values: []string{}},
+	}, filters)
+
+	filter, err = NewFilter().ValueColumns(8).P().Field(7, "x").Build()
+	require.NoError(t, err)
+	require.Equal(t, map[string][][]string{"p": {{"", "", "", "", "", "", "", "x"}}}, filter)
+
+	for _, tc := range []struct {
+		builder *FilterBuilder
+		msg     string
+	}{
+		{NewFilter(), "filter selects no ptype"},
+		{NewFilter().Field(0, "alice").P(), "field 0 set before a ptype is selected"},
+		{NewFilter().P().Field(-1, "alice"), "field index must not be negative, got -1"},
+		{NewFilter().P().Field(6, "alice"), `field 6 of ptype "p" is out of the 6 value columns`},
+		{NewFilter().P().Field(0, "alice").Field(0, "bob"), `field 0 of ptype "p" set to "alice" and "bob"`},
+		{NewFilter().Ptype("").Field(0, "alice"), "filter has an empty ptype"},
+		{NewFilter().ValueColumns(0).P(), "value columns must be positive, got 0"},
+	} {
+		_, err := tc.builder.Build()
+		require.ErrorIs(t, err, ErrInvalidFilter)
+		require.ErrorContains(t, err, tc.msg)
+	}
+}
@@ -0,0 +1,91 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// tablePrivileges are the privileges WithTableGrants accepts
+var tablePrivileges = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER", "ALL"}
+
+// WithTableGrants grants privileges on the tables created by the adapter to roles, given as a map from role
+// to privileges, e.g. when the adapter creates the table with a migration role but the application connects as another:
+//
+//	pgxadapter.WithTableGrants(map[string][]string{
+//		"app_rw": {"SELECT", "INSERT", "UPDATE", "DELETE"},
+//		"app_ro": {"SELECT"},
+//	})
+//
+// The GRANT statements run after the table is created, by the constructors unless SkipTableCreate is used
+// and by EnsureTable, granting a privilege again is a no-op. The privileges are also granted on the tables of
+// WithHistory and WithRevisions, whose triggers write with the privileges of the role changing the rules,
+// along with the USAGE of the sequence of the history table. The privileges are the ones of GRANT ... ON TABLE,
+// the roles must exist. The option can't be used with WithTemporaryTable.
+func WithTableGrants(grants map[string][]string) Option {
+	return func(a *Adapter) {
+		a.tableGrants = grants
+	}
+}
+
+// validateGrants checks the roles and privileges of WithTableGrants, which are written in the GRANT statements
+func validateGrants(grants map[string][]string) error {
+	for role, privileges := range grants {
+		if role == "" || strings.ContainsRune(role, 0) {
+			return fmt.Errorf("invalid role name %q", role)
+		}
+		for _, p := range privileges {
+			if !slices.Contains(tablePrivileges, strings.ToUpper(p)) {
+				return fmt.Errorf("invalid privilege %q for role %q", p, role)
+			}
+		}
+	}
+	return nil
+}
+
+// grantTables grants the privileges of WithTableGrants on the rules table of ctx and its history and revision tables
+func (a *Adapter) grantTables(ctx context.Context, db execQuerier) error {
+	if len(a.tableGrants) == 0 {
+		return nil
+	}
+	tables := []string{a.table(ctx)}
+	if a.history {
+		tables = append(tables, a.historyTable(ctx))
+	}
+	if a.revisions {
+		tables = append(tables, a.revisionTable(ctx))
+	}
+	var sequence string
+	if a.history {
+		if err := queryRowWith(ctx, db, `SELECT pg_get_serial_sequence($1, 'history_id')`, []any{pgx.Identifier{a.historyTable(ctx)}.Sanitize()}, &sequence); err != nil {
+			return err
+		}
+	}
+
+	roles := make([]string, 0, len(a.tableGrants))
+	for role := range a.tableGrants {
+		roles = append(roles, role)
+	}
+	slices.Sort(roles)
+	for _, role := range roles {
+		grantee := pgx.Identifier{role}.Sanitize()
+		for _, table := range tables {
+			for _, p := range a.tableGrants[role] {
+				p = strings.ToUpper(p)
+				if _, err := db.Exec(ctx, fmt.Sprintf(`GRANT %v ON TABLE "%v" TO %v`, p, table, grantee)); err != nil {
+					return fmt.Errorf("granting %v on %q to role %q: %w", p, table, role, err)
+				}
+			}
+		}
+		if sequence != "" {
+			// the sequence name returned by postgres is already quoted
+			if _, err := db.Exec(ctx, fmt.Sprintf(`GRANT USAGE ON SEQUENCE %v TO %v`, sequence, grantee)); err != nil {
+				return fmt.Errorf("granting USAGE on %v to role %q: %w", sequence, role, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestTableGrants() {
+	ctx := context.Background()
+	for _, role := range []string{"casbin_grant_rw", "casbin_grant_ro"} {
+		s.a.db.Exec(ctx, `DROP ROLE IF EXISTS `+role)
+		_, err := s.a.db.Exec(ctx, `CREATE ROLE `+role)
+		s.Require().NoError(err)
+	}
+	grants := func(table string) map[string][]string {
+		rows, err := s.a.db.Query(ctx, `SELECT grantee::text, privilege_type::text FROM information_schema.role_table_grants
+			WHERE table_name = $1 AND grantee IN ('casbin_grant_rw', 'casbin_grant_ro') ORDER BY grantee, privilege_type`, table)
+		s.Require().NoError(err)
+		got := map[string][]string{}
+		for rows.Next() {
+			var grantee, privilege string
+			s.Require().NoError(rows.Scan(&grantee, &privilege))
+			got[grantee] = append(got[grantee], privilege)
+		}
+		s.Require().NoError(rows.Err())
+		return got
+	}
+
+	opts := []Option{WithTableName("rules_grants"), WithHistory(), WithTableGrants(map[string][]string{
+		"casbin_grant_rw": {"select", "INSERT", "UPDATE", "DELETE"},
+		"casbin_grant_ro": {"SELECT"},
+	})}
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), opts...)
+	s.Require().NoError(err)
+	defer func() {
+		a.db.Exec(ctx, `DROP TABLE IF EXISTS "rules_grants", "rules_grants_history" CASCADE`)
+		a.db.Exec(ctx, `DROP ROLE IF EXISTS casbin_grant_rw`)
+		a.db.Exec(ctx, `DROP ROLE IF EXISTS casbin_grant_ro`)
+		a.Close()
+	}()
+	expected := map[string][]string{
+		"casbin_grant_ro": {"SELECT"},
+		"casbin_grant_rw": {"DELETE", "INSERT", "SELECT", "UPDATE"},
+	}
+	s.Assert().Equal(expected, grants("rules_grants"))
+	s.Assert().Equal(expected, grants("rules_grants_history"))
+
+	// granting again is a no-op
+	s.Require().NoError(a.EnsureTable(ctx))
+	s.Assert().Equal(expected, grants("rules_grants"))
+
+	_, err = NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("rules_grants"),
+		WithTableGrants(map[string][]string{"casbin_grant_missing": {"SELECT"}}))
+	s.Require().ErrorContains(err, `granting SELECT on "rules_grants" to role "casbin_grant_missing"`)
+}
+
+func TestMockTableGrants(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS "casbin_rules"`).WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`GRANT SELECT ON TABLE "casbin_rules" TO "app_ro"`)).WillReturnResult(pgxmock.NewResult("GRANT", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`GRANT SELECT ON TABLE "casbin_rules" TO "app_rw"`)).WillReturnResult(pgxmock.NewResult("GRANT", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`GRANT INSERT ON TABLE "casbin_rules" TO "app_rw"`)).WillReturnError(&pgconn.PgError{Code: "42704", Message: `role "app_rw" does not exist`})
+	_, err = NewAdapterByPgxPool(mock, SkipSchemaVerification(), WithTableGrants(map[string][]string{
+		"app_rw": {"select", "insert"},
+		"app_ro": {"SELECT"},
+	}))
+	require.ErrorContains(t, err, `granting INSERT on "casbin_rules" to role "app_rw"`)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	for _, grants := range []map[string][]string{
+		{"app": {"SELECT; DROP TABLE casbin_rules"}},
+		{"": {"SELECT"}},
+	} {
+		_, err = NewAdapterByPgxPool(mock, SkipTableCreate(), SkipSchemaVerification(), WithTableGrants(grants))
+		require.ErrorContains(t, err, "WithTableGrants")
+	}
+	_, err = NewAdapterByPgxPool(mock, WithTemporaryTable(), WithTableGrants(map[string][]string{"app": {"SELECT"}}))
+	require.ErrorContains(t, err, "WithTableGrants can't be used with WithTemporaryTable")
+}
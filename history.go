@@ -0,0 +1,148 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+// WithHistory records every change of the rules table into a history table, named after the rules table
+// with a _history suffix, so the rules stored at a past time can be loaded with LoadPolicyAt.
+// The history table and the trigger filling it are created along with the rules table, unless SkipTableCreate is used.
+// The rules stored when the history starts being recorded are valid from that time.
+// The history grows with every change, PurgeHistory deletes the old entries.
+func WithHistory() Option {
+	return func(a *Adapter) {
+		a.history = true
+	}
+}
+
+// historyTable returns the history table of the rules table of ctx
+func (a *Adapter) historyTable(ctx context.Context) string {
+	return a.table(ctx) + "_history"
+}
+
+// createHistory creates the history table and the trigger recording the changes of the rules table into it
+func (a *Adapter) createHistory(ctx context.Context, db execer) error {
+	table, history := a.table(ctx), a.historyTable(ctx)
+	create := "CREATE TABLE"
+	if a.temporary {
+		create = "CREATE TEMP TABLE"
+	}
+	_, err := db.Exec(ctx, fmt.Sprintf(`
+		%v IF NOT EXISTS "%v" (
+			history_id BIGSERIAL PRIMARY KEY,
+			id TEXT NOT NULL,
+			ptype TEXT NOT NULL,
+			valid_from TIMESTAMPTZ NOT NULL DEFAULT now(),
+			valid_to TIMESTAMPTZ
+		)
+	`, create, history))
+	if err != nil && !isPgError(err, codeDuplicateTable) {
+		return err
+	}
+
+	// the value columns are added like the ones of the rules table, so the history follows WithValueColumns
+	alters := make([]string, 0, a.valueColumns+1)
+	for i := 0; i < a.valueColumns; i++ {
+		alters = append(alters, fmt.Sprintf("ADD COLUMN IF NOT EXISTS v%d TEXT", i))
+	}
+	if a.namespaced {
+		alters = append(alters, "ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT ''")
+	}
+	if _, err := db.Exec(ctx, fmt.Sprintf(`ALTER TABLE "%v" %v`, history, strings.Join(alters, ", "))); err != nil {
+		return err
+	}
+	if _, err := db.Exec(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%[1]v_valid" ON "%[1]v" (valid_from, valid_to)`, history)); err != nil &&
+		!isPgError(err, codeDuplicateTable) {
+		return err
+	}
+
+	cols := a.insertColumns()
+	newCols := "NEW." + strings.ReplaceAll(cols, ", ", ", NEW.")
+	// the rows removed in the transaction adding them, e.g. by SavePolicy, get an empty range and are never loaded
+	_, err = db.Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION "%[1]v_record"() RETURNS trigger LANGUAGE plpgsql AS $fn$
+		BEGIN
+			IF TG_OP = 'TRUNCATE' THEN
+				UPDATE "%[1]v" SET valid_to = now() WHERE valid_to IS NULL;
+				RETURN NULL;
+			END IF;
+			IF TG_OP IN ('UPDATE', 'DELETE') THEN
+				UPDATE "%[1]v" SET valid_to = now() WHERE id = OLD.id AND valid_to IS NULL;
+			END IF;
+			IF TG_OP IN ('INSERT', 'UPDATE') THEN
+				INSERT INTO "%[1]v" (%[2]v) VALUES (%[3]v);
+			END IF;
+			RETURN NULL;
+		END
+		$fn$
+	`, history, cols, newCols))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, fmt.Sprintf(`
+		DO $do$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'record_history' AND tgrelid = '"%[1]v"'::regclass) THEN
+				CREATE TRIGGER record_history AFTER INSERT OR UPDATE OR DELETE ON "%[1]v"
+					FOR EACH ROW EXECUTE FUNCTION "%[2]v_record"();
+				CREATE TRIGGER record_history_truncate AFTER TRUNCATE ON "%[1]v"
+					FOR EACH STATEMENT EXECUTE FUNCTION "%[2]v_record"();
+				INSERT INTO "%[2]v" (%[3]v) SELECT %[3]v FROM "%[1]v";
+			END IF;
+		END
+		$do$
+	`, table, history, cols))
+	if err != nil && !isPgError(err, codeDuplicateObject) {
+		return err
+	}
+	return nil
+}
+
+// LoadPolicyAt loads the rules stored at time t into model, which is expected to hold no rules, see WithHistory.
+// The adapter is marked as filtered afterwards, so the enforcer can't save the past rules back with SavePolicy.
+// The rules removed before the history was recorded, or whose history was purged, are not loaded.
+func (a *Adapter) LoadPolicyAt(ctx context.Context, model model.Model, t time.Time) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "LoadPolicyAt"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v" WHERE valid_from <= $1 AND (valid_to IS NULL OR valid_to > $1)`,
+		a.columns(), a.historyTable(ctx)), []any{t}, false)
+	// the values are loaded as stored, without the quoting of a policy line
+	err = a.loadRows(ctx, a.db, sql, args, func(line *CasbinRule) error {
+		return persist.LoadPolicyArray(line.tokens(), model)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.setFiltered(true)
+	return nil
+}
+
+// PurgeHistory deletes the history of the rules removed before the given time and returns the number of entries deleted,
+// LoadPolicyAt then loads the rules as of a time before it without them.
+// The rules still stored are kept whenever they were added.
+func (a *Adapter) PurgeHistory(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "PurgeHistory"})
+	if err != nil {
+		return 0, err
+	}
+	defer finish(&err)
+
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE valid_to < $1`, a.historyTable(ctx)), []any{before}, false)
+	tag, err := a.db.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,98 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestHistory() {
+	ctx := context.Background()
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTemporaryTable(), WithTableName("rules_history_test"), WithHistory())
+	s.Require().NoError(err)
+	defer a.Close()
+
+	now := func() time.Time {
+		var t time.Time
+		s.Require().NoError(a.queryRow(ctx, `SELECT clock_timestamp()`, nil, &t))
+		return t
+	}
+	loadAt := func(t time.Time) [][]string {
+		m, err := model.NewModelFromFile("examples/rbac_model.conf")
+		s.Require().NoError(err)
+		s.Require().NoError(a.LoadPolicyAt(ctx, m, t))
+		return m.GetPolicy("p", "p")
+	}
+
+	before := now()
+	s.Require().NoError(a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}))
+	t1 := now()
+	s.Require().NoError(a.UpdatePolicy("p", "p", []string{"bob", "data2", "write"}, []string{"bob", "data2", "read"}))
+	t2 := now()
+	s.Require().NoError(a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+	t3 := now()
+
+	s.Require().Empty(loadAt(before))
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}, loadAt(t1))
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "read"}}, loadAt(t2))
+	s.assertPolicy([][]string{{"bob", "data2", "read"}}, loadAt(t3))
+	s.Require().True(a.IsFiltered())
+
+	// purging forgets the removed rules, not the stored ones
+	n, err := a.PurgeHistory(ctx, t3)
+	s.Require().NoError(err)
+	s.Require().EqualValues(2, n)
+	s.assertPolicy([][]string{{"bob", "data2", "read"}}, loadAt(t2))
+}
+
+func TestMockHistoryCreate(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, mock.ExpectationsWereMet()) }()
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules_history"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "casbin_rules_history" ADD COLUMN IF NOT EXISTS v0 TEXT`)).
+		WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX IF NOT EXISTS "casbin_rules_history_valid"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules_history" (id, ptype, v0, v1, v2, v3, v4, v5) VALUES (NEW.id, NEW.ptype, NEW.v0, NEW.v1, NEW.v2, NEW.v3, NEW.v4, NEW.v5)`)).
+		WillReturnResult(pgxmock.NewResult("CREATE FUNCTION", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TRIGGER record_history AFTER INSERT OR UPDATE OR DELETE ON "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("DO", 0))
+	_, err = NewAdapterByPgxPool(mock, WithHistory(), SkipSchemaVerification())
+	require.NoError(t, err)
+}
+
+func TestMockLoadPolicyAt(t *testing.T) {
+	a, mock := newMockAdapter(t, WithHistory(), WithNamespace("tenant1"))
+	ctx := context.Background()
+	at := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules_history" WHERE valid_from <= $1 AND (valid_to IS NULL OR valid_to > $1) AND namespace = $2`)).
+		WithArgs(at, "tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", "alice", "data1", "read", "", "", "").
+			AddRow("2", "p", "bob", `reports, "2026"`, "read", "", "", ""))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicyAt(ctx, m, at))
+	// the values are loaded as stored, a comma or a quote doesn't split them
+	require.Equal(t, [][]string{{"alice", "data1", "read"}, {"bob", `reports, "2026"`, "read"}}, m.GetPolicy("p", "p"))
+	require.True(t, a.IsFiltered())
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules_history" WHERE valid_to < $1 AND namespace = $2`)).
+		WithArgs(at, "tenant1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	n, err := a.PurgeHistory(ctx, at)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, n)
+}
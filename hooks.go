@@ -0,0 +1,113 @@
+package pgxadapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// OpInfo describes an adapter operation given to the hooks
+type OpInfo struct {
+	// Op is the name of the adapter method, e.g. "AddPolicies"
+	Op string
+	// Table is the rules table
+	Table string
+	// Ptype is the policy type the operation applies to, empty when it applies to all the policy types
+	Ptype string
+	// Rules is the number of rules given to the operation, 0 for operations reading or filtering rules
+	Rules int
+}
+
+// Hooks are called around every adapter operation reading or writing the database
+type Hooks struct {
+	// Before is called before the operation runs any statement.
+	// The returned context is used by the operation, a returned error aborts it.
+	Before func(ctx context.Context, op OpInfo) (context.Context, error)
+	// After is called when the operation ends, with the error returned by the operation.
+	// It is also called when the operation fails before the Before hook is called, e.g. its tenant or its table can't be resolved.
+	After func(ctx context.Context, op OpInfo, err error)
+}
+
+// WithHooks adds hooks called around the adapter operations, e.g. to record metrics or log.
+// When the option is given several times, the Before hooks run in order and the After hooks in reverse order.
+func WithHooks(hooks Hooks) Option {
+	return func(a *Adapter) {
+		a.hooks = append(a.hooks, hooks)
+	}
+}
+
+// start begins the operation op: it makes Shutdown wait for it and runs the Before hooks.
+// finish must be deferred with the address of the error returned by the operation,
+// it wraps the error, runs the After hooks and ends the operation.
+// When the operation can't begin, e.g. the adapter is closed, the error is wrapped and every After hook runs.
+func (a *Adapter) start(ctx context.Context, op OpInfo) (context.Context, func(errp *error), error) {
+	op.Table = a.TableName()
+	ctx, done, err := a.begin(ctx)
+	if err != nil {
+		a.checkError(ctx, op.Op, op.Table, &err)
+		a.runAfter(ctx, op, len(a.hooks), err)
+		return ctx, nil, err
+	}
+	ctx = context.WithValue(withOp(ctx, op.Op), tableKey{}, op.Table)
+	ctx, timeout := a.withTimeout(ctx, op.Op)
+
+	begun := time.Now()
+	ran := 0
+	finish := func(errp *error) {
+		defer done()
+		timeout.end(ctx, errp)
+		a.checkError(ctx, op.Op, op.Table, errp)
+		elapsed := time.Since(begun)
+		if a.metrics != nil {
+			a.metrics.record(ctx, op, elapsed, *errp)
+		}
+		a.logSlowOp(ctx, op, elapsed, *errp)
+		a.runAfter(ctx, op, ran, *errp)
+	}
+
+	// the tenant and the table are resolved before the Before hooks, which are given them,
+	// every After hook runs when they can't be
+	if ctx, err = a.withTenant(ctx, op.Op); err != nil {
+		ran = len(a.hooks)
+		finish(&err)
+		return ctx, nil, err
+	}
+	if ctx, err = a.routeTable(ctx, &op); err != nil {
+		ran = len(a.hooks)
+		finish(&err)
+		return ctx, nil, err
+	}
+	for _, h := range a.hooks {
+		if h.Before != nil {
+			ctx, err = h.Before(ctx, op)
+			if err != nil {
+				ran++
+				finish(&err)
+				return ctx, nil, err
+			}
+		}
+		ran++
+	}
+	return ctx, finish, nil
+}
+
+// runAfter runs in reverse order the After hooks of the first n hooks
+func (a *Adapter) runAfter(ctx context.Context, op OpInfo, n int, err error) {
+	for i := n - 1; i >= 0; i-- {
+		if after := a.hooks[i].After; after != nil {
+			after(ctx, op, err)
+		}
+	}
+}
+
+// modelRules returns the number of rules of the model
+func modelRules(m model.Model) int {
+	n := 0
+	for _, sec := range []string{"p", "g"} {
+		for _, ast := range m[sec] {
+			n += len(ast.Policy)
+		}
+	}
+	return n
+}
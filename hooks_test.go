@@ -0,0 +1,143 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type ctxKey struct{}
+
+func TestMockHooks(t *testing.T) {
+	var before, after []OpInfo
+	var afterErrs []error
+	a, mock := newMockAdapter(t, WithHooks(Hooks{
+		Before: func(ctx context.Context, op OpInfo) (context.Context, error) {
+			before = append(before, op)
+			return context.WithValue(ctx, ctxKey{}, op.Op), nil
+		},
+		After: func(ctx context.Context, op OpInfo, err error) {
+			require.Equal(t, op.Op, ctx.Value(ctxKey{}))
+			after = append(after, op)
+			afterErrs = append(afterErrs, err)
+		},
+	}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules"`)).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+	require.Error(t, a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicy(m))
+
+	require.Equal(t, []OpInfo{
+		{Op: "AddPolicies", Table: "casbin_rules", Ptype: "p", Rules: 2},
+		{Op: "RemovePolicy", Table: "casbin_rules", Ptype: "p", Rules: 1},
+		{Op: "LoadPolicy", Table: "casbin_rules"},
+	}, before)
+	require.Equal(t, before, after)
+	require.NoError(t, afterErrs[0])
+	require.ErrorContains(t, afterErrs[1], "connection reset")
+	require.NoError(t, afterErrs[2])
+}
+
+func TestMockHooksAbort(t *testing.T) {
+	refused := errors.New("refused")
+	var calls []string
+	a, _ := newMockAdapter(t,
+		WithHooks(Hooks{
+			Before: func(ctx context.Context, op OpInfo) (context.Context, error) {
+				calls = append(calls, "before 1")
+				return ctx, nil
+			},
+			After: func(ctx context.Context, op OpInfo, err error) {
+				require.ErrorIs(t, err, refused)
+				calls = append(calls, "after 1")
+			},
+		}),
+		WithHooks(Hooks{
+			Before: func(ctx context.Context, op OpInfo) (context.Context, error) {
+				calls = append(calls, "before 2")
+				return ctx, refused
+			},
+			After: func(ctx context.Context, op OpInfo, err error) {
+				calls = append(calls, "after 2")
+			},
+		}),
+		WithHooks(Hooks{
+			Before: func(ctx context.Context, op OpInfo) (context.Context, error) {
+				calls = append(calls, "before 3")
+				return ctx, nil
+			},
+		}),
+	)
+
+	// no statement runs, the mock has no expectation
+	err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	require.ErrorIs(t, err, refused)
+	require.Equal(t, []string{"before 1", "before 2", "after 2", "after 1"}, calls)
+}
+
+func TestMockHooksClosed(t *testing.T) {
+	var calls []string
+	var afterErr error
+	a, mock := newMockAdapter(t, WithHooks(Hooks{
+		Before: func(ctx context.Context, op OpInfo) (context.Context, error) {
+			calls = append(calls, "before")
+			return ctx, nil
+		},
+		After: func(ctx context.Context, op OpInfo, err error) {
+			calls = append(calls, "after "+op.Op)
+			afterErr = err
+		},
+	}))
+	mock.ExpectClose()
+	require.NoError(t, a.Close())
+
+	// the operation can't begin, the After hook still runs with the wrapped error
+	err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	require.ErrorIs(t, err, ErrAdapterClosed)
+	var opErr *OpError
+	require.ErrorAs(t, err, &opErr)
+	require.Equal(t, "AddPolicy", opErr.Op)
+	require.Equal(t, []string{"after AddPolicy"}, calls)
+	require.Equal(t, err, afterErr)
+}
+
+func TestMockHooksTenantAndTableErrors(t *testing.T) {
+	var afterErrs []error
+	a, _ := newMockAdapter(t, WithTenantFromContext(true), WithTableResolver(tenantTables), WithHooks(Hooks{
+		Before: func(ctx context.Context, op OpInfo) (context.Context, error) {
+			t.Fatal("Before runs after the tenant and the table are resolved")
+			return ctx, nil
+		},
+		After: func(ctx context.Context, op OpInfo, err error) {
+			afterErrs = append(afterErrs, err)
+		},
+	}))
+
+	// the operations fail before any Before hook, the After hooks still run
+	_, err := a.CountRules(context.Background(), "p")
+	require.ErrorIs(t, err, ErrTenantRequired)
+	_, err = a.CountRules(WithTenantContext(context.Background(), "down"), "p")
+	require.ErrorContains(t, err, "tenant directory unavailable")
+	require.Len(t, afterErrs, 2)
+	require.ErrorIs(t, afterErrs[0], ErrTenantRequired)
+	require.ErrorContains(t, afterErrs[1], "tenant directory unavailable")
+}
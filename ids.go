@@ -0,0 +1,168 @@
+package pgxadapter
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// IDScheme is the hash giving the ids of the rules, see WithIDScheme
+type IDScheme int
+
+const (
+	// IDSchemeXXH3 is the default scheme, the ids are the xxh3 hashes of the rules
+	IDSchemeXXH3 IDScheme = iota
+	// IDSchemeMD5 gives the md5 hashes of the rules as ids, which postgres can compute, see InstallIDTrigger
+	IDSchemeMD5
+)
+
+func (s IDScheme) String() string {
+	switch s {
+	case IDSchemeXXH3:
+		return "xxh3"
+	case IDSchemeMD5:
+		return "md5"
+	}
+	return fmt.Sprintf("IDScheme(%d)", int(s))
+}
+
+// WithIDScheme sets the hash giving the ids of the rules.
+// The rules stored with another scheme are not found by their id anymore, RepairIDs gives them the ids of the new scheme.
+func WithIDScheme(s IDScheme) Option {
+	return func(a *Adapter) {
+		a.idScheme = s
+	}
+}
+
+// PolicyID returns the id the scheme gives to a rule of namespace, which is empty for the adapters without WithNamespace
+func (s IDScheme) PolicyID(namespace, ptype string, rule []string) string {
+	key := ptype
+	if namespace != "" {
+		// ids of the empty namespace are the ones of the adapters without namespace
+		key = namespace + "\x00" + ptype
+	}
+	if s == IDSchemeMD5 {
+		return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(append([]string{key}, rule...), ","))))
+	}
+	return policyID(key, rule)
+}
+
+// ComputePolicyID returns the id the adapter gives to a rule, so tools writing the rules table directly
+// store rows the adapter can find. The rules of a namespace have other ids, see ComputeNamespacedPolicyID,
+// and so do the adapters using WithIDScheme, see IDScheme.PolicyID.
+func ComputePolicyID(ptype string, rule []string) string {
+	return IDSchemeXXH3.PolicyID("", ptype, rule)
+}
+
+// ComputeNamespacedPolicyID returns the id an adapter using WithNamespace gives to a rule,
+// the ids of the empty namespace are the ones of ComputePolicyID
+func ComputeNamespacedPolicyID(namespace, ptype string, rule []string) string {
+	return IDSchemeXXH3.PolicyID(namespace, ptype, rule)
+}
+
+// IDMismatch is a row whose id is not the one the adapter gives to its rule, returned by VerifyIDs
+type IDMismatch struct {
+	ID         string
+	ExpectedID string
+	Ptype      string
+	Rule       []string
+}
+
+// VerifyIDs returns the rows whose id is not the one the adapter gives to their rule, e.g. rows inserted by hand,
+// which RemovePolicy and UpdatePolicies can't find. The table is read in batches like ForEachRule.
+func (a *Adapter) VerifyIDs(ctx context.Context) (_ []IDMismatch, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "VerifyIDs"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	return a.verifyIDs(ctx)
+}
+
+// verifyIDs returns the rows with a wrong id
+func (a *Adapter) verifyIDs(ctx context.Context) ([]IDMismatch, error) {
+	var mismatches []IDMismatch
+	err := a.forEachRule(ctx, func(line CasbinRule) error {
+		rule := line.rule(a.valueColumns)
+		if expected := a.policyLine(ctx, line.Ptype, rule).ID; line.ID != expected {
+			mismatches = append(mismatches, IDMismatch{ID: line.ID, ExpectedID: expected, Ptype: line.Ptype, Rule: rule})
+		}
+		return nil
+	})
+	return mismatches, err
+}
+
+// RepairIDs gives the rows returned by VerifyIDs the id of their rule, in batches of the size of WithScanBatchSize,
+// each batch in its own transaction, and returns the number of rows repaired.
+// A row whose expected id is already used by another row, which stores the same rule, is left unchanged and returned
+// in collisions, DeduplicateRules removes such rows. Every collision is logged as a warning with the logger of WithLogger.
+func (a *Adapter) RepairIDs(ctx context.Context) (repaired int, collisions []IDMismatch, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RepairIDs"})
+	if err != nil {
+		return 0, nil, err
+	}
+	defer finish(&err)
+
+	mismatches, err := a.verifyIDs(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	for start := 0; start < len(mismatches); start += a.scanBatchSize {
+		batch := mismatches[start:min(start+a.scanBatchSize, len(mismatches))]
+		n, c, err := a.repairIDs(ctx, batch)
+		if err != nil {
+			return repaired, collisions, err
+		}
+		repaired += n
+		collisions = append(collisions, c...)
+	}
+	return repaired, collisions, nil
+}
+
+// repairIDs updates the ids of a batch of rows in a transaction
+func (a *Adapter) repairIDs(ctx context.Context, batch []IDMismatch) (int, []IDMismatch, error) {
+	tx, err := a.conn(ctx).Begin(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var repaired int
+	var collisions []IDMismatch
+	table := a.table(ctx)
+	for _, m := range batch {
+		// the subquery is scoped too, so a distributed table is only read on the shard of the namespace
+		used, args := a.byID(ctx, fmt.Sprintf(`SELECT 1 FROM "%v" WHERE id = $1`, table), []any{m.ExpectedID, m.ID})
+		sql, args := a.byID(ctx, fmt.Sprintf(`UPDATE "%v" SET id = $1 WHERE id = $2 AND NOT EXISTS (%v)`, table, used), args)
+		tag, err := tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return 0, nil, err
+		}
+		if tag.RowsAffected() == 1 {
+			repaired++
+			continue
+		}
+		var exists bool
+		sql, args = a.byID(ctx, fmt.Sprintf(`SELECT 1 FROM "%v" WHERE id = $1`, table), []any{m.ID})
+		if err := queryRowWith(ctx, tx, "SELECT EXISTS ("+sql+")", args, &exists); err != nil {
+			return 0, nil, err
+		}
+		// rows removed since they were verified need no repair
+		if exists {
+			collisions = append(collisions, m)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, err
+	}
+
+	for _, m := range collisions {
+		a.logger.LogAttrs(ctx, slog.LevelWarn, "pgadapter: rule id already used by another row, left unchanged",
+			slog.String("table", table), slog.String("id", m.ID), slog.String("expected_id", m.ExpectedID),
+			slog.String("rule", a.policyLine(ctx, m.Ptype, m.Rule).String()))
+	}
+	return repaired, collisions, nil
+}
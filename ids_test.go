@@ -0,0 +1,84 @@
+package pgxadapter
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestRepairIDs() {
+	ctx := context.Background()
+	_, err := s.a.db.Exec(ctx, `INSERT INTO casbin_rules (id, ptype, v0, v1, v2, v3, v4, v5) VALUES
+		('manual-1', 'p', 'carol', 'data3', 'read', '', '', ''),
+		('manual-2', 'p', 'alice', 'data1', 'read', '', '', '')`)
+	s.Require().NoError(err)
+
+	mismatches, err := s.a.VerifyIDs(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(mismatches, 2)
+	s.Require().Equal(IDMismatch{
+		ID: "manual-1", ExpectedID: ComputePolicyID("p", []string{"carol", "data3", "read"}), Ptype: "p", Rule: []string{"carol", "data3", "read"},
+	}, mismatches[0])
+
+	// carol can't be removed with the wrong id
+	s.Require().NoError(s.a.RemovePolicy("p", "p", []string{"carol", "data3", "read"}))
+	count, err := s.a.CountRules(ctx, "p")
+	s.Require().NoError(err)
+	s.Require().EqualValues(6, count)
+
+	repaired, collisions, err := s.a.RepairIDs(ctx)
+	s.Require().NoError(err)
+	s.Require().Equal(1, repaired)
+	s.Require().Len(collisions, 1)
+	s.Require().Equal("manual-2", collisions[0].ID)
+
+	s.Require().NoError(s.a.RemovePolicy("p", "p", []string{"carol", "data3", "read"}))
+	count, err = s.a.CountRules(ctx, "p")
+	s.Require().NoError(err)
+	s.Require().EqualValues(5, count)
+}
+
+func TestComputePolicyID(t *testing.T) {
+	rule := []string{"alice", "data1", "read"}
+	require.Equal(t, policyID("p", rule), ComputePolicyID("p", rule))
+	require.Equal(t, ComputePolicyID("p", rule), ComputeNamespacedPolicyID("", "p", rule))
+
+	a, _ := newMockAdapter(t, WithNamespace("tenant1"))
+	require.Equal(t, a.policyLine(context.Background(), "p", rule).ID, ComputeNamespacedPolicyID("tenant1", "p", rule))
+}
+
+func TestMockRepairIDs(t *testing.T) {
+	var buf bytes.Buffer
+	a, mock := newMockAdapter(t, WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	ctx := context.Background()
+	carol := ComputePolicyID("p", []string{"carol", "data3", "read"})
+	alice := ComputePolicyID("p", []string{"alice", "data1", "read"})
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE id > $1`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow(alice, "p", "alice", "data1", "read", "", "", "").
+			AddRow("manual-1", "p", "carol", "data3", "read", "", "", "").
+			AddRow("manual-2", "p", "alice", "data1", "read", "", "", ""))
+	mock.ExpectBegin()
+	update := regexp.QuoteMeta(`UPDATE "casbin_rules" SET id = $1 WHERE id = $2 AND NOT EXISTS (SELECT 1 FROM "casbin_rules" WHERE id = $1)`)
+	mock.ExpectExec(update).WithArgs(carol, "manual-1").WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(update).WithArgs(alice, "manual-2").WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM "casbin_rules" WHERE id = $1)`)).WithArgs("manual-2").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectCommit()
+
+	repaired, collisions, err := a.RepairIDs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, repaired)
+	require.Equal(t, []IDMismatch{{ID: "manual-2", ExpectedID: alice, Ptype: "p", Rule: []string{"alice", "data1", "read"}}}, collisions)
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 1)
+	require.Equal(t, "WARN", entries[0]["level"])
+	require.Equal(t, "manual-2", entries[0]["id"])
+}
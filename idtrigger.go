@@ -0,0 +1,78 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// InstallIDTrigger creates a trigger giving the rows inserted without id, or with an empty one, the id the adapter
+// gives to their rule, so the rules inserted with SQL, e.g. by hand with psql, can be removed and updated by the adapter:
+//
+//	INSERT INTO casbin_rules (ptype, v0, v1, v2) VALUES ('p', 'alice', 'data1', 'read');
+//
+// The ids are computed by postgres, so the adapter must use WithIDScheme(IDSchemeMD5), the xxh3 ids can't be.
+// The trigger follows the namespace column of WithNamespace and the value columns of WithValueColumns,
+// it is replaced when InstallIDTrigger is called again, e.g. after the value columns changed.
+func (a *Adapter) InstallIDTrigger(ctx context.Context) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "InstallIDTrigger"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	if a.idScheme != IDSchemeMD5 {
+		return fmt.Errorf("pgadapter: the %v ids can't be computed by postgres, InstallIDTrigger needs WithIDScheme(IDSchemeMD5)", a.idScheme)
+	}
+
+	values := make([]string, a.valueColumns)
+	for i := range values {
+		values[i] = fmt.Sprintf("coalesce(NEW.v%d, '')", i)
+	}
+	// the key of a namespace is prefixed with a NUL byte, which text values can't hold, so the hash is computed on bytes
+	namespace := ""
+	if a.namespaced {
+		namespace = `
+			IF NEW.namespace <> '' THEN
+				data := convert_to(NEW.namespace, 'UTF8') || '\x00'::bytea || data;
+			END IF;`
+	}
+	table := a.table(ctx)
+	// the trailing empty values are not part of the rules given to the adapter, see CasbinRule.rule
+	_, err = a.db.Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION "%[1]v_fill_id"() RETURNS trigger LANGUAGE plpgsql AS $fn$
+		DECLARE
+			vals text[] := ARRAY[%[2]v];
+			n int := %[3]d;
+			data bytea;
+		BEGIN
+			IF NEW.id IS NOT NULL AND NEW.id <> '' THEN
+				RETURN NEW;
+			END IF;
+			WHILE n > 0 AND vals[n] = '' LOOP
+				n := n - 1;
+			END LOOP;
+			data := convert_to(array_to_string(ARRAY[NEW.ptype] || vals[1:n], ','), 'UTF8');%[4]v
+			NEW.id := md5(data);
+			RETURN NEW;
+		END
+		$fn$
+	`, table, strings.Join(values, ", "), a.valueColumns, namespace))
+	if err != nil {
+		return err
+	}
+
+	_, err = a.db.Exec(ctx, fmt.Sprintf(`
+		DO $do$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'fill_id' AND tgrelid = '"%[1]v"'::regclass) THEN
+				CREATE TRIGGER fill_id BEFORE INSERT ON "%[1]v" FOR EACH ROW EXECUTE FUNCTION "%[1]v_fill_id"();
+			END IF;
+		END
+		$do$
+	`, table))
+	if err != nil && !isPgError(err, codeDuplicateObject) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestInstallIDTrigger() {
+	ctx := context.Background()
+	for _, ns := range []string{"", "tenant1"} {
+		opts := []Option{WithTemporaryTable(), WithTableName("rules_id_trigger"), WithIDScheme(IDSchemeMD5)}
+		if ns != "" {
+			opts = append(opts, WithNamespace(ns))
+		}
+		a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), opts...)
+		s.Require().NoError(err)
+		s.Require().NoError(a.InstallIDTrigger(ctx))
+		s.Require().NoError(a.InstallIDTrigger(ctx))
+
+		if ns == "" {
+			_, err = a.db.Exec(ctx, `INSERT INTO rules_id_trigger (ptype, v0, v1, v2) VALUES ('p', 'alice', 'data1', 'read')`)
+		} else {
+			_, err = a.db.Exec(ctx, `INSERT INTO rules_id_trigger (ptype, v0, v1, v2, namespace) VALUES ('p', 'alice', 'data1', 'read', $1)`, ns)
+		}
+		s.Require().NoError(err)
+		_, err = a.db.Exec(ctx, `INSERT INTO rules_id_trigger (id, ptype, v0, v1) VALUES ('', 'g', 'alice', 'admin')`)
+		s.Require().NoError(err)
+		mismatches, err := a.VerifyIDs(ctx)
+		s.Require().NoError(err)
+		s.Require().Empty(mismatches)
+
+		s.Require().NoError(a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+		count, err := a.CountRules(ctx, "p")
+		s.Require().NoError(err)
+		s.Require().EqualValues(0, count)
+		s.Require().NoError(a.Close())
+	}
+}
+
+func TestIDSchemeMD5(t *testing.T) {
+	rule := []string{"alice", "data1", "read"}
+	require.Equal(t, "6af26041539f44835d695c6a1b3dd062", IDSchemeMD5.PolicyID("", "p", rule))
+	require.Equal(t, ComputePolicyID("p", rule), IDSchemeXXH3.PolicyID("", "p", rule))
+	require.NotEqual(t, IDSchemeMD5.PolicyID("", "p", rule), IDSchemeMD5.PolicyID("tenant1", "p", rule))
+
+	a, _ := newMockAdapter(t, WithIDScheme(IDSchemeMD5))
+	require.Equal(t, "6af26041539f44835d695c6a1b3dd062", a.policyLine(context.Background(), "p", rule).ID)
+
+	_, err := NewAdapterByPgxPool(nil, WithIDScheme(IDScheme(7)))
+	require.ErrorContains(t, err, "unknown id scheme IDScheme(7)")
+}
+
+func TestMockInstallIDTrigger(t *testing.T) {
+	a, mock := newMockAdapter(t, WithIDScheme(IDSchemeMD5), WithNamespace("tenant1"))
+	ctx := context.Background()
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE OR REPLACE FUNCTION "casbin_rules_fill_id"()`) + `.*` +
+		regexp.QuoteMeta(`vals text[] := ARRAY[coalesce(NEW.v0, ''), coalesce(NEW.v1, ''), coalesce(NEW.v2, ''), coalesce(NEW.v3, ''), coalesce(NEW.v4, ''), coalesce(NEW.v5, '')];`) + `.*` +
+		regexp.QuoteMeta(`data := convert_to(NEW.namespace, 'UTF8') || '\x00'::bytea || data;`)).
+		WillReturnResult(pgxmock.NewResult("CREATE FUNCTION", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TRIGGER fill_id BEFORE INSERT ON "casbin_rules" FOR EACH ROW EXECUTE FUNCTION "casbin_rules_fill_id"()`)).
+		WillReturnResult(pgxmock.NewResult("DO", 0))
+	require.NoError(t, a.InstallIDTrigger(ctx))
+
+	b, _ := newMockAdapter(t)
+	require.ErrorContains(t, b.InstallIDTrigger(ctx), "the xxh3 ids can't be computed by postgres")
+}
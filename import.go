@@ -0,0 +1,142 @@
+package pgxadapter
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// RejectedLine is a line of a policy file not imported by ImportFromFileAdapter
+type RejectedLine struct {
+	// Line is the line number, starting at 1
+	Line int
+	Text string
+	Err  error
+}
+
+// ImportReport describes the rules imported by ImportFromFileAdapter
+type ImportReport struct {
+	// Rules is the number of valid rules read from the file
+	Rules int
+	// Imported is the number of rules stored, rules already stored and duplicated lines are not counted
+	Imported int64
+	// Rejected lists the lines which are not valid rules
+	Rejected []RejectedLine
+}
+
+// ImportFromFileAdapter stores the rules of a policy file in the format of casbin's file adapter, e.g. a policy.csv,
+// in a single transaction with the multi-row inserts of AddPolicies.
+// Every rule is validated against the model of modelPath and with the validator of the adapter,
+// the lines which are not valid rules are reported with their number and skipped.
+// With replace, the stored rules are deleted first like SavePolicy does,
+// otherwise the rules are merged with the stored ones, ignoring the rules already stored.
+func (a *Adapter) ImportFromFileAdapter(ctx context.Context, modelPath, policyPath string, replace bool) (_ ImportReport, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "ImportFromFileAdapter"})
+	if err != nil {
+		return ImportReport{}, err
+	}
+	defer finish(&err)
+
+	m, err := model.NewModelFromFile(modelPath)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	lines, report, err := a.readPolicyFile(ctx, policyPath, ModelValidator(m))
+	if err != nil {
+		return ImportReport{}, err
+	}
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if replace {
+		sql, args := a.inNamespace(ctx, fmt.Sprintf(`DELETE FROM "%v"`, a.table(ctx)), nil, true)
+		if _, err := tx.Exec(ctx, sql, args...); err != nil {
+			return ImportReport{}, err
+		}
+	}
+	p := a.newProgress("ImportFromFileAdapter", int64(len(lines)))
+	size := a.batchSize()
+	for start := 0; start < len(lines); start += size {
+		chunk := lines[start:min(start+size, len(lines))]
+		n, err := a.insertChunk(ctx, tx, chunk)
+		if err != nil {
+			return ImportReport{}, chunkError(chunk, err)
+		}
+		report.Imported += n
+		p.add(int64(len(chunk)))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return ImportReport{}, err
+	}
+	p.end()
+	return report, nil
+}
+
+// readPolicyFile returns the rules of the policy file at path, parsed like casbin's file adapter does.
+// A rule is stored once when several lines hold it, a single statement can't insert the same id twice.
+func (a *Adapter) readPolicyFile(ctx context.Context, path string, validate RuleValidator) ([]*CasbinRule, ImportReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, ImportReport{}, err
+	}
+	defer f.Close()
+
+	var lines []*CasbinRule
+	var report ImportReport
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		line, err := a.parsePolicyLine(ctx, text, validate)
+		if err != nil {
+			report.Rejected = append(report.Rejected, RejectedLine{Line: n, Text: text, Err: err})
+			continue
+		}
+		report.Rules++
+		if !seen[line.ID] {
+			seen[line.ID] = true
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, ImportReport{}, err
+	}
+	return lines, report, nil
+}
+
+// parsePolicyLine parses a line of a policy file and validates its rule
+func (a *Adapter) parsePolicyLine(ctx context.Context, text string, validate RuleValidator) (*CasbinRule, error) {
+	r := csv.NewReader(strings.NewReader(text))
+	r.TrimLeadingSpace = true
+	tokens, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	ptype, rule := tokens[0], tokens[1:]
+	if ptype == "" || len(rule) == 0 {
+		return nil, errors.New("line has no ptype or no values")
+	}
+	rule = a.normalization.normalize(0, rule)
+	sec := ptype[:1]
+	if err := validate(sec, ptype, rule); err != nil {
+		return nil, err
+	}
+	if err := a.validateRule(sec, ptype, rule); err != nil {
+		return nil, err
+	}
+	return a.policyLine(ctx, ptype, rule), nil
+}
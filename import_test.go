@@ -0,0 +1,71 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestImportFromFileAdapter() {
+	ctx := context.Background()
+	s.Require().NoError(s.a.AddPolicy("p", "p", []string{"carol", "data3", "read"}))
+
+	report, err := s.a.ImportFromFileAdapter(ctx, "examples/rbac_model.conf", "examples/rbac_policy.csv", true)
+	s.Require().NoError(err)
+	s.Require().Equal(ImportReport{Rules: 5, Imported: 5}, report)
+
+	fileEnforcer, err := casbin.NewEnforcer("examples/rbac_model.conf", "examples/rbac_policy.csv")
+	s.Require().NoError(err)
+	s.e, err = casbin.NewEnforcer("examples/rbac_model.conf", s.a)
+	s.Require().NoError(err)
+	s.assertPolicy(fileEnforcer.GetPolicy(), s.e.GetPolicy())
+	s.assertPolicy(fileEnforcer.GetGroupingPolicy(), s.e.GetGroupingPolicy())
+
+	// merging stores nothing new
+	report, err = s.a.ImportFromFileAdapter(ctx, "examples/rbac_model.conf", "examples/import_policy.csv", false)
+	s.Require().NoError(err)
+	s.Require().Zero(report.Imported)
+	s.Require().Len(report.Rejected, 3)
+}
+
+func TestMockImportFromFileAdapter(t *testing.T) {
+	a, mock := newMockAdapter(t, WithMaxBatchSize(3))
+
+	var args []any
+	for _, rule := range [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}} {
+		args = append(args, insertArgsFor("p", rule)...)
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(args...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 3))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(append(insertArgsFor("p", []string{"data2_admin", "data2", "write"}), insertArgsFor("g", []string{"alice", "data2_admin"})...)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	report, err := a.ImportFromFileAdapter(context.Background(), "examples/rbac_model.conf", "examples/import_policy.csv", true)
+	require.NoError(t, err)
+	require.Equal(t, 6, report.Rules)
+	require.EqualValues(t, 4, report.Imported)
+	require.Len(t, report.Rejected, 3)
+
+	require.Equal(t, 6, report.Rejected[0].Line)
+	require.Equal(t, "p, data2_admin, data2", report.Rejected[0].Text)
+	require.EqualError(t, report.Rejected[0].Err, "invalid rule data2_admin, data2 for p: expected 3 values, got 2")
+	require.Equal(t, 8, report.Rejected[1].Line)
+	require.EqualError(t, report.Rejected[1].Err, "invalid rule carol, data3, read for p3: ptype is not defined in the model")
+	require.Equal(t, 11, report.Rejected[2].Line)
+	require.ErrorContains(t, report.Rejected[2].Err, "extraneous or missing \" in quoted-field")
+}
+
+func TestMockImportFromFileAdapterMissingFile(t *testing.T) {
+	a, _ := newMockAdapter(t)
+
+	_, err := a.ImportFromFileAdapter(context.Background(), "examples/rbac_model.conf", "examples/missing.csv", false)
+	require.ErrorContains(t, err, "examples/missing.csv")
+}
@@ -0,0 +1,140 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithMaxLoadRules makes LoadPolicy and LoadFilteredPolicy count the rules they would load first,
+// and fail with a *TooManyRulesError instead of loading them when there are more than n,
+// so a table grown by mistake can't exhaust the memory of every process loading it.
+// The rules counted are the rules of the namespace of the adapter, and for LoadFilteredPolicy the rules matching
+// the filter. 0, the default, disables the check.
+func WithMaxLoadRules(n int64) Option {
+	return func(a *Adapter) {
+		a.maxLoadRules = n
+	}
+}
+
+// TooManyRulesError is returned by the loads of the adapters created with WithMaxLoadRules
+// when there are more rules to load than the limit. It matches ErrTooManyRules with errors.Is.
+type TooManyRulesError struct {
+	// Count is the number of rules the load would have loaded
+	Count int64
+	// Limit is the value of WithMaxLoadRules
+	Limit int64
+}
+
+func (e *TooManyRulesError) Error() string {
+	return fmt.Sprintf("%d rules to load, more than the limit of %d", e.Count, e.Limit)
+}
+
+func (e *TooManyRulesError) Is(target error) bool {
+	return target == ErrTooManyRules
+}
+
+// checkLoadLimit returns a *TooManyRulesError when count is more than the limit of WithMaxLoadRules
+func (a *Adapter) checkLoadLimit(count int64) error {
+	if a.maxLoadRules > 0 && count > a.maxLoadRules {
+		return &TooManyRulesError{Count: count, Limit: a.maxLoadRules}
+	}
+	return nil
+}
+
+// countQueries returns the number of rows of all the queries
+func (a *Adapter) countQueries(ctx context.Context, queries []string, args [][]any) (int64, error) {
+	var total int64
+	for i, sql := range queries {
+		n, err := a.countRows(ctx, sql, args[i])
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// countRows returns the number of rows of the query
+func (a *Adapter) countRows(ctx context.Context, sql string, args []any) (int64, error) {
+	var n int64
+	err := a.queryRow(ctx, fmt.Sprintf(`SELECT count(*) FROM (%v) rules`, sql), args, &n)
+	return n, err
+}
+
+// FilterThreshold tells when a filter of LoadFilteredPolicy is too broad to be worth loading filtered,
+// see WithFilterThreshold. A filter is broad when it matches more than Fraction of the rules or more than Rows rules,
+// a zero field is not checked.
+type FilterThreshold struct {
+	// Fraction is the fraction of the rules above which the filter is broad, between 0 and 1
+	Fraction float64
+	// Rows is the number of rules above which the filter is broad
+	Rows int64
+	// Fail makes LoadFilteredPolicy fail with a *BroadFilterError instead of loading every rule
+	Fail bool
+}
+
+// WithFilterThreshold makes LoadFilteredPolicy count the rules matching its filter first, and load every rule with
+// LoadPolicy instead when the filter is broader than threshold, since a full load is faster than a filtered load
+// returning most of the table. The adapter is then not filtered. With threshold.Fail, LoadFilteredPolicy
+// fails with a *BroadFilterError instead.
+// Without this option, or WithMaxLoadRules, LoadFilteredPolicy runs no count.
+func WithFilterThreshold(threshold FilterThreshold) Option {
+	return func(a *Adapter) {
+		a.filterThreshold = threshold
+	}
+}
+
+// BroadFilterError is returned by LoadFilteredPolicy when its filter is broader than the threshold
+// of WithFilterThreshold and the threshold fails. It matches ErrBroadFilter with errors.Is.
+type BroadFilterError struct {
+	// Matched is the number of rules matching the filter
+	Matched int64
+	// Total is the number of rules, 0 unless the threshold has a Fraction
+	Total int64
+}
+
+func (e *BroadFilterError) Error() string {
+	if e.Total == 0 {
+		return fmt.Sprintf("filter matches %d rules", e.Matched)
+	}
+	return fmt.Sprintf("filter matches %d of the %d rules", e.Matched, e.Total)
+}
+
+func (e *BroadFilterError) Is(target error) bool {
+	return target == ErrBroadFilter
+}
+
+func (t FilterThreshold) enabled() bool {
+	return t.Fraction > 0 || t.Rows > 0
+}
+
+func (t FilterThreshold) validate() error {
+	if t.Fraction < 0 || t.Fraction > 1 {
+		return fmt.Errorf("filter threshold fraction must be between 0 and 1, got %v", t.Fraction)
+	}
+	if t.Rows < 0 {
+		return fmt.Errorf("filter threshold rows can't be negative, got %d", t.Rows)
+	}
+	return nil
+}
+
+// checkFilterThreshold returns a *BroadFilterError when matched rules are more than the threshold of WithFilterThreshold,
+// the rules are counted in the namespace of the adapter
+func (a *Adapter) checkFilterThreshold(ctx context.Context, matched int64) error {
+	t := a.filterThreshold
+	if t.Rows > 0 && matched > t.Rows {
+		return &BroadFilterError{Matched: matched}
+	}
+	if t.Fraction == 0 {
+		return nil
+	}
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT count(*) FROM "%v"`, a.readTable(ctx)), nil, true)
+	var total int64
+	if err := a.queryRow(ctx, sql, args, &total); err != nil {
+		return err
+	}
+	if float64(matched) > t.Fraction*float64(total) {
+		return &BroadFilterError{Matched: matched, Total: total}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestMaxLoadRules() {
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithMaxLoadRules(5))
+	s.Require().NoError(err)
+	defer a.Close()
+	s.Require().NoError(a.LoadPolicy(m))
+	s.Require().NoError(a.LoadFilteredPolicy(m, &Filter{P: []string{}}))
+
+	b, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithMaxLoadRules(4))
+	s.Require().NoError(err)
+	defer b.Close()
+	var tooMany *TooManyRulesError
+	s.Require().ErrorAs(b.LoadPolicy(m), &tooMany)
+	s.Require().EqualValues(5, tooMany.Count)
+	s.Require().NoError(b.LoadFilteredPolicy(m, &Filter{P: []string{}}))
+}
+
+func TestMockMaxLoadRules(t *testing.T) {
+	a, mock := newMockAdapter(t, WithMaxLoadRules(2))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	// just under the limit
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM (SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules") rules`)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(2)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("1", "p", "alice", "data1", "read", "", "", "").
+			AddRow("2", "g", "alice", "admin", "", "", "", ""))
+	require.NoError(t, a.LoadPolicy(m))
+
+	// just over the limit, nothing is loaded
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM (SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules") rules`)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(3)))
+	err = a.LoadPolicy(m)
+	require.ErrorIs(t, err, ErrTooManyRules)
+	require.EqualError(t, err, `pgadapter.LoadPolicy: table "casbin_rules": 3 rules to load, more than the limit of 2`)
+
+	// the rules of every filter are counted
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM (SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v0 = $2) rules`)).
+		WithArgs("p", "alice").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM (SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v0 = $2) rules`)).
+		WithArgs("g", "alice").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(2)))
+	var tooMany *TooManyRulesError
+	require.ErrorAs(t, a.LoadFilteredPolicy(m, []string{"p, alice", "g, alice"}), &tooMany)
+	require.Equal(t, TooManyRulesError{Count: 3, Limit: 2}, *tooMany)
+
+	_, err = newAdapter([]Option{WithMaxLoadRules(-1)})
+	require.EqualError(t, err, "pgadapter.NewAdapter: max load rules can't be negative, got -1")
+}
+
+func (s *AdapterTestSuite) TestFilterThreshold() {
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithFilterThreshold(FilterThreshold{Fraction: 0.5}))
+	s.Require().NoError(err)
+	defer a.Close()
+	s.Require().NoError(a.LoadFilteredPolicy(m, &Filter{P: []string{}}))
+	s.Require().False(a.IsFiltered())
+	s.Require().Len(m.GetPolicy("g", "g"), 1)
+
+	m.ClearPolicy()
+	s.Require().NoError(a.LoadFilteredPolicy(m, &Filter{P: []string{"alice"}}))
+	s.Require().True(a.IsFiltered())
+	s.Require().Empty(m.GetPolicy("g", "g"))
+}
+
+func TestMockFilterThreshold(t *testing.T) {
+	var ops []string
+	a, mock := newMockAdapter(t, WithFilterThreshold(FilterThreshold{Fraction: 0.5}), WithHooks(Hooks{
+		After: func(ctx context.Context, op OpInfo, err error) {
+			ops = append(ops, op.Op)
+		},
+	}))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+	filtered := regexp.QuoteMeta(`SELECT count(*) FROM (SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1) rules`)
+	total := regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`) + "$"
+
+	// under the threshold the filter is used
+	mock.ExpectQuery(filtered).WithArgs("g").WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	mock.ExpectQuery(total).WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(4)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1`)).WithArgs("g").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "g", "alice", "admin", "", "", "", ""))
+	require.NoError(t, a.LoadFilteredPolicy(m, &Filter{G: []string{}}))
+	require.True(t, a.IsFiltered())
+
+	// over the threshold every rule is loaded
+	mock.ExpectQuery(filtered).WithArgs("p").WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(3)))
+	mock.ExpectQuery(total).WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(4)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`) + "$").
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("1", "g", "alice", "admin", "", "", "", "").
+			AddRow("2", "p", "admin", "data1", "read", "", "", ""))
+	m.ClearPolicy()
+	require.NoError(t, a.LoadFilteredPolicy(m, &Filter{P: []string{}}))
+	require.False(t, a.IsFiltered())
+	require.Equal(t, [][]string{{"alice", "admin"}}, m.GetPolicy("g", "g"))
+	// the full load is part of the LoadFilteredPolicy operation
+	require.Equal(t, []string{"LoadFilteredPolicy", "LoadFilteredPolicy"}, ops)
+
+	// or the load fails
+	b, mock := newMockAdapter(t, WithFilterThreshold(FilterThreshold{Rows: 2, Fail: true}))
+	mock.ExpectQuery(filtered).WithArgs("p").WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(3)))
+	err = b.LoadFilteredPolicy(m, &Filter{P: []string{}})
+	require.ErrorIs(t, err, ErrBroadFilter)
+	require.EqualError(t, err, `pgadapter.LoadFilteredPolicy: table "casbin_rules": filter matches 3 rules`)
+
+	_, err = newAdapter([]Option{WithFilterThreshold(FilterThreshold{Fraction: 1.5})})
+	require.EqualError(t, err, "pgadapter.NewAdapter: filter threshold fraction must be between 0 and 1, got 1.5")
+}
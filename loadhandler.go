@@ -0,0 +1,57 @@
+package pgxadapter
+
+import (
+	"errors"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+)
+
+// SkipRule is returned by a LoadHandler to leave a rule out of the model, the load goes on with the next rule
+var SkipRule = errors.New("skip rule")
+
+// LoadHandler adds a rule loaded by the adapter to m, see WithLoadHandler.
+// rule is a copy the handler may modify and keep.
+type LoadHandler func(rule CasbinRule, m model.Model) error
+
+// WithLoadHandler sets the function adding the loaded rules to the model, e.g. to rename legacy roles or to leave out
+// some rules, in place of DefaultLoadHandler. A handler returning SkipRule leaves the rule out, any other error
+// aborts the load and is returned with the rule. A handler transforming the rules usually ends with DefaultLoadHandler:
+//
+//	pgxadapter.WithLoadHandler(func(rule pgxadapter.CasbinRule, m model.Model) error {
+//		if rule.Ptype == "g" && rule.V1 == "admins" {
+//			rule.V1 = "admin"
+//		}
+//		return pgxadapter.DefaultLoadHandler(rule, m)
+//	})
+//
+// The handler is used by LoadPolicy and the methods loading filtered rules, e.g. LoadFilteredPolicy, but not by
+// LoadPolicyAt and LoadPolicyDelta, which load the rules of the history as they were stored.
+// The writes aren't transformed, a rule renamed by the handler is removed from the table with its stored values.
+func WithLoadHandler(h LoadHandler) Option {
+	return func(a *Adapter) {
+		a.loadHandler = h
+	}
+}
+
+// DefaultLoadHandler adds rule to m, unless m already has it. The empty values of rule are left out.
+func DefaultLoadHandler(rule CasbinRule, m model.Model) error {
+	return persist.LoadPolicyArray(rule.tokens(), m)
+}
+
+// loadRule adds line to m with the handler of WithLoadHandler
+func (a *Adapter) loadRule(line *CasbinRule, m model.Model) error {
+	handler := a.loadHandler
+	if handler == nil {
+		handler = DefaultLoadHandler
+	}
+	rule := *line
+	rule.Extra = append([]string(nil), line.Extra...)
+	if err := handler(rule, m); err != nil {
+		if errors.Is(err, SkipRule) {
+			return nil
+		}
+		return ruleError(line, err)
+	}
+	return nil
+}
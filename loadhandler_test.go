@@ -0,0 +1,103 @@
+package pgxadapter
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestLoadHandler() {
+	a, err := NewAdapterByDB(s.a.db.(*pgxpool.Pool), SkipTableCreate(), WithLoadHandler(func(rule CasbinRule, m model.Model) error {
+		if rule.V0 == "bob" {
+			return SkipRule
+		}
+		if rule.Ptype == "g" && rule.V1 == "data2_admin" {
+			rule.V1 = "admin"
+		}
+		return DefaultLoadHandler(rule, m)
+	}))
+	s.Require().NoError(err)
+
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", a)
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}}, e.GetPolicy())
+	s.Assert().Equal([][]string{{"alice", "admin"}}, e.GetGroupingPolicy())
+
+	s.Require().NoError(e.LoadFilteredPolicy(&Filter{P: []string{"bob"}, G: []string{"alice"}}))
+	s.Assert().Empty(e.GetPolicy())
+	s.Assert().Equal([][]string{{"alice", "admin"}}, e.GetGroupingPolicy())
+}
+
+// policyRows returns the rows of the query of LoadPolicy
+func policyRows(rules ...[]string) *pgxmock.Rows {
+	rows := pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"})
+	for i, rule := range rules {
+		values := []any{string(rune('1' + i))}
+		for _, v := range rule {
+			values = append(values, v)
+		}
+		for len(values) < 8 {
+			values = append(values, "")
+		}
+		rows.AddRow(values...)
+	}
+	return rows
+}
+
+func TestMockLoadHandler(t *testing.T) {
+	loadSQL := regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)
+	stored := [][]string{{"p", "alice", "data1", "read"}, {"p", "bob", "data2", "write"}, {"g", "alice", "admins"}}
+
+	// the default handler loads the values containing commas as they are
+	a, mock := newMockAdapter(t)
+	mock.ExpectQuery(loadSQL).WillReturnRows(policyRows([]string{"p", "alice", "data1,data2", "read"}))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicy(m))
+	require.Equal(t, [][]string{{"alice", "data1,data2", "read"}}, m.GetPolicy("p", "p"))
+
+	a, mock = newMockAdapter(t, WithLoadHandler(func(rule CasbinRule, m model.Model) error {
+		if rule.V0 == "bob" {
+			return SkipRule
+		}
+		if rule.Ptype == "g" && rule.V1 == "admins" {
+			rule.V1 = "admin"
+		}
+		return DefaultLoadHandler(rule, m)
+	}))
+	mock.ExpectQuery(loadSQL).WillReturnRows(policyRows(stored...))
+	m, err = model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicy(m))
+	require.Equal(t, [][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "admin"}}, m.GetPolicy("g", "g"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype=$1 AND v0 = $2`)).
+		WithArgs("p", "bob").
+		WillReturnRows(policyRows(stored[1]))
+	m, err = model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadFilteredPolicy(m, &Filter{P: []string{"bob"}}))
+	require.Empty(t, m.GetPolicy("p", "p"))
+
+	// any other error aborts the load
+	failure := errors.New("unknown role")
+	a, mock = newMockAdapter(t, WithLoadHandler(func(rule CasbinRule, m model.Model) error {
+		if rule.Ptype == "g" {
+			return failure
+		}
+		return DefaultLoadHandler(rule, m)
+	}))
+	mock.ExpectQuery(loadSQL).WillReturnRows(policyRows(stored...))
+	m, err = model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	err = a.LoadPolicy(m)
+	require.ErrorIs(t, err, failure)
+	require.ErrorContains(t, err, `rule "g, alice, admins"`)
+}
@@ -0,0 +1,144 @@
+package pgxadapter
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// meterName is the name of the meter recording the adapter metrics
+const meterName = "github.com/thnt/casbin-pgx-adapter"
+
+// WithMeterProvider records OpenTelemetry metrics of the adapter with a meter of provider:
+//
+//   - pgxadapter.operation.duration, a histogram of the duration of the operations in seconds by method and result
+//   - pgxadapter.rules.added and pgxadapter.rules.removed, counting the rules given to the successful
+//     AddPolicy, AddPolicies and SavePolicy, and RemovePolicy and RemovePolicies operations
+//   - pgxadapter.rules.loaded, counting the rules read by LoadPolicy and LoadFilteredPolicy
+//   - pgxadapter.pool.connections, a gauge of the connections of the pool by state, when the adapter uses a *pgxpool.Pool
+//   - pgxadapter.failover.switches, counting the switches between the targets of WithFallbackTargets by from, to and reason
+//
+// The metrics of the operations have the method and table attributes, which are the Op and Table of the OpInfo given to the hooks.
+// Nothing is recorded without this option.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(a *Adapter) {
+		a.meterProvider = provider
+	}
+}
+
+// adapterMetrics are the instruments of WithMeterProvider
+type adapterMetrics struct {
+	meter    metric.Meter
+	duration metric.Float64Histogram
+	added    metric.Int64Counter
+	removed  metric.Int64Counter
+	loaded   metric.Int64Counter
+	failover metric.Int64Counter
+	// pool is the registration of the pool gauge callback, nil until the adapter is set up
+	pool metric.Registration
+}
+
+// newMetrics creates the instruments of WithMeterProvider
+func newMetrics(provider metric.MeterProvider) (*adapterMetrics, error) {
+	m := &adapterMetrics{meter: provider.Meter(meterName)}
+	var err error
+	m.duration, err = m.meter.Float64Histogram("pgxadapter.operation.duration",
+		metric.WithDescription("Duration of the adapter operations"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	m.added, err = m.meter.Int64Counter("pgxadapter.rules.added",
+		metric.WithDescription("Rules added to the rules table"), metric.WithUnit("{rule}"))
+	if err != nil {
+		return nil, err
+	}
+	m.removed, err = m.meter.Int64Counter("pgxadapter.rules.removed",
+		metric.WithDescription("Rules removed from the rules table"), metric.WithUnit("{rule}"))
+	if err != nil {
+		return nil, err
+	}
+	m.loaded, err = m.meter.Int64Counter("pgxadapter.rules.loaded",
+		metric.WithDescription("Rules loaded from the rules table"), metric.WithUnit("{rule}"))
+	if err != nil {
+		return nil, err
+	}
+	m.failover, err = m.meter.Int64Counter("pgxadapter.failover.switches",
+		metric.WithDescription("Switches between the database targets"), metric.WithUnit("{switch}"))
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// registerPoolMetrics registers the gauge of the pool connections if the adapter uses a *pgxpool.Pool
+func (a *Adapter) registerPoolMetrics() error {
+	if a.metrics == nil {
+		return nil
+	}
+	if _, ok := a.pool(); !ok {
+		return nil
+	}
+	conns, err := a.metrics.meter.Int64ObservableGauge("pgxadapter.pool.connections",
+		metric.WithDescription("Connections of the adapter pool by state"), metric.WithUnit("{connection}"))
+	if err != nil {
+		return err
+	}
+	a.metrics.pool, err = a.metrics.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		// the pool is looked up on every collection, it changes when the adapter switches targets
+		pool, ok := a.pool()
+		if !ok {
+			return nil
+		}
+		stat := pool.Stat()
+		o.ObserveInt64(conns, int64(stat.AcquiredConns()), metric.WithAttributes(attribute.String("state", "acquired")))
+		o.ObserveInt64(conns, int64(stat.IdleConns()), metric.WithAttributes(attribute.String("state", "idle")))
+		o.ObserveInt64(conns, int64(stat.ConstructingConns()), metric.WithAttributes(attribute.String("state", "constructing")))
+		o.ObserveInt64(conns, int64(stat.MaxConns()), metric.WithAttributes(attribute.String("state", "max")))
+		return nil
+	}, conns)
+	return err
+}
+
+// opAttributes returns the attributes of the metrics of op
+func opAttributes(op OpInfo) []attribute.KeyValue {
+	return []attribute.KeyValue{attribute.String("method", op.Op), attribute.String("table", op.Table)}
+}
+
+// record records the duration of op and the rules it added or removed
+func (m *adapterMetrics) record(ctx context.Context, op OpInfo, elapsed time.Duration, err error) {
+	attrs := opAttributes(op)
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(append(attrs, attribute.String("result", result))...))
+	if err != nil || op.Rules == 0 {
+		return
+	}
+	switch op.Op {
+	case "AddPolicy", "AddPolicies", "SavePolicy":
+		m.added.Add(ctx, int64(op.Rules), metric.WithAttributes(attrs...))
+	case "RemovePolicy", "RemovePolicies":
+		m.removed.Add(ctx, int64(op.Rules), metric.WithAttributes(attrs...))
+	}
+}
+
+// addLoaded counts n rules loaded by the operation of ctx
+func (a *Adapter) addLoaded(ctx context.Context, n int64) {
+	if a.metrics == nil || n == 0 {
+		return
+	}
+	op := OpInfo{Op: opFrom(ctx), Table: a.table(ctx)}
+	a.metrics.loaded.Add(ctx, n, metric.WithAttributes(opAttributes(op)...))
+}
+
+// addFailover counts a switch from the target from to the target to
+func (a *Adapter) addFailover(ctx context.Context, from, to, reason string) {
+	if a.metrics == nil {
+		return
+	}
+	a.metrics.failover.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("from", from), attribute.String("to", to), attribute.String("reason", reason)))
+}
@@ -0,0 +1,117 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collectMetrics returns the metrics recorded by the reader by name
+func collectMetrics(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Metrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := map[string]metricdata.Metrics{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+// sumValue returns the value of the data point of the sum having the attributes
+func sumValue(t *testing.T, m metricdata.Metrics, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+	set := attribute.NewSet(attrs...)
+	for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+		if dp.Attributes.Equals(&set) {
+			return dp.Value
+		}
+	}
+	return 0
+}
+
+func (s *AdapterTestSuite) TestMeterProvider() {
+	reader := sdkmetric.NewManualReader()
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	s.Require().NoError(err)
+	defer a.Close()
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a.LoadPolicy(m))
+
+	metrics := collectMetrics(s.T(), reader)
+	s.Require().EqualValues(5, sumValue(s.T(), metrics["pgxadapter.rules.loaded"],
+		attribute.String("method", "LoadPolicy"), attribute.String("table", DefaultTableName)))
+
+	var states []string
+	for _, dp := range metrics["pgxadapter.pool.connections"].Data.(metricdata.Gauge[int64]).DataPoints {
+		state, _ := dp.Attributes.Value("state")
+		states = append(states, state.AsString())
+	}
+	s.Require().ElementsMatch([]string{"acquired", "idle", "constructing", "max"}, states)
+}
+
+func TestMockMeterProvider(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	a, mock := newMockAdapter(t, WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	table := attribute.String("table", DefaultTableName)
+
+	rules := testRules(2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(insertArgs(rules...)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicies("p", "p", rules))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id=$1`)).WillReturnError(errors.New("connection lost"))
+	mock.ExpectRollback()
+	require.Error(t, a.RemovePolicy("p", "p", rules[0]))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("a", "p", "alice", "data1", "read", "", "", "").
+			AddRow("b", "p", "bob", "data2", "write", "", "", "").
+			AddRow("c", "g", "alice", "admin", "", "", "", ""))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicy(m))
+
+	metrics := collectMetrics(t, reader)
+	require.EqualValues(t, 2, sumValue(t, metrics["pgxadapter.rules.added"], attribute.String("method", "AddPolicies"), table))
+	// the failed removal is not counted
+	require.Empty(t, metrics["pgxadapter.rules.removed"].Data)
+	require.EqualValues(t, 3, sumValue(t, metrics["pgxadapter.rules.loaded"], attribute.String("method", "LoadPolicy"), table))
+
+	counts := map[string]uint64{}
+	for _, dp := range metrics["pgxadapter.operation.duration"].Data.(metricdata.Histogram[float64]).DataPoints {
+		method, _ := dp.Attributes.Value("method")
+		result, _ := dp.Attributes.Value("result")
+		counts[method.AsString()+" "+result.AsString()] = dp.Count
+	}
+	require.Equal(t, map[string]uint64{"AddPolicies ok": 1, "RemovePolicy error": 1, "LoadPolicy ok": 1}, counts)
+	// the mock pool is not a *pgxpool.Pool
+	require.NotContains(t, metrics, "pgxadapter.pool.connections")
+}
+
+func TestMockWithoutMeterProvider(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	require.Nil(t, a.metrics)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	_, err := a.CountRules(context.Background(), "")
+	require.NoError(t, err)
+}
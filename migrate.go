@@ -0,0 +1,140 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/zeebo/xxh3"
+)
+
+// SchemaVersionTable is the table recording the migrations applied to each rules table
+const SchemaVersionTable = "casbin_schema_version"
+
+// MigrationStep describes a schema migration step
+type MigrationStep struct {
+	Version     int
+	Description string
+}
+
+type migration struct {
+	MigrationStep
+	// up must be idempotent, the step may run against a table already having the change
+	up func(ctx context.Context, tx pgx.Tx, a *Adapter) error
+	// when, if not nil, tells if the step applies to the adapter, a step not applying is not recorded
+	// so it runs once the adapter enables the option it depends on
+	when func(a *Adapter) bool
+}
+
+// migrations are applied in order, new steps must be appended with the next version
+var migrations = []migration{
+	{
+		MigrationStep{1, "create rules table"},
+		func(ctx context.Context, tx pgx.Tx, a *Adapter) error {
+			return a.createTable(ctx, tx)
+		},
+		nil,
+	},
+	{
+		MigrationStep{2, "create ptype index"},
+		func(ctx context.Context, tx pgx.Tx, a *Adapter) error {
+			_, err := tx.Exec(ctx, fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%v_ptype_idx" ON "%v" (ptype)`, a.table(ctx), a.table(ctx)))
+			return err
+		},
+		nil,
+	},
+	{
+		MigrationStep{3, "use the C collation for the id and value columns"},
+		func(ctx context.Context, tx pgx.Tx, a *Adapter) error {
+			return a.collateColumns(ctx, tx)
+		},
+		func(a *Adapter) bool { return a.collateC },
+	},
+}
+
+// advisoryLockKey returns the key of the advisory lock used to serialize schema changes of a table
+func advisoryLockKey(name string) int64 {
+	return int64(xxh3.HashString("casbin-pgx-adapter:" + name))
+}
+
+// Migrate brings the rules table to the current layout by running the migration steps
+// not yet recorded in the casbin_schema_version table, and returns the steps that ran.
+// Migrate is safe to call concurrently from multiple processes, the steps run in a single transaction
+// holding an advisory lock shared by all migrations of the database.
+func (a *Adapter) Migrate(ctx context.Context) (_ []MigrationStep, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "Migrate"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, advisoryLockKey(SchemaVersionTable)); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS "%v" (
+			table_name TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			description TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+			PRIMARY KEY (table_name, version)
+		)
+	`, SchemaVersionTable))
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[int]bool{}
+	rows, err := tx.Query(ctx, fmt.Sprintf(`SELECT version FROM "%v" WHERE table_name = $1`, SchemaVersionTable), a.table(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		applied[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var steps []migration
+	for _, m := range migrations {
+		if !applied[m.Version] && (m.when == nil || m.when(a)) {
+			steps = append(steps, m)
+		}
+	}
+	p := a.newProgress("Migrate", int64(len(steps)))
+	var ran []MigrationStep
+	for _, m := range steps {
+		if err := m.up(ctx, tx, a); err != nil {
+			return nil, fmt.Errorf("migration %d (%v): %w", m.Version, m.Description, err)
+		}
+		_, err = tx.Exec(ctx,
+			fmt.Sprintf(`INSERT INTO "%v" (table_name, version, description) VALUES ($1, $2, $3)`, SchemaVersionTable),
+			a.table(ctx), m.Version, m.Description,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ran = append(ran, m.MigrationStep)
+		p.add(1)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	p.end()
+	return ran, nil
+}
@@ -0,0 +1,78 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockMigrate(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).
+		WithArgs(advisoryLockKey(SchemaVersionTable)).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_schema_version"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT version FROM "casbin_schema_version" WHERE table_name = $1`)).
+		WithArgs("casbin_rules").
+		WillReturnRows(pgxmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE INDEX IF NOT EXISTS "casbin_rules_ptype_idx"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE INDEX", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_schema_version"`)).
+		WithArgs("casbin_rules", 2, "create ptype index").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	ran, err := a.Migrate(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []MigrationStep{{2, "create ptype index"}}, ran)
+}
+
+func (s *AdapterTestSuite) TestMigrate() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+	pool.Exec(ctx, `DROP TABLE IF EXISTS "rules_migrate"`)
+	pool.Exec(ctx, `DELETE FROM "casbin_schema_version" WHERE table_name = 'rules_migrate'`)
+	defer pool.Exec(ctx, `DROP TABLE "rules_migrate"`)
+
+	// table created by the minimal DDL
+	a, err := NewAdapterByDB(pool, WithTableName("rules_migrate"))
+	s.Require().NoError(err)
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	ran, err := a.Migrate(ctx)
+	s.Require().NoError(err)
+	// the collation step only applies with WithCollationC
+	s.Assert().Len(ran, len(migrations)-1)
+
+	var idx int
+	err = pool.QueryRow(ctx, `SELECT count(*) FROM pg_indexes WHERE tablename = 'rules_migrate' AND indexname = 'rules_migrate_ptype_idx'`).Scan(&idx)
+	s.Require().NoError(err)
+	s.Assert().Equal(1, idx)
+
+	ran, err = a.Migrate(ctx)
+	s.Require().NoError(err)
+	s.Assert().Empty(ran)
+
+	// concurrent runs are serialized
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			_, err := a.Migrate(ctx)
+			errs <- err
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		s.Require().NoError(<-errs)
+	}
+}
@@ -0,0 +1,126 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// ModelTable is the table storing the model texts saved with SaveModelText
+const ModelTable = "casbin_models"
+
+// createModelTable creates the model table if it doesn't exist, a temporary one with WithTemporaryTable
+func (a *Adapter) createModelTable(ctx context.Context) error {
+	create := "CREATE TABLE"
+	if a.temporary {
+		create = "CREATE TEMP TABLE"
+	}
+	_, err := a.db.Exec(ctx, fmt.Sprintf(`
+		%v IF NOT EXISTS "%v" (
+			name TEXT PRIMARY KEY,
+			text TEXT NOT NULL,
+			revision BIGINT NOT NULL DEFAULT 1,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`, create, ModelTable))
+	if err != nil && !isPgError(err, codeDuplicateTable, codeUniqueViolation) {
+		return err
+	}
+	return nil
+}
+
+// SaveModelText stores the text of a casbin model under name, creating the casbin_models table if needed,
+// and returns the revision of the model, which starts at 1 and increases every time the text is saved.
+// Consumers can compare the revision with the one they built their model from to know when to rebuild.
+// The watchers are not notified by the adapter, the caller notifies them once the model is saved.
+// The text must be a valid model. With WithTemporaryTable the model table is a temporary table too.
+func (a *Adapter) SaveModelText(ctx context.Context, name, text string) (_ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "SaveModelText"})
+	if err != nil {
+		return 0, err
+	}
+	defer finish(&err)
+
+	if _, err := model.NewModelFromString(text); err != nil {
+		return 0, fmt.Errorf("model %q: %w", name, err)
+	}
+	if err := retryBootstrap(ctx, func() error { return a.createModelTable(ctx) }); err != nil {
+		return 0, err
+	}
+
+	var revision int64
+	err = a.queryRow(ctx, fmt.Sprintf(`
+		INSERT INTO "%[1]v" (name, text) VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET text = EXCLUDED.text, revision = "%[1]v".revision + 1, updated_at = now()
+		RETURNING revision
+	`, ModelTable), []any{name, text}, &revision)
+	if err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// LoadModelText returns the text of the model stored under name and its revision, see SaveModelText.
+// It returns ErrModelNotFound if no model was saved under name.
+func (a *Adapter) LoadModelText(ctx context.Context, name string) (_ string, _ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "LoadModelText"})
+	if err != nil {
+		return "", 0, err
+	}
+	defer finish(&err)
+
+	var text string
+	var revision int64
+	err = a.queryRow(ctx, fmt.Sprintf(`SELECT text, revision FROM "%v" WHERE name = $1`, ModelTable), []any{name}, &text, &revision)
+	if errors.Is(err, pgx.ErrNoRows) || isPgError(err, codeUndefinedTable) {
+		return "", 0, fmt.Errorf("%w: %q", ErrModelNotFound, name)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return text, revision, nil
+}
+
+// queryRow runs a query returning a single row and scans it into dest, it returns pgx.ErrNoRows if there is no row
+func (a *Adapter) queryRow(ctx context.Context, sql string, args []any, dest ...any) error {
+	return queryRowWith(ctx, a.db, sql, args, dest...)
+}
+
+// queryRowWith runs the query of queryRow with q
+func queryRowWith(ctx context.Context, q querier, sql string, args []any, dest ...any) error {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	rows.Close()
+	return rows.Err()
+}
+
+// NewEnforcerFromDB creates an enforcer using the model stored under modelName with SaveModelText,
+// and loads the policy with the adapter
+func NewEnforcerFromDB(ctx context.Context, a *Adapter, modelName string) (*casbin.Enforcer, error) {
+	text, _, err := a.LoadModelText(ctx, modelName)
+	if err != nil {
+		return nil, err
+	}
+	m, err := model.NewModelFromString(text)
+	if err != nil {
+		return nil, fmt.Errorf("pgadapter: model %q: %w", modelName, err)
+	}
+	return casbin.NewEnforcer(m, a)
+}
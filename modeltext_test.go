@@ -0,0 +1,115 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const rbacWithDomainsModel = `[request_definition]
+r = sub, dom, obj, act
+
+[policy_definition]
+p = sub, dom, obj, act
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && r.obj == p.obj && r.act == p.act
+`
+
+func (s *AdapterTestSuite) TestModelText() {
+	ctx := context.Background()
+	_, err := s.a.db.Exec(ctx, `DROP TABLE IF EXISTS casbin_models`)
+	s.Require().NoError(err)
+
+	_, _, err = s.a.LoadModelText(ctx, "rbac_with_domains")
+	s.Require().ErrorIs(err, ErrModelNotFound)
+
+	revision, err := s.a.SaveModelText(ctx, "rbac_with_domains", rbacWithDomainsModel)
+	s.Require().NoError(err)
+	s.Require().EqualValues(1, revision)
+	text, revision, err := s.a.LoadModelText(ctx, "rbac_with_domains")
+	s.Require().NoError(err)
+	s.Require().Equal(rbacWithDomainsModel, text)
+	s.Require().EqualValues(1, revision)
+
+	revision, err = s.a.SaveModelText(ctx, "rbac_with_domains", rbacWithDomainsModel)
+	s.Require().NoError(err)
+	s.Require().EqualValues(2, revision)
+
+	s.Require().NoError(s.a.SavePolicy(nil))
+	s.Require().NoError(s.a.AddPolicies("p", "p", [][]string{{"admin", "domain1", "data1", "read"}, {"admin", "domain2", "data2", "read"}}))
+	s.Require().NoError(s.a.AddPolicy("g", "g", []string{"alice", "admin", "domain1"}))
+
+	e, err := NewEnforcerFromDB(ctx, s.a, "rbac_with_domains")
+	s.Require().NoError(err)
+	ok, err := e.Enforce("alice", "domain1", "data1", "read")
+	s.Require().NoError(err)
+	s.Require().True(ok)
+	ok, err = e.Enforce("alice", "domain2", "data2", "read")
+	s.Require().NoError(err)
+	s.Require().False(ok)
+}
+
+func TestMockSaveModelText(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_models"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`ON CONFLICT (name) DO UPDATE SET text = EXCLUDED.text, revision = "casbin_models".revision + 1`)).
+		WithArgs("rbac", rbacWithDomainsModel).
+		WillReturnRows(pgxmock.NewRows([]string{"revision"}).AddRow(int64(3)))
+	revision, err := a.SaveModelText(ctx, "rbac", rbacWithDomainsModel)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, revision)
+
+	_, err = a.SaveModelText(ctx, "rbac", "[request_definition]\nr = sub\n")
+	require.ErrorContains(t, err, `pgadapter.SaveModelText: table "casbin_rules": model "rbac": `)
+}
+
+func TestMockSaveModelTextTemporary(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	// as set by WithTemporaryTable, which needs a real connection
+	a.temporary = true
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TEMP TABLE IF NOT EXISTS "casbin_models"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "casbin_models"`)).
+		WithArgs("rbac", rbacWithDomainsModel).
+		WillReturnRows(pgxmock.NewRows([]string{"revision"}).AddRow(int64(1)))
+	_, err := a.SaveModelText(context.Background(), "rbac", rbacWithDomainsModel)
+	require.NoError(t, err)
+}
+
+func TestMockLoadModelText(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+	query := regexp.QuoteMeta(`SELECT text, revision FROM "casbin_models" WHERE name = $1`)
+
+	mock.ExpectQuery(query).WithArgs("rbac").
+		WillReturnRows(pgxmock.NewRows([]string{"text", "revision"}).AddRow(rbacWithDomainsModel, int64(2)))
+	text, revision, err := a.LoadModelText(ctx, "rbac")
+	require.NoError(t, err)
+	require.Equal(t, rbacWithDomainsModel, text)
+	require.EqualValues(t, 2, revision)
+
+	mock.ExpectQuery(query).WithArgs("missing").
+		WillReturnRows(pgxmock.NewRows([]string{"text", "revision"}))
+	_, _, err = a.LoadModelText(ctx, "missing")
+	require.ErrorIs(t, err, ErrModelNotFound)
+
+	mock.ExpectQuery(query).WithArgs("rbac").WillReturnError(&pgconn.PgError{Code: codeUndefinedTable})
+	_, err = NewEnforcerFromDB(ctx, a, "rbac")
+	require.ErrorIs(t, err, ErrModelNotFound)
+	require.EqualError(t, err, `pgadapter.LoadModelText: table "casbin_rules": pgadapter: model not found: "rbac"`)
+}
@@ -0,0 +1,70 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+)
+
+// WithNamespace scopes the adapter to the rules of namespace, so several enforcers can share one rules table.
+// A namespace column is added to the table, the rules are stored with namespace and every read, write
+// and delete of the adapter, SavePolicy included, only sees the rules of namespace.
+// The namespace is part of the rule ids, so the same rule can be stored in several namespaces.
+//
+// The rules stored without WithNamespace belong to the empty namespace and keep their ids.
+// Once the table is shared, every adapter using it should be created with WithNamespace,
+// an adapter without it sees the rules of every namespace.
+func WithNamespace(namespace string) Option {
+	return func(a *Adapter) {
+		a.namespace = namespace
+		a.namespaced = true
+	}
+}
+
+// namespaceKey is the context key of the namespace of an operation, see WithTenantFromContext
+type namespaceKey struct{}
+
+// namespaceOf returns the namespace of the operation of ctx, the tenant of the context
+// with WithTenantFromContext or else the namespace of the adapter
+func (a *Adapter) namespaceOf(ctx context.Context) string {
+	if ns, ok := ctx.Value(namespaceKey{}).(string); ok {
+		return ns
+	}
+	return a.namespace
+}
+
+// policyLine returns the rule to store for ptype and rule in the namespace of the operation of ctx
+func (a *Adapter) policyLine(ctx context.Context, ptype string, rule []string) *CasbinRule {
+	line := savePolicyLine(ptype, rule)
+	line.ID = a.idScheme.PolicyID(a.namespaceOf(ctx), ptype, rule)
+	return line
+}
+
+// insertColumns returns the comma separated list of the columns set by the inserts, see insertArgs
+func (a *Adapter) insertColumns() string {
+	if a.namespaced {
+		return a.columns() + ", namespace"
+	}
+	return a.columns()
+}
+
+// rowParams returns the number of parameters of a rule inserted by insertRowsSQL
+func (a *Adapter) rowParams() int {
+	if a.namespaced {
+		return a.valueColumns + 3
+	}
+	return a.valueColumns + 2
+}
+
+// inNamespace appends the condition restricting sql to the namespace of the operation of ctx, if any, with its argument.
+// where tells if the condition starts the WHERE clause of sql.
+func (a *Adapter) inNamespace(ctx context.Context, sql string, args []any, where bool) (string, []any) {
+	if !a.namespaced {
+		return sql, args
+	}
+	args = append(args, a.namespaceOf(ctx))
+	op := " AND"
+	if where {
+		op = " WHERE"
+	}
+	return fmt.Sprintf("%v%v namespace = $%d", sql, op, len(args)), args
+}
@@ -0,0 +1,131 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestNamespace() {
+	ctx := context.Background()
+	tenant1, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithNamespace("tenant1"))
+	s.Require().NoError(err)
+	defer tenant1.Close()
+	tenant2, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithNamespace("tenant2"))
+	s.Require().NoError(err)
+	defer tenant2.Close()
+
+	// both namespaces store the same rule
+	s.Require().NoError(tenant1.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+	s.Require().NoError(tenant2.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}))
+
+	e1, err := casbin.NewEnforcer("examples/rbac_model.conf", tenant1)
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"alice", "data1", "read"}}, e1.GetPolicy())
+	e2, err := casbin.NewEnforcer("examples/rbac_model.conf", tenant2)
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}, e2.GetPolicy())
+
+	// SavePolicy and RemoveFilteredPolicy only replace the rules of their namespace
+	s.Require().NoError(e1.SavePolicy())
+	s.Require().NoError(tenant2.RemoveFilteredPolicy("p", "p", 0, "bob"))
+	count, err := tenant2.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Require().EqualValues(1, count)
+	count, err = tenant1.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Require().EqualValues(1, count)
+
+	// the rules stored before belong to the empty namespace, seen by the adapter without namespace
+	s.Require().NoError(s.e.LoadPolicy())
+	s.Require().Len(s.e.GetPolicy(), 6)
+	empty, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithNamespace(""))
+	s.Require().NoError(err)
+	defer empty.Close()
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", empty)
+	s.Require().NoError(err)
+	s.assertPolicy(
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+		e.GetPolicy(),
+	)
+}
+
+func TestMockNamespace(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+	rule := []string{"alice", "data1", "read"}
+	id := policyID("tenant1\x00p", rule)
+	require.NotEqual(t, policyID("p", rule), id)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules" (id, ptype, v0, v1, v2, v3, v4, v5, namespace) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT DO NOTHING`)).
+		WithArgs(id, "p", "alice", "data1", "read", "", "", "", "tenant1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", rule))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id=$1`)).WithArgs(id).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemovePolicy("p", "p", rule))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v0 = $2 AND namespace = $3`)).
+		WithArgs("p", "alice", "tenant1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemoveFilteredPolicy("p", "p", 0, "alice"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE namespace = $1`)).WithArgs("tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow(id, "p", "alice", "data1", "read", "", "", ""))
+	e, err := casbin.NewEnforcer("examples/rbac_model.conf", a)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{rule}, e.GetPolicy())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE namespace = $1`)).WithArgs("tenant1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(id, "p", "alice", "data1", "read", "", "", "", "tenant1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, e.SavePolicy())
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules" WHERE ptype = $1 AND namespace = $2`)).WithArgs("p", "tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	count, err := a.CountRules(context.Background(), "p")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+}
+
+func TestMockNamespaceEmpty(t *testing.T) {
+	// the empty namespace keeps the ids of the rules stored without namespace
+	a, mock := newMockAdapter(t, WithNamespace(""))
+	rule := []string{"alice", "data1", "read"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules" (id, ptype, v0, v1, v2, v3, v4, v5, namespace)`)).
+		WithArgs(policyID("p", rule), "p", "alice", "data1", "read", "", "", "", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", rule))
+}
+
+func TestMockCreateTableNamespace(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	mock.ExpectExec(`namespace TEXT NOT NULL DEFAULT ''`).WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "casbin_rules" ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT ''`)).
+		WillReturnResult(pgxmock.NewResult("ALTER", 0))
+	_, err = NewAdapterByPgxPool(mock, WithNamespace("tenant1"), SkipSchemaVerification())
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
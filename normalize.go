@@ -0,0 +1,68 @@
+package pgxadapter
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization configures the transforms applied to rule values before they are hashed and persisted.
+// The same transforms are applied to the values used by remove, update and filter methods
+// so lookups stay consistent. Loading is not affected.
+type Normalization struct {
+	// TrimSpace removes leading and trailing white space
+	TrimSpace bool
+	// NFC converts values to Unicode Normalization Form C
+	NFC bool
+	// LowerFields lists the field indexes (0 for v0, ...) whose values are lowercased
+	LowerFields []int
+}
+
+// WithNormalization enables normalization of rule values, see Normalization
+func WithNormalization(n Normalization) Option {
+	return func(a *Adapter) {
+		a.normalization = &n
+	}
+}
+
+func (n *Normalization) lower(fieldIndex int) bool {
+	for _, i := range n.LowerFields {
+		if i == fieldIndex {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize returns a normalized copy of values, the first value being at fieldIndex
+func (n *Normalization) normalize(fieldIndex int, values []string) []string {
+	if n == nil || values == nil {
+		return values
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		if n.TrimSpace {
+			v = strings.TrimSpace(v)
+		}
+		if n.NFC {
+			v = norm.NFC.String(v)
+		}
+		if n.lower(fieldIndex + i) {
+			v = strings.ToLower(v)
+		}
+		out[i] = v
+	}
+	return out
+}
+
+// normalizeRules returns normalized copies of rules
+func (n *Normalization) normalizeRules(rules [][]string) [][]string {
+	if n == nil {
+		return rules
+	}
+	out := make([][]string, len(rules))
+	for i, rule := range rules {
+		out[i] = n.normalize(0, rule)
+	}
+	return out
+}
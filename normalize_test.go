@@ -0,0 +1,46 @@
+package pgxadapter
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalization(t *testing.T) {
+	n := &Normalization{TrimSpace: true, NFC: true, LowerFields: []int{0}}
+	require.Equal(t, []string{"alice", "data1", "Read"}, n.normalize(0, []string{" Alice ", "data1\t", "Read"}))
+	// lowercasing follows the field index of the first value
+	require.Equal(t, []string{"", "DATA1"}, n.normalize(1, []string{"", "DATA1"}))
+	require.Equal(t, []string{"caf\u00e9"}, n.normalize(1, []string{"cafe\u0301"}))
+
+	var none *Normalization
+	require.Equal(t, []string{" Alice "}, none.normalize(0, []string{" Alice "}))
+}
+
+func TestMockNormalizedAddRemove(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNormalization(Normalization{TrimSpace: true, LowerFields: []int{0}}))
+	rule := []string{"alice", "data1", "read"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(policyID("p", rule), "p", "alice", "data1", "read", "", "", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", []string{"alice ", "data1", "read"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id=$1`)).
+		WithArgs(policyID("p", rule)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v0 = $2 AND v1 = $3`)).
+		WithArgs("p", "alice", "DATA1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemoveFilteredPolicy("p", "p", 0, " ALICE", "DATA1 "))
+}
@@ -0,0 +1,113 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+)
+
+// OrphanDefinition tells FindOrphanGroupingRules where the roles and the domains are stored, see WithOrphanDefinition
+type OrphanDefinition struct {
+	// RoleField is the index of the role in the g rules, 1 in "g, user, role"
+	RoleField int
+	// Domains gives the index of the domain in the p and g rules of a model with domains,
+	// e.g. DomainLayout{"p": 1, "g": 2}, a role is then only used by the rules of its domain.
+	// It is nil for models without domains.
+	Domains DomainLayout
+}
+
+// WithOrphanDefinition sets how FindOrphanGroupingRules and RemoveOrphanGroupingRules find the roles of the g rules,
+// by default the role is v1 and the rules have no domain. The whole definition is replaced, RoleField must be given.
+func WithOrphanDefinition(def OrphanDefinition) Option {
+	return func(a *Adapter) {
+		a.orphanDef = def
+	}
+}
+
+// orphanQuery returns the condition selecting the g rules, aliased g, whose role is orphan
+func (a *Adapter) orphanQuery(ctx context.Context) (string, error) {
+	def := a.orphanDef
+	if def.RoleField < 0 || def.RoleField >= a.valueColumns {
+		return "", fmt.Errorf("%w: role field %d is out of the %d value columns", ErrInvalidFilter, def.RoleField, a.valueColumns)
+	}
+	var pDomain, gDomain string
+	if def.Domains != nil {
+		if _, err := def.Domains.ptypes(a.valueColumns); err != nil {
+			return "", err
+		}
+		p, okP := def.Domains["p"]
+		g, okG := def.Domains["g"]
+		if !okP || !okG {
+			return "", fmt.Errorf("%w: domain layout must give the domain of p and g", ErrInvalidFilter)
+		}
+		pDomain = fmt.Sprintf(" AND p.v%d = g.v%d", p, g)
+		gDomain = fmt.Sprintf(" AND o.v%d = g.v%d", g, g)
+	}
+	var pNamespace, gNamespace string
+	if a.namespaced {
+		pNamespace = " AND p.namespace = g.namespace"
+		gNamespace = " AND o.namespace = g.namespace"
+	}
+	table := a.table(ctx)
+	return fmt.Sprintf(`g.ptype = 'g'
+		AND NOT EXISTS (SELECT 1 FROM "%[1]v" p WHERE p.ptype = 'p' AND p.v0 = g.v%[2]d%[3]v%[4]v)
+		AND NOT EXISTS (SELECT 1 FROM "%[1]v" o WHERE o.ptype = 'g' AND o.v0 = g.v%[2]d%[5]v%[6]v)`,
+		table, def.RoleField, pDomain, pNamespace, gDomain, gNamespace), nil
+}
+
+// FindOrphanGroupingRules returns the g rules whose role is neither the subject (v0) of a p rule
+// nor a member (v0) of another g rule, so they grant nothing, see WithOrphanDefinition for models with domains.
+// The rules are returned without the ptype, ordered by id.
+func (a *Adapter) FindOrphanGroupingRules(ctx context.Context) (_ [][]string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "FindOrphanGroupingRules", Ptype: "g"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	cond, err := a.orphanQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v" g WHERE %v`, a.columns(), a.table(ctx), cond), nil, false)
+	return a.queryOrphans(ctx, sql+" ORDER BY id", args)
+}
+
+// RemoveOrphanGroupingRules removes the g rules returned by FindOrphanGroupingRules in a single statement
+// and returns them, when dryRun is set the rules are only returned.
+func (a *Adapter) RemoveOrphanGroupingRules(ctx context.Context, dryRun bool) (_ [][]string, err error) {
+	if dryRun {
+		return a.FindOrphanGroupingRules(ctx)
+	}
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RemoveOrphanGroupingRules", Ptype: "g"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	cond, err := a.orphanQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`DELETE FROM "%v" g WHERE %v`, a.table(ctx), cond), nil, false)
+	return a.queryOrphans(ctx, sql+" RETURNING "+a.columns(), args)
+}
+
+// queryOrphans runs a query returning rules and returns their values
+func (a *Adapter) queryOrphans(ctx context.Context, sql string, args []any) ([][]string, error) {
+	rows, err := a.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules [][]string
+	scanner := a.newRuleScanner()
+	for rows.Next() {
+		line, err := scanner.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, line.rule(a.valueColumns))
+	}
+	return rules, rows.Err()
+}
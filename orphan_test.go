@@ -0,0 +1,76 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestOrphanGroupingRules() {
+	ctx := context.Background()
+	s.Require().NoError(s.a.AddPolicies("g", "g", [][]string{{"bob", "ghost"}, {"carol", "managers"}, {"managers", "data2_admin"}}))
+
+	orphans, err := s.a.FindOrphanGroupingRules(ctx)
+	s.Require().NoError(err)
+	s.Require().Equal([][]string{{"bob", "ghost"}}, orphans)
+
+	orphans, err = s.a.RemoveOrphanGroupingRules(ctx, true)
+	s.Require().NoError(err)
+	s.Require().Len(orphans, 1)
+	count, err := s.a.CountRules(ctx, "g")
+	s.Require().NoError(err)
+	s.Require().EqualValues(4, count)
+
+	orphans, err = s.a.RemoveOrphanGroupingRules(ctx, false)
+	s.Require().NoError(err)
+	s.Require().Equal([][]string{{"bob", "ghost"}}, orphans)
+	count, err = s.a.CountRules(ctx, "g")
+	s.Require().NoError(err)
+	s.Require().EqualValues(3, count)
+}
+
+func (s *AdapterTestSuite) TestOrphanGroupingRulesDomains() {
+	ctx := context.Background()
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTemporaryTable(), WithTableName("rules_orphan_domains"),
+		WithOrphanDefinition(OrphanDefinition{RoleField: 1, Domains: DomainLayout{"p": 1, "g": 2}}))
+	s.Require().NoError(err)
+	defer a.Close()
+
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"admin", "domain1", "data1", "read"}))
+	s.Require().NoError(a.AddPolicies("g", "g", [][]string{{"alice", "admin", "domain1"}, {"bob", "admin", "domain2"}}))
+	orphans, err := a.FindOrphanGroupingRules(ctx)
+	s.Require().NoError(err)
+	s.Require().Equal([][]string{{"bob", "admin", "domain2"}}, orphans)
+}
+
+func TestMockOrphanGroupingRules(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"),
+		WithOrphanDefinition(OrphanDefinition{RoleField: 1, Domains: DomainLayout{"p": 1, "g": 2}}))
+	ctx := context.Background()
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	mock.ExpectQuery(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" g WHERE g.ptype = 'g'\s+` +
+		regexp.QuoteMeta(`AND NOT EXISTS (SELECT 1 FROM "casbin_rules" p WHERE p.ptype = 'p' AND p.v0 = g.v1 AND p.v1 = g.v2 AND p.namespace = g.namespace)`) + `\s+` +
+		regexp.QuoteMeta(`AND NOT EXISTS (SELECT 1 FROM "casbin_rules" o WHERE o.ptype = 'g' AND o.v0 = g.v1 AND o.v2 = g.v2 AND o.namespace = g.namespace) AND namespace = $1 ORDER BY id`)).
+		WithArgs("tenant1").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "g", "bob", "admin", "domain2", "", "", ""))
+	orphans, err := a.FindOrphanGroupingRules(ctx)
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"bob", "admin", "domain2"}}, orphans)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM "casbin_rules" g WHERE g.ptype = 'g'`) + `.*` +
+		regexp.QuoteMeta(`AND namespace = $1 RETURNING id, ptype, v0, v1, v2, v3, v4, v5`)).
+		WithArgs("tenant1").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "g", "bob", "admin", "domain2", "", "", ""))
+	orphans, err = a.RemoveOrphanGroupingRules(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, orphans, 1)
+
+	b, _ := newMockAdapter(t, WithOrphanDefinition(OrphanDefinition{RoleField: 1, Domains: DomainLayout{"p": 1}}))
+	_, err = b.FindOrphanGroupingRules(ctx)
+	require.ErrorIs(t, err, ErrInvalidFilter)
+}
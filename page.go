@@ -0,0 +1,95 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+)
+
+// LoadPolicyPage returns up to limit rules of the given ptype, or of every ptype if ptype is empty,
+// with an id greater than afterID, ordered by id. Pass an empty afterID to get the first page.
+// nextCursor is the afterID of the next page, it is empty when there are no more rules.
+//
+// Pages use keyset pagination on the id column, so a cursor stays valid when rules are added or removed:
+// removed rules are never returned again and the following pages are not shifted,
+// but a rule added with an id lower than the cursor is only seen when paging again from the start.
+// Ids are hashes of the rules, so pages are not in insertion order.
+//
+// LoadPolicyPage returns raw rules, it doesn't load them into a model.
+func (a *Adapter) LoadPolicyPage(ctx context.Context, ptype string, limit int, afterID string) (_ []CasbinRule, _ string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "LoadPolicyPage", Ptype: ptype})
+	if err != nil {
+		return nil, "", err
+	}
+	defer finish(&err)
+
+	if limit < 1 {
+		return nil, "", fmt.Errorf("pgadapter: page limit must be positive, got %d", limit)
+	}
+	var page []CasbinRule
+	var next string
+	err = a.retryMissingTable(ctx, func(ctx context.Context) (err error) {
+		page, next, err = a.queryPage(ctx, a.db, ptype, limit, afterID)
+		return err
+	})
+	return page, next, err
+}
+
+// queryPage runs the LoadPolicyPage query with q
+func (a *Adapter) queryPage(ctx context.Context, q querier, ptype string, limit int, afterID string) ([]CasbinRule, string, error) {
+	query := fmt.Sprintf(`SELECT %v FROM "%v" WHERE id > $1`, a.columns(), a.readTable(ctx))
+	args := []any{afterID}
+	if ptype != "" {
+		query += " AND ptype = $2"
+		args = append(args, ptype)
+	}
+	query, args = a.inNamespace(ctx, query, args, false)
+	// one more rule than requested tells if there is a next page
+	query += fmt.Sprintf(" ORDER BY id LIMIT %d", limit+1)
+
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	page := make([]CasbinRule, 0, limit)
+	for rows.Next() {
+		line, err := a.scanRule(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		page = append(page, *line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if len(page) <= limit {
+		return page, "", nil
+	}
+	page = page[:limit]
+	return page, page[limit-1].ID, nil
+}
+
+// CountRules returns the number of rules of the given ptype, or of every ptype if ptype is empty
+func (a *Adapter) CountRules(ctx context.Context, ptype string) (_ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "CountRules", Ptype: ptype})
+	if err != nil {
+		return 0, err
+	}
+	defer finish(&err)
+
+	query := fmt.Sprintf(`SELECT count(*) FROM "%v"`, a.readTable(ctx))
+	var args []any
+	if ptype != "" {
+		query += " WHERE ptype = $1"
+		args = append(args, ptype)
+	}
+	query, args = a.inNamespace(ctx, query, args, ptype == "")
+
+	var count int64
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		return a.queryRow(ctx, query, args, &count)
+	})
+	return count, err
+}
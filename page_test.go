@@ -0,0 +1,82 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestLoadPolicyPage() {
+	ctx := context.Background()
+	count, err := s.a.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Require().EqualValues(5, count)
+	count, err = s.a.CountRules(ctx, "p")
+	s.Require().NoError(err)
+	s.Require().EqualValues(4, count)
+
+	var rules [][]string
+	seen := map[string]bool{}
+	cursor := ""
+	for {
+		page, next, err := s.a.LoadPolicyPage(ctx, "p", 3, cursor)
+		s.Require().NoError(err)
+		for _, r := range page {
+			s.Require().False(seen[r.ID], "rule returned twice")
+			seen[r.ID] = true
+			rules = append(rules, r.toStringPolicy()[1:])
+		}
+		if next == "" {
+			break
+		}
+		// rules added while paging don't break the cursor
+		s.Require().NoError(s.a.AddPolicy("p", "p", []string{"carol", "data3", "read"}))
+		cursor = next
+	}
+	s.Require().GreaterOrEqual(len(rules), 4)
+	s.Require().LessOrEqual(len(rules), 5)
+}
+
+func TestMockLoadPolicyPage(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE id > $1 AND ptype = $2 ORDER BY id LIMIT 3`)).
+		WithArgs("", "p").
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("a", "p", "alice", "data1", "read", "", "", "").
+			AddRow("b", "p", "bob", "data2", "write", "", "", "").
+			AddRow("c", "p", "carol", "data3", "read", "", "", ""))
+	page, next, err := a.LoadPolicyPage(ctx, "p", 2, "")
+	require.NoError(t, err)
+	require.Equal(t, "b", next)
+	require.Len(t, page, 2)
+	require.Equal(t, []string{"p", "bob", "data2", "write"}, page[1].toStringPolicy())
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE id > $1 ORDER BY id LIMIT 3`)).
+		WithArgs("b").
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("c", "p", "carol", "data3", "read", "", "", ""))
+	page, next, err = a.LoadPolicyPage(ctx, "", 2, "b")
+	require.NoError(t, err)
+	require.Equal(t, "", next)
+	require.Len(t, page, 1)
+
+	_, _, err = a.LoadPolicyPage(ctx, "p", 0, "")
+	require.Error(t, err)
+}
+
+func TestMockCountRules(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules" WHERE ptype = $1`)).
+		WithArgs("g").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(7)))
+	count, err := a.CountRules(context.Background(), "g")
+	require.NoError(t, err)
+	require.EqualValues(t, 7, count)
+}
@@ -0,0 +1,150 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// MaxLoadWorkers is the highest number of concurrent queries used by WithParallelLoad
+const MaxLoadWorkers = 16
+
+// WithParallelLoad makes LoadPolicy fetch the rules with n concurrent queries over ranges of the id keyspace,
+// each query running on its own pool connection, n is capped at MaxLoadWorkers.
+// The rules are added to the model one at a time, in no particular order.
+// Unless WithParallelLoadSkew is used, the queries read the same snapshot of the table,
+// exported by a transaction kept open during the load, so the pool needs n+1 connections to run all the queries at once.
+func WithParallelLoad(n int) Option {
+	return func(a *Adapter) {
+		a.loadWorkers = n
+	}
+}
+
+// WithParallelLoadSkew lets the queries of WithParallelLoad run without a shared snapshot,
+// so rules changed during the load may be seen by some of the ranges and not by others.
+func WithParallelLoadSkew() Option {
+	return func(a *Adapter) {
+		a.loadSkew = true
+	}
+}
+
+// idRange is a range of ids, from is inclusive and to exclusive, an empty bound is unbounded
+type idRange struct {
+	from, to string
+}
+
+// idRanges splits the keyspace of the hexadecimal policy ids in at most n contiguous ranges covering all the ids
+func idRanges(n int) []idRange {
+	const digits = "0123456789abcdef"
+	if n > MaxLoadWorkers {
+		n = MaxLoadWorkers
+	}
+	ranges := make([]idRange, 0, n)
+	from := ""
+	for k := 1; k < n; k++ {
+		to := string(digits[len(digits)*k/n])
+		ranges = append(ranges, idRange{from, to})
+		from = to
+	}
+	return append(ranges, idRange{from, ""})
+}
+
+// query returns the condition selecting the ids of the range and its arguments
+func (r idRange) query() (string, []any) {
+	var conds []string
+	var args []any
+	if r.from != "" {
+		args = append(args, r.from)
+		conds = append(conds, fmt.Sprintf("id >= $%d", len(args)))
+	}
+	if r.to != "" {
+		args = append(args, r.to)
+		conds = append(conds, fmt.Sprintf("id < $%d", len(args)))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// loadParallel calls fn with every rule, fetched with one query per id range.
+// fn calls are serialized.
+func (a *Adapter) loadParallel(ctx context.Context, fn func(line *CasbinRule) error) error {
+	snapshot := ""
+	if !a.loadSkew {
+		tx, err := a.db.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		if _, err := tx.Exec(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`); err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, `SELECT pg_export_snapshot()`)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			if err := rows.Scan(&snapshot); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	load := func(line *CasbinRule) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return fn(line)
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for _, r := range idRanges(a.loadWorkers) {
+		wg.Add(1)
+		go func(r idRange) {
+			defer wg.Done()
+			if err := a.loadRange(ctx, snapshot, r, load); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(r)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// loadRange calls fn with the rules of the id range, read from the exported snapshot if not empty
+func (a *Adapter) loadRange(ctx context.Context, snapshot string, r idRange, fn func(line *CasbinRule) error) error {
+	var q querier = a.db
+	if snapshot != "" {
+		tx, err := a.db.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		if _, err := tx.Exec(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`SET TRANSACTION SNAPSHOT '%v'`, strings.ReplaceAll(snapshot, "'", "''"))); err != nil {
+			return err
+		}
+		q = tx
+	}
+
+	where, args := r.query()
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v"%v`, a.columns(), a.readTable(ctx), where), args, where == "")
+	return a.loadRows(ctx, q, sql, args, fn)
+}
@@ -0,0 +1,141 @@
+package pgxadapter
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestParallelLoad() {
+	rules := make([][]string, 2000)
+	for i := range rules {
+		rules[i] = []string{fmt.Sprintf("user%d", i), "data", "read"}
+	}
+	s.Require().NoError(s.a.AddPolicies("p", "p", rules))
+
+	expected, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(s.a.LoadPolicy(expected))
+
+	for _, opts := range [][]Option{{WithParallelLoad(4)}, {WithParallelLoad(7), WithParallelLoadSkew()}} {
+		a, err := NewAdapterByDB(s.a.db.(*pgxpool.Pool), append(opts, SkipTableCreate())...)
+		s.Require().NoError(err)
+
+		m, err := model.NewModelFromFile("examples/rbac_model.conf")
+		s.Require().NoError(err)
+		s.Require().NoError(a.LoadPolicy(m))
+		s.Require().ElementsMatch(expected.GetPolicy("p", "p"), m.GetPolicy("p", "p"))
+		s.Require().ElementsMatch(expected.GetPolicy("g", "g"), m.GetPolicy("g", "g"))
+	}
+}
+
+func TestIDRanges(t *testing.T) {
+	var ids []string
+	for i := 0; i < 5000; i++ {
+		ids = append(ids, policyID("p", []string{fmt.Sprint(i)}))
+	}
+	ids = append(ids, "", "0", "f", "ffffffffffffffff", "g", "zz")
+
+	for n := 1; n <= MaxLoadWorkers+2; n++ {
+		ranges := idRanges(n)
+		require.LessOrEqual(t, len(ranges), MaxLoadWorkers)
+		require.Equal(t, "", ranges[0].from)
+		require.Equal(t, "", ranges[len(ranges)-1].to)
+		for i := 1; i < len(ranges); i++ {
+			require.Equal(t, ranges[i-1].to, ranges[i].from)
+		}
+		for _, id := range ids {
+			matches := 0
+			for _, r := range ranges {
+				if (r.from == "" || id >= r.from) && (r.to == "" || id < r.to) {
+					matches++
+				}
+			}
+			require.Equal(t, 1, matches, "id %q with %d ranges", id, n)
+		}
+	}
+}
+
+func TestMockParallelLoadSkew(t *testing.T) {
+	a, mock := newMockAdapter(t, WithParallelLoad(2), WithParallelLoadSkew())
+	mock.MatchExpectationsInOrder(false)
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE id < $1`)).
+		WithArgs("8").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE id >= $1`)).
+		WithArgs("8").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("a", "g", "alice", "admin", "", "", "", ""))
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicy(m))
+	require.Equal(t, [][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "admin"}}, m.GetPolicy("g", "g"))
+}
+
+func TestMockParallelLoadSnapshot(t *testing.T) {
+	a, mock := newMockAdapter(t, WithParallelLoad(2))
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`)).
+		WillReturnResult(pgxmock.NewResult("SET", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_export_snapshot()`)).
+		WillReturnRows(pgxmock.NewRows([]string{"pg_export_snapshot"}).AddRow("00000003-0000001B-1"))
+	mock.MatchExpectationsInOrder(false)
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`)).
+			WillReturnResult(pgxmock.NewResult("SET", 0))
+		mock.ExpectExec(regexp.QuoteMeta(`SET TRANSACTION SNAPSHOT '00000003-0000001B-1'`)).
+			WillReturnResult(pgxmock.NewResult("SET", 0))
+		mock.ExpectRollback()
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE id < $1`)).
+		WithArgs("8").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE id >= $1`)).
+		WithArgs("8").
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("a", "p", "bob", "data2", "write", "", "", ""))
+	mock.ExpectRollback()
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicy(m))
+	require.ElementsMatch(t, [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}, m.GetPolicy("p", "p"))
+}
+
+func BenchmarkParallelLoadPolicy(b *testing.B) {
+	if os.Getenv("PG_CONN") == "" {
+		b.Skip("PG_CONN is not set")
+	}
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithDatabaseName("casbin_bench"))
+	require.NoError(b, err)
+	defer a.Close()
+
+	rules := make([][]string, 100000)
+	for i := range rules {
+		rules[i] = []string{fmt.Sprintf("user%d", i), fmt.Sprintf("data%d", i%100), "read"}
+	}
+	require.NoError(b, a.AddPolicies("p", "p", rules))
+
+	for _, n := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", n), func(b *testing.B) {
+			pa, err := NewAdapterByDB(a.db.(*pgxpool.Pool), SkipTableCreate(), WithParallelLoad(n))
+			require.NoError(b, err)
+			for i := 0; i < b.N; i++ {
+				m, err := model.NewModelFromFile("examples/rbac_model.conf")
+				require.NoError(b, err)
+				require.NoError(b, pa.LoadPolicy(m))
+			}
+		})
+	}
+}
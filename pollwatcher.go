@@ -0,0 +1,167 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+)
+
+// DefaultRevisionPollInterval is the delay between the reads of the revision by the PollingWatcher
+// unless WithRevisionPollInterval is given
+const DefaultRevisionPollInterval = time.Second
+
+// PollingWatcherOption configures a PollingWatcher
+type PollingWatcherOption func(w *PollingWatcher)
+
+// WithRevisionPollInterval sets the delay between the reads of the revision
+func WithRevisionPollInterval(interval time.Duration) PollingWatcherOption {
+	return func(w *PollingWatcher) {
+		w.interval = interval
+	}
+}
+
+// WithPollingErrors sets a function called when reading the revision fails, it is read again after the poll interval
+func WithPollingErrors(fn func(error)) PollingWatcherOption {
+	return func(w *PollingWatcher) {
+		w.errors = fn
+	}
+}
+
+// PollingWatcher is a casbin watcher reading the revision of the rules table of WithRevisions at a regular interval,
+// for the deployments where LISTEN doesn't work, e.g. behind PgBouncer in transaction pooling mode.
+// The update callback is called with the new revision as payload when the revision read differs from the previous one,
+// so the changes made within an interval are seen as one. The revisions are compared, not the clocks of the servers.
+//
+// Every statement writing the rules table increases the revision, so the changes made by hand are seen too,
+// and so are the changes of the instance itself: unlike Watcher, the update callback is called for them.
+// A failed read is reported to the function of WithPollingErrors and the revision is read again after the interval.
+type PollingWatcher struct {
+	db       PgxPool
+	table    string
+	interval time.Duration
+	errors   func(error)
+
+	mu       sync.Mutex
+	callback func(string)
+	closed   bool
+	// revision is the last revision read
+	revision int64
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ persist.Watcher = (*PollingWatcher)(nil)
+
+// NewPollingWatcher creates a watcher polling the revision of the rules table, the adapter must be created with
+// WithRevisions. The revision is read once before NewPollingWatcher returns, the changes made after are seen.
+// Close must be called to stop the watcher.
+func (a *Adapter) NewPollingWatcher(ctx context.Context, opts ...PollingWatcherOption) (*PollingWatcher, error) {
+	if !a.revisions {
+		return nil, fmt.Errorf("pgadapter.NewPollingWatcher: %w", errRevisionsDisabled)
+	}
+	w := &PollingWatcher{
+		db:       a.db,
+		table:    a.revisionTable(ctx),
+		interval: DefaultRevisionPollInterval,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.interval <= 0 {
+		return nil, fmt.Errorf("pgadapter.NewPollingWatcher: poll interval must be positive, got %v", w.interval)
+	}
+	revision, err := w.read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pgadapter.NewPollingWatcher: %w", err)
+	}
+	w.revision = revision
+
+	loopCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	go w.run(loopCtx)
+	return w, nil
+}
+
+// read returns the current revision
+func (w *PollingWatcher) read(ctx context.Context) (int64, error) {
+	var revision int64
+	err := queryRowWith(ctx, w.db, fmt.Sprintf(`SELECT revision FROM "%v"`, w.table), nil, &revision)
+	return revision, err
+}
+
+// run reads the revision every interval until ctx is canceled
+func (w *PollingWatcher) run(ctx context.Context) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		revision, err := w.read(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if w.errors != nil {
+				w.errors(fmt.Errorf("pgadapter.PollingWatcher: read revision: %w", err))
+			}
+			continue
+		}
+		w.changed(revision)
+	}
+}
+
+// changed calls the update callback if revision differs from the last revision read, unless the watcher is closed
+func (w *PollingWatcher) changed(revision int64) {
+	w.mu.Lock()
+	if revision == w.revision || w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.revision = revision
+	callback := w.callback
+	w.mu.Unlock()
+	if callback != nil {
+		callback(strconv.FormatInt(revision, 10))
+	}
+}
+
+// SetUpdateCallback sets the function called when the revision changed, a classic callback is Enforcer.LoadPolicy
+func (w *PollingWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	w.callback = callback
+	w.mu.Unlock()
+	return nil
+}
+
+// Update increases the revision so the other watchers call their update callback, like the writes of the rules table do
+func (w *PollingWatcher) Update() error {
+	select {
+	case <-w.done:
+		return fmt.Errorf("pgadapter.PollingWatcher: %w", ErrWatcherClosed)
+	default:
+	}
+	if _, err := w.db.Exec(context.Background(), fmt.Sprintf(`UPDATE "%v" SET revision = revision + 1`, w.table)); err != nil {
+		return fmt.Errorf("pgadapter.PollingWatcher: increase revision: %w", err)
+	}
+	return nil
+}
+
+// Close stops polling and waits for the running read to end, the update callback isn't called anymore
+func (w *PollingWatcher) Close() {
+	w.cancel()
+	<-w.done
+
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+}
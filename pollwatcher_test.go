@@ -0,0 +1,99 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestPollingWatcher() {
+	ctx := context.Background()
+	pool := s.a.db.(*pgxpool.Pool)
+	a, err := NewAdapterByDB(pool, WithTableName("rules_polling_test"), WithRevisions())
+	s.Require().NoError(err)
+	defer pool.Exec(ctx, `DROP TABLE IF EXISTS "rules_polling_test", "rules_polling_test_revision"`)
+	b, err := NewAdapterByDB(pool, WithTableName("rules_polling_test"), WithRevisions(), SkipTableCreate())
+	s.Require().NoError(err)
+
+	const interval = 20 * time.Millisecond
+	wa, err := a.NewPollingWatcher(ctx, WithRevisionPollInterval(interval))
+	s.Require().NoError(err)
+	defer wa.Close()
+	wb, err := b.NewPollingWatcher(ctx, WithRevisionPollInterval(interval))
+	s.Require().NoError(err)
+	defer wb.Close()
+	payloads := make(chan string, 10)
+	s.Require().NoError(wb.SetUpdateCallback(func(payload string) { payloads <- payload }))
+
+	// a write of the other instance is seen within a couple of polls
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+	select {
+	case <-payloads:
+	case <-time.After(5 * interval):
+		s.FailNow("the update callback wasn't called after the rules changed")
+	}
+
+	s.Require().NoError(wa.Update())
+	select {
+	case <-payloads:
+	case <-time.After(5 * interval):
+		s.FailNow("the update callback wasn't called after Update")
+	}
+	select {
+	case payload := <-payloads:
+		s.FailNow("the update callback was called without a change", payload)
+	case <-time.After(3 * interval):
+	}
+}
+
+// revisionRows returns the rows of the query reading the revision
+func revisionRows(revision int64) *pgxmock.Rows {
+	return pgxmock.NewRows([]string{"revision"}).AddRow(revision)
+}
+
+func TestMockPollingWatcher(t *testing.T) {
+	a, mock := newMockAdapter(t, WithRevisions())
+	ctx := context.Background()
+	readSQL := regexp.QuoteMeta(`SELECT revision FROM "casbin_rules_revision"`)
+
+	mock.ExpectQuery(readSQL).WillReturnRows(revisionRows(1))
+	// a failed read is reported and doesn't stop the watcher
+	mock.ExpectQuery(readSQL).WillReturnError(errors.New("connection reset"))
+	mock.ExpectQuery(readSQL).WillReturnRows(revisionRows(1))
+	mock.ExpectQuery(readSQL).WillReturnRows(revisionRows(3))
+	errs := make(chan error, 1)
+	payloads := make(chan string, 1)
+	w, err := a.NewPollingWatcher(ctx, WithRevisionPollInterval(time.Millisecond), WithPollingErrors(func(err error) {
+		// the reads following the expected ones fail too
+		select {
+		case errs <- err:
+		default:
+		}
+	}))
+	require.NoError(t, err)
+	require.NoError(t, w.SetUpdateCallback(func(p string) { payloads <- p }))
+	require.ErrorContains(t, <-errs, "pgadapter.PollingWatcher: read revision: connection reset")
+	require.Equal(t, "3", <-payloads)
+	w.Close()
+	require.ErrorIs(t, w.Update(), ErrWatcherClosed)
+
+	mock.ExpectQuery(readSQL).WillReturnRows(revisionRows(3))
+	w, err = a.NewPollingWatcher(ctx, WithRevisionPollInterval(time.Hour))
+	require.NoError(t, err)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "casbin_rules_revision" SET revision = revision + 1`)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	require.NoError(t, w.Update())
+	w.Close()
+
+	_, err = a.NewPollingWatcher(ctx, WithRevisionPollInterval(0))
+	require.ErrorContains(t, err, "poll interval must be positive")
+	b, _ := newMockAdapter(t)
+	_, err = b.NewPollingWatcher(ctx)
+	require.ErrorIs(t, err, errRevisionsDisabled)
+}
@@ -0,0 +1,168 @@
+package pgxadapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultApplicationName is the application_name of the connections opened by the pool NewAdapter creates
+const DefaultApplicationName = "casbin-pgx-adapter"
+
+// WithDatabaseName sets the database created and used by NewAdapterWithOptions,
+// it is the option equivalent of the dbname argument of NewAdapter
+func WithDatabaseName(name string) Option {
+	return func(a *Adapter) {
+		a.dbName = name
+	}
+}
+
+// WithApplicationName sets the application_name reported in pg_stat_activity by the connections
+// of the pool created by NewAdapter, it overrides the application_name of the given config or URL.
+// Without this option the pool uses DefaultApplicationName unless the config already sets one.
+// The option has no effect on pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithApplicationName(name string) Option {
+	return func(a *Adapter) {
+		a.applicationName = name
+		a.applicationNameSet = true
+	}
+}
+
+// WithBeforeConnect adds a hook called before each connection of the pool created by NewAdapter is opened,
+// see pgxpool.Config.BeforeConnect. The hook runs after the BeforeConnect hook of the given config, if any.
+// The option has no effect on pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithBeforeConnect(hook func(ctx context.Context, cfg *pgx.ConnConfig) error) Option {
+	return func(a *Adapter) {
+		a.beforeConnect = append(a.beforeConnect, hook)
+	}
+}
+
+// WithAfterConnect adds a hook called after each connection of the pool created by NewAdapter is established,
+// see pgxpool.Config.AfterConnect. The hook runs after the AfterConnect hook of the given config, if any.
+// The option has no effect on pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithAfterConnect(hook func(ctx context.Context, conn *pgx.Conn) error) Option {
+	return func(a *Adapter) {
+		a.afterConnect = append(a.afterConnect, hook)
+	}
+}
+
+// poolConfig returns a copy of cfg with the settings of the pool created by the adapter
+func (a *Adapter) poolConfig(cfg *pgxpool.Config) *pgxpool.Config {
+	cfg = cfg.Copy()
+	if cfg.ConnConfig.RuntimeParams == nil {
+		cfg.ConnConfig.RuntimeParams = map[string]string{}
+	}
+	if _, ok := cfg.ConnConfig.RuntimeParams["application_name"]; !ok || a.applicationNameSet {
+		cfg.ConnConfig.RuntimeParams["application_name"] = a.applicationName
+	}
+
+	if hooks := a.beforeConnect; len(hooks) > 0 {
+		prev := cfg.BeforeConnect
+		cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+			if prev != nil {
+				if err := prev(ctx, cc); err != nil {
+					return err
+				}
+			}
+			for _, hook := range hooks {
+				if err := hook(ctx, cc); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if len(a.afterConnect) > 0 {
+		cfg.AfterConnect = chainAfterConnect(cfg.AfterConnect, a.afterConnect...)
+	}
+	return cfg
+}
+
+// chainAfterConnect returns an AfterConnect hook calling prev, if not nil, then hooks in order
+func chainAfterConnect(prev func(context.Context, *pgx.Conn) error, hooks ...func(context.Context, *pgx.Conn) error) func(context.Context, *pgx.Conn) error {
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		if prev != nil {
+			if err := prev(ctx, conn); err != nil {
+				return err
+			}
+		}
+		for _, hook := range hooks {
+			if err := hook(ctx, conn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithMaxConns sets the maximum number of connections of the pool created by NewAdapter,
+// see pgxpool.Config.MaxConns. It overrides the pool_max_conns of the given config or URL.
+// The option is ignored, with a warning logged, for pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithMaxConns(n int32) Option {
+	return func(a *Adapter) {
+		a.maxConns = n
+	}
+}
+
+// WithMinConns sets the minimum number of connections the pool created by NewAdapter keeps open,
+// see pgxpool.Config.MinConns. It can't be greater than the value of WithMaxConns.
+// The option is ignored, with a warning logged, for pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithMinConns(n int32) Option {
+	return func(a *Adapter) {
+		a.minConns = n
+	}
+}
+
+// WithConnLifetime sets the duration after which the pool created by NewAdapter closes a connection,
+// see pgxpool.Config.MaxConnLifetime.
+// The option is ignored, with a warning logged, for pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithConnLifetime(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.connLifetime = d
+	}
+}
+
+// WithConnIdleTime sets the duration after which the pool created by NewAdapter closes an idle connection,
+// see pgxpool.Config.MaxConnIdleTime.
+// The option is ignored, with a warning logged, for pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithConnIdleTime(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.connIdleTime = d
+	}
+}
+
+// WithHealthCheckPeriod sets how often the pool created by NewAdapter checks its idle connections,
+// see pgxpool.Config.HealthCheckPeriod.
+// The option is ignored, with a warning logged, for pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithHealthCheckPeriod(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.healthCheckPeriod = d
+	}
+}
+
+// poolTuned reports whether one of the pool size or lifetime options is given
+func (a *Adapter) poolTuned() bool {
+	return a.maxConns > 0 || a.minConns > 0 || a.connLifetime > 0 || a.connIdleTime > 0 || a.healthCheckPeriod > 0
+}
+
+// tunePool applies the pool size and lifetime options to cfg, the settings not given keep their value.
+// The pool creating the database is not tuned, it only opens a single connection.
+func (a *Adapter) tunePool(cfg *pgxpool.Config) {
+	if a.maxConns > 0 {
+		cfg.MaxConns = a.maxConns
+	}
+	if a.minConns > 0 {
+		cfg.MinConns = a.minConns
+	}
+	if a.connLifetime > 0 {
+		cfg.MaxConnLifetime = a.connLifetime
+	}
+	if a.connIdleTime > 0 {
+		cfg.MaxConnIdleTime = a.connIdleTime
+	}
+	if a.healthCheckPeriod > 0 {
+		cfg.HealthCheckPeriod = a.healthCheckPeriod
+	}
+}
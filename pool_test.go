@@ -0,0 +1,164 @@
+package pgxadapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestApplicationName() {
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithApplicationName("casbin-test"))
+	s.Require().NoError(err)
+	defer a.Close()
+
+	var name string
+	rows, err := a.db.Query(context.Background(), `SELECT current_setting('application_name')`)
+	s.Require().NoError(err)
+	s.Require().True(rows.Next())
+	s.Require().NoError(rows.Scan(&name))
+	rows.Close()
+	s.Require().Equal("casbin-test", name)
+}
+
+func (s *AdapterTestSuite) TestConnectHooks() {
+	var before, after int32
+	cfg, err := pgxpool.ParseConfig(os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		_, err := conn.Exec(ctx, "SET search_path TO public")
+		return err
+	}
+
+	a, err := NewAdapterWithOptions(cfg,
+		WithBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+			atomic.AddInt32(&before, 1)
+			return nil
+		}),
+		WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+			atomic.AddInt32(&after, 1)
+			return nil
+		}),
+	)
+	s.Require().NoError(err)
+	defer a.Close()
+
+	s.Require().Positive(atomic.LoadInt32(&before))
+	s.Require().Positive(atomic.LoadInt32(&after))
+}
+
+func TestPoolConfigApplicationName(t *testing.T) {
+	cfg, err := pgxpool.ParseConfig("postgres://user@localhost/app")
+	require.NoError(t, err)
+
+	a, err := newAdapter(nil)
+	require.NoError(t, err)
+	require.Equal(t, DefaultApplicationName, a.poolConfig(cfg).ConnConfig.RuntimeParams["application_name"])
+	require.NotContains(t, cfg.ConnConfig.RuntimeParams, "application_name")
+
+	a, err = newAdapter([]Option{WithApplicationName("authz")})
+	require.NoError(t, err)
+	require.Equal(t, "authz", a.poolConfig(cfg).ConnConfig.RuntimeParams["application_name"])
+
+	cfg, err = pgxpool.ParseConfig("postgres://user@localhost/app?application_name=billing")
+	require.NoError(t, err)
+
+	a, err = newAdapter(nil)
+	require.NoError(t, err)
+	require.Equal(t, "billing", a.poolConfig(cfg).ConnConfig.RuntimeParams["application_name"])
+
+	a, err = newAdapter([]Option{WithApplicationName("authz")})
+	require.NoError(t, err)
+	require.Equal(t, "authz", a.poolConfig(cfg).ConnConfig.RuntimeParams["application_name"])
+	require.Equal(t, "billing", cfg.ConnConfig.RuntimeParams["application_name"])
+}
+
+func TestPoolConfigHooks(t *testing.T) {
+	var calls []string
+	cfg, err := pgxpool.ParseConfig("postgres://user@localhost/app")
+	require.NoError(t, err)
+	cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		calls = append(calls, "config before")
+		return nil
+	}
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		calls = append(calls, "config after")
+		return nil
+	}
+
+	a, err := newAdapter([]Option{
+		WithBeforeConnect(func(ctx context.Context, cc *pgx.ConnConfig) error {
+			calls = append(calls, "option before")
+			return nil
+		}),
+		WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+			calls = append(calls, "option after 1")
+			return nil
+		}),
+		WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+			calls = append(calls, "option after 2")
+			return errors.New("refused")
+		}),
+	})
+	require.NoError(t, err)
+
+	pc := a.poolConfig(cfg)
+	require.NoError(t, pc.BeforeConnect(context.Background(), pc.ConnConfig))
+	require.EqualError(t, pc.AfterConnect(context.Background(), nil), "refused")
+	require.Equal(t, []string{"config before", "option before", "config after", "option after 1", "option after 2"}, calls)
+
+	// the hooks of the caller's config are left alone
+	calls = nil
+	require.NoError(t, cfg.AfterConnect(context.Background(), nil))
+	require.Equal(t, []string{"config after"}, calls)
+}
+
+func (s *AdapterTestSuite) TestPoolSettings() {
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithMaxConns(7), WithMinConns(1), WithConnLifetime(time.Hour))
+	s.Require().NoError(err)
+	defer a.Close()
+
+	pool, ok := a.pool()
+	s.Require().True(ok)
+	s.Require().EqualValues(7, pool.Config().MaxConns)
+	s.Require().EqualValues(1, pool.Config().MinConns)
+	s.Require().Equal(time.Hour, pool.Config().MaxConnLifetime)
+}
+
+func TestMockPoolSettings(t *testing.T) {
+	cfg, err := pgxpool.ParseConfig("postgres://user@127.0.0.1:1/app?pool_max_conns=20&pool_min_conns=2")
+	require.NoError(t, err)
+
+	a, err := newAdapter([]Option{WithMaxConns(5), WithConnLifetime(time.Hour), WithConnIdleTime(time.Minute), WithHealthCheckPeriod(time.Second)})
+	require.NoError(t, err)
+	pool, err := a.openPool(context.Background(), cfg)
+	require.NoError(t, err)
+	defer pool.Close()
+	require.EqualValues(t, 5, pool.Config().MaxConns)
+	require.EqualValues(t, 2, pool.Config().MinConns)
+	require.Equal(t, time.Hour, pool.Config().MaxConnLifetime)
+	require.Equal(t, time.Minute, pool.Config().MaxConnIdleTime)
+	require.Equal(t, time.Second, pool.Config().HealthCheckPeriod)
+	require.EqualValues(t, 20, cfg.MaxConns)
+
+	_, err = newAdapter([]Option{WithMaxConns(2), WithMinConns(3)})
+	require.EqualError(t, err, "pgadapter.NewAdapter: min conns 3 is greater than max conns 2")
+	_, err = newAdapter([]Option{WithConnIdleTime(-time.Second)})
+	require.EqualError(t, err, "pgadapter.NewAdapter: pool settings can't be negative")
+
+	// a borrowed pool is not changed
+	var buf bytes.Buffer
+	newMockAdapter(t, WithMaxConns(5), WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 1)
+	require.Equal(t, "pgadapter: pool settings ignored for a pool passed to the adapter", entries[0]["msg"])
+	require.EqualValues(t, 5, entries[0]["max_conns"])
+}
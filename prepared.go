@@ -0,0 +1,86 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxGIDLength is the maximum length in bytes of the identifier of a prepared transaction
+const maxGIDLength = 199
+
+// PrepareTx is WithTx for the transactions coordinated by an external transaction manager with a two-phase commit,
+// e.g. to change the rules atomically with the writes to another database. When fn returns nil, the transaction is
+// prepared with PREPARE TRANSACTION and the identifier gid rather than committed: its changes are stored but not
+// visible, and its locks are held, until CommitPrepared or RollbackPrepared is called with gid, from any adapter
+// or session connected to the database. When fn returns an error or panics, the transaction is rolled back.
+//
+// The server must run with max_prepared_transactions set above 0. A prepared transaction is kept by the server
+// until it is committed or rolled back, even across restarts, so the transaction manager must always end it.
+// The watchers are not notified by the adapter, the caller notifies them once the transaction is committed.
+// PrepareTx returns an error with WithDryRun, a prepared transaction could be committed by any session.
+func (a *Adapter) PrepareTx(ctx context.Context, gid string, fn func(tx TxAdapter) error) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "PrepareTx"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	if a.dryRun {
+		return errors.New("pgadapter: PrepareTx can't be used with WithDryRun")
+	}
+	if err := validateGID(gid); err != nil {
+		return err
+	}
+	return a.runTx(ctx, fn, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, "PREPARE TRANSACTION "+quoteLiteral(gid))
+		return err
+	})
+}
+
+// CommitPrepared commits the transaction prepared by PrepareTx with gid,
+// it returns ErrPreparedTxNotFound when there is none, e.g. it was committed already.
+func (a *Adapter) CommitPrepared(ctx context.Context, gid string) (err error) {
+	return a.endPrepared(ctx, "CommitPrepared", "COMMIT PREPARED", gid)
+}
+
+// RollbackPrepared rolls back the transaction prepared by PrepareTx with gid,
+// it returns ErrPreparedTxNotFound when there is none, e.g. it was rolled back already.
+func (a *Adapter) RollbackPrepared(ctx context.Context, gid string) (err error) {
+	return a.endPrepared(ctx, "RollbackPrepared", "ROLLBACK PREPARED", gid)
+}
+
+// endPrepared runs statement, COMMIT PREPARED or ROLLBACK PREPARED, for the transaction prepared with gid
+func (a *Adapter) endPrepared(ctx context.Context, op, statement, gid string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: op})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	if err := validateGID(gid); err != nil {
+		return err
+	}
+	// the statement can't run in a transaction block, so it doesn't use the transaction of WithTx
+	_, err = a.db.Exec(ctx, statement+" "+quoteLiteral(gid))
+	if isPgError(err, codeUndefinedObject) {
+		return fmt.Errorf("%w: %q", ErrPreparedTxNotFound, gid)
+	}
+	return err
+}
+
+// validateGID checks that gid can identify a prepared transaction
+func validateGID(gid string) error {
+	if gid == "" || len(gid) > maxGIDLength || strings.ContainsRune(gid, 0) {
+		return fmt.Errorf("pgadapter: invalid prepared transaction id %q, it must have 1 to %d bytes", gid, maxGIDLength)
+	}
+	return nil
+}
+
+// quoteLiteral returns s as a string literal, for the statements taking no parameters, e.g. PREPARE TRANSACTION
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,95 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestPrepareTx() {
+	ctx := context.Background()
+	var maxPrepared int
+	s.Require().NoError(s.a.queryRow(ctx, `SELECT current_setting('max_prepared_transactions')::int`, nil, &maxPrepared))
+	if maxPrepared == 0 {
+		s.T().Skip("max_prepared_transactions is 0")
+	}
+
+	err := s.a.PrepareTx(ctx, "pgadapter-test-1", func(tx TxAdapter) error {
+		if err := tx.AddPolicies("p", "p", [][]string{{"carol", "data3", "read"}, {"dave", "data4", "read"}}); err != nil {
+			return err
+		}
+		return tx.RemovePolicies("p", "p", [][]string{{"alice", "data1", "read"}})
+	})
+	s.Require().NoError(err)
+	// the changes are not visible until the transaction is committed
+	s.Require().NoError(s.e.LoadPolicy())
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+		s.e.GetPolicy())
+	s.Require().NoError(s.a.CommitPrepared(ctx, "pgadapter-test-1"))
+	s.Require().NoError(s.e.LoadPolicy())
+	s.assertPolicy([][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"},
+		{"carol", "data3", "read"}, {"dave", "data4", "read"}}, s.e.GetPolicy())
+	s.Require().ErrorIs(s.a.CommitPrepared(ctx, "pgadapter-test-1"), ErrPreparedTxNotFound)
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	m.AddPolicy("p", "p", []string{"erin", "data5", "read"})
+	s.Require().NoError(s.a.PrepareTx(ctx, "pgadapter-test-2", func(tx TxAdapter) error {
+		return tx.SavePolicy(m)
+	}))
+	s.Require().NoError(s.a.RollbackPrepared(ctx, "pgadapter-test-2"))
+	count, err := s.a.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Require().EqualValues(6, count)
+}
+
+func TestMockPrepareTx(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+	alice := []string{"alice", "data1", "read"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("SAVEPOINT", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(insertArgs(alice)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("RELEASE SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("RELEASE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`PREPARE TRANSACTION 'order-42''s rules'`)).WillReturnResult(pgxmock.NewResult("PREPARE TRANSACTION", 0))
+	mock.ExpectRollback()
+	err := a.PrepareTx(ctx, "order-42's rules", func(tx TxAdapter) error {
+		return tx.AddPolicies("p", "p", [][]string{alice})
+	})
+	require.NoError(t, err)
+
+	// the transaction is rolled back when fn fails, nothing is prepared
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	err = a.PrepareTx(ctx, "order-43", func(tx TxAdapter) error {
+		return errors.New("boom")
+	})
+	require.ErrorContains(t, err, "boom")
+
+	mock.ExpectExec(regexp.QuoteMeta(`COMMIT PREPARED 'order-42''s rules'`)).WillReturnResult(pgxmock.NewResult("COMMIT PREPARED", 0))
+	require.NoError(t, a.CommitPrepared(ctx, "order-42's rules"))
+	mock.ExpectExec(regexp.QuoteMeta(`ROLLBACK PREPARED 'order-43'`)).
+		WillReturnError(&pgconn.PgError{Code: "42704", Message: `prepared transaction with identifier "order-43" does not exist`})
+	require.ErrorIs(t, a.RollbackPrepared(ctx, "order-43"), ErrPreparedTxNotFound)
+
+	require.Error(t, a.PrepareTx(ctx, "", func(tx TxAdapter) error { return nil }))
+	require.Error(t, a.CommitPrepared(ctx, string(make([]byte, 200))))
+}
+
+func TestMockPrepareTxDryRun(t *testing.T) {
+	a, _ := newMockAdapter(t, WithDryRun())
+
+	// nothing is prepared, the dry run could be committed with COMMIT PREPARED
+	err := a.PrepareTx(context.Background(), "order-42", func(tx TxAdapter) error {
+		return tx.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}})
+	})
+	require.ErrorContains(t, err, "PrepareTx can't be used with WithDryRun")
+}
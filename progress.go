@@ -0,0 +1,88 @@
+package pgxadapter
+
+import (
+	"time"
+)
+
+// The defaults of WithProgress
+const (
+	DefaultProgressRows     = 10000
+	DefaultProgressInterval = time.Second
+)
+
+// ProgressFunc is called during a long operation op with the number of rules, or migration steps for Migrate,
+// done so far out of total, -1 when the total is not known
+type ProgressFunc func(op string, done, total int64)
+
+// WithProgress makes LoadPolicy, SavePolicy, ImportFromFileAdapter and Migrate call fn as they progress,
+// every time every more rules are done or interval elapsed since the last call, whichever comes first.
+// A zero every or interval uses DefaultProgressRows or DefaultProgressInterval.
+// The total of LoadPolicy is only known when the rules are counted anyway, see WithMaxLoadRules.
+// When the operation succeeds, fn is called a last time with done equal to total.
+// The calls of an operation are made from its goroutine, or serialized for a parallel load, and never concurrently.
+func WithProgress(fn ProgressFunc, every int64, interval time.Duration) Option {
+	return func(a *Adapter) {
+		a.progressFn = fn
+		a.progressRows = every
+		a.progressInterval = interval
+	}
+}
+
+// progress calls the ProgressFunc of an operation, a nil progress does nothing
+type progress struct {
+	fn       ProgressFunc
+	op       string
+	total    int64
+	every    int64
+	interval time.Duration
+
+	done     int64
+	reported int64
+	calls    int64
+	last     time.Time
+}
+
+// newProgress returns the progress of the operation op of total rules, nil without WithProgress
+func (a *Adapter) newProgress(op string, total int64) *progress {
+	if a.progressFn == nil {
+		return nil
+	}
+	p := &progress{fn: a.progressFn, op: op, total: total, every: a.progressRows, interval: a.progressInterval, last: time.Now()}
+	if p.every <= 0 {
+		p.every = DefaultProgressRows
+	}
+	if p.interval <= 0 {
+		p.interval = DefaultProgressInterval
+	}
+	return p
+}
+
+// add records n more rules done, and calls the ProgressFunc when it is time to.
+// The clock is only read every 64 calls to keep the cost of a call low.
+func (p *progress) add(n int64) {
+	if p == nil {
+		return
+	}
+	p.done += n
+	p.calls++
+	if p.done-p.reported >= p.every || (p.calls&63 == 0 && time.Since(p.last) >= p.interval) {
+		p.report(p.total)
+	}
+}
+
+// end calls the ProgressFunc a last time with the total, which is the rules done if it was not known
+func (p *progress) end() {
+	if p == nil {
+		return
+	}
+	if p.total < 0 {
+		p.total = p.done
+	}
+	p.report(p.total)
+}
+
+func (p *progress) report(total int64) {
+	p.reported = p.done
+	p.last = time.Now()
+	p.fn(p.op, p.done, total)
+}
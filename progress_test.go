@@ -0,0 +1,120 @@
+package pgxadapter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type progressCall struct {
+	op          string
+	done, total int64
+}
+
+func TestMockProgress(t *testing.T) {
+	var calls []progressCall
+	a, mock := newMockAdapter(t, WithProgress(func(op string, done, total int64) {
+		calls = append(calls, progressCall{op, done, total})
+	}, 1000, time.Hour))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+	rows := pgxmock.NewRows(cols)
+	for i := 0; i < 2500; i++ {
+		rows.AddRow(fmt.Sprint(i), "p", fmt.Sprintf("user%d", i), "data1", "read", nil, nil, nil)
+	}
+	mock.ExpectQuery("SELECT").WillReturnRows(rows)
+	require.NoError(t, a.LoadPolicy(m))
+	require.Equal(t, []progressCall{
+		{"LoadPolicy", 1000, -1},
+		{"LoadPolicy", 2000, -1},
+		{"LoadPolicy", 2500, 2500},
+	}, calls)
+
+	// the total is known from the count of WithMaxLoadRules
+	calls = nil
+	b, mock := newMockAdapter(t, WithMaxLoadRules(5000), WithProgress(func(op string, done, total int64) {
+		calls = append(calls, progressCall{op, done, total})
+	}, 0, 0))
+	m.ClearPolicy()
+	mock.ExpectQuery("SELECT count").WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(2)))
+	mock.ExpectQuery("SELECT").WillReturnRows(pgxmock.NewRows(cols).
+		AddRow("1", "p", "alice", "data1", "read", nil, nil, nil).
+		AddRow("2", "p", "bob", "data2", "write", nil, nil, nil))
+	require.NoError(t, b.LoadPolicy(m))
+	require.Equal(t, []progressCall{{"LoadPolicy", 2, 2}}, calls)
+
+	// a failed load doesn't report its end
+	calls = nil
+	mock.ExpectQuery("SELECT count").WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	mock.ExpectQuery("SELECT").WillReturnError(fmt.Errorf("connection reset"))
+	require.Error(t, b.LoadPolicy(m))
+	require.Empty(t, calls)
+
+	calls = nil
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE").WillReturnResult(pgxmock.NewResult("DELETE", 2))
+	mock.ExpectExec("INSERT").WithArgs(insertArgs([]string{"alice", "data1", "read"})...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("INSERT").WithArgs(insertArgs([]string{"bob", "data2", "write"})...).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, b.SavePolicy(m))
+	require.Equal(t, []progressCall{{"SavePolicy", 2, 2}}, calls)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProgressInterval(t *testing.T) {
+	var calls []progressCall
+	a := &Adapter{progressFn: func(op string, done, total int64) {
+		calls = append(calls, progressCall{op, done, total})
+	}, progressInterval: time.Nanosecond}
+	p := a.newProgress("LoadPolicy", 200)
+	for i := 0; i < 200; i++ {
+		p.add(1)
+	}
+	p.end()
+	// the clock is read every 64 rules
+	require.Equal(t, []progressCall{
+		{"LoadPolicy", 64, 200},
+		{"LoadPolicy", 128, 200},
+		{"LoadPolicy", 192, 200},
+		{"LoadPolicy", 200, 200},
+	}, calls)
+
+	var none *progress
+	none.add(1)
+	none.end()
+	require.Nil(t, (&Adapter{}).newProgress("LoadPolicy", 1))
+}
+
+func BenchmarkLoadPolicyProgress(b *testing.B) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(b, err)
+	var reported int64
+	a, err := NewAdapterByPgxPool(mock, SkipTableCreate(), SkipSchemaVerification(),
+		WithProgress(func(op string, done, total int64) { reported = done }, 0, 0))
+	require.NoError(b, err)
+
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+	values := make([][]any, 10000)
+	for i := range values {
+		values[i] = []any{fmt.Sprint(i), "p", fmt.Sprintf("user%d", i), fmt.Sprintf("data%d", i%100), "read", nil, nil, nil}
+	}
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		m.ClearPolicy()
+		mock.ExpectQuery("SELECT").WillReturnRows(pgxmock.NewRows(cols).AddRows(values...))
+		b.StartTimer()
+		require.NoError(b, a.LoadPolicy(m))
+	}
+	require.EqualValues(b, len(values), reported)
+}
@@ -0,0 +1,113 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// WithRowQuota limits the number of rules each namespace can store to limit, e.g. the policies included in the plan
+// of a tenant with WithTenantFromContext. Without namespace the limit applies to the whole table.
+// AddPolicy and AddPolicies count the stored rules in their transaction, holding an advisory lock of the namespace
+// until it commits, so concurrent writes can't together exceed the quota. A write adding more rules than the quota
+// has left stores none of them and fails with a *QuotaExceededError, unless WithPartialQuota is used.
+// The rules already stored don't count. The other writes, such as SavePolicy or ImportFromFileAdapter, are not limited.
+func WithRowQuota(limit int64) Option {
+	return func(a *Adapter) {
+		a.rowQuota = limit
+	}
+}
+
+// WithPartialQuota makes AddPolicies store the first rules of a batch which fit in the quota of WithRowQuota
+// rather than none, the error then gives the number of rules stored in its Admitted field.
+func WithPartialQuota() Option {
+	return func(a *Adapter) {
+		a.partialQuota = true
+	}
+}
+
+// QuotaExceededError is returned by AddPolicy and AddPolicies when the rules to add exceed the quota of WithRowQuota.
+// It matches ErrQuotaExceeded with errors.Is.
+type QuotaExceededError struct {
+	Namespace string
+	// Current is the number of rules stored in the namespace before the write
+	Current int64
+	// Requested is the number of rules the write would add
+	Requested int64
+	// Admitted is the number of rules stored anyway with WithPartialQuota
+	Admitted int64
+	Limit    int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	msg := fmt.Sprintf("quota of %d rules of namespace %q exceeded: %d stored, %d to add", e.Limit, e.Namespace, e.Current, e.Requested)
+	if e.Admitted > 0 {
+		msg += fmt.Sprintf(", %d added", e.Admitted)
+	}
+	return msg
+}
+
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// admitLines returns the lines which fit in the quota of the namespace of the operation of ctx, the rules already
+// stored always fit. It locks the namespace for the rest of tx. Without WithPartialQuota, the lines are all admitted
+// or the *QuotaExceededError is returned, otherwise the error is returned with the admitted lines.
+func (a *Adapter) admitLines(ctx context.Context, tx pgx.Tx, lines []*CasbinRule) ([]*CasbinRule, *QuotaExceededError, error) {
+	if a.rowQuota == 0 {
+		return lines, nil, nil
+	}
+	ns := a.namespaceOf(ctx)
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, advisoryLockKey(a.table(ctx)+"/quota/"+ns)); err != nil {
+		return nil, nil, err
+	}
+	var current int64
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT count(*) FROM "%v"`, a.table(ctx)), nil, true)
+	if err := queryRowWith(ctx, tx, sql, args, &current); err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]string, len(lines))
+	for i, line := range lines {
+		ids[i] = line.ID
+	}
+	sql, args = a.byID(ctx, fmt.Sprintf(`SELECT id FROM "%v" WHERE id = ANY($1)`, a.table(ctx)), []any{ids})
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	stored, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := make(map[string]bool, len(lines))
+	for _, id := range stored {
+		seen[id] = true
+	}
+
+	left := max(a.rowQuota-current, 0)
+	var requested int64
+	admitted := make([]*CasbinRule, 0, len(lines))
+	for _, line := range lines {
+		if seen[line.ID] {
+			admitted = append(admitted, line)
+			continue
+		}
+		seen[line.ID] = true
+		requested++
+		if requested <= left {
+			admitted = append(admitted, line)
+		}
+	}
+	if requested <= left {
+		return lines, nil, nil
+	}
+	quotaErr := &QuotaExceededError{Namespace: ns, Current: current, Requested: requested, Limit: a.rowQuota}
+	if !a.partialQuota {
+		return nil, nil, quotaErr
+	}
+	quotaErr.Admitted = left
+	return admitted, quotaErr, nil
+}
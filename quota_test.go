@@ -0,0 +1,115 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestRowQuota() {
+	ctx := context.Background()
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("casbin_rules_quota"), WithNamespace("tenant1"), WithRowQuota(5))
+	s.Require().NoError(err)
+	defer a.Close()
+	defer a.db.Exec(ctx, `DROP TABLE IF EXISTS "casbin_rules_quota"`)
+
+	// two batches racing for the quota can't both be stored
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var rules [][]string
+			for j := 0; j < 3; j++ {
+				rules = append(rules, []string{fmt.Sprintf("user%d", i), fmt.Sprintf("data%d", j), "read"})
+			}
+			errs[i] = a.AddPolicies("p", "p", rules)
+		}(i)
+	}
+	wg.Wait()
+	if errs[0] == nil {
+		s.Require().ErrorIs(errs[1], ErrQuotaExceeded)
+	} else {
+		s.Require().ErrorIs(errs[0], ErrQuotaExceeded)
+		s.Require().NoError(errs[1])
+	}
+	count, err := a.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Require().EqualValues(3, count)
+
+	// stored rules don't count
+	s.Require().NoError(a.AddPolicies("p", "p", [][]string{{"user0", "data0", "read"}, {"user1", "data0", "read"}}))
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"user1", "data0", "write"}))
+	var quotaErr *QuotaExceededError
+	s.Require().ErrorAs(a.AddPolicy("p", "p", []string{"user1", "data0", "delete"}), &quotaErr)
+	s.Require().Equal(QuotaExceededError{Namespace: "tenant1", Current: 5, Requested: 1, Limit: 5}, *quotaErr)
+
+	// other namespaces have their own quota
+	b, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("casbin_rules_quota"), WithNamespace("tenant2"), WithRowQuota(5))
+	s.Require().NoError(err)
+	defer b.Close()
+	s.Require().NoError(b.AddPolicy("p", "p", []string{"user1", "data0", "delete"}))
+}
+
+func TestMockRowQuota(t *testing.T) {
+	rules := [][]string{{"alice", "data1", "read"}, {"bob", "data1", "read"}, {"carol", "data1", "read"}}
+	expectQuota := func(mock pgxmock.PgxPoolIface, current int64, stored ...string) {
+		mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_xact_lock($1)`)).
+			WithArgs(advisoryLockKey("casbin_rules/quota/tenant1")).
+			WillReturnResult(pgxmock.NewResult("SELECT", 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules" WHERE namespace = $1`)).
+			WithArgs("tenant1").
+			WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(current))
+		rows := pgxmock.NewRows([]string{"id"})
+		for _, id := range stored {
+			rows.AddRow(id)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id FROM "casbin_rules" WHERE id = ANY($1)`)).WillReturnRows(rows)
+	}
+
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"), WithRowQuota(4))
+	ids := make([]string, len(rules))
+	for i, rule := range rules {
+		ids[i] = a.policyLine(context.Background(), "p", rule).ID
+	}
+
+	// the batch would exceed the quota, nothing is stored
+	mock.ExpectBegin()
+	expectQuota(mock, 2)
+	mock.ExpectRollback()
+	var quotaErr *QuotaExceededError
+	err := a.AddPolicies("p", "p", rules)
+	require.ErrorAs(t, err, &quotaErr)
+	require.Equal(t, QuotaExceededError{Namespace: "tenant1", Current: 2, Requested: 3, Limit: 4}, *quotaErr)
+	require.ErrorContains(t, err, `quota of 4 rules of namespace "tenant1" exceeded: 2 stored, 3 to add`)
+
+	// a stored rule doesn't count
+	mock.ExpectBegin()
+	expectQuota(mock, 2, ids[1])
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicies("p", "p", rules))
+
+	// the rules fitting in the quota are stored
+	b, mock := newMockAdapter(t, WithNamespace("tenant1"), WithRowQuota(4), WithPartialQuota())
+	mock.ExpectBegin()
+	expectQuota(mock, 3)
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules" (id, ptype, v0, v1, v2, v3, v4, v5, namespace) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT DO NOTHING`)).
+		WithArgs(b.insertArgs(context.Background(), b.policyLine(context.Background(), "p", rules[0]))...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	err = b.AddPolicies("p", "p", rules)
+	require.ErrorAs(t, err, &quotaErr)
+	require.EqualValues(t, 1, quotaErr.Admitted)
+	require.ErrorContains(t, err, "3 stored, 3 to add, 1 added")
+
+	_, err = newAdapter([]Option{WithRowQuota(-1)})
+	require.EqualError(t, err, "pgadapter.NewAdapter: row quota can't be negative, got -1")
+}
@@ -0,0 +1,11 @@
+package pgxadapter
+
+// WithReadOnlyReconnect resets the pool created by NewAdapter when a write fails with ErrReadOnlyDatabase,
+// closing its connections so the next operation connects again and resolves the host name again,
+// e.g. to reach the new primary after a failover.
+// The failed operation is not retried. The option has no effect on pools passed to NewAdapterByDB or NewAdapterByPgxPool.
+func WithReadOnlyReconnect() Option {
+	return func(a *Adapter) {
+		a.readOnlyReconnect = true
+	}
+}
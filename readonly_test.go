@@ -0,0 +1,50 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestReadOnlyDatabase() {
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), SkipTableCreate(), WithReadOnlyReconnect(),
+		WithAfterConnect(func(ctx context.Context, conn *pgx.Conn) error {
+			_, err := conn.Exec(ctx, "SET default_transaction_read_only = on")
+			return err
+		}),
+	)
+	s.Require().NoError(err)
+	defer a.Close()
+
+	err = a.AddPolicy("p", "p", []string{"alice", "data3", "read"})
+	s.Require().ErrorIs(err, ErrReadOnlyDatabase)
+	var pgErr *pgconn.PgError
+	s.Require().ErrorAs(err, &pgErr)
+	s.Require().Equal(codeReadOnlyTransaction, pgErr.Code)
+}
+
+func TestMockReadOnlyDatabase(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Code: codeReadOnlyTransaction, Message: "cannot execute INSERT in a read-only transaction"})
+	mock.ExpectRollback()
+	err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	require.ErrorIs(t, err, ErrReadOnlyDatabase)
+	require.ErrorContains(t, err, "cannot execute INSERT in a read-only transaction")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules"`)).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+	err = a.RemovePolicy("p", "p", []string{"alice", "data1", "read"})
+	require.EqualError(t, err, `pgadapter.RemovePolicy: table "casbin_rules": rule "p, alice, data1, read": connection reset`)
+	require.NotErrorIs(t, err, ErrReadOnlyDatabase)
+}
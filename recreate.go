@@ -0,0 +1,44 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// WithAutoRecreateTable makes the operations failing because the rules table doesn't exist,
+// e.g. after the database was wiped while the adapter runs, create the table again like NewAdapter does
+// and retry once. It applies to the methods of the casbin adapter interfaces, LoadPolicyPage, ForEachRule,
+// CountRules and CountRulesByPtype, and has no effect with SkipTableCreate.
+// Every recreation is logged as a warning with the logger of WithLogger.
+func WithAutoRecreateTable() Option {
+	return func(a *Adapter) {
+		a.autoRecreate = true
+	}
+}
+
+type retryKey struct{}
+
+// retryMissingTable runs fn, and runs it again after creating the rules table if it failed
+// because the table doesn't exist and WithAutoRecreateTable is used.
+// fn is retried once, and not at all when it runs within an operation already retrying.
+func (a *Adapter) retryMissingTable(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !a.autoRecreate || a.skipTableCreate || ctx.Value(retryKey{}) != nil {
+		return fn(ctx)
+	}
+	ctx = context.WithValue(ctx, retryKey{}, true)
+	err := fn(ctx)
+	if !isPgError(err, codeUndefinedTable) {
+		return err
+	}
+
+	table := a.table(ctx)
+	if cerr := a.createTableifNotExists(ctx); cerr != nil {
+		a.logger.LogAttrs(ctx, slog.LevelError, "pgadapter: rules table does not exist and can't be created again",
+			slog.String("op", opFrom(ctx)), slog.String("table", table), slog.Any("error", cerr))
+		return fmt.Errorf("%w, creating it again failed: %v", err, cerr)
+	}
+	a.logger.LogAttrs(ctx, slog.LevelWarn, "pgadapter: rules table does not exist, created it again",
+		slog.String("op", opFrom(ctx)), slog.String("table", table), slog.Any("error", err))
+	return fn(ctx)
+}
@@ -0,0 +1,112 @@
+package pgxadapter
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestAutoRecreateTable() {
+	ctx := context.Background()
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithAutoRecreateTable())
+	s.Require().NoError(err)
+	defer a.Close()
+
+	_, err = s.a.db.Exec(ctx, `DROP TABLE casbin_rules`)
+	s.Require().NoError(err)
+
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+	count, err := a.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Require().EqualValues(1, count)
+
+	// the adapter without the option keeps failing
+	_, err = s.a.db.Exec(ctx, `DROP TABLE casbin_rules`)
+	s.Require().NoError(err)
+	s.Require().ErrorIs(s.a.AddPolicy("p", "p", []string{"alice", "data1", "read"}), ErrTableNotExist)
+}
+
+// newRecreatingMockAdapter returns a mock adapter using WithAutoRecreateTable, logging to buf
+func newRecreatingMockAdapter(t *testing.T, buf *bytes.Buffer) (*Adapter, pgxmock.PgxPoolIface) {
+	t.Helper()
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	a, err := NewAdapterByPgxPool(mock, WithAutoRecreateTable(), SkipSchemaVerification(),
+		WithLogger(slog.New(slog.NewJSONHandler(buf, nil))))
+	require.NoError(t, err)
+	return a, mock
+}
+
+func TestMockAutoRecreateTable(t *testing.T) {
+	var buf bytes.Buffer
+	a, mock := newRecreatingMockAdapter(t, &buf)
+	rule := []string{"alice", "data1", "read"}
+	missing := &pgconn.PgError{Severity: "ERROR", Code: codeUndefinedTable, Message: `relation "casbin_rules" does not exist`}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WillReturnError(missing)
+	mock.ExpectRollback()
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(insertArgs(rule)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", rule))
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 1)
+	require.Equal(t, "pgadapter: rules table does not exist, created it again", entries[0]["msg"])
+	require.Equal(t, "WARN", entries[0]["level"])
+	require.Equal(t, "AddPolicy", entries[0]["op"])
+	require.Equal(t, DefaultTableName, entries[0]["table"])
+
+	// the operation is retried once
+	buf.Reset()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).WillReturnError(missing)
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).WillReturnError(missing)
+	_, err := a.CountRules(context.Background(), "")
+	require.ErrorIs(t, err, ErrTableNotExist)
+	require.Len(t, logEntries(t, &buf), 1)
+}
+
+func TestMockAutoRecreateTableFails(t *testing.T) {
+	var buf bytes.Buffer
+	a, mock := newRecreatingMockAdapter(t, &buf)
+	missing := &pgconn.PgError{Severity: "ERROR", Code: codeUndefinedTable, Message: `relation "casbin_rules" does not exist`}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT ptype, count(*) FROM "casbin_rules"`)).WillReturnError(missing)
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Severity: "ERROR", Code: "42501", Message: "permission denied for schema public"})
+	_, err := a.CountRulesByPtype(context.Background())
+	require.ErrorIs(t, err, ErrTableNotExist)
+	require.ErrorContains(t, err, "creating it again failed: ERROR: permission denied for schema public")
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 1)
+	require.Equal(t, "ERROR", entries[0]["level"])
+}
+
+func TestMockAutoRecreateTableSkipTableCreate(t *testing.T) {
+	a, mock := newMockAdapter(t, WithAutoRecreateTable())
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillReturnError(&pgconn.PgError{Code: codeUndefinedTable})
+	_, err := a.CountRules(context.Background(), "")
+	require.ErrorIs(t, err, ErrTableNotExist)
+}
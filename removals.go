@@ -0,0 +1,100 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+)
+
+// FilteredRemoval is a filter of RemoveFilteredPolicies, it selects the rules like the arguments of RemoveFilteredPolicy
+type FilteredRemoval struct {
+	Ptype       string
+	FieldIndex  int
+	FieldValues []string
+}
+
+// RemoveFilteredPolicies removes the rules matching each filter in a single transaction, e.g. the p, g and g2 rules
+// of a domain when a tenant is deleted: either every filter is applied or none is.
+// The filters are checked before any rule is removed.
+func (a *Adapter) RemoveFilteredPolicies(ctx context.Context, removals []FilteredRemoval) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RemoveFilteredPolicies"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	_, err = a.removeFiltered(ctx, removals, false)
+	return err
+}
+
+// RemoveFilteredPoliciesReturning is RemoveFilteredPolicies returning the rules removed by each filter,
+// removed[i] holds the values of the rules removed by removals[i]
+func (a *Adapter) RemoveFilteredPoliciesReturning(ctx context.Context, removals []FilteredRemoval) (removed [][][]string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RemoveFilteredPolicies"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	return a.removeFiltered(ctx, removals, true)
+}
+
+// removeFiltered runs a DELETE per filter in a transaction, returning the removed rules by filter if returning is set
+func (a *Adapter) removeFiltered(ctx context.Context, removals []FilteredRemoval, returning bool) (removed [][][]string, err error) {
+	for i, r := range removals {
+		if err := a.checkFieldValues(r.FieldIndex, r.FieldValues); err != nil {
+			return nil, fmt.Errorf("filter %d of ptype %q: %w", i, r.Ptype, err)
+		}
+	}
+	if len(removals) == 0 {
+		return nil, nil
+	}
+
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		removed = nil
+		tx, err := a.conn(ctx).Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		for _, r := range removals {
+			sql, args := a.removeFilteredQuery(ctx, r.Ptype, r.FieldIndex, r.FieldValues)
+			if !returning {
+				if _, err := tx.Exec(ctx, sql, args...); err != nil {
+					return err
+				}
+				continue
+			}
+			rules, err := a.removeReturning(ctx, tx, sql+" RETURNING "+a.columns(), args)
+			if err != nil {
+				return err
+			}
+			removed = append(removed, rules)
+		}
+		return tx.Commit(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// removeReturning runs the DELETE statement sql returning the columns of the rules, and returns the removed rules
+func (a *Adapter) removeReturning(ctx context.Context, q querier, sql string, args []any) ([][]string, error) {
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := [][]string{}
+	scanner := a.newRuleScanner()
+	for rows.Next() {
+		line, err := scanner.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, line.rule(a.valueColumns))
+	}
+	return rules, rows.Err()
+}
@@ -0,0 +1,89 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestRemoveFilteredPolicies() {
+	ctx := context.Background()
+	a, err := NewAdapterByDB(s.a.db.(*pgxpool.Pool), SkipTableCreate())
+	s.Require().NoError(err)
+
+	// the second filter is invalid, the rules of the first one are kept
+	err = a.RemoveFilteredPolicies(ctx, []FilteredRemoval{
+		{Ptype: "p", FieldIndex: 1, FieldValues: []string{"data2"}},
+		{Ptype: "g", FieldIndex: -1, FieldValues: []string{"alice"}},
+	})
+	s.Require().ErrorIs(err, ErrInvalidFilter)
+	s.Require().NoError(s.e.LoadPolicy())
+	s.assertPolicy(
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+		s.e.GetPolicy(),
+	)
+
+	removed, err := a.RemoveFilteredPoliciesReturning(ctx, []FilteredRemoval{
+		{Ptype: "p", FieldIndex: 0, FieldValues: []string{"data2_admin"}},
+		{Ptype: "g", FieldIndex: 1, FieldValues: []string{"data2_admin"}},
+		{Ptype: "g2", FieldIndex: 0, FieldValues: []string{"nobody"}},
+	})
+	s.Require().NoError(err)
+	s.Assert().ElementsMatch([][]string{{"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}}, removed[0])
+	s.Assert().Equal([][]string{{"alice", "data2_admin"}}, removed[1])
+	s.Assert().Empty(removed[2])
+
+	s.Require().NoError(s.e.LoadPolicy())
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}, s.e.GetPolicy())
+	s.assertPolicy([][]string{}, s.e.GetGroupingPolicy())
+}
+
+func TestMockRemoveFilteredPolicies(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+
+	// no statement runs when a filter is invalid
+	err := a.RemoveFilteredPolicies(ctx, []FilteredRemoval{
+		{Ptype: "p", FieldIndex: 1, FieldValues: []string{"domain1"}},
+		{Ptype: "g", FieldIndex: 6, FieldValues: []string{"domain1"}},
+	})
+	require.ErrorIs(t, err, ErrInvalidFilter)
+	require.ErrorContains(t, err, `filter 1 of ptype "g"`)
+
+	// a failing DELETE rolls back the previous ones
+	errReset := errors.New("connection reset")
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v1 = $2`)).
+		WithArgs("p", "domain1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 2))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v2 = $2`)).
+		WithArgs("g", "domain1").
+		WillReturnError(errReset)
+	mock.ExpectRollback()
+	err = a.RemoveFilteredPolicies(ctx, []FilteredRemoval{
+		{Ptype: "p", FieldIndex: 1, FieldValues: []string{"domain1"}},
+		{Ptype: "g", FieldIndex: 2, FieldValues: []string{"domain1"}},
+	})
+	require.ErrorIs(t, err, errReset)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v1 = $2 RETURNING id, ptype, v0, v1, v2, v3, v4, v5`)).
+		WithArgs("p", "domain1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", "alice", "domain1", "data1", "read", "", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v2 = $2 RETURNING`)).
+		WithArgs("g", "domain1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	mock.ExpectCommit()
+	removed, err := a.RemoveFilteredPoliciesReturning(ctx, []FilteredRemoval{
+		{Ptype: "p", FieldIndex: 1, FieldValues: []string{"domain1"}},
+		{Ptype: "g", FieldIndex: 2, FieldValues: []string{"domain1"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][][]string{{{"alice", "domain1", "data1", "read"}}, {}}, removed)
+}
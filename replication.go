@@ -0,0 +1,439 @@
+package pgxadapter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultReplicationPollInterval is the delay between the reads of the replication slot unless WithPollInterval is given
+const DefaultReplicationPollInterval = time.Second
+
+// replicationPlugin is the output plugin of the slots read by ReplicationWatcher
+const replicationPlugin = "wal2json"
+
+// replicationBatch is the number of changes read from the slot at once, whole transactions are always read
+const replicationBatch = 1000
+
+// Postgres error codes of the replication slot functions
+const (
+	codeDuplicateObject = "42710"
+	codeUndefinedObject = "42704"
+)
+
+// RuleChangeKind is the kind of a RuleChange
+type RuleChangeKind int
+
+const (
+	// RuleInserted is a rule added to the table
+	RuleInserted RuleChangeKind = iota
+	// RuleUpdated is a rule replaced by another one, e.g. by UpdatePolicy
+	RuleUpdated
+	// RuleDeleted is a rule removed from the table
+	RuleDeleted
+	// RulesTruncated is the table being truncated, every rule was removed
+	RulesTruncated
+)
+
+func (k RuleChangeKind) String() string {
+	switch k {
+	case RuleInserted:
+		return "inserted"
+	case RuleUpdated:
+		return "updated"
+	case RuleDeleted:
+		return "deleted"
+	case RulesTruncated:
+		return "truncated"
+	}
+	return fmt.Sprintf("RuleChangeKind(%d)", int(k))
+}
+
+// RuleChange is a change of the rules table decoded by ReplicationWatcher
+type RuleChange struct {
+	Kind RuleChangeKind
+	// Ptype and Rule are the rule inserted, the new rule of an update or the rule deleted,
+	// they are empty for RulesTruncated
+	Ptype string
+	Rule  []string
+	// OldPtype and OldRule are the rule replaced by an update
+	OldPtype string
+	OldRule  []string
+}
+
+// ReplicationWatcherOption configures a ReplicationWatcher
+type ReplicationWatcherOption func(w *ReplicationWatcher)
+
+// WithPollInterval sets the delay between the reads of the replication slot
+func WithPollInterval(interval time.Duration) ReplicationWatcherOption {
+	return func(w *ReplicationWatcher) {
+		w.interval = interval
+	}
+}
+
+// WithRuleChanges sets a function called with the changes decoded from the replication slot, before the update callback,
+// so the enforcer can apply them instead of loading the whole policy again:
+//
+//	w, err := a.NewReplicationWatcher(ctx, "casbin", pgxadapter.WithRuleChanges(func(changes []pgxadapter.RuleChange) {
+//		for _, c := range changes {
+//			switch c.Kind {
+//			case pgxadapter.RuleInserted:
+//				e.SelfAddPolicy("p", c.Ptype, c.Rule)
+//			...
+//			}
+//		}
+//	}))
+func WithRuleChanges(fn func([]RuleChange)) ReplicationWatcherOption {
+	return func(w *ReplicationWatcher) {
+		w.changes = fn
+	}
+}
+
+// WithReplicationErrors sets a function called when reading the replication slot fails, the slot is read again after the poll interval
+func WithReplicationErrors(fn func(error)) ReplicationWatcherOption {
+	return func(w *ReplicationWatcher) {
+		w.errors = fn
+	}
+}
+
+// ReplicationWatcher is a casbin watcher reading the changes of the rules table from a logical replication slot
+// decoded by the wal2json plugin, so every change is seen whichever client made it, including SQL run outside of casbin.
+// The update callback is called with the LSN of the last change read as payload.
+//
+// The changes are read with pg_logical_slot_peek_changes through the adapter pool, which works behind PgBouncer,
+// and the slot is advanced once the callbacks returned. The slot keeps its position when the watcher stops,
+// the next watcher reading it resumes after the last change confirmed, so a change is seen at least once.
+// The server keeps the WAL the slot didn't confirm, a slot not read anymore must be dropped with DropReplicationSlot.
+type ReplicationWatcher struct {
+	db        PgxPool
+	slot      string
+	table     string
+	namespace string
+	// namespaced is set when the changes are filtered by the namespace of the adapter
+	namespaced bool
+	interval   time.Duration
+	changes    func([]RuleChange)
+	errors     func(error)
+
+	mu       sync.Mutex
+	callback func(string)
+	closed   bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ persist.Watcher = (*ReplicationWatcher)(nil)
+
+// CreateReplicationSlot creates the logical replication slot read by NewReplicationWatcher, using the wal2json plugin,
+// and sets the replica identity of the rules table to full so the deleted and replaced rules are decoded too.
+// Nothing is done when the slot already exists. The server must run with wal_level set to logical and have wal2json installed.
+// It returns an error with WithDryRun.
+func (a *Adapter) CreateReplicationSlot(ctx context.Context, slot string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "CreateReplicationSlot"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	if a.dryRun {
+		return errors.New("pgadapter: CreateReplicationSlot can't be used with WithDryRun")
+	}
+	if a.temporary {
+		return errors.New("pgadapter: the changes of temporary tables are not replicated")
+	}
+	if err := a.checkWalLevel(ctx); err != nil {
+		return err
+	}
+	if _, err := a.db.Exec(ctx, fmt.Sprintf(`ALTER TABLE "%v" REPLICA IDENTITY FULL`, a.table(ctx))); err != nil {
+		return err
+	}
+	_, err = a.db.Exec(ctx, `SELECT pg_create_logical_replication_slot($1, $2)`, slot, replicationPlugin)
+	if isPgError(err, codeDuplicateObject) {
+		return nil
+	}
+	return err
+}
+
+// DropReplicationSlot drops the replication slot created by CreateReplicationSlot, nothing is done when it doesn't exist.
+// It fails while a watcher reads the slot, and returns an error with WithDryRun.
+func (a *Adapter) DropReplicationSlot(ctx context.Context, slot string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "DropReplicationSlot"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	if a.dryRun {
+		return errors.New("pgadapter: DropReplicationSlot can't be used with WithDryRun")
+	}
+	_, err = a.db.Exec(ctx, `SELECT pg_drop_replication_slot($1)`, slot)
+	if isPgError(err, codeUndefinedObject) {
+		return nil
+	}
+	return err
+}
+
+// checkWalLevel returns ErrWalLevelNotLogical when the server doesn't run with wal_level set to logical
+func (a *Adapter) checkWalLevel(ctx context.Context) error {
+	var level string
+	if err := a.queryRow(ctx, `SHOW wal_level`, nil, &level); err != nil {
+		return err
+	}
+	if level != "logical" {
+		return fmt.Errorf("%w: it is %q, set it to logical in postgresql.conf and restart the server", ErrWalLevelNotLogical, level)
+	}
+	return nil
+}
+
+// NewReplicationWatcher creates a watcher reading the changes of the rules table from slot,
+// which must have been created with CreateReplicationSlot. The changes of other tables are skipped,
+// and so are the rules of other namespaces when WithNamespace is used.
+// The watcher reads the slot when NewReplicationWatcher returns, Close must be called to stop it.
+func (a *Adapter) NewReplicationWatcher(ctx context.Context, slot string, opts ...ReplicationWatcherOption) (*ReplicationWatcher, error) {
+	w := &ReplicationWatcher{
+		db:         a.db,
+		slot:       slot,
+		table:      a.TableName(),
+		namespace:  a.namespace,
+		namespaced: a.namespaced,
+		interval:   DefaultReplicationPollInterval,
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.interval <= 0 {
+		return nil, fmt.Errorf("pgadapter.NewReplicationWatcher: poll interval must be positive, got %v", w.interval)
+	}
+	if err := a.checkWalLevel(ctx); err != nil {
+		return nil, fmt.Errorf("pgadapter.NewReplicationWatcher: %w", err)
+	}
+	var plugin string
+	err := a.queryRow(ctx, `SELECT plugin FROM pg_replication_slots WHERE slot_name = $1`, []any{slot}, &plugin)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("pgadapter.NewReplicationWatcher: replication slot %q does not exist, create it with CreateReplicationSlot", slot)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pgadapter.NewReplicationWatcher: %w", err)
+	}
+	if plugin != replicationPlugin {
+		return nil, fmt.Errorf("pgadapter.NewReplicationWatcher: replication slot %q uses the %q plugin, not %q", slot, plugin, replicationPlugin)
+	}
+
+	loopCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	go w.run(loopCtx)
+	return w, nil
+}
+
+// run reads the slot every interval until ctx is canceled
+func (w *ReplicationWatcher) run(ctx context.Context) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		// a full batch is followed by the next one without waiting
+		for {
+			n, err := w.poll(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && w.errors != nil {
+				w.errors(fmt.Errorf("pgadapter.ReplicationWatcher: read slot %q: %w", w.slot, err))
+			}
+			if err != nil || n < replicationBatch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads the pending changes of the slot, calls the callbacks when the rules table changed
+// and advances the slot, it returns the number of records read
+func (w *ReplicationWatcher) poll(ctx context.Context) (int, error) {
+	rows, err := w.db.Query(ctx, `SELECT lsn::text, data FROM pg_logical_slot_peek_changes($1, NULL, $2, 'format-version', '2', 'add-tables', $3)`,
+		w.slot, replicationBatch, "*."+escapeTableFilter(w.table))
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	var last string
+	var changes []RuleChange
+	for rows.Next() {
+		var lsn, data string
+		if err := rows.Scan(&lsn, &data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		n++
+		last = lsn
+		change, ok, err := w.decode(data)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("decode change at %v: %w", lsn, err)
+		}
+		if ok {
+			changes = append(changes, change)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if len(changes) > 0 {
+		w.call(changes, last)
+	}
+	// the begin and commit records of the transactions not changing the table are read too, so the slot moves past them
+	if _, err := w.db.Exec(ctx, `SELECT pg_replication_slot_advance($1, $2::pg_lsn)`, w.slot, last); err != nil {
+		return n, fmt.Errorf("advance to %v: %w", last, err)
+	}
+	return n, nil
+}
+
+// walChange is a record of wal2json format version 2
+type walChange struct {
+	Action   string      `json:"action"`
+	Table    string      `json:"table"`
+	Columns  []walColumn `json:"columns"`
+	Identity []walColumn `json:"identity"`
+}
+
+type walColumn struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value"`
+}
+
+// decode returns the change of a wal2json record, ok is false for the records not changing rules of the watcher
+func (w *ReplicationWatcher) decode(data string) (change RuleChange, ok bool, err error) {
+	var rec walChange
+	if err := json.Unmarshal([]byte(data), &rec); err != nil {
+		return RuleChange{}, false, err
+	}
+	if rec.Table != "" && rec.Table != w.table {
+		return RuleChange{}, false, nil
+	}
+	switch rec.Action {
+	case "I":
+		change.Kind = RuleInserted
+		change.Ptype, change.Rule, ok = w.decodeRule(rec.Columns)
+	case "U":
+		change.Kind = RuleUpdated
+		var newOk, oldOk bool
+		change.Ptype, change.Rule, newOk = w.decodeRule(rec.Columns)
+		change.OldPtype, change.OldRule, oldOk = w.decodeRule(rec.Identity)
+		ok = newOk || oldOk
+	case "D":
+		change.Kind = RuleDeleted
+		change.Ptype, change.Rule, ok = w.decodeRule(rec.Identity)
+	case "T":
+		change.Kind = RulesTruncated
+		ok = true
+	}
+	return change, ok, nil
+}
+
+// decodeRule returns the rule stored in columns, ok is false when it belongs to another namespace
+func (w *ReplicationWatcher) decodeRule(columns []walColumn) (ptype string, rule []string, ok bool) {
+	var r CasbinRule
+	var n int
+	namespace := ""
+	for _, col := range columns {
+		if col.Value == nil {
+			continue
+		}
+		switch {
+		case col.Name == "ptype":
+			r.Ptype = *col.Value
+		case col.Name == "namespace":
+			namespace = *col.Value
+		case strings.HasPrefix(col.Name, "v"):
+			i, err := strconv.Atoi(col.Name[1:])
+			if err != nil || i < 0 || i >= MaxValueColumns {
+				continue
+			}
+			r.setValue(i, *col.Value)
+			n = max(n, i+1)
+		}
+	}
+	if w.namespaced && namespace != w.namespace {
+		return "", nil, false
+	}
+	return r.Ptype, r.rule(n), true
+}
+
+// escapeTableFilter escapes the characters having a meaning in the add-tables option of wal2json
+func escapeTableFilter(name string) string {
+	var sb strings.Builder
+	for _, c := range name {
+		switch c {
+		case '\\', ',', '.', '*', ' ':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// call calls the callbacks with the changes, unless the watcher is closed
+func (w *ReplicationWatcher) call(changes []RuleChange, lsn string) {
+	w.mu.Lock()
+	callback := w.callback
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return
+	}
+	if w.changes != nil {
+		w.changes(changes)
+	}
+	if callback != nil {
+		callback(lsn)
+	}
+}
+
+// SetUpdateCallback sets the function called when the rules table changed, a classic callback is Enforcer.LoadPolicy
+func (w *ReplicationWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	w.callback = callback
+	w.mu.Unlock()
+	return nil
+}
+
+// Update does nothing since every change of the rules table is read from the replication slot
+func (w *ReplicationWatcher) Update() error {
+	select {
+	case <-w.done:
+		return fmt.Errorf("pgadapter.ReplicationWatcher: %w", ErrWatcherClosed)
+	default:
+	}
+	return nil
+}
+
+// Close stops reading the slot and waits for the running read to end, the callbacks aren't called anymore.
+// The slot is kept so another watcher can resume from it.
+func (w *ReplicationWatcher) Close() {
+	w.cancel()
+	<-w.done
+
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+}
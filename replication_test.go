@@ -0,0 +1,154 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestReplicationWatcher() {
+	ctx := context.Background()
+	var level string
+	s.Require().NoError(s.a.queryRow(ctx, `SHOW wal_level`, nil, &level))
+	if level != "logical" {
+		s.T().Skip("wal_level is not logical")
+	}
+	s.Require().NoError(s.a.CreateReplicationSlot(ctx, "pgadapter_test"))
+	defer s.a.DropReplicationSlot(ctx, "pgadapter_test")
+
+	changes := make(chan []RuleChange, 10)
+	w, err := s.a.NewReplicationWatcher(ctx, "pgadapter_test", WithPollInterval(50*time.Millisecond),
+		WithRuleChanges(func(c []RuleChange) { changes <- c }))
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.a.AddPolicy("p", "p", []string{"carol", "data3", "read"}))
+	s.Require().NoError(s.a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+	var got []RuleChange
+	for len(got) < 2 {
+		select {
+		case c := <-changes:
+			got = append(got, c...)
+		case <-time.After(5 * time.Second):
+			s.FailNow("no change read")
+		}
+	}
+	s.Require().Equal([]RuleChange{
+		{Kind: RuleInserted, Ptype: "p", Rule: []string{"carol", "data3", "read"}},
+		{Kind: RuleDeleted, Ptype: "p", Rule: []string{"alice", "data1", "read"}},
+	}, got)
+	w.Close()
+
+	// the next watcher resumes after the changes read
+	s.Require().NoError(s.a.AddPolicy("p", "p", []string{"dave", "data3", "read"}))
+	w, err = s.a.NewReplicationWatcher(ctx, "pgadapter_test", WithPollInterval(50*time.Millisecond),
+		WithRuleChanges(func(c []RuleChange) { changes <- c }))
+	s.Require().NoError(err)
+	defer w.Close()
+	select {
+	case c := <-changes:
+		s.Require().Equal([]RuleChange{{Kind: RuleInserted, Ptype: "p", Rule: []string{"dave", "data3", "read"}}}, c)
+	case <-time.After(5 * time.Second):
+		s.FailNow("no change read")
+	}
+}
+
+// walRows returns the rows of pg_logical_slot_peek_changes
+func walRows(records ...string) *pgxmock.Rows {
+	rows := pgxmock.NewRows([]string{"lsn", "data"})
+	for i, r := range records {
+		rows.AddRow("0/16B37"+string(rune('A'+i)), r)
+	}
+	return rows
+}
+
+func TestMockReplicationWatcher(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+
+	mock.ExpectQuery("SHOW wal_level").WillReturnRows(pgxmock.NewRows([]string{"wal_level"}).AddRow("logical"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT plugin FROM pg_replication_slots`)).WithArgs("casbin").
+		WillReturnRows(pgxmock.NewRows([]string{"plugin"}).AddRow("wal2json"))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM pg_logical_slot_peek_changes($1, NULL, $2, 'format-version', '2', 'add-tables', $3)`)).
+		WithArgs("casbin", replicationBatch, "*.casbin_rules").
+		WillReturnRows(walRows(
+			`{"action":"B"}`,
+			`{"action":"I","schema":"public","table":"casbin_rules","columns":[{"name":"id","value":"1"},{"name":"ptype","value":"p"},{"name":"v0","value":"alice"},{"name":"v1","value":"data1"},{"name":"v2","value":"read"},{"name":"v3","value":null},{"name":"v4","value":""},{"name":"v5","value":""},{"name":"namespace","value":"tenant1"}]}`,
+			`{"action":"I","schema":"public","table":"casbin_rules","columns":[{"name":"ptype","value":"p"},{"name":"v0","value":"bob"},{"name":"namespace","value":"tenant2"}]}`,
+			`{"action":"U","schema":"public","table":"casbin_rules","columns":[{"name":"ptype","value":"g"},{"name":"v0","value":"bob"},{"name":"v1","value":"admin"},{"name":"namespace","value":"tenant1"}],"identity":[{"name":"ptype","value":"g"},{"name":"v0","value":"bob"},{"name":"v1","value":"user"},{"name":"namespace","value":"tenant1"}]}`,
+			`{"action":"D","schema":"public","table":"casbin_rules","identity":[{"name":"ptype","value":"p"},{"name":"v0","value":"carol"},{"name":"namespace","value":"tenant1"}]}`,
+			`{"action":"C"}`,
+		))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_replication_slot_advance($1, $2::pg_lsn)`)).WithArgs("casbin", "0/16B37F").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	changes := make(chan []RuleChange, 1)
+	payloads := make(chan string, 1)
+	w, err := a.NewReplicationWatcher(context.Background(), "casbin", WithPollInterval(time.Hour),
+		WithRuleChanges(func(c []RuleChange) { changes <- c }))
+	require.NoError(t, err)
+	require.NoError(t, w.SetUpdateCallback(func(p string) { payloads <- p }))
+	defer w.Close()
+
+	require.Equal(t, []RuleChange{
+		{Kind: RuleInserted, Ptype: "p", Rule: []string{"alice", "data1", "read"}},
+		{Kind: RuleUpdated, Ptype: "g", Rule: []string{"bob", "admin"}, OldPtype: "g", OldRule: []string{"bob", "user"}},
+		{Kind: RuleDeleted, Ptype: "p", Rule: []string{"carol"}},
+	}, <-changes)
+	require.Equal(t, "0/16B37F", <-payloads)
+}
+
+func TestMockReplicationWatcherErrors(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+
+	mock.ExpectQuery("SHOW wal_level").WillReturnRows(pgxmock.NewRows([]string{"wal_level"}).AddRow("replica"))
+	_, err := a.NewReplicationWatcher(ctx, "casbin")
+	require.ErrorIs(t, err, ErrWalLevelNotLogical)
+	require.ErrorContains(t, err, `it is "replica", set it to logical`)
+
+	mock.ExpectQuery("SHOW wal_level").WillReturnRows(pgxmock.NewRows([]string{"wal_level"}).AddRow("logical"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT plugin FROM pg_replication_slots`)).WithArgs("casbin").
+		WillReturnRows(pgxmock.NewRows([]string{"plugin"}))
+	_, err = a.NewReplicationWatcher(ctx, "casbin")
+	require.EqualError(t, err, `pgadapter.NewReplicationWatcher: replication slot "casbin" does not exist, create it with CreateReplicationSlot`)
+
+	_, err = a.NewReplicationWatcher(ctx, "casbin", WithPollInterval(0))
+	require.ErrorContains(t, err, "poll interval must be positive")
+
+	// a failed read is reported and the slot is not advanced
+	mock.ExpectQuery("SHOW wal_level").WillReturnRows(pgxmock.NewRows([]string{"wal_level"}).AddRow("logical"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT plugin FROM pg_replication_slots`)).WithArgs("casbin").
+		WillReturnRows(pgxmock.NewRows([]string{"plugin"}).AddRow("wal2json"))
+	mock.ExpectQuery(regexp.QuoteMeta(`pg_logical_slot_peek_changes`)).WillReturnRows(walRows(`{"action":`))
+	errs := make(chan error, 1)
+	w, err := a.NewReplicationWatcher(ctx, "casbin", WithPollInterval(time.Hour), WithReplicationErrors(func(err error) { errs <- err }))
+	require.NoError(t, err)
+	require.ErrorContains(t, <-errs, `pgadapter.ReplicationWatcher: read slot "casbin": decode change at 0/16B37A`)
+	w.Close()
+	require.ErrorIs(t, w.Update(), ErrWatcherClosed)
+}
+
+func TestMockReplicationSlot(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+
+	mock.ExpectQuery("SHOW wal_level").WillReturnRows(pgxmock.NewRows([]string{"wal_level"}).AddRow("logical"))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "casbin_rules" REPLICA IDENTITY FULL`)).
+		WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_create_logical_replication_slot($1, $2)`)).WithArgs("casbin", "wal2json").
+		WillReturnError(&pgconn.PgError{Code: codeDuplicateObject})
+	require.NoError(t, a.CreateReplicationSlot(ctx, "casbin"))
+
+	mock.ExpectQuery("SHOW wal_level").WillReturnRows(pgxmock.NewRows([]string{"wal_level"}).AddRow("minimal"))
+	require.ErrorIs(t, a.CreateReplicationSlot(ctx, "casbin"), ErrWalLevelNotLogical)
+
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_drop_replication_slot($1)`)).WithArgs("casbin").
+		WillReturnError(&pgconn.PgError{Code: codeUndefinedObject})
+	require.NoError(t, a.DropReplicationSlot(ctx, "casbin"))
+
+	require.Equal(t, `my\.rules\,1`, escapeTableFilter("my.rules,1"))
+}
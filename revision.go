@@ -0,0 +1,183 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// WithRevisions counts the changes of the rules table in a revision table, named after the rules table
+// with a _revision suffix, so SavePolicyChecked can detect the changes made since LoadPolicy.
+// Every statement writing the rules table increases the revision, including the statements run by hand
+// and the ones changing no row. The revision table and the trigger maintaining it are created along with the
+// rules table, unless SkipTableCreate is used. The revision counts the changes of every namespace of the table.
+func WithRevisions() Option {
+	return func(a *Adapter) {
+		a.revisions = true
+	}
+}
+
+// StaleModelError is returned by SavePolicyChecked when the rules changed since they were loaded
+type StaleModelError struct {
+	// Loaded is the revision read by the last LoadPolicy, 0 if the policy was not loaded
+	Loaded int64
+	// Stored is the current revision
+	Stored int64
+}
+
+func (e *StaleModelError) Error() string {
+	if e.Loaded == 0 {
+		return fmt.Sprintf("policy was not loaded, stored revision is %d", e.Stored)
+	}
+	return fmt.Sprintf("policy loaded at revision %d, stored revision is %d", e.Loaded, e.Stored)
+}
+
+func (e *StaleModelError) Is(target error) bool {
+	return target == ErrStaleModel
+}
+
+// errRevisionsDisabled is returned by the revision methods of the adapters created without WithRevisions
+var errRevisionsDisabled = errors.New("revisions are not recorded, see WithRevisions")
+
+// revisionTable returns the revision table of the rules table of ctx
+func (a *Adapter) revisionTable(ctx context.Context) string {
+	return a.table(ctx) + "_revision"
+}
+
+// createRevisions creates the revision table and the trigger increasing the revision on every change of the rules table
+func (a *Adapter) createRevisions(ctx context.Context, db execer) error {
+	table, revision := a.table(ctx), a.revisionTable(ctx)
+	create := "CREATE TABLE"
+	if a.temporary {
+		create = "CREATE TEMP TABLE"
+	}
+	// the table has a single row, the revisions start at 1 so 0 is the revision of a policy never loaded
+	_, err := db.Exec(ctx, fmt.Sprintf(`
+		%v IF NOT EXISTS "%v" (
+			id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+			revision BIGINT NOT NULL DEFAULT 1
+		)
+	`, create, revision))
+	if err != nil && !isPgError(err, codeDuplicateTable) {
+		return err
+	}
+	if _, err := db.Exec(ctx, fmt.Sprintf(`INSERT INTO "%v" (id) VALUES (true) ON CONFLICT DO NOTHING`, revision)); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION "%[1]v_bump"() RETURNS trigger LANGUAGE plpgsql AS $fn$
+		BEGIN
+			UPDATE "%[1]v" SET revision = revision + 1;
+			RETURN NULL;
+		END
+		$fn$
+	`, revision))
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, fmt.Sprintf(`
+		DO $do$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'bump_revision' AND tgrelid = '"%[1]v"'::regclass) THEN
+				CREATE TRIGGER bump_revision AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON "%[1]v"
+					FOR EACH STATEMENT EXECUTE FUNCTION "%[2]v_bump"();
+			END IF;
+		END
+		$do$
+	`, table, revision))
+	if err != nil && !isPgError(err, codeDuplicateObject) {
+		return err
+	}
+	return nil
+}
+
+// Revision returns the current revision of the rules table, see WithRevisions
+func (a *Adapter) Revision(ctx context.Context) (_ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "Revision"})
+	if err != nil {
+		return 0, err
+	}
+	defer finish(&err)
+
+	return a.revision(ctx, a.db, false)
+}
+
+// revision reads the revision of the rules table of ctx with q, locking it until the transaction of q ends if lock is set
+func (a *Adapter) revision(ctx context.Context, q querier, lock bool) (int64, error) {
+	if !a.revisions {
+		return 0, errRevisionsDisabled
+	}
+	sql := fmt.Sprintf(`SELECT revision FROM "%v"`, a.revisionTable(ctx))
+	if lock {
+		sql += " FOR UPDATE"
+	}
+	var revision int64
+	if err := queryRowWith(ctx, q, sql, nil, &revision); err != nil {
+		return 0, err
+	}
+	return revision, nil
+}
+
+// loadedRevision returns the revision read by the last LoadPolicy of the rules table of ctx
+func (a *Adapter) loadedRevision(ctx context.Context) int64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.loadedRevisions[a.table(ctx)]
+}
+
+// setLoadedRevision records the revision of the rules of the table of ctx held by the enforcer
+func (a *Adapter) setLoadedRevision(ctx context.Context, revision int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.loadedRevisions == nil {
+		a.loadedRevisions = map[string]int64{}
+	}
+	a.loadedRevisions[a.table(ctx)] = revision
+}
+
+// SavePolicyChecked is SavePolicy failing with a *StaleModelError, leaving the rules table untouched,
+// when the rules changed since the last LoadPolicy of the adapter, so it doesn't discard the changes
+// saved meanwhile by other adapters. It needs WithRevisions, and afterwards the saved rules are the loaded ones.
+// SavePolicy itself always replaces the rules.
+func (a *Adapter) SavePolicyChecked(ctx context.Context, model model.Model) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "SavePolicyChecked", Rules: modelRules(model)})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	if !a.revisions {
+		return errRevisionsDisabled
+	}
+	tx, err := a.conn(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// the revision stays locked until the rules are saved, so concurrent checked saves are serialized
+	stored, err := a.revision(ctx, tx, true)
+	if err != nil {
+		return err
+	}
+	if loaded := a.loadedRevision(ctx); loaded == 0 || loaded != stored {
+		return &StaleModelError{Loaded: loaded, Stored: stored}
+	}
+	txCtx := context.WithValue(ctx, txKey{}, &txPool{tx: tx})
+	if err := a.savePolicy(txCtx, model); err != nil {
+		return err
+	}
+	saved, err := a.revision(ctx, tx, false)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	a.setLoadedRevision(ctx, saved)
+	return nil
+}
@@ -0,0 +1,111 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestSavePolicyChecked() {
+	ctx := context.Background()
+	newAdapter := func() *Adapter {
+		a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("rules_revision_test"), WithRevisions())
+		s.Require().NoError(err)
+		return a
+	}
+	a1, a2 := newAdapter(), newAdapter()
+	defer a1.Close()
+	defer a2.Close()
+	defer a1.db.Exec(ctx, `DROP TABLE IF EXISTS "rules_revision_test", "rules_revision_test_revision"`)
+
+	load := func(a *Adapter) model.Model {
+		m, err := model.NewModelFromFile("examples/rbac_model.conf")
+		s.Require().NoError(err)
+		s.Require().NoError(a.LoadPolicy(m))
+		return m
+	}
+	m1, m2 := load(a1), load(a2)
+
+	m1.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	s.Require().NoError(a1.SavePolicyChecked(ctx, m1))
+	m2.AddPolicy("p", "p", []string{"bob", "data2", "write"})
+	s.Require().ErrorIs(a2.SavePolicyChecked(ctx, m2), ErrStaleModel)
+	s.Require().Equal([][]string{{"alice", "data1", "read"}}, load(a2).GetPolicy("p", "p"))
+
+	// the adapter which saved can save again, its model is the stored one
+	m1.AddPolicy("p", "p", []string{"carol", "data3", "read"})
+	s.Require().NoError(a1.SavePolicyChecked(ctx, m1))
+	s.Require().ErrorIs(a2.SavePolicyChecked(ctx, m2), ErrStaleModel)
+}
+
+func TestMockRevisionsCreate(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer func() { require.NoError(t, mock.ExpectationsWereMet()) }()
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules_revision"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules_revision" (id) VALUES (true) ON CONFLICT DO NOTHING`)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE OR REPLACE FUNCTION "casbin_rules_revision_bump"()`)).
+		WillReturnResult(pgxmock.NewResult("CREATE FUNCTION", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TRIGGER bump_revision AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("DO", 0))
+	_, err = NewAdapterByPgxPool(mock, WithRevisions(), SkipSchemaVerification())
+	require.NoError(t, err)
+}
+
+func TestMockSavePolicyChecked(t *testing.T) {
+	a, mock := newMockAdapter(t, WithRevisions())
+	ctx := context.Background()
+	revision := func(n int64) *pgxmock.Rows {
+		return pgxmock.NewRows([]string{"revision"}).AddRow(n)
+	}
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+
+	// a policy never loaded can't be saved
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT revision FROM "casbin_rules_revision" FOR UPDATE`)).WillReturnRows(revision(3))
+	mock.ExpectRollback()
+	err = a.SavePolicyChecked(ctx, m)
+	require.ErrorIs(t, err, ErrStaleModel)
+	require.EqualError(t, err, `pgadapter.SavePolicyChecked: table "casbin_rules": policy was not loaded, stored revision is 3`)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT revision FROM "casbin_rules_revision"`)).WillReturnRows(revision(3))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	require.NoError(t, a.LoadPolicy(m))
+
+	// the revision didn't move, the rules are saved in the transaction holding it
+	m.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT revision FROM "casbin_rules_revision" FOR UPDATE`)).WillReturnRows(revision(3))
+	mock.ExpectExec("SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("SAVEPOINT", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(insertArgs([]string{"alice", "data1", "read"})...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("RELEASE SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("RELEASE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT revision FROM "casbin_rules_revision"`)).WillReturnRows(revision(5))
+	mock.ExpectCommit()
+	require.NoError(t, a.SavePolicyChecked(ctx, m))
+
+	// another adapter saved meanwhile, nothing is written
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT revision FROM "casbin_rules_revision" FOR UPDATE`)).WillReturnRows(revision(6))
+	mock.ExpectRollback()
+	err = a.SavePolicyChecked(ctx, m)
+	var stale *StaleModelError
+	require.ErrorAs(t, err, &stale)
+	require.Equal(t, StaleModelError{Loaded: 5, Stored: 6}, *stale)
+
+	a, _ = newMockAdapter(t)
+	require.ErrorContains(t, a.SavePolicyChecked(ctx, m), "revisions are not recorded, see WithRevisions")
+}
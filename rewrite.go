@@ -0,0 +1,83 @@
+package pgxadapter
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// QueryRewriter rewrites a statement of the adapter operation op before it is sent to the database
+type QueryRewriter func(op string, sql string, args []any) (string, []any)
+
+// WithQueryRewriter makes the adapter pass every statement it runs, including the statements of its
+// transactions and the DDL run when it starts, to rewrite before sending it to the database,
+// e.g. to add a comment routing the statement or to target another relation.
+// op is the name of the adapter method running the statement, or "NewAdapter" when the adapter starts.
+// The statements run by WithRole and by the connect hooks are not rewritten.
+func WithQueryRewriter(rewrite QueryRewriter) Option {
+	return func(a *Adapter) {
+		a.rewriter = rewrite
+	}
+}
+
+type opKey struct{}
+
+// withOp returns a context carrying the name of the running adapter operation
+func withOp(ctx context.Context, op string) context.Context {
+	return context.WithValue(ctx, opKey{}, op)
+}
+
+// opFrom returns the name of the adapter operation carried by ctx
+func opFrom(ctx context.Context) string {
+	op, _ := ctx.Value(opKey{}).(string)
+	return op
+}
+
+// rewritePool passes the statements sent to the pool and to its transactions to a QueryRewriter
+type rewritePool struct {
+	PgxPool
+	rewrite QueryRewriter
+}
+
+func (p *rewritePool) unwrap() PgxPool {
+	return p.PgxPool
+}
+
+func (p *rewritePool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	sql, arguments = p.rewrite(opFrom(ctx), sql, arguments)
+	return p.PgxPool.Exec(ctx, sql, arguments...)
+}
+
+func (p *rewritePool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	sql, args = p.rewrite(opFrom(ctx), sql, args)
+	return p.PgxPool.Query(ctx, sql, args...)
+}
+
+func (p *rewritePool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.PgxPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &rewriteTx{Tx: tx, rewrite: p.rewrite}, nil
+}
+
+type rewriteTx struct {
+	pgx.Tx
+	rewrite QueryRewriter
+}
+
+func (tx *rewriteTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	sql, arguments = tx.rewrite(opFrom(ctx), sql, arguments)
+	return tx.Tx.Exec(ctx, sql, arguments...)
+}
+
+func (tx *rewriteTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	sql, args = tx.rewrite(opFrom(ctx), sql, args)
+	return tx.Tx.Query(ctx, sql, args...)
+}
+
+func (tx *rewriteTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	sql, args = tx.rewrite(opFrom(ctx), sql, args)
+	return tx.Tx.QueryRow(ctx, sql, args...)
+}
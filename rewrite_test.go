@@ -0,0 +1,60 @@
+package pgxadapter
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockQueryRewriter(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	var ops []string
+	rewrite := func(op string, sql string, args []any) (string, []any) {
+		ops = append(ops, op)
+		return sql + " /* route:primary */", args
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`) + `.* /\* route:primary \*/$`).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	a, err := NewAdapterByPgxPool(mock, SkipSchemaVerification(), WithQueryRewriter(rewrite))
+	require.NoError(t, err)
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	m.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" /* route:primary */`)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`) + `.* /\* route:primary \*/$`).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.SavePolicy(m))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" /* route:primary */`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	require.NoError(t, a.LoadPolicy(m))
+
+	require.Equal(t, []string{"NewAdapter", "SavePolicy", "SavePolicy", "LoadPolicy"}, ops)
+}
+
+func TestMockQueryRewriterArgs(t *testing.T) {
+	a, mock := newMockAdapter(t, WithQueryRewriter(func(op string, sql string, args []any) (string, []any) {
+		return regexp.MustCompile(`"casbin_rules"`).ReplaceAllString(sql, `"authz"."rules"`), append(args[:0:0], args...)
+	}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "authz"."rules" WHERE id=$1`)).
+		WithArgs(policyID("p", []string{"alice", "data1", "read"})).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+}
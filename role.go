@@ -0,0 +1,123 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// WithRole makes every adapter operation run with role as the current role.
+// Connections of the pool created by NewAdapter run SET ROLE once when they are established.
+// With a pool passed to NewAdapterByDB or NewAdapterByPgxPool, the adapter doesn't own the connections
+// so each statement runs in a transaction starting with SET LOCAL ROLE, reads included,
+// and the role is reset when the transaction ends.
+func WithRole(role string) Option {
+	return func(a *Adapter) {
+		a.role = role
+	}
+}
+
+// setRoleSQL returns the statement switching to role, local to the current transaction if local is true
+func setRoleSQL(role string, local bool) string {
+	if local {
+		return "SET LOCAL ROLE " + pgx.Identifier{role}.Sanitize()
+	}
+	return "SET ROLE " + pgx.Identifier{role}.Sanitize()
+}
+
+// setSessionRole is the AfterConnect hook setting the role of the connections of the pool created by the adapter
+func (a *Adapter) setSessionRole(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, setRoleSQL(a.role, false)); err != nil {
+		return fmt.Errorf("set role %q: %w", a.role, err)
+	}
+	return nil
+}
+
+// rolePool runs every statement sent to a borrowed pool in a transaction using the role
+type rolePool struct {
+	PgxPool
+	role string
+}
+
+func (p *rolePool) unwrap() PgxPool {
+	return p.PgxPool
+}
+
+func (p *rolePool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.PgxPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, setRoleSQL(p.role, true)); err != nil {
+		tx.Rollback(ctx)
+		return nil, fmt.Errorf("set role %q: %w", p.role, err)
+	}
+	return tx, nil
+}
+
+func (p *rolePool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, sql, arguments...)
+	if err != nil {
+		return tag, err
+	}
+	return tag, tx.Commit(ctx)
+}
+
+func (p *rolePool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	return &roleRows{Rows: rows, ctx: ctx, tx: tx}, nil
+}
+
+// roleRows ends the transaction of a rolePool query when the rows are closed
+type roleRows struct {
+	pgx.Rows
+	ctx    context.Context
+	tx     pgx.Tx
+	closed bool
+	err    error
+}
+
+func (r *roleRows) Close() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.Rows.Close()
+	if r.Rows.Err() != nil {
+		r.tx.Rollback(r.ctx)
+		return
+	}
+	r.err = r.tx.Commit(r.ctx)
+}
+
+func (r *roleRows) Err() error {
+	if err := r.Rows.Err(); err != nil {
+		return err
+	}
+	return r.err
+}
+
+func (r *roleRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	// end the transaction as soon as the rows are consumed, like pgx releases the connection
+	r.Close()
+	return false
+}
@@ -0,0 +1,92 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestRole() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+
+	cleanup := func() {
+		pool.Exec(ctx, "DROP TABLE IF EXISTS casbin_role_rules")
+		pool.Exec(ctx, "DROP OWNED BY casbin_writer")
+		pool.Exec(ctx, "DROP ROLE IF EXISTS casbin_writer")
+	}
+	cleanup()
+	defer cleanup()
+	_, err = pool.Exec(ctx, "CREATE ROLE casbin_writer NOLOGIN")
+	s.Require().NoError(err)
+	_, err = pool.Exec(ctx, "GRANT CREATE, USAGE ON SCHEMA public TO casbin_writer")
+	s.Require().NoError(err)
+	_, err = pool.Exec(ctx, "GRANT casbin_writer TO CURRENT_USER")
+	s.Require().NoError(err)
+
+	a, err := NewAdapterByDB(pool, WithTableName("casbin_role_rules"), WithRole("casbin_writer"))
+	s.Require().NoError(err)
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	var owner string
+	rows, err := pool.Query(ctx, `SELECT tableowner FROM pg_tables WHERE tablename = 'casbin_role_rules'`)
+	s.Require().NoError(err)
+	s.Require().True(rows.Next())
+	s.Require().NoError(rows.Scan(&owner))
+	rows.Close()
+	s.Require().Equal("casbin_writer", owner)
+
+	_, err = NewAdapterByDB(pool, WithTableName("casbin_role_rules"), WithRole("casbin_missing"))
+	s.Require().ErrorContains(err, `set role "casbin_missing"`)
+}
+
+func TestSetRoleSQL(t *testing.T) {
+	require.Equal(t, `SET ROLE "casbin_writer"`, setRoleSQL("casbin_writer", false))
+	require.Equal(t, `SET LOCAL ROLE "casbin_writer"`, setRoleSQL("casbin_writer", true))
+	require.Equal(t, `SET LOCAL ROLE "x""; RESET ROLE; --"`, setRoleSQL(`x"; RESET ROLE; --`, true))
+}
+
+func TestMockRole(t *testing.T) {
+	a, mock := newMockAdapter(t, WithRole("casbin_writer"))
+	rule := []string{"alice", "data1", "read"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL ROLE "casbin_writer"`)).
+		WillReturnResult(pgxmock.NewResult("SET", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", rule))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL ROLE "casbin_writer"`)).
+		WillReturnResult(pgxmock.NewResult("SET", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+	mock.ExpectCommit()
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicy(m))
+	require.Equal(t, [][]string{rule}, m.GetPolicy("p", "p"))
+}
+
+func TestMockRoleError(t *testing.T) {
+	a, mock := newMockAdapter(t, WithRole("casbin_writer"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL ROLE "casbin_writer"`)).
+		WillReturnError(errors.New(`role "casbin_writer" does not exist`))
+	mock.ExpectRollback()
+	err := a.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	require.EqualError(t, err, `pgadapter.AddPolicy: table "casbin_rules": set role "casbin_writer": role "casbin_writer" does not exist`)
+}
@@ -0,0 +1,86 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// TableResolver returns the rules table of an operation from its context, e.g. a dedicated table for the tenant
+// of the context, or an empty name for the table of the adapter
+type TableResolver func(ctx context.Context) (string, error)
+
+// WithTableResolver routes every operation, SavePolicy included, to the table resolver returns for its context,
+// e.g. to give the largest tenants their own rules table. The resolver is called once when an operation starts,
+// so all its statements target the same table, and an empty name targets the table of the adapter.
+// The methods of the casbin adapter interfaces, such as LoadPolicy, take no context so they get a background context.
+//
+// A table is validated like WithTableName, verified like VerifySchema unless SkipSchemaVerification is used,
+// and created unless SkipTableCreate is used, the first time it is resolved, then the adapter remembers it.
+// An error of the resolver fails the operation. WithReadRelation only applies to the table of the adapter.
+// A Watcher is not bound to a table, the adapters of different tables should use different channels, see WithChannel.
+func WithTableResolver(resolver TableResolver) Option {
+	return func(a *Adapter) {
+		a.tableResolver = resolver
+	}
+}
+
+// routedTables are the tables returned by the resolver of WithTableResolver which are ready to be used
+type routedTables struct {
+	mu     sync.Mutex
+	tables map[string]bool
+}
+
+// routeTable sets the table of the operation op to the one returned by the resolver of WithTableResolver, if any,
+// and prepares it the first time
+func (a *Adapter) routeTable(ctx context.Context, op *OpInfo) (context.Context, error) {
+	if a.tableResolver == nil || op.Op == "SetTableName" {
+		return ctx, nil
+	}
+	table, err := a.tableResolver(ctx)
+	if err != nil {
+		return ctx, fmt.Errorf("resolve table: %w", err)
+	}
+	if table == "" || table == op.Table {
+		return ctx, nil
+	}
+	op.Table = table
+	ctx = context.WithValue(ctx, tableKey{}, table)
+	if err := a.prepareTable(ctx, table); err != nil {
+		return ctx, err
+	}
+	return ctx, nil
+}
+
+// prepareTable validates, creates and verifies a table returned by the resolver the first time it is used
+func (a *Adapter) prepareTable(ctx context.Context, table string) error {
+	a.routed.mu.Lock()
+	ready := a.routed.tables[table]
+	a.routed.mu.Unlock()
+	if ready {
+		return nil
+	}
+
+	if err := validateTableName(table); err != nil {
+		return err
+	}
+	// concurrent operations may prepare the same table, creating and verifying it are idempotent
+	if !a.skipTableCreate {
+		if err := a.createTableifNotExists(ctx); err != nil {
+			return err
+		}
+	}
+	if !a.skipSchemaVerify {
+		if err := a.verifyRelation(ctx, table); err != nil {
+			return err
+		}
+	}
+
+	a.routed.mu.Lock()
+	defer a.routed.mu.Unlock()
+	if a.routed.tables == nil {
+		a.routed.tables = map[string]bool{}
+	}
+	a.routed.tables[table] = true
+	return nil
+}
@@ -0,0 +1,105 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantTables routes the tenant "big" to its own table and the other tenants to the table of the adapter
+func tenantTables(ctx context.Context) (string, error) {
+	tenant, _ := TenantFromContext(ctx)
+	switch tenant {
+	case "big":
+		return "casbin_rules_big", nil
+	case "invalid":
+		return `bad"table`, nil
+	case "down":
+		return "", errors.New("tenant directory unavailable")
+	}
+	return "", nil
+}
+
+func (s *AdapterTestSuite) TestTableResolver() {
+	a, err := NewAdapterByPgxPool(s.a.db, WithTableResolver(tenantTables))
+	s.Require().NoError(err)
+	big := WithTenantContext(context.Background(), "big")
+	small := WithTenantContext(context.Background(), "small")
+	defer a.db.Exec(context.Background(), `DROP TABLE IF EXISTS "casbin_rules_big"`)
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	m.AddPolicy("p", "p", []string{"carol", "data9", "read"})
+	s.Require().NoError(a.WithTx(big, func(tx TxAdapter) error {
+		return tx.SavePolicy(m)
+	}))
+
+	n, err := a.CountRules(big, "")
+	s.Require().NoError(err)
+	s.Require().EqualValues(1, n)
+	n, err = a.CountRules(small, "")
+	s.Require().NoError(err)
+	s.Require().EqualValues(5, n)
+}
+
+func TestMockTableResolver(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	a, err := NewAdapterByPgxPool(mock, SkipSchemaVerification(), WithTableResolver(tenantTables))
+	require.NoError(t, err)
+	big := WithTenantContext(context.Background(), "big")
+	small := WithTenantContext(context.Background(), "small")
+
+	// the table of the tenant is created the first time it is resolved
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules_big"`)).WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules_big"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	n, err := a.CountRules(big, "")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(5)))
+	n, err = a.CountRules(small, "")
+	require.NoError(t, err)
+	require.EqualValues(t, 5, n)
+
+	// SavePolicy only replaces the rules of the table of the tenant
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	m.AddPolicy("p", "p", []string{"carol", "data9", "read"})
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("SAVEPOINT", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules_big"`)).WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules_big"`)).WithArgs(insertArgs([]string{"carol", "data9", "read"})...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec("RELEASE SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("RELEASE", 0))
+	mock.ExpectCommit()
+	require.NoError(t, a.WithTx(big, func(tx TxAdapter) error {
+		return tx.SavePolicy(m)
+	}))
+
+	var table string
+	a.hooks = append(a.hooks, Hooks{After: func(ctx context.Context, op OpInfo, err error) {
+		table = op.Table
+	}})
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id=$1`)).WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemovePolicy("p", "p", []string{"carol", "data9", "read"}))
+	require.Equal(t, "casbin_rules", table)
+
+	_, err = a.CountRules(WithTenantContext(context.Background(), "invalid"), "")
+	require.ErrorIs(t, err, ErrInvalidTableName)
+	_, err = a.CountRules(WithTenantContext(context.Background(), "down"), "")
+	require.EqualError(t, err, `pgadapter.CountRules: table "casbin_rules": resolve table: tenant directory unavailable`)
+}
@@ -0,0 +1,121 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// CountRulesByPtype returns the number of rules of every ptype having rules
+func (a *Adapter) CountRulesByPtype(ctx context.Context) (_ map[string]int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "CountRulesByPtype"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	var counts map[string]int64
+	err = a.retryMissingTable(ctx, func(ctx context.Context) (err error) {
+		counts, err = a.countByPtype(ctx)
+		return err
+	})
+	return counts, err
+}
+
+// countByPtype runs the CountRulesByPtype query
+func (a *Adapter) countByPtype(ctx context.Context) (map[string]int64, error) {
+	query, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT ptype, count(*) FROM "%v"`, a.readTable(ctx)), nil, true)
+	rows, err := a.db.Query(ctx, query+" GROUP BY ptype", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int64{}
+	for rows.Next() {
+		var ptype string
+		var count int64
+		if err := rows.Scan(&ptype, &count); err != nil {
+			return nil, err
+		}
+		counts[ptype] = count
+	}
+	return counts, rows.Err()
+}
+
+// CountSample is the number of rules per ptype given by StartCountSampler
+type CountSample struct {
+	// Counts maps the ptypes to their number of rules.
+	// A ptype whose rules were all deleted since a previous sample is kept with a count of 0.
+	Counts map[string]int64
+	// Time is when Counts were taken
+	Time time.Time
+	// Err is the error of the last attempt when it failed, Counts and Time are then the ones of the last sample taken
+	Err error
+}
+
+// StartCountSampler counts the rules per ptype with CountRulesByPtype every interval, starting now,
+// and calls fn with every sample, e.g. to set a gauge labelled by ptype and alert when the rules table shrinks:
+//
+//	rules := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "casbin_rules"}, []string{"ptype"})
+//	a.StartCountSampler(ctx, time.Minute, func(s pgxadapter.CountSample) {
+//		if s.Err != nil {
+//			log.Printf("count casbin rules: %v", s.Err)
+//		}
+//		for ptype, n := range s.Counts {
+//			rules.WithLabelValues(ptype).Set(float64(n))
+//		}
+//	})
+//
+// A failed count doesn't stop the sampler, fn is called with the error and the last counts.
+// The queries run as CountRulesByPtype operations, so the hooks given to WithHooks observe them.
+// The sampler runs in the background until ctx is canceled or the adapter is closed, fn is not called afterwards.
+func (a *Adapter) StartCountSampler(ctx context.Context, interval time.Duration, fn func(CountSample)) error {
+	if interval <= 0 {
+		return fmt.Errorf("pgadapter.StartCountSampler: interval must be positive, got %v", interval)
+	}
+	if fn == nil {
+		return errors.New("pgadapter.StartCountSampler: fn is nil")
+	}
+	_, done, err := a.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("pgadapter.StartCountSampler: %w", err)
+	}
+	// the sampler is not an operation Shutdown waits for, only its queries are
+	done()
+
+	go a.sampleCounts(ctx, interval, fn)
+	return nil
+}
+
+// sampleCounts calls fn with the counts every interval until ctx is canceled or the adapter is closed
+func (a *Adapter) sampleCounts(ctx context.Context, interval time.Duration, fn func(CountSample)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last CountSample
+	for {
+		counts, err := a.CountRulesByPtype(ctx)
+		if ctx.Err() != nil || errors.Is(err, ErrAdapterClosed) {
+			return
+		}
+		if err != nil {
+			last.Err = err
+		} else {
+			for ptype := range last.Counts {
+				if _, ok := counts[ptype]; !ok {
+					counts[ptype] = 0
+				}
+			}
+			last = CountSample{Counts: counts, Time: time.Now()}
+		}
+		fn(last)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestCountSampler() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	samples := make(chan CountSample, 10)
+	s.Require().NoError(s.a.StartCountSampler(ctx, 10*time.Millisecond, func(sample CountSample) {
+		samples <- sample
+	}))
+
+	sample := <-samples
+	s.Require().NoError(sample.Err)
+	s.Require().Equal(map[string]int64{"p": 4, "g": 1}, sample.Counts)
+
+	s.Require().NoError(s.a.RemoveFilteredPolicy("g", "g", 0))
+	for sample.Counts["g"] != 0 {
+		sample = <-samples
+	}
+	s.Require().Equal(map[string]int64{"p": 4, "g": 0}, sample.Counts)
+}
+
+func TestMockCountSampler(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	query := regexp.QuoteMeta(`SELECT ptype, count(*) FROM "casbin_rules" GROUP BY ptype`)
+	mock.ExpectQuery(query).
+		WillReturnRows(pgxmock.NewRows([]string{"ptype", "count"}).AddRow("p", int64(4)).AddRow("g", int64(1)))
+	mock.ExpectQuery(query).WillReturnError(errors.New("connection lost"))
+	mock.ExpectQuery(query).
+		WillReturnRows(pgxmock.NewRows([]string{"ptype", "count"}).AddRow("p", int64(4)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	samples := make(chan CountSample, 10)
+	n := 0
+	require.NoError(t, a.StartCountSampler(ctx, 10*time.Millisecond, func(sample CountSample) {
+		samples <- sample
+		if n++; n == 3 {
+			cancel()
+		}
+	}))
+
+	first := <-samples
+	require.NoError(t, first.Err)
+	require.Equal(t, map[string]int64{"p": 4, "g": 1}, first.Counts)
+
+	// the last counts are kept when the query fails
+	failed := <-samples
+	require.ErrorContains(t, failed.Err, "connection lost")
+	require.Equal(t, first.Counts, failed.Counts)
+	require.Equal(t, first.Time, failed.Time)
+
+	// the ptypes without rules anymore are reported with 0 rules
+	last := <-samples
+	require.NoError(t, last.Err)
+	require.Equal(t, map[string]int64{"p": 4, "g": 0}, last.Counts)
+
+	// the sampler stops with ctx
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, samples)
+}
+
+func TestMockCountSamplerInvalid(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+	fn := func(CountSample) {}
+
+	require.Error(t, a.StartCountSampler(ctx, 0, fn))
+	require.Error(t, a.StartCountSampler(ctx, time.Second, nil))
+
+	mock.ExpectClose()
+	require.NoError(t, a.Close())
+	require.ErrorIs(t, a.StartCountSampler(ctx, time.Second, fn), ErrAdapterClosed)
+}
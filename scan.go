@@ -0,0 +1,107 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"iter"
+)
+
+// DefaultScanBatchSize is the number of rules fetched per query by ForEachRule unless WithScanBatchSize is given
+const DefaultScanBatchSize = 1000
+
+// WithScanBatchSize sets the number of rules fetched per query by ForEachRule
+func WithScanBatchSize(n int) Option {
+	return func(a *Adapter) {
+		a.scanBatchSize = n
+	}
+}
+
+// WithSnapshotScan makes ForEachRule read all the batches in a single read only transaction
+// with the repeatable read isolation level, so the scan sees the table as it was when it started.
+// The transaction and its connection are held until the scan ends.
+func WithSnapshotScan() Option {
+	return func(a *Adapter) {
+		a.snapshotScan = true
+	}
+}
+
+// ForEachRule calls fn with every rule stored in the table, ordered by id, without loading them all in memory.
+// Rules are fetched in batches using keyset pagination, each batch is a separate query,
+// so rules changed during the scan may or may not be seen unless WithSnapshotScan is used.
+// ForEachRule stops and returns the error of the first fn call failing,
+// and checks ctx between batches.
+func (a *Adapter) ForEachRule(ctx context.Context, fn func(CasbinRule) error) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "ForEachRule"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		return a.forEachRule(ctx, fn)
+	})
+}
+
+// forEachRule runs the ForEachRule scan
+func (a *Adapter) forEachRule(ctx context.Context, fn func(CasbinRule) error) error {
+	var q querier = a.db
+	if a.snapshotScan {
+		tx, err := a.db.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		if _, err := tx.Exec(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`); err != nil {
+			return err
+		}
+		q = tx
+	}
+
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		page, next, err := a.queryPage(ctx, q, "", a.scanBatchSize, cursor)
+		if err != nil {
+			return err
+		}
+		for _, rule := range page {
+			if err := fn(rule); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// errStopIteration ends the ForEachRule call of a Rules iterator when the loop body breaks
+var errStopIteration = errors.New("pgadapter: iteration stopped")
+
+// Rules returns an iterator over the rules stored in the table, fetched like ForEachRule:
+//
+//	for rule, err := range a.Rules(ctx) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(rule.String())
+//	}
+//
+// Breaking out of the loop stops the scan, no query stays open.
+// A failing query or a canceled ctx is yielded as the last error with an empty rule.
+func (a *Adapter) Rules(ctx context.Context) iter.Seq2[CasbinRule, error] {
+	return func(yield func(CasbinRule, error) bool) {
+		err := a.ForEachRule(ctx, func(rule CasbinRule) error {
+			if !yield(rule, nil) {
+				return errStopIteration
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopIteration) {
+			yield(CasbinRule{}, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestForEachRule() {
+	rules := make([][]string, 3000)
+	for i := range rules {
+		rules[i] = []string{fmt.Sprintf("user%d", i), "data", "read"}
+	}
+	s.Require().NoError(s.a.AddPolicies("p", "p", rules))
+
+	for _, opts := range [][]Option{{WithScanBatchSize(128)}, {WithScanBatchSize(128), WithSnapshotScan()}} {
+		a, err := NewAdapterByDB(s.a.db.(*pgxpool.Pool), append(opts, SkipTableCreate())...)
+		s.Require().NoError(err)
+
+		seen := map[string]bool{}
+		err = a.ForEachRule(context.Background(), func(r CasbinRule) error {
+			s.Require().False(seen[r.ID], "rule visited twice")
+			seen[r.ID] = true
+			return nil
+		})
+		s.Require().NoError(err)
+		s.Require().Len(seen, 3005)
+
+		stop := errors.New("stop")
+		n := 0
+		err = a.ForEachRule(context.Background(), func(r CasbinRule) error {
+			n++
+			if n == 200 {
+				return stop
+			}
+			return nil
+		})
+		s.Require().ErrorIs(err, stop)
+		s.Require().Equal(200, n)
+	}
+}
+
+var scanQuery = regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE id > $1 ORDER BY id LIMIT 3`)
+
+func scanPage(ids ...string) *pgxmock.Rows {
+	rows := pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"})
+	for _, id := range ids {
+		rows.AddRow(id, "p", "user"+id, "data", "read", "", "", "")
+	}
+	return rows
+}
+
+func TestMockForEachRule(t *testing.T) {
+	a, mock := newMockAdapter(t, WithScanBatchSize(2))
+
+	mock.ExpectQuery(scanQuery).WithArgs("").WillReturnRows(scanPage("a", "b", "c"))
+	mock.ExpectQuery(scanQuery).WithArgs("b").WillReturnRows(scanPage("c", "d", "e"))
+	mock.ExpectQuery(scanQuery).WithArgs("d").WillReturnRows(scanPage("e"))
+
+	var ids []string
+	err := a.ForEachRule(context.Background(), func(r CasbinRule) error {
+		ids = append(ids, r.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c", "d", "e"}, ids)
+}
+
+func TestMockForEachRuleStop(t *testing.T) {
+	a, mock := newMockAdapter(t, WithScanBatchSize(2))
+
+	mock.ExpectQuery(scanQuery).WithArgs("").WillReturnRows(scanPage("a", "b", "c"))
+	mock.ExpectQuery(scanQuery).WithArgs("b").WillReturnRows(scanPage("c", "d", "e"))
+
+	stop := errors.New("stop")
+	var ids []string
+	err := a.ForEachRule(context.Background(), func(r CasbinRule) error {
+		ids = append(ids, r.ID)
+		if r.ID == "c" {
+			return stop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, stop)
+	require.Equal(t, []string{"a", "b", "c"}, ids)
+}
+
+func TestMockForEachRuleCancel(t *testing.T) {
+	a, mock := newMockAdapter(t, WithScanBatchSize(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mock.ExpectQuery(scanQuery).WithArgs("").WillReturnRows(scanPage("a", "b", "c"))
+
+	err := a.ForEachRule(ctx, func(r CasbinRule) error {
+		if r.ID == "b" {
+			cancel()
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMockForEachRuleSnapshot(t *testing.T) {
+	a, mock := newMockAdapter(t, WithScanBatchSize(2), WithSnapshotScan())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY`)).
+		WillReturnResult(pgxmock.NewResult("SET", 0))
+	mock.ExpectQuery(scanQuery).WithArgs("").WillReturnRows(scanPage("a"))
+	mock.ExpectRollback()
+
+	n := 0
+	err := a.ForEachRule(context.Background(), func(r CasbinRule) error {
+		n++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+}
+
+func TestScanBatchSizeValidation(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	_, err = NewAdapterByPgxPool(mock, WithScanBatchSize(0))
+	require.Error(t, err)
+}
+
+func TestMockRules(t *testing.T) {
+	a, mock := newMockAdapter(t, WithScanBatchSize(2))
+
+	mock.ExpectQuery(scanQuery).WithArgs("").WillReturnRows(scanPage("a", "b", "c"))
+	mock.ExpectQuery(scanQuery).WithArgs("b").WillReturnRows(scanPage("c"))
+
+	var ids []string
+	for rule, err := range a.Rules(context.Background()) {
+		require.NoError(t, err)
+		ids = append(ids, rule.ID)
+	}
+	require.Equal(t, []string{"a", "b", "c"}, ids)
+}
+
+func TestMockRulesBreak(t *testing.T) {
+	a, mock := newMockAdapter(t, WithScanBatchSize(2))
+
+	mock.ExpectQuery(scanQuery).WithArgs("").WillReturnRows(scanPage("a", "b", "c"))
+
+	var ids []string
+	for rule, err := range a.Rules(context.Background()) {
+		require.NoError(t, err)
+		ids = append(ids, rule.ID)
+		break
+	}
+	require.Equal(t, []string{"a"}, ids)
+
+	// the scan is over, Shutdown doesn't wait for it
+	mock.ExpectClose()
+	require.NoError(t, a.Shutdown(context.Background()))
+}
+
+func TestMockRulesError(t *testing.T) {
+	a, mock := newMockAdapter(t, WithScanBatchSize(2))
+
+	mock.ExpectQuery(scanQuery).WithArgs("").WillReturnRows(scanPage("a", "b", "c"))
+	mock.ExpectQuery(scanQuery).WithArgs("b").WillReturnError(errors.New("connection reset"))
+
+	var ids []string
+	var errs []error
+	for rule, err := range a.Rules(context.Background()) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ids = append(ids, rule.ID)
+	}
+	require.Equal(t, []string{"a", "b"}, ids)
+	require.Len(t, errs, 1)
+	require.EqualError(t, errs[0], `pgadapter.ForEachRule: table "casbin_rules": connection reset`)
+}
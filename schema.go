@@ -0,0 +1,167 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SchemaError lists the differences between the rules table and the layout expected by the adapter
+type SchemaError struct {
+	Table    string
+	Problems []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("table %q does not match the expected schema: %v", e.Table, strings.Join(e.Problems, "; "))
+}
+
+// SkipSchemaVerification skips the VerifySchema check when the adapter starts
+func SkipSchemaVerification() Option {
+	return func(a *Adapter) {
+		a.skipSchemaVerify = true
+	}
+}
+
+// WithTableVerification probes the rules table with a query selecting all the adapter columns
+// when the adapter starts, e.g. to validate a table created beforehand when SkipTableCreate is used.
+// The probe doesn't need any row to exist nor access to information_schema,
+// so it can be used instead of VerifySchema together with SkipSchemaVerification.
+func WithTableVerification() Option {
+	return func(a *Adapter) {
+		a.verifyTable = true
+	}
+}
+
+// probeTable runs a query selecting the adapter columns without returning any row,
+// from the rules table and the relation given to WithReadRelation
+func (a *Adapter) probeTable(ctx context.Context) error {
+	for _, name := range a.relations(ctx) {
+		rows, err := a.db.Query(ctx, fmt.Sprintf(`SELECT %v FROM "%v" LIMIT 0`, a.insertColumns(), name))
+		if err != nil {
+			return err
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relations returns the rules table followed by the relation given to WithReadRelation, if any
+func (a *Adapter) relations(ctx context.Context) []string {
+	table := a.table(ctx)
+	if a.readRelation != "" && a.readRelation != table {
+		return []string{table, a.readRelation}
+	}
+	return []string{table}
+}
+
+// expectedColumns returns the columns required by the adapter in table order
+func (a *Adapter) expectedColumns() []string {
+	return strings.Split(a.insertColumns(), ", ")
+}
+
+// VerifySchema checks that the rules table has all the columns used by the adapter with a text type,
+// and so does the relation given to WithReadRelation.
+// It returns a *SchemaError listing every missing or mismatched column of the first relation not matching.
+// VerifySchema runs when the adapter is created unless SkipSchemaVerification is used.
+func (a *Adapter) VerifySchema(ctx context.Context) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "VerifySchema"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	for _, name := range a.relations(ctx) {
+		if err := a.verifyRelation(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyRelation checks the columns of the table or view name
+func (a *Adapter) verifyRelation(ctx context.Context, name string) error {
+	// the schema is the one the unqualified name resolves to, which may come after current_schema() in search_path
+	schema := `(SELECT n.nspname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.oid = to_regclass(quote_ident($1)))`
+	if a.temporary {
+		// temporary tables live in a schema of their own, pg_temp_N
+		schema = "(SELECT nspname FROM pg_namespace WHERE oid = pg_my_temp_schema())"
+	}
+	rows, err := a.db.Query(ctx, fmt.Sprintf(`
+		SELECT column_name, data_type, character_maximum_length
+		FROM information_schema.columns
+		WHERE table_schema = %v AND table_name = $1
+	`, schema), name)
+	if err != nil {
+		return err
+	}
+	types := map[string]string{}
+	for rows.Next() {
+		var column, dataType string
+		var maxLength pgtype.Int4
+		if err := rows.Scan(&column, &dataType, &maxLength); err != nil {
+			rows.Close()
+			return err
+		}
+		switch {
+		case dataType == "character varying" && maxLength.Valid:
+			dataType = fmt.Sprintf("varchar(%d)", maxLength.Int32)
+		case dataType == "character" && maxLength.Valid:
+			dataType = fmt.Sprintf("char(%d)", maxLength.Int32)
+		}
+		types[column] = dataType
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(types) == 0 {
+		return fmt.Errorf("%w: %w", ErrTableNotExist, &SchemaError{Table: name, Problems: []string{"table does not exist"}})
+	}
+
+	var problems []string
+	for _, col := range a.expectedColumns() {
+		t, ok := types[col]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("column %v missing", col))
+		case t != "text":
+			problems = append(problems, fmt.Sprintf("%v is %v expected text", col, t))
+		}
+	}
+	if len(problems) > 0 {
+		return &SchemaError{Table: name, Problems: problems}
+	}
+	return nil
+}
+
+// RepairSchema adds the missing value columns to the rules table as nullable text columns,
+// and the namespace column when WithNamespace is used,
+// then verifies the schema again. Mismatched types and missing id or ptype columns are not repaired.
+func (a *Adapter) RepairSchema(ctx context.Context) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RepairSchema"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	alters := make([]string, 0, a.valueColumns)
+	for i := 0; i < a.valueColumns; i++ {
+		alters = append(alters, fmt.Sprintf("ADD COLUMN IF NOT EXISTS v%d TEXT", i))
+	}
+	if a.namespaced {
+		alters = append(alters, "ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT ''")
+	}
+	_, err = a.db.Exec(ctx, fmt.Sprintf(`ALTER TABLE "%v" %v`, a.table(ctx), strings.Join(alters, ", ")))
+	if err != nil {
+		return err
+	}
+	return a.VerifySchema(ctx)
+}
@@ -0,0 +1,120 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// schemaRows returns information_schema.columns rows for text columns
+func schemaRows(columns ...string) *pgxmock.Rows {
+	rows := pgxmock.NewRows([]string{"column_name", "data_type", "character_maximum_length"})
+	for _, c := range columns {
+		rows.AddRow(c, "text", nil)
+	}
+	return rows
+}
+
+func TestMockVerifySchema(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+
+	mock.ExpectQuery("information_schema.columns").
+		WithArgs("casbin_rules").
+		WillReturnRows(schemaRows("id", "v0", "v1", "v2", "v3", "v4").
+			AddRow("ptype", "character varying", int64(10)))
+
+	err := a.VerifySchema(ctx)
+	var serr *SchemaError
+	require.True(t, errors.As(err, &serr), "unexpected error %v", err)
+	require.Equal(t, []string{"ptype is varchar(10) expected text", "column v5 missing"}, serr.Problems)
+	require.EqualError(t, err, `pgadapter.VerifySchema: table "casbin_rules": table "casbin_rules" does not match the expected schema: ptype is varchar(10) expected text; column v5 missing`)
+
+	mock.ExpectQuery("information_schema.columns").
+		WithArgs("casbin_rules").
+		WillReturnRows(schemaRows())
+	err = a.VerifySchema(ctx)
+	require.True(t, errors.As(err, &serr))
+	require.Equal(t, []string{"table does not exist"}, serr.Problems)
+
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "casbin_rules" ADD COLUMN IF NOT EXISTS v0 TEXT`)).
+		WillReturnResult(pgxmock.NewResult("ALTER TABLE", 0))
+	mock.ExpectQuery("information_schema.columns").
+		WithArgs("casbin_rules").
+		WillReturnRows(schemaRows("id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"))
+	require.NoError(t, a.RepairSchema(ctx))
+}
+
+func TestMockTableVerification(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5, v6 FROM "rules" LIMIT 0`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "v6"}))
+	_, err = NewAdapterByPgxPool(mock, WithTableName("rules"), WithValueColumns(7),
+		SkipTableCreate(), SkipSchemaVerification(), WithTableVerification())
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "rules" LIMIT 0`)).
+		WillReturnError(errors.New(`column "v5" does not exist`))
+	_, err = NewAdapterByPgxPool(mock, WithTableName("rules"),
+		SkipTableCreate(), SkipSchemaVerification(), WithTableVerification())
+	require.EqualError(t, err, `pgadapter.NewAdapter: table "rules" is not usable by the adapter: column "v5" does not exist`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func (s *AdapterTestSuite) TestVerifySchema() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+	defer pool.Exec(ctx, `DROP TABLE "rules_drift"`)
+
+	_, err = NewAdapterByDB(pool, WithTableName("rules_drift"))
+	s.Require().NoError(err)
+	_, err = pool.Exec(ctx, `ALTER TABLE "rules_drift" DROP COLUMN v5`)
+	s.Require().NoError(err)
+
+	_, err = NewAdapterByDB(pool, WithTableName("rules_drift"), SkipTableCreate())
+	var serr *SchemaError
+	s.Require().True(errors.As(err, &serr))
+	s.Assert().Equal([]string{"column v5 missing"}, serr.Problems)
+
+	_, err = NewAdapterByDB(pool, WithTableName("rules_drift"), SkipTableCreate(), SkipSchemaVerification(), WithTableVerification())
+	s.Require().ErrorContains(err, "is not usable by the adapter")
+
+	a, err := NewAdapterByDB(pool, WithTableName("rules_drift"), SkipTableCreate(), SkipSchemaVerification())
+	s.Require().NoError(err)
+	s.Require().NoError(a.RepairSchema(ctx))
+	s.Require().NoError(a.VerifySchema(ctx))
+}
+
+func (s *AdapterTestSuite) TestVerifySchemaSearchPath() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+
+	// with a schema named after the user, current_schema() is that schema but the table is found in public
+	var userSchema string
+	s.Require().NoError(pool.QueryRow(ctx, `SELECT current_user`).Scan(&userSchema))
+	_, err = pool.Exec(ctx, fmt.Sprintf(`CREATE SCHEMA %v`, pgx.Identifier{userSchema}.Sanitize()))
+	s.Require().NoError(err)
+	defer pool.Exec(ctx, fmt.Sprintf(`DROP SCHEMA %v`, pgx.Identifier{userSchema}.Sanitize()))
+
+	var current string
+	s.Require().NoError(pool.QueryRow(ctx, `SELECT current_schema()`).Scan(&current))
+	s.Require().Equal(userSchema, current)
+	a, err := NewAdapterByDB(pool, SkipTableCreate())
+	s.Require().NoError(err)
+	s.Require().NoError(a.VerifySchema(ctx))
+}
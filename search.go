@@ -0,0 +1,137 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SearchQuery selects the rules returned by SearchRules
+type SearchQuery struct {
+	// Ptype keeps the rules of the ptype, every ptype if empty
+	Ptype string
+	// FieldContains keeps the rules whose value at the field index contains the string, ignoring the case.
+	// The empty strings match every rule.
+	FieldContains map[int]string
+	// OrderBy is the column the rules are sorted by: id, ptype or v0 to v5, or up to the value columns
+	// of WithValueColumns. The rules are sorted by id if empty, and by id after OrderBy otherwise.
+	OrderBy string
+	// Desc sorts the rules in descending order of OrderBy
+	Desc bool
+	// Limit caps the number of rules returned if positive
+	Limit int
+	// Offset skips the first rules matched
+	Offset int
+}
+
+// SearchRules returns a page of the rules matching q, and the number of rules matching q ignoring
+// its Limit and Offset, e.g. for the rules table and the pager of an administration UI.
+// The rules are read from the namespace of the operation, see WithNamespace and WithTenantFromContext.
+//
+// Like LoadPolicyPage, SearchRules returns raw rules and doesn't load them into a model. The count and the page
+// are read by two queries, a rule changed between them may be counted and not returned, or the other way around.
+func (a *Adapter) SearchRules(ctx context.Context, q SearchQuery) (_ []CasbinRule, _ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "SearchRules", Ptype: q.Ptype})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer finish(&err)
+
+	orderBy, err := a.searchOrder(q.OrderBy)
+	if err != nil {
+		return nil, 0, err
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		return nil, 0, fmt.Errorf("pgadapter: limit and offset can't be negative, got %d and %d", q.Limit, q.Offset)
+	}
+	var conds []string
+	var args []any
+	if q.Ptype != "" {
+		args = append(args, q.Ptype)
+		conds = append(conds, fmt.Sprintf("ptype = $%d", len(args)))
+	}
+	// the fields are matched in order so the statements are the same for the same query
+	fields := make([]int, 0, len(q.FieldContains))
+	for i := range q.FieldContains {
+		fields = append(fields, i)
+	}
+	slices.Sort(fields)
+	for _, i := range fields {
+		if i < 0 || i >= a.valueColumns {
+			return nil, 0, fmt.Errorf("%w: field index %d is out of the %d value columns", ErrInvalidFilter, i, a.valueColumns)
+		}
+		if q.FieldContains[i] == "" {
+			continue
+		}
+		if err := a.checkUnencrypted(i, "a contains match"); err != nil {
+			return nil, 0, err
+		}
+		args = append(args, "%"+likeEscaper.Replace(q.FieldContains[i])+"%")
+		conds = append(conds, fmt.Sprintf("v%d ILIKE $%d", i, len(args)))
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+	where, args = a.inNamespace(ctx, where, args, len(conds) == 0)
+	table := a.readTable(ctx)
+
+	countSQL := fmt.Sprintf(`SELECT count(*) FROM "%v"%v`, table, where)
+	sql := fmt.Sprintf(`SELECT %v FROM "%v"%v ORDER BY %v`, a.columns(), table, where, orderBy)
+	if q.Desc {
+		sql += " DESC"
+	}
+	if orderBy != "id" {
+		sql += ", id"
+	}
+	if q.Limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", q.Limit)
+	}
+	if q.Offset > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", q.Offset)
+	}
+
+	var rules []CasbinRule
+	var total int64
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		rules = nil
+		if err := a.queryRow(ctx, countSQL, args, &total); err != nil {
+			return err
+		}
+		if total <= int64(q.Offset) {
+			// paging past the end
+			return nil
+		}
+		rows, err := a.db.Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			line, err := a.scanRule(rows)
+			if err != nil {
+				return err
+			}
+			rules = append(rules, *line)
+		}
+		return rows.Err()
+	})
+	return rules, total, err
+}
+
+// searchOrder returns the column of SearchQuery.OrderBy, it rejects the columns which are not the id, ptype or a value column
+func (a *Adapter) searchOrder(orderBy string) (string, error) {
+	switch orderBy {
+	case "", "id":
+		return "id", nil
+	case "ptype":
+		return orderBy, nil
+	}
+	for i := 0; i < a.valueColumns; i++ {
+		if orderBy == fmt.Sprintf("v%d", i) {
+			return orderBy, a.checkUnencrypted(i, "sorting")
+		}
+	}
+	return "", fmt.Errorf("%w: can't order by %q", ErrInvalidFilter, orderBy)
+}
@@ -0,0 +1,136 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestSearchRules() {
+	ctx := context.Background()
+	s.Require().NoError(s.a.AddPolicies("p", "p", [][]string{{"alice2", "data3", "read"}, {"bob", "data_1", "read"}}))
+
+	rules, total, err := s.a.SearchRules(ctx, SearchQuery{
+		Ptype:         "p",
+		FieldContains: map[int]string{0: "ALI", 2: "rea"},
+		OrderBy:       "v0",
+		Desc:          true,
+	})
+	s.Require().NoError(err)
+	s.Assert().EqualValues(2, total)
+	s.Require().Len(rules, 2)
+	s.Assert().Equal([]string{"p", "alice2", "data3", "read"}, rules[0].toStringPolicy())
+	s.Assert().Equal([]string{"p", "alice", "data1", "read"}, rules[1].toStringPolicy())
+
+	// the wildcards of ILIKE are matched literally
+	rules, total, err = s.a.SearchRules(ctx, SearchQuery{FieldContains: map[int]string{1: "a_1"}})
+	s.Require().NoError(err)
+	s.Assert().EqualValues(1, total)
+	s.Require().Len(rules, 1)
+	s.Assert().Equal("data_1", rules[0].V1)
+
+	rules, total, err = s.a.SearchRules(ctx, SearchQuery{OrderBy: "ptype", Limit: 2, Offset: 5})
+	s.Require().NoError(err)
+	s.Assert().EqualValues(7, total)
+	s.Require().Len(rules, 2)
+	s.Assert().Equal("p", rules[0].Ptype)
+	rules, total, err = s.a.SearchRules(ctx, SearchQuery{Limit: 2, Offset: 7})
+	s.Require().NoError(err)
+	s.Assert().EqualValues(7, total)
+	s.Assert().Empty(rules)
+
+	rules, total, err = s.a.SearchRules(ctx, SearchQuery{Ptype: "g", FieldContains: map[int]string{0: "carol"}})
+	s.Require().NoError(err)
+	s.Assert().Zero(total)
+	s.Assert().Empty(rules)
+}
+
+func TestMockSearchRules(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules" WHERE ptype = $1 AND v0 ILIKE $2 AND v2 ILIKE $3`)).
+		WithArgs("p", "%al\\%%", "%read%").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(3)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype = $1 AND v0 ILIKE $2 AND v2 ILIKE $3 ORDER BY v1 DESC, id LIMIT 2 OFFSET 1`)).
+		WithArgs("p", "%al\\%%", "%read%").
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("b", "p", "al%b", "data2", "read", "", "", "").
+			AddRow("a", "p", "al%a", "data1", "read", "", "", ""))
+	rules, total, err := a.SearchRules(ctx, SearchQuery{
+		Ptype:         "p",
+		FieldContains: map[int]string{2: "read", 0: "al%", 1: ""},
+		OrderBy:       "v1",
+		Desc:          true,
+		Limit:         2,
+		Offset:        1,
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	require.Len(t, rules, 2)
+	require.Equal(t, []string{"p", "al%a", "data1", "read"}, rules[1].toStringPolicy())
+
+	// no page is read past the end
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(3)))
+	rules, total, err = a.SearchRules(ctx, SearchQuery{Offset: 3})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	require.Empty(t, rules)
+
+	for _, q := range []SearchQuery{
+		{OrderBy: "v6"},
+		{OrderBy: "v0; DROP TABLE casbin_rules"},
+		{FieldContains: map[int]string{6: "a"}},
+	} {
+		_, _, err = a.SearchRules(ctx, q)
+		require.ErrorIs(t, err, ErrInvalidFilter)
+	}
+	_, _, err = a.SearchRules(ctx, SearchQuery{Offset: -1})
+	require.Error(t, err)
+}
+
+func TestMockSearchRulesNamespace(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules" WHERE namespace = $1`)).
+		WithArgs("tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE namespace = $1 ORDER BY id`)).
+		WithArgs("tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("a", "p", "alice", "data1", "read", "", "", ""))
+	rules, total, err := a.SearchRules(context.Background(), SearchQuery{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Len(t, rules, 1)
+}
+
+func TestMockSearchRulesReadTimeout(t *testing.T) {
+	a, mock := newMockAdapter(t, WithOperationTimeout(30*time.Millisecond), WithReadTimeout(300*time.Millisecond))
+
+	// SearchRules is a read, a delay exceeding the shared timeout is within the read timeout
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillDelayFor(100 * time.Millisecond).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" ORDER BY id`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("a", "p", "alice", "data1", "read", "", "", ""))
+	_, total, err := a.SearchRules(context.Background(), SearchQuery{})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillDelayFor(time.Second).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}))
+	_, _, err = a.SearchRules(context.Background(), SearchQuery{})
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.False(t, timeoutErr.Write)
+	require.Equal(t, 300*time.Millisecond, timeoutErr.Timeout)
+}
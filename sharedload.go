@@ -0,0 +1,74 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// WithSharedLoad coalesces the concurrent calls of LoadPolicy, e.g. the enforcers sharing the adapter reloading
+// on the same watcher notification: one call fetches the rules while the other calls wait for it, then every call
+// adds the fetched rules to its own model. The error of the fetch is returned by all of them.
+// A call only shares a fetch started after it was made, so it sees the changes committed before it was made:
+// a call made while a fetch runs waits for it to end, then shares the next one.
+//
+// The fetched rules are buffered in memory until every waiting call has added them to its model.
+func WithSharedLoad() Option {
+	return func(a *Adapter) {
+		a.sharedLoad = &loadFlight{}
+	}
+}
+
+// errLoadAborted is returned to the calls waiting for a fetch which panicked
+var errLoadAborted = errors.New("shared LoadPolicy aborted")
+
+// loadFlight coalesces the concurrent fetches of LoadPolicy
+type loadFlight struct {
+	mu   sync.Mutex
+	call *loadCall
+	// started is the number of fetches started
+	started uint64
+}
+
+// loadCall is a fetch in flight
+type loadCall struct {
+	done  chan struct{}
+	rules []CasbinRule
+	err   error
+	// gen is the value of started when the fetch started
+	gen uint64
+}
+
+// do returns the rules fetched by fetch, or by the fetch in flight if it started after do was called.
+// The returned rules are shared and must not be modified.
+func (f *loadFlight) do(ctx context.Context, fetch func() ([]CasbinRule, error)) ([]CasbinRule, error) {
+	f.mu.Lock()
+	arrived := f.started
+	for f.call != nil {
+		c := f.call
+		f.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if c.gen > arrived {
+			return c.rules, c.err
+		}
+		// the fetch read the rules before the call was made, the call shares the next one
+		f.mu.Lock()
+	}
+	f.started++
+	c := &loadCall{done: make(chan struct{}), err: errLoadAborted, gen: f.started}
+	f.call = c
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		f.call = nil
+		f.mu.Unlock()
+		close(c.done)
+	}()
+	c.rules, c.err = fetch()
+	return c.rules, c.err
+}
@@ -0,0 +1,239 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// loadCounter counts the queries loading the rules,
+// the first one waits for release to be closed once its rows are read
+type loadCounter struct {
+	loads   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+// firstLoadKey marks the context of the first query loading the rules
+type firstLoadKey struct{}
+
+func newLoadCounter() *loadCounter {
+	return &loadCounter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (c *loadCounter) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if strings.HasPrefix(data.SQL, "SELECT id, ptype") && c.loads.Add(1) == 1 {
+		return context.WithValue(ctx, firstLoadKey{}, true)
+	}
+	return ctx
+}
+
+func (c *loadCounter) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	if ctx.Value(firstLoadKey{}) != nil {
+		close(c.started)
+		<-c.release
+	}
+}
+
+// countCalls returns hooks counting the calls of op
+func countCalls(op string, calls *atomic.Int32) Option {
+	return WithHooks(Hooks{Before: func(ctx context.Context, info OpInfo) (context.Context, error) {
+		if info.Op == op {
+			calls.Add(1)
+		}
+		return ctx, nil
+	}})
+}
+
+func (s *AdapterTestSuite) TestSharedLoad() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	counter := newLoadCounter()
+	config.ConnConfig.Tracer = counter
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	defer pool.Close()
+	var calls atomic.Int32
+	a, err := NewAdapterByDB(pool, WithSharedLoad(), SkipTableCreate(), countCalls("LoadPolicy", &calls))
+	s.Require().NoError(err)
+
+	const n = 50
+	models := make([]model.Model, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range models {
+		models[i], err = model.NewModelFromFile("examples/rbac_model.conf")
+		s.Require().NoError(err)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = a.LoadPolicy(models[i])
+		}(i)
+	}
+	<-counter.started
+	s.Require().Eventually(func() bool { return calls.Load() == n }, 5*time.Second, time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	close(counter.release)
+	wg.Wait()
+
+	// the calls made during the first fetch share the second one
+	s.Assert().EqualValues(2, counter.loads.Load())
+	for i, m := range models {
+		s.Require().NoError(errs[i])
+		s.assertPolicy([][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"},
+			{"data2_admin", "data2", "write"}}, m.GetPolicy("p", "p"))
+		s.Assert().Equal([][]string{{"alice", "data2_admin"}}, m.GetPolicy("g", "g"))
+	}
+
+	// a load made after the fetch ends runs its own query
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a.LoadPolicy(m))
+	s.Assert().EqualValues(3, counter.loads.Load())
+}
+
+func (s *AdapterTestSuite) TestSharedLoadWriteDuringFetch() {
+	config, err := pgxpool.ParseConfig(os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	counter := newLoadCounter()
+	config.ConnConfig.Tracer = counter
+	pool, err := pgxpool.NewWithConfig(context.Background(), config)
+	s.Require().NoError(err)
+	defer pool.Close()
+	a, err := NewAdapterByDB(pool, WithSharedLoad(), SkipTableCreate())
+	s.Require().NoError(err)
+
+	before, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	var beforeErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		beforeErr = a.LoadPolicy(before)
+	}()
+	// the first fetch read the rules, a change is committed and notified before it ends
+	<-counter.started
+	s.Require().NoError(s.a.AddPolicy("p", "p", []string{"carol", "data3", "read"}))
+	after, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	var afterErr error
+	afterDone := make(chan struct{})
+	go func() {
+		defer close(afterDone)
+		afterErr = a.LoadPolicy(after)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(counter.release)
+	<-done
+	<-afterDone
+
+	s.Require().NoError(beforeErr)
+	s.Require().NoError(afterErr)
+	s.Assert().False(before.HasPolicy("p", "p", []string{"carol", "data3", "read"}))
+	s.Assert().True(after.HasPolicy("p", "p", []string{"carol", "data3", "read"}))
+	s.Assert().EqualValues(2, counter.loads.Load())
+}
+
+func TestMockSharedLoad(t *testing.T) {
+	a, mock := newMockAdapter(t, WithSharedLoad())
+	const n = 50
+
+	// the calls made during the first fetch share the second one,
+	// the two queries expected fail the test if another one runs
+	for range 2 {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+			WillDelayFor(200 * time.Millisecond).
+			WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+				AddRow("1", "p", "alice", "data1", "read", "", "", "").
+				AddRow("2", "g", "alice", "admin", "", "", "", ""))
+	}
+	models := make([]model.Model, n)
+	errs := make([]error, n)
+	for i := range models {
+		m, err := model.NewModelFromFile("examples/rbac_model.conf")
+		require.NoError(t, err)
+		models[i] = m
+	}
+	var wg sync.WaitGroup
+	for i := range models {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = a.LoadPolicy(models[i])
+		}(i)
+	}
+	wg.Wait()
+	for i, m := range models {
+		require.NoError(t, errs[i])
+		require.Equal(t, [][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+		require.Equal(t, [][]string{{"alice", "admin"}}, m.GetPolicy("g", "g"))
+	}
+
+	// the error of the fetch is returned to every waiting call
+	failure := errors.New("connection reset")
+	for range 2 {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+			WillDelayFor(200 * time.Millisecond).
+			WillReturnError(failure)
+	}
+	for i := range models {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = a.LoadPolicy(models[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.ErrorIs(t, err, failure)
+	}
+}
+
+func TestMockSharedLoadWriteDuringFetch(t *testing.T) {
+	a, mock := newMockAdapter(t, WithSharedLoad())
+	columns := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	// the second query sees a rule committed during the first one
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(pgxmock.NewRows(columns).AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows(columns).
+			AddRow("1", "p", "alice", "data1", "read", "", "", "").
+			AddRow("2", "p", "carol", "data3", "read", "", "", ""))
+
+	before, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	after, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	var beforeErr, afterErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		beforeErr = a.LoadPolicy(before)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	go func() {
+		defer wg.Done()
+		afterErr = a.LoadPolicy(after)
+	}()
+	wg.Wait()
+
+	require.NoError(t, beforeErr)
+	require.NoError(t, afterErr)
+	require.Equal(t, [][]string{{"alice", "data1", "read"}}, before.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "data1", "read"}, {"carol", "data3", "read"}}, after.GetPolicy("p", "p"))
+}
@@ -0,0 +1,188 @@
+package pgxadapter
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// WithLogger sets the logger of the adapter, slog.Default() is used unless it is given
+func WithLogger(logger *slog.Logger) Option {
+	return func(a *Adapter) {
+		a.logger = logger
+	}
+}
+
+// WithSlowQueryThreshold makes the adapter log a warning for every operation, and every statement of an operation,
+// taking d or longer. The entries give the operation, the table, the duration and the number of rules given
+// to the operation or of rows returned or changed by the statement, but not the SQL unless WithSlowQuerySQL is used.
+// The entries are logged with the logger of WithLogger, independently of the tracer of the pool.
+func WithSlowQueryThreshold(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.slowThreshold = d
+	}
+}
+
+// WithSlowQuerySQL adds the SQL of the slow statements to the entries of WithSlowQueryThreshold.
+// The SQL is logged without its arguments, so the values of the rules are not logged.
+func WithSlowQuerySQL() Option {
+	return func(a *Adapter) {
+		a.slowSQL = true
+	}
+}
+
+// logSlowOp logs op if it took the slow query threshold or longer
+func (a *Adapter) logSlowOp(ctx context.Context, op OpInfo, elapsed time.Duration, err error) {
+	if a.slowThreshold <= 0 || elapsed < a.slowThreshold {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.String("op", op.Op),
+		slog.String("table", op.Table),
+		slog.Duration("duration", elapsed),
+		slog.Int("rules", op.Rules),
+	}
+	if op.Ptype != "" {
+		attrs = append(attrs, slog.String("ptype", op.Ptype))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	a.logger.LogAttrs(ctx, slog.LevelWarn, "pgadapter: slow operation", attrs...)
+}
+
+// logSlowStatement logs the statement sql if it took the slow query threshold or longer
+func (a *Adapter) logSlowStatement(ctx context.Context, sql string, elapsed time.Duration, rows int64) {
+	if elapsed < a.slowThreshold {
+		return
+	}
+	table, _ := ctx.Value(tableKey{}).(string)
+	attrs := []slog.Attr{
+		slog.String("op", opFrom(ctx)),
+		slog.String("table", table),
+		slog.Duration("duration", elapsed),
+		slog.Int64("rows", rows),
+	}
+	if a.slowSQL {
+		attrs = append(attrs, slog.String("sql", sql))
+	}
+	a.logger.LogAttrs(ctx, slog.LevelWarn, "pgadapter: slow statement", attrs...)
+}
+
+// slowPool times the statements sent to the pool and to its transactions, see WithSlowQueryThreshold
+type slowPool struct {
+	PgxPool
+	a *Adapter
+}
+
+func (p *slowPool) unwrap() PgxPool {
+	return p.PgxPool
+}
+
+func (p *slowPool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return slowExec(ctx, p.a, p.PgxPool, sql, arguments)
+}
+
+func (p *slowPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return slowQuery(ctx, p.a, p.PgxPool, sql, args)
+}
+
+func (p *slowPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.PgxPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &slowTx{Tx: tx, a: p.a}, nil
+}
+
+type slowTx struct {
+	pgx.Tx
+	a *Adapter
+}
+
+func (tx *slowTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return slowExec(ctx, tx.a, tx.Tx, sql, arguments)
+}
+
+func (tx *slowTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return slowQuery(ctx, tx.a, tx.Tx, sql, args)
+}
+
+func (tx *slowTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := slowQuery(ctx, tx.a, tx.Tx, sql, args)
+	return &slowRow{rows: rows, err: err}
+}
+
+func slowExec(ctx context.Context, a *Adapter, db execer, sql string, arguments []any) (pgconn.CommandTag, error) {
+	start := time.Now()
+	tag, err := db.Exec(ctx, sql, arguments...)
+	a.logSlowStatement(ctx, sql, time.Since(start), tag.RowsAffected())
+	return tag, err
+}
+
+// slowQuery runs the query, the statement is timed until its rows are read
+func slowQuery(ctx context.Context, a *Adapter, db querier, sql string, args []any) (pgx.Rows, error) {
+	start := time.Now()
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		a.logSlowStatement(ctx, sql, time.Since(start), 0)
+		return nil, err
+	}
+	return &slowRows{Rows: rows, ctx: ctx, a: a, sql: sql, start: start}, nil
+}
+
+// slowRows counts the rows of a query and logs it when they are closed
+type slowRows struct {
+	pgx.Rows
+	ctx    context.Context
+	a      *Adapter
+	sql    string
+	start  time.Time
+	n      int64
+	closed bool
+}
+
+func (r *slowRows) Close() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.Rows.Close()
+	r.a.logSlowStatement(r.ctx, r.sql, time.Since(r.start), r.n)
+}
+
+func (r *slowRows) Next() bool {
+	if r.Rows.Next() {
+		r.n++
+		return true
+	}
+	r.Close()
+	return false
+}
+
+// slowRow is the pgx.Row of slowTx.QueryRow, it reads the first row like pgx does
+type slowRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *slowRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
@@ -0,0 +1,117 @@
+package pgxadapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// logEntries returns the JSON entries logged in buf
+func logEntries(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func (s *AdapterTestSuite) TestSlowQueryThreshold() {
+	ctx := context.Background()
+	_, err := s.a.db.Exec(ctx, `CREATE VIEW slow_rules AS SELECT r.* FROM casbin_rules r, pg_sleep(0.05)`)
+	s.Require().NoError(err)
+
+	var buf bytes.Buffer
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"),
+		WithReadRelation("slow_rules"),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		WithSlowQueryThreshold(20*time.Millisecond),
+		WithSlowQuerySQL(),
+	)
+	s.Require().NoError(err)
+	defer a.Close()
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a.LoadPolicy(m))
+
+	entries := logEntries(s.T(), &buf)
+	s.Require().Len(entries, 2)
+	s.Require().Equal("pgadapter: slow statement", entries[0]["msg"])
+	s.Require().Equal("LoadPolicy", entries[0]["op"])
+	s.Require().EqualValues(5, entries[0]["rows"])
+	s.Require().Contains(entries[0]["sql"], `FROM "slow_rules"`)
+	s.Require().Equal("pgadapter: slow operation", entries[1]["msg"])
+}
+
+func TestMockSlowQueryThreshold(t *testing.T) {
+	var buf bytes.Buffer
+	a, mock := newMockAdapter(t, WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))), WithSlowQueryThreshold(10*time.Millisecond))
+
+	rules := testRules(2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(insertArgs(rules...)...).
+		WillDelayFor(20 * time.Millisecond).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicies("p", "p", rules))
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 2)
+	require.Equal(t, "pgadapter: slow statement", entries[0]["msg"])
+	require.Equal(t, "WARN", entries[0]["level"])
+	require.Equal(t, "AddPolicies", entries[0]["op"])
+	require.Equal(t, DefaultTableName, entries[0]["table"])
+	require.EqualValues(t, 2, entries[0]["rows"])
+	// the SQL is only logged with WithSlowQuerySQL
+	require.NotContains(t, entries[0], "sql")
+	require.Equal(t, "pgadapter: slow operation", entries[1]["msg"])
+	require.Equal(t, "AddPolicies", entries[1]["op"])
+	require.Equal(t, "p", entries[1]["ptype"])
+	require.EqualValues(t, 2, entries[1]["rules"])
+	require.GreaterOrEqual(t, entries[1]["duration"], float64(20*time.Millisecond))
+
+	// fast operations are not logged
+	buf.Reset()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(2)))
+	_, err := a.CountRules(context.Background(), "")
+	require.NoError(t, err)
+	require.Empty(t, buf.String())
+}
+
+func TestMockSlowQuerySQL(t *testing.T) {
+	var buf bytes.Buffer
+	a, mock := newMockAdapter(t, WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		WithSlowQueryThreshold(10*time.Millisecond), WithSlowQuerySQL())
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillDelayFor(20 * time.Millisecond).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+			AddRow("a", "p", "alice", "data1", "read", "", "", ""))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadPolicy(m))
+
+	entries := logEntries(t, &buf)
+	require.Len(t, entries, 2)
+	require.Equal(t, `SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`, entries[0]["sql"])
+	require.EqualValues(t, 1, entries[0]["rows"])
+	// the values of the rules are never logged
+	require.NotContains(t, buf.String(), "alice")
+}
@@ -0,0 +1,115 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// SQLCommenter returns the values of the comment added to a statement of the adapter operation op,
+// e.g. the traceparent of the span carried by ctx and the name of the service
+type SQLCommenter func(ctx context.Context, op string) map[string]string
+
+// WithSQLComment makes the adapter append a sqlcommenter comment, e.g. /*service='authz',traceparent='00-...'*/,
+// to every statement it runs: the reads, the writes, the statements of its transactions and the DDL run when it starts.
+// The keys and values returned by comment for the context of the statement are percent-encoded, so they can't end
+// the comment whatever they hold, and sorted by key. No comment is added when comment returns no value.
+// op is the name of the adapter method running the statement, or "NewAdapter" when the adapter starts.
+//
+// The comment is appended after the rewrite of WithQueryRewriter. Values changing for every call, like a trace id,
+// make each statement text unique, so pgx prepares them again instead of reusing its statement cache,
+// and WarmUp prepares the statements without comment.
+// The statements run by WithRole and by the connect hooks are not commented.
+func WithSQLComment(comment SQLCommenter) Option {
+	return func(a *Adapter) {
+		a.commenter = comment
+	}
+}
+
+// sqlComment returns the sqlcommenter comment of values, empty when there is none
+func sqlComment(values map[string]string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%v='%v'", commentEscape(k), commentEscape(values[k]))
+	}
+	return "/*" + strings.Join(pairs, ",") + "*/"
+}
+
+// commentEscape percent-encodes every byte of s but the unreserved characters of RFC 3986,
+// so s holds no quote, star or slash which could end the comment or nest another one
+func commentEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// commentPool appends the comment of a SQLCommenter to the statements sent to the pool and to its transactions
+type commentPool struct {
+	PgxPool
+	comment SQLCommenter
+}
+
+func (p *commentPool) unwrap() PgxPool {
+	return p.PgxPool
+}
+
+// withComment returns sql followed by its comment for ctx
+func withComment(ctx context.Context, comment SQLCommenter, sql string) string {
+	if c := sqlComment(comment(ctx, opFrom(ctx))); c != "" {
+		return sql + " " + c
+	}
+	return sql
+}
+
+func (p *commentPool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return p.PgxPool.Exec(ctx, withComment(ctx, p.comment, sql), arguments...)
+}
+
+func (p *commentPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return p.PgxPool.Query(ctx, withComment(ctx, p.comment, sql), args...)
+}
+
+func (p *commentPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx, err := p.PgxPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &commentTx{Tx: tx, comment: p.comment}, nil
+}
+
+type commentTx struct {
+	pgx.Tx
+	comment SQLCommenter
+}
+
+func (tx *commentTx) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return tx.Tx.Exec(ctx, withComment(ctx, tx.comment, sql), arguments...)
+}
+
+func (tx *commentTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return tx.Tx.Query(ctx, withComment(ctx, tx.comment, sql), args...)
+}
+
+func (tx *commentTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return tx.Tx.QueryRow(ctx, withComment(ctx, tx.comment, sql), args...)
+}
@@ -0,0 +1,74 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type traceKey struct{}
+
+func TestMockSQLComment(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	comment := func(ctx context.Context, op string) map[string]string {
+		values := map[string]string{"service": "authz", "action": op}
+		if trace, ok := ctx.Value(traceKey{}).(string); ok {
+			values["traceparent"] = trace
+		}
+		return values
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules"`) + `.* /\*action='NewAdapter',service='authz'\*/$`).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	a, err := NewAdapterByPgxPool(mock, SkipSchemaVerification(), WithSQLComment(comment))
+	require.NoError(t, err)
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	m.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" /*action='SavePolicy',service='authz'*/`)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`) + `.* /\*action='SavePolicy',service='authz'\*/$`).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.SavePolicy(m))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" /*action='LoadPolicy',service='authz'*/`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	require.NoError(t, a.LoadPolicy(m))
+
+	// the values come from the context of the call
+	ctx := context.WithValue(context.Background(), traceKey{}, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v1 = $2 `+
+		`/*action='RemoveFilteredPolicies',service='authz',traceparent='00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01'*/`)).
+		WithArgs("p", "data1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemoveFilteredPolicies(ctx, []FilteredRemoval{{Ptype: "p", FieldIndex: 1, FieldValues: []string{"data1"}}}))
+
+	// a value can't end the comment
+	ctx = context.WithValue(context.Background(), traceKey{}, "x'*/; DROP TABLE casbin_rules; /*")
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ptype = $1 AND v1 = $2 `+
+		`/*action='RemoveFilteredPolicies',service='authz',traceparent='x%27%2A%2F%3B%20DROP%20TABLE%20casbin_rules%3B%20%2F%2A'*/`)).
+		WithArgs("p", "data1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.RemoveFilteredPolicies(ctx, []FilteredRemoval{{Ptype: "p", FieldIndex: 1, FieldValues: []string{"data1"}}}))
+}
+
+func TestSQLComment(t *testing.T) {
+	require.Empty(t, sqlComment(nil))
+	require.Equal(t, `/*a='1',b%3D='x%2Fy'*/`, sqlComment(map[string]string{"b=": "x/y", "a": "1"}))
+	require.Equal(t, `/*k='%2A%2F%2F%2A%27%5C%0A--%C3%A9'*/`, sqlComment(map[string]string{"k": "*//*'\\\n--é"}))
+}
@@ -0,0 +1,264 @@
+package pgxadapter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// StagedAction is the change a StagedChange makes to the rules table when applied
+type StagedAction string
+
+const (
+	// StagedAdd adds the rule
+	StagedAdd StagedAction = "add"
+	// StagedRemove removes the rule
+	StagedRemove StagedAction = "remove"
+)
+
+// StagedChange is a change of the rules parked by StageAdd or StageRemove until ApplyStaged or DiscardStaged
+type StagedChange struct {
+	ID       string
+	Action   StagedAction
+	Ptype    string
+	Rule     []string
+	Author   string
+	Note     string
+	StagedAt time.Time
+}
+
+// stagedTable returns the table storing the staged changes of the rules table of ctx
+func (a *Adapter) stagedTable(ctx context.Context) string {
+	return a.table(ctx) + "_staged"
+}
+
+// createStagedTable creates the table of the staged changes if it doesn't exist
+func (a *Adapter) createStagedTable(ctx context.Context) error {
+	create := "CREATE TABLE"
+	if a.temporary {
+		create = "CREATE TEMP TABLE"
+	}
+	_, err := a.db.Exec(ctx, fmt.Sprintf(`
+		%v IF NOT EXISTS "%v" (
+			id TEXT PRIMARY KEY,
+			action TEXT NOT NULL,
+			ptype TEXT NOT NULL,
+			rule TEXT[] NOT NULL,
+			author TEXT NOT NULL,
+			note TEXT NOT NULL,
+			namespace TEXT NOT NULL DEFAULT '',
+			staged_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)
+	`, create, a.stagedTable(ctx)))
+	if err != nil && !isPgError(err, codeDuplicateTable, codeUniqueViolation) {
+		return err
+	}
+	return nil
+}
+
+// StageAdd parks the addition of a rule for review and returns the id of the staged change, see ApplyStaged.
+// The rule is normalized and validated like AddPolicy does, and must not be stored already.
+// The enforcement is unaffected until the change is applied.
+func (a *Adapter) StageAdd(ctx context.Context, sec string, ptype string, rule []string, author, note string) (_ string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "StageAdd", Ptype: ptype, Rules: 1})
+	if err != nil {
+		return "", err
+	}
+	defer finish(&err)
+
+	rule = a.normalization.normalize(0, rule)
+	if err := a.validateRule(sec, ptype, rule); err != nil {
+		return "", err
+	}
+	return a.stage(ctx, StagedAdd, ptype, rule, author, note)
+}
+
+// StageRemove parks the removal of a stored rule for review and returns the id of the staged change, see ApplyStaged.
+// The enforcement is unaffected until the change is applied.
+func (a *Adapter) StageRemove(ctx context.Context, sec string, ptype string, rule []string, author, note string) (_ string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "StageRemove", Ptype: ptype, Rules: 1})
+	if err != nil {
+		return "", err
+	}
+	defer finish(&err)
+
+	return a.stage(ctx, StagedRemove, ptype, a.normalization.normalize(0, rule), author, note)
+}
+
+// stage stores a staged change after checking it can be applied to the rules stored now
+func (a *Adapter) stage(ctx context.Context, action StagedAction, ptype string, rule []string, author, note string) (string, error) {
+	line := a.policyLine(ctx, ptype, rule)
+	if err := a.checkStaged(ctx, action, line); err != nil {
+		return "", err
+	}
+	if err := retryBootstrap(ctx, func() error { return a.createStagedTable(ctx) }); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate staged change id: %w", err)
+	}
+	id := hex.EncodeToString(b)
+	_, err := a.db.Exec(ctx, fmt.Sprintf(`INSERT INTO "%v" (id, action, ptype, rule, author, note, namespace) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		a.stagedTable(ctx)), id, string(action), ptype, rule, author, note, a.namespaceOf(ctx))
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// checkStaged returns ErrPolicyAlreadyExists when the rule of a staged addition is stored,
+// and ErrPolicyNotFound when the rule of a staged removal isn't
+func (a *Adapter) checkStaged(ctx context.Context, action StagedAction, line *CasbinRule) error {
+	var exists bool
+	sql, args := a.byID(ctx, fmt.Sprintf(`SELECT 1 FROM "%v" WHERE id = $1`, a.table(ctx)), []any{line.ID})
+	err := a.queryRow(ctx, "SELECT EXISTS ("+sql+")", args, &exists)
+	if err != nil {
+		return err
+	}
+	switch {
+	case action == StagedAdd && exists:
+		return ruleError(line, ErrPolicyAlreadyExists)
+	case action == StagedRemove && !exists:
+		return ruleError(line, ErrPolicyNotFound)
+	}
+	return nil
+}
+
+// ListStagedChanges returns the staged changes in the order they were staged
+func (a *Adapter) ListStagedChanges(ctx context.Context) (_ []StagedChange, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "ListStagedChanges"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	changes, err := a.selectStaged(ctx, a.db, nil, false)
+	if isPgError(err, codeUndefinedTable) {
+		return nil, nil
+	}
+	return changes, err
+}
+
+// selectStaged returns the staged changes with the given ids, or all of them when ids is nil, locking them if lock is set
+func (a *Adapter) selectStaged(ctx context.Context, q querier, ids []string, lock bool) ([]StagedChange, error) {
+	sql := fmt.Sprintf(`SELECT id, action, ptype, rule, author, note, staged_at FROM "%v"`, a.stagedTable(ctx))
+	var args []any
+	if ids != nil {
+		sql += " WHERE id = ANY($1)"
+		args = append(args, ids)
+	}
+	sql, args = a.inNamespace(ctx, sql, args, ids == nil)
+	sql += " ORDER BY staged_at, id"
+	if lock {
+		sql += " FOR UPDATE"
+	}
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []StagedChange
+	for rows.Next() {
+		var c StagedChange
+		var action string
+		if err := rows.Scan(&c.ID, &action, &c.Ptype, &c.Rule, &c.Author, &c.Note, &c.StagedAt); err != nil {
+			return nil, err
+		}
+		c.Action = StagedAction(action)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// ApplyStaged applies the staged changes with the given ids to the rules table in a single transaction,
+// in the order they were staged, and removes them from the staged changes.
+// It fails with ErrStagedConflict, applying nothing, when a rule to add was stored or a rule to remove was removed
+// since it was staged, and with ErrStagedChangeNotFound when an id is unknown.
+// The enforcers see the changes once they load the policy again, e.g. after Watcher.Update is called.
+func (a *Adapter) ApplyStaged(ctx context.Context, ids ...string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "ApplyStaged", Rules: len(ids)})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	if len(ids) == 0 {
+		return nil
+	}
+	tx, err := a.conn(ctx).Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	changes, err := a.selectStaged(ctx, tx, ids, true)
+	if isPgError(err, codeUndefinedTable) {
+		return fmt.Errorf("%w: %q", ErrStagedChangeNotFound, ids[0])
+	}
+	if err != nil {
+		return err
+	}
+	found := make(map[string]bool, len(changes))
+	for _, c := range changes {
+		found[c.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return fmt.Errorf("%w: %q", ErrStagedChangeNotFound, id)
+		}
+	}
+
+	for _, c := range changes {
+		line := a.policyLine(ctx, c.Ptype, c.Rule)
+		var n int64
+		switch c.Action {
+		case StagedAdd:
+			res, err := tx.Exec(ctx, a.insertSQL(ctx)+" ON CONFLICT DO NOTHING", a.insertArgs(ctx, line)...)
+			if err != nil {
+				return ruleError(line, err)
+			}
+			n = res.RowsAffected()
+		case StagedRemove:
+			sql, args := a.byID(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE id=$1`, a.table(ctx)), []any{line.ID})
+			res, err := tx.Exec(ctx, sql, args...)
+			if err != nil {
+				return ruleError(line, err)
+			}
+			n = res.RowsAffected()
+		default:
+			return fmt.Errorf("staged change %q: unknown action %q", c.ID, c.Action)
+		}
+		if n == 0 {
+			return fmt.Errorf("%w: staged change %q to %v %v: the rule changed since it was staged", ErrStagedConflict, c.ID, c.Action, line)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE id = ANY($1)`, a.stagedTable(ctx)), ids); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// DiscardStaged removes the staged changes with the given ids without applying them and returns the number removed
+func (a *Adapter) DiscardStaged(ctx context.Context, ids ...string) (_ int64, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "DiscardStaged", Rules: len(ids)})
+	if err != nil {
+		return 0, err
+	}
+	defer finish(&err)
+
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE id = ANY($1)`, a.stagedTable(ctx)), []any{ids}, false)
+	tag, err := a.db.Exec(ctx, sql, args...)
+	if isPgError(err, codeUndefinedTable) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
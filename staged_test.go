@@ -0,0 +1,132 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestStagedChanges() {
+	ctx := context.Background()
+	defer s.a.db.Exec(ctx, `DROP TABLE IF EXISTS casbin_rules_staged`)
+
+	addCarol, err := s.a.StageAdd(ctx, "p", "p", []string{"carol", "data3", "read"}, "sec-team", "new hire")
+	s.Require().NoError(err)
+	removeAlice, err := s.a.StageRemove(ctx, "p", "p", []string{"alice", "data1", "read"}, "sec-team", "left")
+	s.Require().NoError(err)
+	addDave, err := s.a.StageAdd(ctx, "p", "p", []string{"dave", "data3", "read"}, "sec-team", "")
+	s.Require().NoError(err)
+	_, err = s.a.StageRemove(ctx, "p", "p", []string{"nobody", "data1", "read"}, "sec-team", "")
+	s.Require().ErrorIs(err, ErrPolicyNotFound)
+
+	changes, err := s.a.ListStagedChanges(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 3)
+	s.Require().Equal(addCarol, changes[0].ID)
+	s.Require().Equal(StagedAdd, changes[0].Action)
+	s.Require().Equal([]string{"carol", "data3", "read"}, changes[0].Rule)
+	s.Require().Equal("new hire", changes[0].Note)
+
+	// the staged rules are not enforced
+	s.Require().NoError(s.e.LoadPolicy())
+	ok, err := s.e.Enforce("carol", "data3", "read")
+	s.Require().NoError(err)
+	s.Require().False(ok)
+
+	// partial application
+	s.Require().NoError(s.a.ApplyStaged(ctx, addCarol, removeAlice))
+	s.Require().NoError(s.e.LoadPolicy())
+	s.assertPolicy(
+		[][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}, {"carol", "data3", "read"}},
+		s.e.GetPolicy(),
+	)
+	changes, err = s.a.ListStagedChanges(ctx)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 1)
+	s.Require().Equal(addDave, changes[0].ID)
+
+	// the live rule changed since staging
+	s.Require().NoError(s.a.AddPolicy("p", "p", []string{"dave", "data3", "read"}))
+	s.Require().ErrorIs(s.a.ApplyStaged(ctx, addDave), ErrStagedConflict)
+	n, err := s.a.DiscardStaged(ctx, addDave)
+	s.Require().NoError(err)
+	s.Require().EqualValues(1, n)
+	s.Require().ErrorIs(s.a.ApplyStaged(ctx, addDave), ErrStagedChangeNotFound)
+}
+
+func TestMockApplyStaged(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+	carol := []string{"carol", "data3", "read"}
+	alice := []string{"alice", "data1", "read"}
+	stagedAt := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	// a conflict applies nothing
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, action, ptype, rule, author, note, staged_at FROM "casbin_rules_staged" WHERE id = ANY($1) ORDER BY staged_at, id FOR UPDATE`)).
+		WithArgs([]string{"c1", "c2"}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "action", "ptype", "rule", "author", "note", "staged_at"}).
+			AddRow("c1", "add", "p", carol, "sec", "", stagedAt).
+			AddRow("c2", "remove", "p", alice, "sec", "", stagedAt))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(insertArgs(carol)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id=$1`)).WithArgs(policyID("p", alice)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectRollback()
+	err := a.ApplyStaged(ctx, "c1", "c2")
+	require.ErrorIs(t, err, ErrStagedConflict)
+	require.ErrorContains(t, err, `staged change "c2" to remove p, alice, data1, read`)
+
+	// an unknown id applies nothing
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "casbin_rules_staged"`)).WithArgs([]string{"c1", "c3"}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "action", "ptype", "rule", "author", "note", "staged_at"}).
+			AddRow("c1", "add", "p", carol, "sec", "", stagedAt))
+	mock.ExpectRollback()
+	require.ErrorIs(t, a.ApplyStaged(ctx, "c1", "c3"), ErrStagedChangeNotFound)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "casbin_rules_staged"`)).WithArgs([]string{"c1"}).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "action", "ptype", "rule", "author", "note", "staged_at"}).
+			AddRow("c1", "add", "p", carol, "sec", "", stagedAt))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(insertArgs(carol)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules_staged" WHERE id = ANY($1)`)).WithArgs([]string{"c1"}).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.ApplyStaged(ctx, "c1"))
+}
+
+func TestMockStageAdd(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+	ctx := context.Background()
+	carol := []string{"carol", "data3", "read"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM "casbin_rules" WHERE id = $1)`)).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE IF NOT EXISTS "casbin_rules_staged"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules_staged" (id, action, ptype, rule, author, note, namespace)`)).
+		WithArgs(pgxmock.AnyArg(), "add", "p", carol, "sec", "new hire", "tenant1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	id, err := a.StageAdd(ctx, "p", "p", carol, "sec", "new hire")
+	require.NoError(t, err)
+	require.Len(t, id, 16)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS`)).
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	_, err = a.StageAdd(ctx, "p", "p", carol, "sec", "")
+	require.ErrorIs(t, err, ErrPolicyAlreadyExists)
+
+	// no change was ever staged
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "casbin_rules_staged" WHERE namespace = $1 ORDER BY staged_at, id`)).WithArgs("tenant1").
+		WillReturnError(&pgconn.PgError{Code: codeUndefinedTable})
+	changes, err := a.ListStagedChanges(ctx)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
@@ -0,0 +1,170 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// LoadSubjectPolicy loads the rules needed to enforce the requests of a single subject into model:
+// the g rules assigning roles to subject, then the p rules whose subject (v0) is subject or one of these roles.
+// Only one level of roles is loaded, the roles inherited by the roles of subject are not,
+// so models with deeper role hierarchies need LoadPolicy or LoadFilteredPolicy.
+// The adapter is marked as filtered afterwards, so the enforcer can't overwrite the other rules with SavePolicy.
+func (a *Adapter) LoadSubjectPolicy(ctx context.Context, model model.Model, subject string) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "LoadSubjectPolicy"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	subject = a.normalization.normalize(0, []string{subject})[0]
+	return a.retryMissingTable(ctx, func(ctx context.Context) error {
+		subjects, err := a.loadSubjectRoles(ctx, model, subject)
+		if err != nil {
+			return err
+		}
+		if a.encryption.encrypts(0) {
+			for i, s := range subjects {
+				if subjects[i], err = a.encryption.encrypt(s); err != nil {
+					return err
+				}
+			}
+		}
+		sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v" WHERE ptype = 'p' AND v0 = ANY($1)`, a.columns(), a.readTable(ctx)),
+			[]any{subjects}, false)
+		err = a.loadRows(ctx, a.db, sql, args, func(line *CasbinRule) error {
+			return a.loadRule(line, model)
+		})
+		if err != nil {
+			return err
+		}
+		a.setFiltered(true)
+		return nil
+	})
+}
+
+// loadSubjectRoles loads the g rules of subject into model and returns subject followed by its roles
+func (a *Adapter) loadSubjectRoles(ctx context.Context, model model.Model, subject string) ([]string, error) {
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v" WHERE ptype = 'g' AND v0 = $1`, a.columns(), a.readTable(ctx)),
+		[]any{a.fieldArg(0, subject)}, false)
+	rows, err := a.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var n int64
+	defer func() { a.addLoaded(ctx, n) }()
+	subjects := []string{subject}
+	scanner := a.newRuleScanner()
+	for rows.Next() {
+		line, err := scanner.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.loadRule(line, model); err != nil {
+			return nil, err
+		}
+		subjects = append(subjects, line.V1)
+		n++
+	}
+	return subjects, rows.Err()
+}
+
+// WithSubjectFields sets the values RemoveSubject compares with the subject, fields maps the ptypes to the indexes
+// of their values, e.g. map[string][]int{"p": {0}, "g": {0, 1}} to remove a role along with its members and rules,
+// or map[string][]int{"p": {0}, "g": {0}} to leave the other ptypes of the model alone.
+// By default the subject is compared with v0 of every ptype.
+func WithSubjectFields(fields map[string][]int) Option {
+	return func(a *Adapter) {
+		a.subjectFields = fields
+	}
+}
+
+// RemoveSubject removes the rules naming subject, e.g. when a user is offboarded: by default its g memberships
+// and the p rules, and the rules of every other ptype, whose v0 is subject, see WithSubjectFields.
+// The rules are removed by a single statement, so either all of them are removed or none,
+// and the operation is a single change for the hooks and the revision of WithRevisions.
+// The removed rules are returned with their ptype first, e.g. {"g", "alice", "admin"}, in no particular order.
+func (a *Adapter) RemoveSubject(ctx context.Context, subject string) (removed [][]string, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "RemoveSubject"})
+	if err != nil {
+		return nil, err
+	}
+	defer finish(&err)
+
+	if subject == "" {
+		return nil, fmt.Errorf("%w: subject is empty", ErrInvalidFilter)
+	}
+	cond, args, err := a.subjectCondition(subject)
+	if err != nil {
+		return nil, err
+	}
+	sql, args := a.inNamespace(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE (%v)`, a.table(ctx), cond), args, false)
+	sql += " RETURNING " + a.columns()
+
+	err = a.retryMissingTable(ctx, func(ctx context.Context) error {
+		removed = nil
+		rows, err := a.conn(ctx).Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		scanner := a.newRuleScanner()
+		for rows.Next() {
+			line, err := scanner.scan(rows)
+			if err != nil {
+				return err
+			}
+			removed = append(removed, append([]string{line.Ptype}, line.rule(a.valueColumns)...))
+		}
+		return rows.Err()
+	})
+	return removed, err
+}
+
+// subjectCondition returns the condition selecting the rules of RemoveSubject and its arguments
+func (a *Adapter) subjectCondition(subject string) (string, []any, error) {
+	var args []any
+	// the subject is normalized for every field it is compared with, see WithNormalization
+	params := map[int]int{}
+	param := func(i int) int {
+		if _, ok := params[i]; !ok {
+			args = append(args, a.fieldArg(i, a.normalization.normalize(i, []string{subject})[0]))
+			params[i] = len(args)
+		}
+		return params[i]
+	}
+	if len(a.subjectFields) == 0 {
+		return fmt.Sprintf("v0 = $%d", param(0)), args, nil
+	}
+
+	ptypes := make([]string, 0, len(a.subjectFields))
+	for ptype := range a.subjectFields {
+		ptypes = append(ptypes, ptype)
+	}
+	sort.Strings(ptypes)
+	var conds []string
+	for _, ptype := range ptypes {
+		var fields []string
+		for _, i := range a.subjectFields[ptype] {
+			if i < 0 || i >= a.valueColumns {
+				return "", nil, fmt.Errorf("%w: field index %d of ptype %q is out of the %d value columns", ErrInvalidFilter, i, ptype, a.valueColumns)
+			}
+			fields = append(fields, fmt.Sprintf("v%d = $%d", i, param(i)))
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		args = append(args, ptype)
+		conds = append(conds, fmt.Sprintf("ptype = $%d AND (%v)", len(args), strings.Join(fields, " OR ")))
+	}
+	if len(conds) == 0 {
+		return "", nil, fmt.Errorf("%w: no subject fields, see WithSubjectFields", ErrInvalidFilter)
+	}
+	return "(" + strings.Join(conds, ") OR (") + ")", args, nil
+}
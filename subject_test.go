@@ -0,0 +1,116 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestLoadSubjectPolicy() {
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(s.a.LoadSubjectPolicy(context.Background(), m, "alice"))
+	s.Require().True(s.a.IsFiltered())
+
+	e, err := casbin.NewEnforcer(m)
+	s.Require().NoError(err)
+	s.assertPolicy([][]string{{"alice", "data1", "read"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}}, e.GetPolicy())
+	s.Require().Equal([][]string{{"alice", "data2_admin"}}, e.GetGroupingPolicy())
+	ok, err := e.Enforce("alice", "data2", "write")
+	s.Require().NoError(err)
+	s.Require().True(ok)
+}
+
+func TestMockLoadSubjectPolicy(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype = 'g' AND v0 = $1`)).
+		WithArgs("alice").
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("1", "g", "alice", "admin", "", "", "", "").
+			AddRow("2", "g", "alice", "auditor", "", "", "", ""))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" WHERE ptype = 'p' AND v0 = ANY($1)`)).
+		WithArgs([]string{"alice", "admin", "auditor"}).
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("3", "p", "admin", "data1", "write", "", "", "").
+			AddRow("4", "p", "auditor", "data1", "read", "", "", ""))
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	require.NoError(t, a.LoadSubjectPolicy(context.Background(), m, "alice"))
+	require.Equal(t, [][]string{{"admin", "data1", "write"}, {"auditor", "data1", "read"}}, m.GetPolicy("p", "p"))
+	require.Equal(t, [][]string{{"alice", "admin"}, {"alice", "auditor"}}, m.GetPolicy("g", "g"))
+	require.True(t, a.IsFiltered())
+}
+
+func (s *AdapterTestSuite) TestRemoveSubject() {
+	ctx := context.Background()
+	s.Require().NoError(s.a.AddPolicies("p", "p", [][]string{{"alice", "data3", "write"}, {"bob", "alice", "read"}}))
+	s.Require().NoError(s.a.AddPolicy("g", "g", []string{"carol", "alice"}))
+
+	removed, err := s.a.RemoveSubject(ctx, "alice")
+	s.Require().NoError(err)
+	s.Assert().ElementsMatch([][]string{{"p", "alice", "data1", "read"}, {"p", "alice", "data3", "write"}, {"g", "alice", "data2_admin"}}, removed)
+	// the rules naming alice in other fields are left alone
+	s.Require().NoError(s.e.LoadPolicy())
+	s.assertPolicy([][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}, {"bob", "alice", "read"}},
+		s.e.GetPolicy())
+	s.Assert().Equal([][]string{{"carol", "alice"}}, s.e.GetGroupingPolicy())
+
+	removed, err = s.a.RemoveSubject(ctx, "alice")
+	s.Require().NoError(err)
+	s.Assert().Empty(removed)
+
+	// a role is removed with its members
+	b, err := NewAdapterByDB(s.a.db.(*pgxpool.Pool), WithSubjectFields(map[string][]int{"p": {0}, "g": {0, 1}}), SkipTableCreate())
+	s.Require().NoError(err)
+	removed, err = b.RemoveSubject(ctx, "data2_admin")
+	s.Require().NoError(err)
+	s.Assert().ElementsMatch([][]string{{"p", "data2_admin", "data2", "read"}, {"p", "data2_admin", "data2", "write"}}, removed)
+	removed, err = b.RemoveSubject(ctx, "alice")
+	s.Require().NoError(err)
+	s.Assert().Equal([][]string{{"g", "carol", "alice"}}, removed)
+	s.Require().NoError(s.e.LoadPolicy())
+	s.assertPolicy([][]string{{"bob", "data2", "write"}, {"bob", "alice", "read"}}, s.e.GetPolicy())
+	s.Assert().Empty(s.e.GetGroupingPolicy())
+}
+
+func TestMockRemoveSubject(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("tenant1"))
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE (v0 = $1) AND namespace = $2 RETURNING id, ptype, v0, v1, v2, v3, v4, v5`)).
+		WithArgs("alice", "tenant1").
+		WillReturnRows(pgxmock.NewRows(cols).
+			AddRow("1", "p", "alice", "data1", "read", "", "", "").
+			AddRow("2", "g", "alice", "admin", "", "", "", ""))
+	removed, err := a.RemoveSubject(context.Background(), "alice")
+	require.NoError(t, err)
+	require.Equal(t, [][]string{{"p", "alice", "data1", "read"}, {"g", "alice", "admin"}}, removed)
+
+	_, err = a.RemoveSubject(context.Background(), "")
+	require.ErrorIs(t, err, ErrInvalidFilter)
+}
+
+func TestMockRemoveSubjectFields(t *testing.T) {
+	a, mock := newMockAdapter(t, WithSubjectFields(map[string][]int{"p": {0}, "g": {0, 1}, "g2": {}}),
+		WithNormalization(Normalization{LowerFields: []int{0}}))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE ((ptype = $3 AND (v0 = $1 OR v1 = $2)) OR (ptype = $4 AND (v0 = $1))) RETURNING`)).
+		WithArgs("alice", "Alice", "g", "p").
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	removed, err := a.RemoveSubject(context.Background(), "Alice")
+	require.NoError(t, err)
+	require.Empty(t, removed)
+
+	b, _ := newMockAdapter(t, WithSubjectFields(map[string][]int{"p": {6}}))
+	_, err = b.RemoveSubject(context.Background(), "alice")
+	require.ErrorIs(t, err, ErrInvalidFilter)
+}
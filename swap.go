@@ -0,0 +1,98 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// WithSwapSave makes SavePolicy replace the rules table rather than update it, so a large save doesn't hold
+// row locks while it writes nor leave the dead rows of the previous rules in the table.
+// The rules are first copied into a temporary staging table, holding no lock readers or writers wait for,
+// then a short final step locks the rules table, truncates it and fills it from the staging table
+// in the same transaction. Readers see the previous rules or the new ones, never a part of them.
+//
+// The rules table is truncated rather than swapped with the staging table, so its indexes, the triggers
+// of WithHistory and WithRevisions, its grants and the views reading it are kept as they are.
+// With WithNamespace the rules of the other namespaces are copied back in the final step.
+// The role of the adapter needs the TRUNCATE privilege on the table.
+func WithSwapSave() Option {
+	return func(a *Adapter) {
+		a.swapSave = true
+	}
+}
+
+// WithTruncateSave makes SavePolicy empty the rules table with TRUNCATE rather than DELETE before it writes the rules,
+// so frequent saves don't leave the dead rows of the previous rules in the table and its indexes until autovacuum
+// reclaims them. TRUNCATE is transactional, a failed save keeps the previous rules.
+//
+// TRUNCATE takes an ACCESS EXCLUSIVE lock on the table until the save commits: unlike with DELETE, the readers,
+// LoadPolicy included, wait for the save rather than read the previous rules, and a transaction reading the table
+// since before the save, with the REPEATABLE READ isolation level, sees it empty afterwards.
+// The role of the adapter needs the TRUNCATE privilege on the table.
+//
+// SavePolicy only truncates the table when it replaces every rule of it: the adapters created with WithNamespace
+// or WithTenantFromContext delete the rules of their namespace as without the option.
+// WithTruncateSave can't be used with WithSwapSave, which truncates the table already.
+func WithTruncateSave() Option {
+	return func(a *Adapter) {
+		a.truncateSave = true
+	}
+}
+
+// stagingTable returns the staging table of the rules table of ctx used by WithSwapSave
+func (a *Adapter) stagingTable(ctx context.Context) string {
+	return a.table(ctx) + "_staging"
+}
+
+// swapRules replaces the rules of the table of ctx with lines, see WithSwapSave, reporting to p as they are copied
+func (a *Adapter) swapRules(ctx context.Context, lines []*CasbinRule, p *progress) error {
+	tx, err := a.conn(ctx).Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("start DB transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	table, staging := a.table(ctx), a.stagingTable(ctx)
+	// a temporary table is private to the connection, concurrent saves don't share it
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`DROP TABLE IF EXISTS pg_temp."%v"`, staging)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`CREATE TEMP TABLE "%v" (LIKE "%v" INCLUDING DEFAULTS) ON COMMIT DROP`, staging, table)); err != nil {
+		return err
+	}
+	rows := make([][]any, len(lines))
+	for i, line := range lines {
+		rows[i] = a.insertArgs(ctx, line)
+	}
+	columns := strings.Split(a.insertColumns(), ", ")
+	n, err := tx.CopyFrom(ctx, pgx.Identifier{staging}, columns, pgx.CopyFromRows(rows))
+	if err != nil {
+		return err
+	}
+	p.add(n)
+
+	// the rules table is locked from here to the commit
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`LOCK TABLE "%v" IN ACCESS EXCLUSIVE MODE`, table)); err != nil {
+		return err
+	}
+	if a.namespaced {
+		sql := fmt.Sprintf(`INSERT INTO "%v" SELECT * FROM "%v" WHERE namespace <> $1`, staging, table)
+		if _, err := tx.Exec(ctx, sql, a.namespaceOf(ctx)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`TRUNCATE "%v"`, table)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`INSERT INTO "%v" SELECT * FROM "%v"`, table, staging)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	p.end()
+	return nil
+}
@@ -0,0 +1,144 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestSwapSave() {
+	newAdapter := func(namespace string) *Adapter {
+		a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("rules_swap_test"), WithNamespace(namespace), WithSwapSave())
+		s.Require().NoError(err)
+		return a
+	}
+	a1, a2 := newAdapter("tenant1"), newAdapter("tenant2")
+	defer a1.Close()
+	defer a2.Close()
+	defer a1.db.Exec(context.Background(), `DROP TABLE IF EXISTS "rules_swap_test"`)
+
+	s.Require().NoError(a2.AddPolicy("p", "p", []string{"bob", "data2", "write"}))
+	for i := 0; i < 2; i++ {
+		m, err := model.NewModelFromFile("examples/rbac_model.conf")
+		s.Require().NoError(err)
+		m.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+		m.AddPolicy("g", "g", []string{"alice", "admin"})
+		s.Require().NoError(a1.SavePolicy(m))
+	}
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a1.LoadPolicy(m))
+	s.Require().Equal([][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+	s.Require().Equal([][]string{{"alice", "admin"}}, m.GetPolicy("g", "g"))
+
+	// the rules of the other namespace are kept
+	m, err = model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a2.LoadPolicy(m))
+	s.Require().Equal([][]string{{"bob", "data2", "write"}}, m.GetPolicy("p", "p"))
+}
+
+func TestMockSwapSave(t *testing.T) {
+	a, mock := newMockAdapter(t, WithSwapSave())
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	m.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	m.AddPolicy("g", "g", []string{"alice", "admin"})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS pg_temp."casbin_rules_staging"`)).WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TEMP TABLE "casbin_rules_staging" (LIKE "casbin_rules" INCLUDING DEFAULTS) ON COMMIT DROP`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectCopyFrom(`"casbin_rules_staging"`, []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}).
+		WillReturnResult(2)
+	mock.ExpectExec(regexp.QuoteMeta(`LOCK TABLE "casbin_rules" IN ACCESS EXCLUSIVE MODE`)).WillReturnResult(pgxmock.NewResult("LOCK TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`TRUNCATE "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("TRUNCATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules" SELECT * FROM "casbin_rules_staging"`)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectCommit()
+	require.NoError(t, a.SavePolicy(m))
+}
+
+func TestMockSwapSaveNamespace(t *testing.T) {
+	a, mock := newMockAdapter(t, WithSwapSave(), WithNamespace("tenant1"))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+
+	// the rules of the other namespaces are copied back before the table is truncated
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS pg_temp."casbin_rules_staging"`)).WillReturnResult(pgxmock.NewResult("DROP TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE TEMP TABLE "casbin_rules_staging"`)).WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	mock.ExpectCopyFrom(`"casbin_rules_staging"`, []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "namespace"}).
+		WillReturnResult(0)
+	mock.ExpectExec(regexp.QuoteMeta(`LOCK TABLE "casbin_rules" IN ACCESS EXCLUSIVE MODE`)).WillReturnResult(pgxmock.NewResult("LOCK TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules_staging" SELECT * FROM "casbin_rules" WHERE namespace <> $1`)).
+		WithArgs("tenant1").WillReturnResult(pgxmock.NewResult("INSERT", 3))
+	mock.ExpectExec(regexp.QuoteMeta(`TRUNCATE "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("TRUNCATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules" SELECT * FROM "casbin_rules_staging"`)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 3))
+	mock.ExpectCommit()
+	require.NoError(t, a.SavePolicy(m))
+}
+
+func (s *AdapterTestSuite) TestTruncateSave() {
+	ctx := context.Background()
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTableName("rules_truncate_test"), WithTruncateSave())
+	s.Require().NoError(err)
+	defer a.Close()
+	defer a.db.Exec(ctx, `DROP TABLE IF EXISTS "rules_truncate_test"`)
+
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"bob", "data2", "write"}))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	m.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+	m.AddPolicy("g", "g", []string{"alice", "admin"})
+	s.Require().NoError(a.SavePolicy(m))
+	m.ClearPolicy()
+	s.Require().NoError(a.LoadPolicy(m))
+	s.Require().Equal([][]string{{"alice", "data1", "read"}}, m.GetPolicy("p", "p"))
+	s.Require().Equal([][]string{{"alice", "admin"}}, m.GetPolicy("g", "g"))
+
+	// a namespaced adapter keeps the rules of the other namespaces
+	tenant1, err := NewAdapterByDB(a.db.(*pgxpool.Pool), WithTableName("rules_truncate_test"), WithNamespace("tenant1"), WithTruncateSave())
+	s.Require().NoError(err)
+	tenant2, err := NewAdapterByDB(a.db.(*pgxpool.Pool), WithTableName("rules_truncate_test"), WithNamespace("tenant2"))
+	s.Require().NoError(err)
+	s.Require().NoError(tenant2.AddPolicy("p", "p", []string{"dave", "data4", "read"}))
+	m.ClearPolicy()
+	s.Require().NoError(tenant1.SavePolicy(m))
+	count, err := tenant2.CountRules(ctx, "")
+	s.Require().NoError(err)
+	s.Require().EqualValues(1, count)
+}
+
+func TestMockTruncateSave(t *testing.T) {
+	a, mock := newMockAdapter(t, WithTruncateSave())
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	m.AddPolicy("p", "p", []string{"alice", "data1", "read"})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`TRUNCATE "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("TRUNCATE TABLE", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.SavePolicy(m))
+
+	// a namespaced adapter deletes the rules of its namespace
+	b, mock := newMockAdapter(t, WithTruncateSave(), WithNamespace("tenant1"))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE namespace = $1`)).WithArgs("tenant1").
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, b.SavePolicy(m))
+
+	_, err = NewAdapterByPgxPool(nil, WithTruncateSave(), WithSwapSave(), SkipTableCreate(), SkipSchemaVerification())
+	require.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// validateTableName checks that name can be used as the rules table name.
+// The name is written between double quotes in the statements, so it can't contain one.
+func validateTableName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: name is empty", ErrInvalidTableName)
+	case len(name) > maxIdentifierLength:
+		return fmt.Errorf("%w: %q is longer than %d bytes", ErrInvalidTableName, name, maxIdentifierLength)
+	case strings.ContainsAny(name, "\"\x00"):
+		return fmt.Errorf("%w: %q contains a double quote or a NUL character", ErrInvalidTableName, name)
+	case !utf8.ValidString(name):
+		return fmt.Errorf("%w: %q is not valid UTF-8", ErrInvalidTableName, name)
+	}
+	return nil
+}
+
+type tableKey struct{}
+
+// TableName returns the name of the rules table
+func (a *Adapter) TableName() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.tableName
+}
+
+// table returns the rules table of the operation running with ctx.
+// The table is read once when an operation starts, so all its statements target the same table
+// even if SetTableName is called meanwhile.
+func (a *Adapter) table(ctx context.Context) string {
+	if table, ok := ctx.Value(tableKey{}).(string); ok {
+		return table
+	}
+	return a.TableName()
+}
+
+// SetTableName points the adapter to the rules table name, e.g. after a blue/green swap,
+// without creating the table. Unless the adapter was created with SkipSchemaVerification,
+// the columns of the table are verified like VerifySchema first, and with WithTableVerification the table is probed,
+// the adapter keeps using its current table if the check fails.
+//
+// Operations started before the switch finish with the previous table, operations started after it use the new one.
+// The relation given to WithReadRelation is not changed.
+//
+// The enforcers must reload their policy after the switch. With WithRevisions, the revision of the previous table
+// is increased once the adapter uses the new one, so the PollingWatchers see the switch as a change.
+// A Watcher is not notified by the adapter, the caller calls its Update after the switch, e.g. from an After hook
+// of the "SetTableName" operation.
+func (a *Adapter) SetTableName(ctx context.Context, name string) (err error) {
+	if err := validateTableName(name); err != nil {
+		return err
+	}
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "SetTableName"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	ctx = context.WithValue(ctx, tableKey{}, name)
+	if a.verifyTable {
+		if err := a.probeTable(ctx); err != nil {
+			return fmt.Errorf("table %q is not usable by the adapter: %w", name, err)
+		}
+	}
+	if !a.skipSchemaVerify {
+		if err := a.verifyRelation(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	previous := a.tableName
+	a.tableName = name
+	a.mu.Unlock()
+
+	if a.revisions && previous != name {
+		ctx = context.WithValue(ctx, tableKey{}, previous)
+		if _, err := a.db.Exec(ctx, fmt.Sprintf(`UPDATE "%v" SET revision = revision + 1`, a.revisionTable(ctx))); err != nil {
+			return fmt.Errorf("switched to table %q but the revision of %q can't be increased: %w", name, previous, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package pgxadapter
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestSetTableName() {
+	ctx := context.Background()
+	s.Require().NoError(s.a.AddPolicy("p", "p", []string{"carol", "data3", "read"}))
+	_, err := s.a.db.Exec(ctx, `CREATE TABLE casbin_rules_green (LIKE casbin_rules INCLUDING ALL)`)
+	s.Require().NoError(err)
+	_, err = s.a.db.Exec(ctx, `INSERT INTO casbin_rules_green SELECT * FROM casbin_rules`)
+	s.Require().NoError(err)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				m, err := model.NewModelFromFile("examples/rbac_model.conf")
+				s.Assert().NoError(err)
+				s.Assert().NoError(s.a.LoadPolicy(m))
+				s.Assert().Len(m.GetPolicy("p", "p"), 5)
+			}
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		name := "casbin_rules_green"
+		if i%2 == 1 {
+			name = DefaultTableName
+		}
+		s.Require().NoError(s.a.SetTableName(ctx, name))
+		s.Require().Equal(name, s.a.TableName())
+	}
+	close(stop)
+	wg.Wait()
+
+	s.Require().ErrorIs(s.a.SetTableName(ctx, "missing_rules"), ErrTableNotExist)
+	s.Require().Equal(DefaultTableName, s.a.TableName())
+}
+
+func TestValidateTableName(t *testing.T) {
+	for _, name := range []string{"casbin_rules", "Casbin-Rules", "règles", strings.Repeat("t", 63)} {
+		require.NoError(t, validateTableName(name), name)
+	}
+	for _, name := range []string{"", strings.Repeat("t", 64), `ru"les`, "ru\x00les", "\xff"} {
+		require.ErrorIs(t, validateTableName(name), ErrInvalidTableName, name)
+	}
+
+	_, err := NewAdapterByPgxPool(nil, WithTableName(`ru"les`))
+	require.ErrorIs(t, err, ErrInvalidTableName)
+}
+
+func TestMockSetTableName(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules" LIMIT 0`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	a, err := NewAdapterByPgxPool(mock, SkipTableCreate(), WithTableVerification(), SkipSchemaVerification())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.ErrorIs(t, a.SetTableName(ctx, ""), ErrInvalidTableName)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "rules_green" LIMIT 0`)).
+		WillReturnError(&pgconn.PgError{Code: codeUndefinedTable})
+	err = a.SetTableName(ctx, "rules_green")
+	require.ErrorIs(t, err, ErrTableNotExist)
+	require.Equal(t, DefaultTableName, a.TableName())
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "rules_green" LIMIT 0`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	require.NoError(t, a.SetTableName(ctx, "rules_green"))
+	require.Equal(t, "rules_green", a.TableName())
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "rules_green"`)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMockSetTableNameDuringLoad(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	a, mock := newMockAdapter(t, WithHooks(Hooks{
+		Before: func(ctx context.Context, op OpInfo) (context.Context, error) {
+			if op.Op == "LoadPolicy" && op.Table == DefaultTableName {
+				close(started)
+				<-release
+			}
+			return ctx, nil
+		},
+	}))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	loaded := make(chan error)
+	go func() {
+		loaded <- a.LoadPolicy(m)
+	}()
+
+	// the load started before the switch keeps reading the previous table
+	<-started
+	require.NoError(t, a.SetTableName(context.Background(), "rules_green"))
+	close(release)
+	require.NoError(t, <-loaded)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "rules_green"`)).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}))
+	require.NoError(t, a.LoadPolicy(m))
+}
+
+func TestMockSetTableNameRevision(t *testing.T) {
+	a, mock := newMockAdapter(t, WithRevisions())
+	ctx := context.Background()
+
+	// the pollers of the previous table see the switch
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "casbin_rules_revision" SET revision = revision + 1`)).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	require.NoError(t, a.SetTableName(ctx, "rules_green"))
+	require.Equal(t, "rules_green", a.TableName())
+
+	// switching to the same table changes nothing
+	require.NoError(t, a.SetTableName(ctx, "rules_green"))
+}
@@ -0,0 +1,207 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PgxConn is the subset of *pgx.Conn used by an adapter created with NewAdapterByConn.
+// It matches pgxmock's PgxConnIface.
+type PgxConn interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Close(ctx context.Context) error
+}
+
+// WithTemporaryTable creates the rules table with CREATE TEMP TABLE, so it only exists in the session
+// of the adapter and is dropped when the adapter is closed, e.g. for tests running in parallel against the same database.
+//
+// A temporary table is only visible to the connection that created it, so the adapter runs every operation
+// on a single connection: the adapter takes a connection out of the pool passed to NewAdapterByDB or created by NewAdapter,
+// or uses the one passed to NewAdapterByConn. Operations are serialized, an operation waits until the previous one
+// released the connection, including the rows of a query and the transactions such as a WithSnapshotScan scan.
+// The callback of ForEachRule must not call the adapter when WithSnapshotScan is used, it would wait for itself.
+//
+// NewAdapterByPgxPool fails with WithTemporaryTable unless the pool is a *pgxpool.Pool,
+// and WithParallelLoad can't be used since other connections don't see the table.
+func WithTemporaryTable() Option {
+	return func(a *Adapter) {
+		a.temporary = true
+	}
+}
+
+// NewAdapterByConn creates new Adapter using a dedicated connection, which is closed when the adapter is closed.
+// Operations are serialized on the connection, see WithTemporaryTable.
+func NewAdapterByConn(conn PgxConn, opts ...Option) (*Adapter, error) {
+	a, err := newAdapter(opts)
+	if err != nil {
+		return nil, err
+	}
+	a.db = newConnPool(conn, nil)
+	if a.role != "" {
+		a.db = &rolePool{PgxPool: a.db, role: a.role}
+	}
+	if a.dryRun {
+		a.db = &dryRunPool{PgxPool: a.db, a: a}
+	}
+	if a.slowThreshold > 0 {
+		a.db = &slowPool{PgxPool: a.db, a: a}
+	}
+	if a.commenter != nil {
+		a.db = &commentPool{PgxPool: a.db, comment: a.commenter}
+	}
+	if a.rewriter != nil {
+		a.db = &rewritePool{PgxPool: a.db, rewrite: a.rewriter}
+	}
+
+	if err := a.setup(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// pinConnection takes a connection out of db for an adapter using a temporary table
+func (a *Adapter) pinConnection(db PgxPool) (PgxPool, error) {
+	pool, ok := db.(*pgxpool.Pool)
+	if !ok {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: WithTemporaryTable needs a *pgxpool.Pool or a connection passed to NewAdapterByConn, got %T", db)
+	}
+	conn, err := pool.Acquire(withOp(context.Background(), "NewAdapter"))
+	if err != nil {
+		return nil, fmt.Errorf("pgadapter.NewAdapter: %w", err)
+	}
+	// the hijacked connection is closed with the adapter, never returned to the pool with the table in its session
+	return newConnPool(conn.Hijack(), pool), nil
+}
+
+// errConnBusy is returned when a connection is used again by the transaction or the rows holding it
+var errConnBusy = errors.New("pgadapter: connection is busy")
+
+// connPool serializes the adapter operations on a single connection.
+// Rows and transactions hold the connection until they are closed, committed or rolled back.
+type connPool struct {
+	conn PgxConn
+	// parent is the pool the connection was taken from, closed with the connection
+	parent PgxPool
+	sem    chan struct{}
+}
+
+func newConnPool(conn PgxConn, parent PgxPool) *connPool {
+	return &connPool{conn: conn, parent: parent, sem: make(chan struct{}, 1)}
+}
+
+// acquire waits until the connection is free or ctx is done
+func (p *connPool) acquire(ctx context.Context) error {
+	select {
+	case p.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %w", errConnBusy, ctx.Err())
+	}
+}
+
+func (p *connPool) release() {
+	<-p.sem
+}
+
+func (p *connPool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	if err := p.acquire(ctx); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer p.release()
+	return p.conn.Exec(ctx, sql, arguments...)
+}
+
+func (p *connPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := p.conn.Query(ctx, sql, args...)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	return &connRows{Rows: rows, release: p.release}, nil
+}
+
+func (p *connPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	if err := p.acquire(ctx); err != nil {
+		return nil, err
+	}
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+	return &connTx{Tx: tx, release: p.release}, nil
+}
+
+// Close closes the connection, dropping the temporary table, then the pool it was taken from
+func (p *connPool) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCloseTimeout)
+	defer cancel()
+	p.conn.Close(ctx)
+	if p.parent != nil {
+		p.parent.Close()
+	}
+}
+
+// connRows releases the connection of a connPool query when the rows are closed
+type connRows struct {
+	pgx.Rows
+	release func()
+	closed  bool
+}
+
+func (r *connRows) Close() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	r.Rows.Close()
+	r.release()
+}
+
+func (r *connRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+// connTx releases the connection of a connPool transaction when it ends
+type connTx struct {
+	pgx.Tx
+	release func()
+	done    bool
+}
+
+func (tx *connTx) end() {
+	if !tx.done {
+		tx.done = true
+		tx.release()
+	}
+}
+
+func (tx *connTx) Commit(ctx context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	defer tx.end()
+	return tx.Tx.Commit(ctx)
+}
+
+func (tx *connTx) Rollback(ctx context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	defer tx.end()
+	return tx.Tx.Rollback(ctx)
+}
@@ -0,0 +1,120 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestTemporaryTable() {
+	ctx := context.Background()
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithTemporaryTable(), WithTableName("temp_rules"))
+	s.Require().NoError(err)
+
+	s.Require().NoError(a.AddPolicies("p", "p", [][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}}))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, err := a.CountRules(ctx, "p")
+			s.Assert().NoError(err)
+			s.Assert().EqualValues(2, count)
+		}()
+	}
+	wg.Wait()
+	s.Require().NoError(a.Close())
+
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+	var exists bool
+	s.Require().NoError(pool.QueryRow(ctx, `SELECT EXISTS (SELECT FROM pg_class WHERE relname = 'temp_rules')`).Scan(&exists))
+	s.Assert().False(exists)
+}
+
+func TestMockTemporaryTable(t *testing.T) {
+	conn, err := pgxmock.NewConn()
+	require.NoError(t, err)
+
+	conn.ExpectExec(regexp.QuoteMeta(`CREATE TEMP TABLE IF NOT EXISTS "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("CREATE TABLE", 0))
+	conn.ExpectQuery(regexp.QuoteMeta(`WHERE table_schema = (SELECT nspname FROM pg_namespace WHERE oid = pg_my_temp_schema()) AND table_name = $1`)).
+		WithArgs("casbin_rules").
+		WillReturnRows(schemaRows("id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"))
+	a, err := NewAdapterByConn(conn, WithTemporaryTable(), WithBootstrapLock())
+	require.NoError(t, err)
+
+	conn.ExpectBegin()
+	conn.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WithArgs(policyID("p", []string{"alice", "data1", "read"}), "p", "alice", "data1", "read", "", "", "").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	conn.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+
+	conn.ExpectClose()
+	require.NoError(t, a.Close())
+	require.NoError(t, conn.ExpectationsWereMet())
+}
+
+func TestMockTemporaryTableInvalid(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	_, err = NewAdapterByPgxPool(mock, WithTemporaryTable())
+	require.ErrorContains(t, err, "WithTemporaryTable needs a *pgxpool.Pool")
+
+	_, err = NewAdapterByPgxPool(mock, WithTemporaryTable(), WithParallelLoad(4))
+	require.EqualError(t, err, "pgadapter.NewAdapter: WithParallelLoad can't be used with WithTemporaryTable")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMockConnPoolSerializes(t *testing.T) {
+	conn, err := pgxmock.NewConn()
+	require.NoError(t, err)
+	p := newConnPool(conn, nil)
+	ctx := context.Background()
+
+	conn.ExpectQuery("SELECT 1").WillReturnRows(pgxmock.NewRows([]string{"n"}).AddRow(1))
+	rows, err := p.Query(ctx, "SELECT 1")
+	require.NoError(t, err)
+
+	// the rows hold the connection
+	busy, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Exec(busy, "SELECT 2")
+	require.ErrorIs(t, err, errConnBusy)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	for rows.Next() {
+	}
+	conn.ExpectBegin()
+	conn.ExpectExec("SELECT 2").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	conn.ExpectCommit()
+	tx, err := p.Begin(ctx)
+	require.NoError(t, err)
+	_, err = tx.Exec(ctx, "SELECT 2")
+	require.NoError(t, err)
+
+	// the transaction holds the connection until it ends
+	busy, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Query(busy, "SELECT 3")
+	require.ErrorIs(t, err, errConnBusy)
+	require.NoError(t, tx.Commit(ctx))
+	require.ErrorIs(t, tx.Rollback(ctx), pgx.ErrTxClosed)
+
+	conn.ExpectExec("SELECT 4").WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	_, err = p.Exec(ctx, "SELECT 4")
+	require.NoError(t, err)
+	require.NoError(t, conn.ExpectationsWereMet())
+}
@@ -0,0 +1,66 @@
+package pgxadapter
+
+import (
+	"context"
+)
+
+// tenantKey is the context key of the tenant set by WithTenantContext
+type tenantKey struct{}
+
+// WithTenantContext returns a copy of ctx carrying the tenant id, used by the adapters created with
+// WithTenantFromContext as the namespace of the operations given ctx. An empty id is no tenant.
+func WithTenantContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant set on ctx by WithTenantContext
+func TenantFromContext(ctx context.Context) (string, bool) {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	return id, id != ""
+}
+
+// WithTenantFromContext makes the methods taking a context use the tenant of the context, see WithTenantContext,
+// as their namespace, so one adapter serves many tenants sharing the rules table, see WithNamespace.
+// Every statement of such an operation, reads, writes and rule ids, is scoped to the tenant of its context.
+//
+// When the context has no tenant the operation uses the namespace of WithNamespace, or the empty namespace,
+// unless required is set, in which case it fails with ErrTenantRequired, except the operations on the table itself
+// such as VerifySchema and Migrate. The methods of the casbin adapter
+// interfaces, such as LoadPolicy and AddPolicy, take no context so they have no tenant.
+func WithTenantFromContext(required bool) Option {
+	return func(a *Adapter) {
+		a.tenantFromContext = true
+		a.tenantRequired = required
+		a.namespaced = true
+	}
+}
+
+// tableOps are the operations on the rules table itself rather than on the rules of a namespace,
+// they don't need a tenant
+var tableOps = map[string]bool{
+	"CreateReplicationSlot": true,
+	"DropReplicationSlot":   true,
+	"EnsureDomainIndexes":   true,
+	"EnsureTable":           true,
+	"InstallIDTrigger":      true,
+	"Migrate":               true,
+	"RepairSchema":          true,
+	"SetTableName":          true,
+	"VerifySchema":          true,
+	"WarmUp":                true,
+}
+
+// withTenant returns ctx with the namespace of its tenant, see WithTenantFromContext
+func (a *Adapter) withTenant(ctx context.Context, op string) (context.Context, error) {
+	if !a.tenantFromContext {
+		return ctx, nil
+	}
+	tenant, ok := TenantFromContext(ctx)
+	if !ok {
+		if a.tenantRequired && !tableOps[op] {
+			return ctx, ErrTenantRequired
+		}
+		return ctx, nil
+	}
+	return context.WithValue(ctx, namespaceKey{}, tenant), nil
+}
@@ -0,0 +1,105 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestTenantFromContext() {
+	a, err := NewAdapterByPgxPool(s.a.db, WithTenantFromContext(true))
+	s.Require().NoError(err)
+	tenant1 := WithTenantContext(context.Background(), "tenant1")
+	tenant2 := WithTenantContext(context.Background(), "tenant2")
+
+	id, err := a.StageAdd(tenant1, "p", "p", []string{"alice", "data1", "read"}, "ops", "")
+	s.Require().NoError(err)
+	s.Require().NoError(a.ApplyStaged(tenant1, id))
+	n, err := a.CountRules(tenant1, "p")
+	s.Require().NoError(err)
+	s.Require().EqualValues(1, n)
+	n, err = a.CountRules(tenant2, "p")
+	s.Require().NoError(err)
+	s.Require().EqualValues(0, n)
+
+	_, err = a.CountRules(context.Background(), "p")
+	s.Require().ErrorIs(err, ErrTenantRequired)
+}
+
+func TestMockTenantFromContext(t *testing.T) {
+	a, mock := newMockAdapter(t, WithTenantFromContext(true))
+	mock.MatchExpectationsInOrder(false)
+
+	// interleaved calls of two tenants only count the rules of their tenant
+	const calls = 20
+	for i := 0; i < calls; i++ {
+		tenant := fmt.Sprintf("tenant%d", i%2)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules" WHERE ptype = $1 AND namespace = $2`)).
+			WithArgs("p", tenant).
+			WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(i % 2)))
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n, err := a.CountRules(WithTenantContext(context.Background(), fmt.Sprintf("tenant%d", i%2)), "p")
+			require.NoError(t, err)
+			require.EqualValues(t, i%2, n)
+		}(i)
+	}
+	wg.Wait()
+
+	// the ids of the rules are the ones of their tenant
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS (SELECT 1 FROM "casbin_rules" WHERE ptype = $1 AND (v0 = $2 OR v0 = '*') AND namespace = $3)`)).
+		WithArgs("p", "alice", "tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+	ok, err := a.ExistsMatchingPolicy(WithTenantContext(context.Background(), "tenant1"), "p", map[int]string{0: "alice"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	ctx, err := a.withTenant(WithTenantContext(context.Background(), "tenant1"), "AddPolicy")
+	require.NoError(t, err)
+	require.Equal(t, ComputeNamespacedPolicyID("tenant1", "p", []string{"alice"}), a.policyLine(ctx, "p", []string{"alice"}).ID)
+
+	_, err = a.CountRules(context.Background(), "p")
+	require.ErrorIs(t, err, ErrTenantRequired)
+	require.EqualError(t, err, `pgadapter.CountRules: table "casbin_rules": pgadapter: tenant required in context`)
+	_, err = a.CountRules(WithTenantContext(context.Background(), ""), "p")
+	require.ErrorIs(t, err, ErrTenantRequired)
+}
+
+func TestMockTenantFromContextOptional(t *testing.T) {
+	a, mock := newMockAdapter(t, WithNamespace("shared"), WithTenantFromContext(false))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules" WHERE namespace = $1`)).
+		WithArgs("shared").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(3)))
+	n, err := a.CountRules(context.Background(), "")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, n)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules" WHERE namespace = $1`)).
+		WithArgs("tenant1").
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(1)))
+	n, err = a.CountRules(WithTenantContext(context.Background(), "tenant1"), "")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+}
+
+func TestMockTenantRequiredTableOps(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+
+	// the schema is verified without tenant when the adapter starts
+	mock.ExpectQuery("information_schema.columns").
+		WithArgs("casbin_rules").
+		WillReturnRows(schemaRows("id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5", "namespace"))
+	_, err = NewAdapterByPgxPool(mock, SkipTableCreate(), WithTenantFromContext(true))
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,128 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithOperationTimeout limits the duration of every adapter operation, a longer operation is canceled
+// and returns a *TimeoutError. WithReadTimeout and WithWriteTimeout override it for the reads and the writes.
+// A deadline of the context given to the methods taking one still applies when it is earlier.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.opTimeout = d
+	}
+}
+
+// WithReadTimeout limits the duration of the operations only reading the database, such as LoadPolicy,
+// LoadFilteredPolicy and CountRules, see WithOperationTimeout
+func WithReadTimeout(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.readTimeout = d
+	}
+}
+
+// WithWriteTimeout limits the duration of the operations writing the database, such as AddPolicy,
+// RemoveFilteredPolicy and SavePolicy, see WithOperationTimeout
+func WithWriteTimeout(d time.Duration) Option {
+	return func(a *Adapter) {
+		a.writeTimeout = d
+	}
+}
+
+// readOps are the operations only reading the database, the others are writes
+var readOps = map[string]bool{
+	"CountRules":              true,
+	"CountRulesByPtype":       true,
+	"ExistsMatchingPolicy":    true,
+	"ExplainLoad":             true,
+	"ExplainRemoveFiltered":   true,
+	"FindConflictingRules":    true,
+	"FindOrphanGroupingRules": true,
+	"GetDistinctValues":       true,
+	"ForEachRule":             true,
+	"ListStagedChanges":       true,
+	"LoadDomainPolicy":        true,
+	"LoadFilteredPolicy":      true,
+	"LoadModelText":           true,
+	"LoadPolicy":              true,
+	"LoadPolicyAt":            true,
+	"LoadPolicyDelta":         true,
+	"LoadPolicyPage":          true,
+	"LoadSubjectPolicy":       true,
+	"Revision":                true,
+	"SearchRules":             true,
+	"VerifyIDs":               true,
+	"VerifyParity":            true,
+	"VerifySchema":            true,
+	"WarmUp":                  true,
+}
+
+// TimeoutError is wrapped by the errors of the operations canceled by WithOperationTimeout,
+// WithReadTimeout or WithWriteTimeout
+type TimeoutError struct {
+	// Op is the canceled operation
+	Op string
+	// Write is set when the timeout is the one of the writes
+	Write bool
+	// Timeout is the timeout of the operation
+	Timeout time.Duration
+	// Err is the error of the canceled statement
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	kind := "read"
+	if e.Write {
+		kind = "write"
+	}
+	return fmt.Sprintf("%v timeout of %v exceeded: %v", kind, e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// opTimeout is the deadline set on an operation by the timeout options
+type opTimeout struct {
+	op       string
+	write    bool
+	timeout  time.Duration
+	deadline time.Time
+	cancel   context.CancelFunc
+}
+
+// withTimeout returns ctx with the deadline of the timeout of op, the returned opTimeout is nil when op has none
+func (a *Adapter) withTimeout(ctx context.Context, op string) (context.Context, *opTimeout) {
+	t := &opTimeout{op: op, write: !readOps[op], timeout: a.opTimeout}
+	if t.write && a.writeTimeout > 0 {
+		t.timeout = a.writeTimeout
+	} else if !t.write && a.readTimeout > 0 {
+		t.timeout = a.readTimeout
+	}
+	if t.timeout <= 0 {
+		return ctx, nil
+	}
+	t.deadline = time.Now().Add(t.timeout)
+	ctx, t.cancel = context.WithDeadline(ctx, t.deadline)
+	return ctx, t
+}
+
+// end releases the deadline and wraps *errp with a *TimeoutError when the deadline canceled the operation,
+// rather than an earlier deadline of the caller
+func (t *opTimeout) end(ctx context.Context, errp *error) {
+	if t == nil {
+		return
+	}
+	defer t.cancel()
+	if *errp == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) || time.Now().Before(t.deadline) {
+		return
+	}
+	*errp = &TimeoutError{Op: t.op, Write: t.write, Timeout: t.timeout, Err: *errp}
+}
@@ -0,0 +1,79 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestReadWriteTimeout() {
+	a, err := NewAdapterWithOptions(os.Getenv("PG_CONN"), WithReadTimeout(time.Minute), WithWriteTimeout(time.Nanosecond))
+	s.Require().NoError(err)
+	defer a.Close()
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a.LoadPolicy(m))
+
+	err = a.AddPolicy("p", "p", []string{"alice", "data3", "read"})
+	s.Require().ErrorIs(err, ErrTimeout)
+	s.Require().ErrorIs(err, context.DeadlineExceeded)
+}
+
+func TestMockReadWriteTimeout(t *testing.T) {
+	a, mock := newMockAdapter(t, WithOperationTimeout(30*time.Millisecond), WithReadTimeout(300*time.Millisecond))
+	cols := []string{"id", "ptype", "v0", "v1", "v2", "v3", "v4", "v5"}
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+
+	// a slow read is within the read timeout
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillDelayFor(100 * time.Millisecond).
+		WillReturnRows(pgxmock.NewRows(cols).AddRow("1", "p", "alice", "data1", "read", "", "", ""))
+	require.NoError(t, a.LoadPolicy(m))
+
+	// the same delay exceeds the shared timeout used by the writes
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WillDelayFor(100 * time.Millisecond).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectRollback()
+	err = a.AddPolicy("p", "p", []string{"bob", "data2", "write"})
+	require.ErrorIs(t, err, ErrTimeout)
+	var timeoutErr *TimeoutError
+	require.ErrorAs(t, err, &timeoutErr)
+	require.Equal(t, TimeoutError{Op: "AddPolicy", Write: true, Timeout: 30 * time.Millisecond, Err: timeoutErr.Err}, *timeoutErr)
+	require.ErrorContains(t, err, "pgadapter.AddPolicy: table \"casbin_rules\": write timeout of 30ms exceeded")
+
+	// a read slower than the read timeout
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, ptype, v0, v1, v2, v3, v4, v5 FROM "casbin_rules"`)).
+		WillDelayFor(time.Second).
+		WillReturnRows(pgxmock.NewRows(cols))
+	err = a.LoadPolicy(m)
+	require.ErrorAs(t, err, &timeoutErr)
+	require.False(t, timeoutErr.Write)
+	require.Equal(t, 300*time.Millisecond, timeoutErr.Timeout)
+}
+
+func TestMockTimeoutCallerDeadline(t *testing.T) {
+	a, mock := newMockAdapter(t, WithReadTimeout(time.Minute))
+
+	// the earlier deadline of the caller is not reported as the adapter timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "casbin_rules"`)).
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(pgxmock.NewRows([]string{"count"}).AddRow(int64(0)))
+	_, err := a.CountRules(ctx, "")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrTimeout)
+
+	_, err = NewAdapterByPgxPool(mock, SkipTableCreate(), WithWriteTimeout(-time.Second))
+	require.EqualError(t, err, "pgadapter.NewAdapter: timeouts can't be negative")
+}
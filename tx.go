@@ -0,0 +1,174 @@
+package pgxadapter
+
+import (
+	"context"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// TxAdapter runs the write methods of the adapter in the transaction of WithTx
+type TxAdapter interface {
+	SavePolicy(model model.Model) error
+	AddPolicy(sec string, ptype string, rule []string) error
+	AddPolicies(sec string, ptype string, rules [][]string) error
+	RemovePolicy(sec string, ptype string, rule []string) error
+	RemovePolicies(sec string, ptype string, rules [][]string) error
+	RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error
+	UpdatePolicy(sec string, ptype string, oldRule, newRule []string) error
+	UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error
+	UpdateFilteredPolicies(sec string, ptype string, newPolicies [][]string, fieldIndex int, fieldValues ...string) ([][]string, error)
+	// WithTx calls fn with the TxAdapter itself, nested calls run in the transaction of the outermost WithTx
+	WithTx(ctx context.Context, fn func(tx TxAdapter) error) error
+}
+
+// WithTx calls fn with a TxAdapter whose methods write in a single transaction, so several changes are applied atomically:
+//
+//	err := a.WithTx(ctx, func(tx pgxadapter.TxAdapter) error {
+//		if err := tx.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+//			return err
+//		}
+//		return tx.AddPolicy("p", "p", []string{"alice", "data1", "write"})
+//	})
+//
+// The transaction is committed when fn returns nil, and rolled back when fn returns an error or panics.
+// Each method of the TxAdapter runs in a savepoint, so a method failing leaves the transaction usable
+// and doesn't undo the methods that succeeded before it, unless fn returns the error.
+// Calling WithTx on the TxAdapter reuses the transaction instead of starting another one.
+// The TxAdapter must not be used concurrently nor after fn returns.
+func (a *Adapter) WithTx(ctx context.Context, fn func(tx TxAdapter) error) (err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "WithTx"})
+	if err != nil {
+		return err
+	}
+	defer finish(&err)
+
+	return a.runTx(ctx, fn, func(tx pgx.Tx) error {
+		return tx.Commit(ctx)
+	})
+}
+
+// runTx calls fn with a TxAdapter writing in a transaction ended by end when fn returns nil, see WithTx
+func (a *Adapter) runTx(ctx context.Context, fn func(tx TxAdapter) error, end func(tx pgx.Tx) error) error {
+	tx, err := a.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(&txAdapter{a: a, ctx: context.WithValue(ctx, txKey{}, &txPool{tx: tx})}); err != nil {
+		return err
+	}
+	return end(tx)
+}
+
+type txKey struct{}
+
+// conn returns the pool running the statements of the operation of ctx, which is bound to the transaction of WithTx if any
+func (a *Adapter) conn(ctx context.Context) PgxPool {
+	if pool, ok := ctx.Value(txKey{}).(*txPool); ok {
+		return pool
+	}
+	return a.db
+}
+
+// txAdapter runs the adapter methods with a context carrying the transaction of WithTx
+type txAdapter struct {
+	a   *Adapter
+	ctx context.Context
+}
+
+func (t *txAdapter) SavePolicy(model model.Model) error {
+	return t.a.savePolicy(t.ctx, model)
+}
+
+func (t *txAdapter) AddPolicy(sec string, ptype string, rule []string) error {
+	return t.a.addPolicy(t.ctx, sec, ptype, rule)
+}
+
+func (t *txAdapter) AddPolicies(sec string, ptype string, rules [][]string) error {
+	return t.a.addPolicies(t.ctx, sec, ptype, rules)
+}
+
+func (t *txAdapter) RemovePolicy(sec string, ptype string, rule []string) error {
+	return t.a.removePolicy(t.ctx, sec, ptype, rule)
+}
+
+func (t *txAdapter) RemovePolicies(sec string, ptype string, rules [][]string) error {
+	return t.a.removePolicies(t.ctx, sec, ptype, rules)
+}
+
+func (t *txAdapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
+	return t.a.removeFilteredPolicy(t.ctx, sec, ptype, fieldIndex, fieldValues...)
+}
+
+func (t *txAdapter) UpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	return t.a.updatePolicies(t.ctx, sec, ptype, [][]string{oldRule}, [][]string{newRule})
+}
+
+func (t *txAdapter) UpdatePolicies(sec string, ptype string, oldRules, newRules [][]string) error {
+	return t.a.updatePolicies(t.ctx, sec, ptype, oldRules, newRules)
+}
+
+func (t *txAdapter) UpdateFilteredPolicies(sec string, ptype string, newPolicies [][]string, fieldIndex int, fieldValues ...string) ([][]string, error) {
+	return t.a.updateFilteredPolicies(t.ctx, sec, ptype, newPolicies, fieldIndex, fieldValues...)
+}
+
+func (t *txAdapter) WithTx(ctx context.Context, fn func(tx TxAdapter) error) error {
+	return fn(t)
+}
+
+// savepointName is the savepoint of the operations run in the transaction of WithTx, they never overlap
+const savepointName = "pgadapter_op"
+
+// txPool runs the statements in the transaction of WithTx,
+// the transactions begun by the operations are savepoints of it
+type txPool struct {
+	tx pgx.Tx
+}
+
+func (p *txPool) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	return p.tx.Exec(ctx, sql, arguments...)
+}
+
+func (p *txPool) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return p.tx.Query(ctx, sql, args...)
+}
+
+func (p *txPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	if _, err := p.tx.Exec(ctx, "SAVEPOINT "+savepointName); err != nil {
+		return nil, err
+	}
+	return &savepointTx{Tx: p.tx}, nil
+}
+
+// Close does nothing, the transaction ends when WithTx returns
+func (p *txPool) Close() {}
+
+// savepointTx is a savepoint of the transaction of WithTx
+type savepointTx struct {
+	pgx.Tx
+	done bool
+}
+
+func (tx *savepointTx) Commit(ctx context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+	_, err := tx.Tx.Exec(ctx, "RELEASE SAVEPOINT "+savepointName)
+	return err
+}
+
+func (tx *savepointTx) Rollback(ctx context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+	if _, err := tx.Tx.Exec(ctx, "ROLLBACK TO SAVEPOINT "+savepointName); err != nil {
+		return err
+	}
+	_, err := tx.Tx.Exec(ctx, "RELEASE SAVEPOINT "+savepointName)
+	return err
+}
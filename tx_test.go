@@ -0,0 +1,104 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestWithTx() {
+	ctx := context.Background()
+	errStop := errors.New("stop")
+	err := s.a.WithTx(ctx, func(tx TxAdapter) error {
+		s.Require().NoError(tx.RemovePolicy("p", "p", []string{"alice", "data1", "read"}))
+		s.Require().NoError(tx.AddPolicies("p", "p", [][]string{{"carol", "data3", "read"}, {"dave", "data3", "write"}}))
+		s.Require().NoError(tx.UpdatePolicy("p", "p", []string{"bob", "data2", "write"}, []string{"bob", "data2", "read"}))
+		return errStop
+	})
+	s.Require().ErrorIs(err, errStop)
+	s.Require().NoError(s.e.LoadPolicy())
+	s.assertPolicy(
+		[][]string{{"alice", "data1", "read"}, {"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}},
+		s.e.GetPolicy(),
+	)
+
+	err = s.a.WithTx(ctx, func(tx TxAdapter) error {
+		if err := tx.RemovePolicy("p", "p", []string{"alice", "data1", "read"}); err != nil {
+			return err
+		}
+		// a failed step doesn't abort the transaction
+		s.Require().ErrorIs(tx.UpdatePolicy("p", "p", []string{"nobody", "data1", "read"}, []string{"nobody", "data1", "write"}), ErrPolicyNotFound)
+		return tx.WithTx(ctx, func(tx TxAdapter) error {
+			return tx.AddPolicy("p", "p", []string{"carol", "data3", "read"})
+		})
+	})
+	s.Require().NoError(err)
+	s.e, err = casbin.NewEnforcer("examples/rbac_model.conf", s.a)
+	s.Require().NoError(err)
+	s.assertPolicy(
+		[][]string{{"bob", "data2", "write"}, {"data2_admin", "data2", "read"}, {"data2_admin", "data2", "write"}, {"carol", "data3", "read"}},
+		s.e.GetPolicy(),
+	)
+}
+
+func TestMockWithTx(t *testing.T) {
+	a, mock := newMockAdapter(t)
+	ctx := context.Background()
+	alice := []string{"alice", "data1", "read"}
+	bob := []string{"bob", "data2", "write"}
+
+	// a failing step rolls back the steps before it
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("SAVEPOINT", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "casbin_rules" WHERE id=$1`)).WithArgs(policyID("p", alice)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec("RELEASE SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("RELEASE", 0))
+	mock.ExpectExec("SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("SAVEPOINT", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(insertArgs(bob)...).
+		WillReturnError(&pgconn.PgError{Code: "23514", Message: "check violation"})
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("ROLLBACK", 0))
+	mock.ExpectExec("RELEASE SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("RELEASE", 0))
+	mock.ExpectRollback()
+	err := a.WithTx(ctx, func(tx TxAdapter) error {
+		if err := tx.RemovePolicy("p", "p", alice); err != nil {
+			return err
+		}
+		return tx.AddPolicy("p", "p", bob)
+	})
+	require.ErrorContains(t, err, "check violation")
+	var opErr *OpError
+	require.ErrorAs(t, err, &opErr)
+	require.Equal(t, "AddPolicy", opErr.Op)
+
+	// the transaction is committed when fn returns nil, nested calls reuse it
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("SAVEPOINT", 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).WithArgs(insertArgs(alice, bob)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 2))
+	mock.ExpectExec("RELEASE SAVEPOINT pgadapter_op").WillReturnResult(pgxmock.NewResult("RELEASE", 0))
+	mock.ExpectCommit()
+	err = a.WithTx(ctx, func(tx TxAdapter) error {
+		return tx.WithTx(ctx, func(tx TxAdapter) error {
+			return tx.AddPolicies("p", "p", [][]string{alice, bob})
+		})
+	})
+	require.NoError(t, err)
+}
+
+func TestMockWithTxPanic(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	require.PanicsWithValue(t, "boom", func() {
+		a.WithTx(context.Background(), func(tx TxAdapter) error {
+			panic("boom")
+		})
+	})
+}
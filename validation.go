@@ -0,0 +1,67 @@
+package pgxadapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/casbin/casbin/v2/model"
+)
+
+// ValidationError is returned when a rule is rejected by the configured RuleValidator
+type ValidationError struct {
+	Sec    string
+	Ptype  string
+	Rule   []string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid rule %v for %v: %v", strings.Join(e.Rule, ", "), e.Ptype, e.Reason)
+}
+
+// RuleValidator checks a rule before it is persisted by AddPolicy, AddPolicies and the Update methods
+type RuleValidator func(sec string, ptype string, rule []string) error
+
+// WithRuleValidator sets a custom validator for rules being written.
+// Validation is disabled unless a validator is configured.
+func WithRuleValidator(v RuleValidator) Option {
+	return func(a *Adapter) {
+		a.validator = v
+	}
+}
+
+// WithModelValidation validates rules being written against the token count of the model's ptype.
+// A *ValidationError is returned when the ptype is not defined or the rule length doesn't match.
+func WithModelValidation(m model.Model) Option {
+	return WithRuleValidator(ModelValidator(m))
+}
+
+// ModelValidator returns a RuleValidator checking rules against the definitions of the model
+func ModelValidator(m model.Model) RuleValidator {
+	return func(sec string, ptype string, rule []string) error {
+		ast, ok := m[sec][ptype]
+		if !ok {
+			return &ValidationError{Sec: sec, Ptype: ptype, Rule: rule, Reason: "ptype is not defined in the model"}
+		}
+		if len(rule) != len(ast.Tokens) {
+			return &ValidationError{
+				Sec:    sec,
+				Ptype:  ptype,
+				Rule:   rule,
+				Reason: fmt.Sprintf("expected %d values, got %d", len(ast.Tokens), len(rule)),
+			}
+		}
+		return nil
+	}
+}
+
+// validateRule checks the rule fits into the table and passes the configured validator
+func (a *Adapter) validateRule(sec string, ptype string, rule []string) error {
+	if err := a.checkRule(rule); err != nil {
+		return err
+	}
+	if a.validator != nil {
+		return a.validator(sec, ptype, rule)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package pgxadapter
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelValidation(t *testing.T) {
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	require.NoError(t, err)
+	a, mock := newMockAdapter(t, WithModelValidation(m))
+
+	tests := []struct {
+		name  string
+		sec   string
+		ptype string
+		rule  []string
+	}{
+		{"too few", "p", "p", []string{"alice", "data1"}},
+		{"too many", "p", "p", []string{"alice", "data1", "read", "allow"}},
+		{"unknown ptype", "p", "p2", []string{"alice", "data1", "read"}},
+		{"grouping too many", "g", "g", []string{"alice", "admin", "domain1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.AddPolicy(tt.sec, tt.ptype, tt.rule)
+			var verr *ValidationError
+			require.True(t, errors.As(err, &verr), "unexpected error %v", err)
+			require.Equal(t, tt.ptype, verr.Ptype)
+			require.Equal(t, tt.rule, verr.Rule)
+
+			err = a.AddPolicies(tt.sec, tt.ptype, [][]string{tt.rule})
+			require.True(t, errors.As(err, &verr), "unexpected error %v", err)
+
+			err = a.UpdatePolicy(tt.sec, tt.ptype, []string{"bob", "data2", "write"}, tt.rule)
+			require.True(t, errors.As(err, &verr), "unexpected error %v", err)
+		})
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p", []string{"alice", "data1", "read"}))
+}
+
+func TestValidationSkipped(t *testing.T) {
+	a, mock := newMockAdapter(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "casbin_rules"`)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+	require.NoError(t, a.AddPolicy("p", "p2", []string{"alice", "data1", "read", "allow"}))
+}
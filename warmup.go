@@ -0,0 +1,93 @@
+package pgxadapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// WarmUpStats describes the work done by WarmUp
+type WarmUpStats struct {
+	// Conns is the number of connections warmed up
+	Conns int
+	// Statements is the number of statements prepared on each connection
+	Statements int
+	// Duration is how long WarmUp took
+	Duration time.Duration
+}
+
+// warmUpStatements returns the statements run by the most frequent adapter operations
+func (a *Adapter) warmUpStatements(ctx context.Context) []string {
+	load, _ := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v"`, a.columns(), a.readTable(ctx)), nil, true)
+	filtered, _ := a.inNamespace(ctx, fmt.Sprintf(`SELECT %v FROM "%v" WHERE ptype=$1`, a.columns(), a.readTable(ctx)), []any{"p"}, false)
+	remove, _ := a.byID(ctx, fmt.Sprintf(`DELETE FROM "%v" WHERE id=$1`, a.table(ctx)), []any{""})
+	return []string{
+		load,
+		filtered,
+		a.insertSQL(ctx) + " ON CONFLICT DO NOTHING",
+		remove,
+	}
+}
+
+// pool returns the *pgxpool.Pool used by the adapter under the pools wrapping it, if any
+func (a *Adapter) pool() (*pgxpool.Pool, bool) {
+	db := a.db
+	for {
+		w, ok := db.(interface{ unwrap() PgxPool })
+		if !ok {
+			break
+		}
+		db = w.unwrap()
+	}
+	pool, ok := db.(*pgxpool.Pool)
+	return pool, ok
+}
+
+// WarmUp opens up to minConns connections of the pool and prepares the statements of the main adapter operations
+// on each of them, so the first operations after startup don't pay for connecting and preparing.
+// The statements are prepared with their SQL as name, which pgx looks up before preparing a query itself.
+// minConns is capped at the size of the pool, and WarmUp does nothing when the adapter doesn't use a *pgxpool.Pool.
+func (a *Adapter) WarmUp(ctx context.Context, minConns int) (_ WarmUpStats, err error) {
+	ctx, finish, err := a.start(ctx, OpInfo{Op: "WarmUp"})
+	if err != nil {
+		return WarmUpStats{}, err
+	}
+	defer finish(&err)
+
+	start := time.Now()
+	pool, ok := a.pool()
+	if !ok || minConns < 1 {
+		return WarmUpStats{Duration: time.Since(start)}, nil
+	}
+	if size := int(pool.Config().MaxConns); minConns > size {
+		minConns = size
+	}
+
+	// the connections are held until all are acquired, so each one is a different connection
+	statements := a.warmUpStatements(ctx)
+	conns := make([]*pgxpool.Conn, 0, minConns)
+	defer func() {
+		for _, conn := range conns {
+			conn.Release()
+		}
+	}()
+	for i := 0; i < minConns; i++ {
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return WarmUpStats{}, err
+		}
+		conns = append(conns, conn)
+		for _, sql := range statements {
+			if a.rewriter != nil {
+				sql, _ = a.rewriter("WarmUp", sql, nil)
+			}
+			if _, err := conn.Conn().Prepare(ctx, sql, sql); err != nil {
+				return WarmUpStats{}, err
+			}
+		}
+	}
+
+	return WarmUpStats{Conns: len(conns), Statements: len(statements), Duration: time.Since(start)}, nil
+}
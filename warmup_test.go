@@ -0,0 +1,54 @@
+package pgxadapter
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestWarmUp() {
+	ctx := context.Background()
+	cfg, err := pgxpool.ParseConfig(os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	cfg.MaxConns = 1
+
+	a, err := NewAdapterWithOptions(cfg)
+	s.Require().NoError(err)
+	defer a.Close()
+
+	stats, err := a.WarmUp(ctx, 4)
+	s.Require().NoError(err)
+	s.Require().Equal(1, stats.Conns)
+	s.Require().Equal(len(a.warmUpStatements(context.Background())), stats.Statements)
+
+	prepared := func() int {
+		rows, err := a.db.Query(ctx, `SELECT count(*) FROM pg_prepared_statements`)
+		s.Require().NoError(err)
+		defer rows.Close()
+		var n int
+		s.Require().True(rows.Next())
+		s.Require().NoError(rows.Scan(&n))
+		return n
+	}
+	before := prepared()
+
+	m, err := model.NewModelFromFile("examples/rbac_model.conf")
+	s.Require().NoError(err)
+	s.Require().NoError(a.LoadPolicy(m))
+	s.Require().NoError(a.AddPolicy("p", "p", []string{"alice", "data3", "read"}))
+	s.Require().NoError(a.RemovePolicy("p", "p", []string{"alice", "data3", "read"}))
+	s.Require().Equal(before, prepared())
+}
+
+func TestMockWarmUp(t *testing.T) {
+	a, _ := newMockAdapter(t)
+
+	stats, err := a.WarmUp(context.Background(), 4)
+	require.NoError(t, err)
+	require.Zero(t, stats.Conns)
+	require.Zero(t, stats.Statements)
+}
@@ -0,0 +1,370 @@
+package pgxadapter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DefaultWatcherChannel is the channel used by the watcher unless WithChannel is given
+const DefaultWatcherChannel = "casbin_rules"
+
+// Default delays between the attempts to connect again after the watcher connection is lost, see WithReconnectBackoff
+const (
+	DefaultReconnectMinDelay = 100 * time.Millisecond
+	DefaultReconnectMaxDelay = 30 * time.Second
+)
+
+// WatcherEventKind is the kind of a WatcherEvent
+type WatcherEventKind int
+
+const (
+	// WatcherDisconnected is sent when the listening connection fails
+	WatcherDisconnected WatcherEventKind = iota
+	// WatcherReconnectFailed is sent when an attempt to connect again fails, the next attempt is made after Delay
+	WatcherReconnectFailed
+	// WatcherReconnected is sent when the watcher listens again, before the update callback is called to resync
+	WatcherReconnected
+)
+
+func (k WatcherEventKind) String() string {
+	switch k {
+	case WatcherDisconnected:
+		return "disconnected"
+	case WatcherReconnectFailed:
+		return "reconnect failed"
+	case WatcherReconnected:
+		return "reconnected"
+	}
+	return fmt.Sprintf("WatcherEventKind(%d)", int(k))
+}
+
+// WatcherEvent describes a change of the watcher connection, given to the WithWatcherEvents handler
+type WatcherEvent struct {
+	Kind WatcherEventKind
+	// Attempt is the number of attempts made to connect again since the connection was lost
+	Attempt int
+	// Delay is the delay before the next attempt when Kind is WatcherReconnectFailed
+	Delay time.Duration
+	// Err is the error of the connection or of the failed attempt
+	Err error
+}
+
+// WatcherOption configures a Watcher
+type WatcherOption func(w *Watcher)
+
+// WithChannel sets the channel the watcher notifies and listens to
+func WithChannel(channel string) WatcherOption {
+	return func(w *Watcher) {
+		w.channel = channel
+	}
+}
+
+// WithReconnectBackoff sets the delays between the attempts to connect again when the listening connection is lost.
+// The delay starts at min and doubles after every failed attempt up to max, each delay is randomized between half and all of it.
+func WithReconnectBackoff(min, max time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		w.minDelay = min
+		w.maxDelay = max
+	}
+}
+
+// WithWatcherEvents sets a function called when the listening connection is lost, fails to connect again or is back,
+// e.g. to log or to record metrics. fn is called from the listen loop and must not block.
+func WithWatcherEvents(fn func(WatcherEvent)) WatcherOption {
+	return func(w *Watcher) {
+		w.events = fn
+	}
+}
+
+// WithDebounce coalesces the notifications received within window into a single call of the update callback,
+// with the payload of the last one. The callback is called window after the first notification of a burst,
+// so at most once per window and always after the last notification.
+// A notification received while the callback runs is delivered by another call, window after the callback returns.
+// The default window is 0, the callback is called for every notification.
+func WithDebounce(window time.Duration) WatcherOption {
+	return func(w *Watcher) {
+		w.debounce = window
+	}
+}
+
+// WithInstanceID sets the id sent as payload by Update, a random id is generated by default.
+// The watcher ignores the notifications carrying its own id unless WithNotifySelf is used,
+// so the instance that changed the policy doesn't reload it.
+func WithInstanceID(id string) WatcherOption {
+	return func(w *Watcher) {
+		w.id = id
+	}
+}
+
+// WithNotifySelf makes the watcher call the update callback for the notifications sent by its own Update
+func WithNotifySelf() WatcherOption {
+	return func(w *Watcher) {
+		w.notifySelf = true
+	}
+}
+
+// listenConn is the subset of *pgx.Conn used to listen to notifications
+type listenConn interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// Watcher is a casbin watcher using postgres LISTEN and NOTIFY.
+// Update notifies the channel and the update callback of the other watchers listening to it is called
+// with the instance id of the notifying watcher as payload.
+//
+// The watcher listens on a dedicated connection. When it is lost, e.g. after a failover or when a proxy closes idle connections,
+// the watcher connects again with an exponential backoff and, since the notifications sent meanwhile are lost,
+// calls the update callback once with an empty payload after listening again so the enforcer reloads its policy.
+type Watcher struct {
+	notifier execer
+	connect  func(ctx context.Context) (listenConn, error)
+	channel  string
+	minDelay time.Duration
+	maxDelay time.Duration
+	events   func(WatcherEvent)
+	debounce time.Duration
+	// id is the payload of Update, see WithInstanceID
+	id         string
+	notifySelf bool
+
+	mu       sync.Mutex
+	callback func(string)
+	closed   bool
+	// the debounced notifications, the timer is set while a call is scheduled or running
+	timer   *time.Timer
+	pending string
+	dirty   bool
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ persist.Watcher = (*Watcher)(nil)
+
+// NewWatcher creates a watcher notifying with pool and listening on a connection taken out of pool.
+// The watcher listens when NewWatcher returns, Close must be called to stop it.
+func NewWatcher(ctx context.Context, pool *pgxpool.Pool, opts ...WatcherOption) (*Watcher, error) {
+	return newWatcher(ctx, pool, func(ctx context.Context) (listenConn, error) {
+		conn, err := pool.Acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// the connection listens until it is closed, it doesn't go back to the pool
+		return conn.Hijack(), nil
+	}, opts)
+}
+
+// newWatcher creates a watcher notifying with notifier and listening on the connections returned by connect
+func newWatcher(ctx context.Context, notifier execer, connect func(ctx context.Context) (listenConn, error), opts []WatcherOption) (*Watcher, error) {
+	w := &Watcher{
+		notifier: notifier,
+		connect:  connect,
+		channel:  DefaultWatcherChannel,
+		minDelay: DefaultReconnectMinDelay,
+		maxDelay: DefaultReconnectMaxDelay,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.id == "" {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("pgadapter.NewWatcher: generate instance id: %w", err)
+		}
+		w.id = hex.EncodeToString(b)
+	}
+	if w.channel == "" {
+		return nil, errors.New("pgadapter.NewWatcher: channel is empty")
+	}
+	if w.minDelay <= 0 || w.maxDelay < w.minDelay {
+		return nil, fmt.Errorf("pgadapter.NewWatcher: invalid reconnect backoff %v to %v", w.minDelay, w.maxDelay)
+	}
+	if w.debounce < 0 {
+		return nil, fmt.Errorf("pgadapter.NewWatcher: debounce window must not be negative, got %v", w.debounce)
+	}
+
+	conn, err := w.listen(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("pgadapter.NewWatcher: %w", err)
+	}
+	loopCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	go w.run(loopCtx, conn)
+	return w, nil
+}
+
+// listen connects and listens to the channel
+func (w *Watcher) listen(ctx context.Context) (listenConn, error) {
+	conn, err := w.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{w.channel}.Sanitize()); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+	return conn, nil
+}
+
+// run receives the notifications until ctx is canceled, connecting again when the connection is lost
+func (w *Watcher) run(ctx context.Context, conn listenConn) {
+	defer close(w.done)
+	for {
+		err := w.receive(ctx, conn)
+		conn.Close(context.Background())
+		if ctx.Err() != nil {
+			return
+		}
+		w.event(WatcherEvent{Kind: WatcherDisconnected, Err: err})
+
+		conn = w.reconnect(ctx)
+		if conn == nil {
+			return
+		}
+		// notifications sent while the watcher wasn't listening are lost
+		w.notify("")
+	}
+}
+
+// receive calls the update callback with every notification until the connection fails or ctx is canceled
+func (w *Watcher) receive(ctx context.Context, conn listenConn) error {
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		if n.Payload == w.id && !w.notifySelf {
+			continue
+		}
+		w.notify(n.Payload)
+	}
+}
+
+// reconnect listens again with an exponential backoff, it returns nil when ctx is canceled
+func (w *Watcher) reconnect(ctx context.Context) listenConn {
+	delay := w.minDelay
+	for attempt := 1; ; attempt++ {
+		conn, err := w.listen(ctx)
+		if err == nil {
+			w.event(WatcherEvent{Kind: WatcherReconnected, Attempt: attempt})
+			return conn
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		// full delays would make the watchers of every instance reconnect at the same time
+		wait := delay/2 + mathrand.N(delay/2+1)
+		w.event(WatcherEvent{Kind: WatcherReconnectFailed, Attempt: attempt, Delay: wait, Err: err})
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, w.maxDelay)
+	}
+}
+
+func (w *Watcher) event(e WatcherEvent) {
+	if w.events != nil {
+		w.events(e)
+	}
+}
+
+// notify calls the update callback with payload, or schedules the call when WithDebounce is used
+func (w *Watcher) notify(payload string) {
+	if w.debounce <= 0 {
+		w.call(payload)
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = payload
+	w.dirty = true
+	if w.timer == nil && !w.closed {
+		w.timer = time.AfterFunc(w.debounce, w.flush)
+	}
+}
+
+// flush calls the update callback with the last debounced payload
+func (w *Watcher) flush() {
+	w.mu.Lock()
+	payload := w.pending
+	w.dirty = false
+	w.mu.Unlock()
+
+	w.call(payload)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.dirty && !w.closed {
+		w.timer.Reset(w.debounce)
+		return
+	}
+	w.timer = nil
+}
+
+// call calls the update callback, if any, unless the watcher is closed
+func (w *Watcher) call(payload string) {
+	w.mu.Lock()
+	callback := w.callback
+	if w.closed {
+		callback = nil
+	}
+	w.mu.Unlock()
+	if callback != nil {
+		callback(payload)
+	}
+}
+
+// SetUpdateCallback sets the function called when the channel is notified, a classic callback is Enforcer.LoadPolicy
+func (w *Watcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	w.callback = callback
+	w.mu.Unlock()
+	return nil
+}
+
+// InstanceID returns the id sent as payload by Update
+func (w *Watcher) InstanceID() string {
+	return w.id
+}
+
+// Update notifies the channel with the instance id as payload so the other watchers listening to it call their update callback
+func (w *Watcher) Update() error {
+	select {
+	case <-w.done:
+		return fmt.Errorf("pgadapter.Watcher: %w", ErrWatcherClosed)
+	default:
+	}
+	if _, err := w.notifier.Exec(context.Background(), `SELECT pg_notify($1, $2)`, w.channel, w.id); err != nil {
+		return fmt.Errorf("pgadapter.Watcher: notify %q: %w", w.channel, err)
+	}
+	return nil
+}
+
+// Close stops listening and waits for the listen loop to end, the update callback isn't called anymore
+func (w *Watcher) Close() {
+	w.cancel()
+	<-w.done
+
+	w.mu.Lock()
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+}
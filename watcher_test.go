@@ -0,0 +1,296 @@
+package pgxadapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *AdapterTestSuite) TestWatcherReconnect() {
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, os.Getenv("PG_CONN"))
+	s.Require().NoError(err)
+	defer pool.Close()
+
+	w, err := NewWatcher(ctx, pool, WithChannel("casbin_watcher_test"), WithReconnectBackoff(10*time.Millisecond, 100*time.Millisecond), WithNotifySelf())
+	s.Require().NoError(err)
+	defer w.Close()
+	payloads := make(chan string, 10)
+	s.Require().NoError(w.SetUpdateCallback(func(payload string) { payloads <- payload }))
+
+	s.Require().NoError(w.Update())
+	s.Require().Equal(w.InstanceID(), <-payloads)
+
+	_, err = pool.Exec(ctx, `SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query = 'LISTEN "casbin_watcher_test"'`)
+	s.Require().NoError(err)
+	select {
+	case payload := <-payloads:
+		s.Require().Equal("", payload)
+	case <-time.After(5 * time.Second):
+		s.FailNow("the update callback wasn't called after the watcher reconnected")
+	}
+
+	s.Require().NoError(w.Update())
+	s.Require().Equal(w.InstanceID(), <-payloads)
+}
+
+// fakeListenConn is a listenConn receiving the notifications sent to its channel
+type fakeListenConn struct {
+	notifications chan *pgconn.Notification
+	fail          chan error
+	mu            sync.Mutex
+	listened      []string
+	closed        bool
+}
+
+func newFakeListenConn() *fakeListenConn {
+	return &fakeListenConn{notifications: make(chan *pgconn.Notification), fail: make(chan error)}
+}
+
+func (c *fakeListenConn) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listened = append(c.listened, sql)
+	return pgconn.NewCommandTag("LISTEN"), nil
+}
+
+func (c *fakeListenConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case n := <-c.notifications:
+		return n, nil
+	case err := <-c.fail:
+		return nil, err
+	}
+}
+
+func (c *fakeListenConn) Close(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// fakeConnector returns the results in order, one per call
+func fakeConnector(results ...any) func(ctx context.Context) (listenConn, error) {
+	var mu sync.Mutex
+	return func(ctx context.Context) (listenConn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		r := results[0]
+		results = results[1:]
+		if err, ok := r.(error); ok {
+			return nil, err
+		}
+		return r.(*fakeListenConn), nil
+	}
+}
+
+func TestWatcherReconnect(t *testing.T) {
+	c1, c2 := newFakeListenConn(), newFakeListenConn()
+	events := make(chan WatcherEvent, 10)
+	w, err := newWatcher(context.Background(), nil, fakeConnector(c1, errors.New("connection refused"), c2), []WatcherOption{
+		WithChannel("rules"),
+		WithReconnectBackoff(time.Millisecond, 4*time.Millisecond),
+		WithWatcherEvents(func(e WatcherEvent) { events <- e }),
+	})
+	require.NoError(t, err)
+	payloads := make(chan string, 10)
+	require.NoError(t, w.SetUpdateCallback(func(payload string) { payloads <- payload }))
+	require.Equal(t, []string{`LISTEN "rules"`}, c1.listened)
+
+	c1.notifications <- &pgconn.Notification{Channel: "rules", Payload: "first"}
+	require.Equal(t, "first", <-payloads)
+
+	// the backend is killed
+	c1.fail <- io.ErrUnexpectedEOF
+	e := <-events
+	require.Equal(t, WatcherDisconnected, e.Kind)
+	require.ErrorIs(t, e.Err, io.ErrUnexpectedEOF)
+	e = <-events
+	require.Equal(t, WatcherReconnectFailed, e.Kind)
+	require.Equal(t, 1, e.Attempt)
+	require.EqualError(t, e.Err, "connection refused")
+	require.LessOrEqual(t, e.Delay, time.Millisecond)
+	require.Equal(t, WatcherEvent{Kind: WatcherReconnected, Attempt: 2}, <-events)
+
+	// the callback is called once to resync after the gap
+	require.Equal(t, "", <-payloads)
+	require.Equal(t, []string{`LISTEN "rules"`}, c2.listened)
+
+	c2.notifications <- &pgconn.Notification{Channel: "rules", Payload: "second"}
+	require.Equal(t, "second", <-payloads)
+
+	w.Close()
+	require.True(t, c1.closed)
+	require.True(t, c2.closed)
+	require.ErrorIs(t, w.Update(), ErrWatcherClosed)
+	require.Empty(t, payloads)
+}
+
+func TestWatcherCloseWhileReconnecting(t *testing.T) {
+	c1 := newFakeListenConn()
+	connect := fakeConnector(c1, errors.New("connection refused"), errors.New("connection refused"))
+	attempts := make(chan struct{}, 10)
+	w, err := newWatcher(context.Background(), nil, connect, []WatcherOption{
+		WithReconnectBackoff(time.Hour, time.Hour),
+		WithWatcherEvents(func(e WatcherEvent) {
+			if e.Kind == WatcherReconnectFailed {
+				attempts <- struct{}{}
+			}
+		}),
+	})
+	require.NoError(t, err)
+
+	c1.fail <- io.EOF
+	<-attempts
+	// Close doesn't wait for the backoff delay
+	w.Close()
+}
+
+func TestNewWatcherInvalid(t *testing.T) {
+	_, err := newWatcher(context.Background(), nil, fakeConnector(errors.New("connection refused")), nil)
+	require.EqualError(t, err, "pgadapter.NewWatcher: connection refused")
+
+	_, err = newWatcher(context.Background(), nil, nil, []WatcherOption{WithChannel("")})
+	require.EqualError(t, err, "pgadapter.NewWatcher: channel is empty")
+
+	_, err = newWatcher(context.Background(), nil, nil, []WatcherOption{WithReconnectBackoff(time.Second, time.Millisecond)})
+	require.EqualError(t, err, "pgadapter.NewWatcher: invalid reconnect backoff 1s to 1ms")
+
+	_, err = newWatcher(context.Background(), nil, nil, []WatcherOption{WithDebounce(-time.Second)})
+	require.EqualError(t, err, "pgadapter.NewWatcher: debounce window must not be negative, got -1s")
+}
+
+func TestMockWatcherUpdate(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	defer mock.Close()
+
+	w, err := newWatcher(context.Background(), mock, fakeConnector(newFakeListenConn()), nil)
+	require.NoError(t, err)
+	defer w.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_notify($1, $2)`)).WithArgs(DefaultWatcherChannel, w.InstanceID()).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	require.NoError(t, w.Update())
+
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_notify($1, $2)`)).WithArgs(DefaultWatcherChannel, w.InstanceID()).
+		WillReturnError(errors.New("connection reset"))
+	require.EqualError(t, w.Update(), `pgadapter.Watcher: notify "casbin_rules": connection reset`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	c := newFakeListenConn()
+	const window = 200 * time.Millisecond
+	w, err := newWatcher(context.Background(), nil, fakeConnector(c), []WatcherOption{WithDebounce(window)})
+	require.NoError(t, err)
+	defer w.Close()
+	var mu sync.Mutex
+	var calls []string
+	require.NoError(t, w.SetUpdateCallback(func(payload string) {
+		mu.Lock()
+		calls = append(calls, payload)
+		mu.Unlock()
+	}))
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(calls)
+	}
+
+	start := time.Now()
+	for i := 0; i < 500; i++ {
+		c.notifications <- &pgconn.Notification{Payload: fmt.Sprint(i)}
+	}
+	require.Less(t, time.Since(start), window, "the burst must fit in the window")
+	require.Eventually(t, func() bool { return count() == 1 }, 2*window, 10*time.Millisecond)
+	time.Sleep(window + window/2)
+	require.Equal(t, []string{"499"}, calls)
+
+	// a later notification gets its own trailing call
+	c.notifications <- &pgconn.Notification{Payload: "last"}
+	require.Eventually(t, func() bool { return count() == 2 }, 2*window, 10*time.Millisecond)
+	require.Equal(t, "last", calls[1])
+}
+
+func TestWatcherDebounceClose(t *testing.T) {
+	c := newFakeListenConn()
+	w, err := newWatcher(context.Background(), nil, fakeConnector(c), []WatcherOption{WithDebounce(50 * time.Millisecond)})
+	require.NoError(t, err)
+	called := make(chan string, 1)
+	require.NoError(t, w.SetUpdateCallback(func(payload string) { called <- payload }))
+
+	c.notifications <- &pgconn.Notification{Payload: "dropped"}
+	w.Close()
+	time.Sleep(100 * time.Millisecond)
+	require.Empty(t, called)
+}
+
+// fakeBus delivers the pg_notify calls to the listening fake connections
+type fakeBus struct {
+	conns []*fakeListenConn
+}
+
+func (b *fakeBus) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	for _, c := range b.conns {
+		c.notifications <- &pgconn.Notification{Channel: arguments[0].(string), Payload: arguments[1].(string)}
+	}
+	return pgconn.NewCommandTag("SELECT 1"), nil
+}
+
+func TestWatcherSuppressSelf(t *testing.T) {
+	ca, cb, cc := newFakeListenConn(), newFakeListenConn(), newFakeListenConn()
+	bus := &fakeBus{conns: []*fakeListenConn{ca, cb, cc}}
+	payloads := map[string]chan string{}
+	watchers := map[string]*Watcher{}
+	for name, c := range map[string]*fakeListenConn{"a": ca, "b": cb, "c": cc} {
+		opts := []WatcherOption{WithInstanceID(name)}
+		if name == "c" {
+			opts = append(opts, WithNotifySelf())
+		}
+		w, err := newWatcher(context.Background(), bus, fakeConnector(c), opts)
+		require.NoError(t, err)
+		defer w.Close()
+		payloads[name] = make(chan string, 10)
+		require.NoError(t, w.SetUpdateCallback(func(payload string) { payloads[name] <- payload }))
+		watchers[name] = w
+	}
+
+	require.NoError(t, watchers["a"].Update())
+	require.Equal(t, "a", <-payloads["b"])
+	require.Equal(t, "a", <-payloads["c"])
+
+	require.NoError(t, watchers["c"].Update())
+	require.Equal(t, "c", <-payloads["a"])
+	require.Equal(t, "c", <-payloads["b"])
+	require.Equal(t, "c", <-payloads["c"])
+
+	// a's own notification was received before c's one
+	require.Empty(t, payloads["a"])
+}
+
+func TestWatcherInstanceID(t *testing.T) {
+	w1, err := newWatcher(context.Background(), nil, fakeConnector(newFakeListenConn()), nil)
+	require.NoError(t, err)
+	defer w1.Close()
+	w2, err := newWatcher(context.Background(), nil, fakeConnector(newFakeListenConn()), nil)
+	require.NoError(t, err)
+	defer w2.Close()
+
+	require.Len(t, w1.InstanceID(), 16)
+	require.NotEqual(t, w1.InstanceID(), w2.InstanceID())
+}